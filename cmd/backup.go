@@ -0,0 +1,17 @@
+package cmd
+
+import (
+	"blazar/cmd/backup"
+
+	"github.com/spf13/cobra"
+)
+
+var backupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "Chain data backup related commands",
+}
+
+func init() {
+	backupCmd.AddCommand(backup.GetBackupRestoreCmd())
+	rootCmd.AddCommand(backupCmd)
+}
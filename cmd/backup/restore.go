@@ -0,0 +1,85 @@
+package backup
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"blazar/internal/pkg/backup"
+	"blazar/internal/pkg/config"
+	"blazar/internal/pkg/daemon"
+	"blazar/internal/pkg/errors"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	height  int64
+	archive string
+)
+
+func GetBackupRestoreCmd() *cobra.Command {
+	restoreCmd := &cobra.Command{
+		Use:   "restore",
+		Short: "Restore the chain data backup taken by the BACKUP_DATA step. The node must be stopped",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			cfgFile := cmd.Flag("config").Value.String()
+			if cfgFile == "" {
+				return errors.New("config is required")
+			}
+
+			cfg, err := config.ReadConfig(cfgFile)
+			if err != nil {
+				return err
+			}
+
+			backupCfg := cfg.Checks.PreUpgrade.BackupData
+			if archive == "" {
+				if backupCfg == nil {
+					return errors.New("checks.pre-upgrade.backup-data is not configured, specify the archive with --archive")
+				}
+
+				archive, err = findArchive(backupCfg.BackupDir, height)
+				if err != nil {
+					return err
+				}
+			}
+
+			// the node would keep writing into the chain data being replaced
+			isRunning, err := daemon.IsNodeRunning(cmd.Context(), cfg)
+			if err != nil {
+				return errors.Wrapf(err, "failed to check if the node is stopped")
+			}
+			if isRunning {
+				return fmt.Errorf("service %s is running, stop the node before restoring the backup", cfg.ComposeService)
+			}
+
+			fmt.Printf("Restoring %s into %s\n", archive, cfg.ChainHome)
+			if err := backup.Restore(archive, cfg.ChainHome); err != nil {
+				return err
+			}
+
+			fmt.Println("Backup restored successfully")
+			return nil
+		},
+	}
+
+	restoreCmd.Flags().Int64Var(&height, "height", 0, "Upgrade height of the backup to restore (default: the most recent backup)")
+	restoreCmd.Flags().StringVar(&archive, "archive", "", "Path of the backup archive to restore, overrides --height")
+
+	return restoreCmd
+}
+
+func findArchive(backupDir string, height int64) (string, error) {
+	if height != 0 {
+		return filepath.Join(backupDir, backup.FileName(height)), nil
+	}
+
+	archives, err := backup.List(backupDir)
+	if err != nil {
+		return "", err
+	}
+	if len(archives) == 0 {
+		return "", fmt.Errorf("no backups found in %s", backupDir)
+	}
+	return archives[0], nil
+}
@@ -21,6 +21,8 @@ var runCmd = &cobra.Command{
 		if err != nil {
 			return errors.Wrapf(err, "failed to read the toml config")
 		}
+		cfg.DryRun = dryRun
+		cfg.SyncFromGenesis = syncFromGenesis
 
 		if err := cfg.ValidateAll(); err != nil {
 			return errors.Wrapf(err, "failed to validate config")
@@ -31,6 +33,13 @@ var runCmd = &cobra.Command{
 
 		// setup initial logger
 		lg := logger.FromContext(cmd.Context())
+		if cfg.DryRun {
+			// mark every log line, so the dry-run logs are not mistaken for a real upgrade
+			dryRunLogger := lg.With().Bool("dry_run", true).Logger()
+			lg = &dryRunLogger
+			cmd.SetContext(logger.WithContext(cmd.Context(), lg))
+			lg.Warn().Msg("Running in dry-run mode, blazar won't modify the node, the compose/version files or the upgrades state")
+		}
 
 		// setup metrics
 		hostname := util.GetHostname()
@@ -66,6 +75,13 @@ var runCmd = &cobra.Command{
 	},
 }
 
+var (
+	dryRun          bool
+	syncFromGenesis bool
+)
+
 func init() {
+	runCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Watch upgrades and report what would be done, without modifying the node")
+	runCmd.Flags().BoolVar(&syncFromGenesis, "sync-from-genesis", false, "Replay the past upgrades while the node syncs from genesis, instead of expiring them")
 	rootCmd.AddCommand(runCmd)
 }
@@ -3,7 +3,9 @@ package upgrades
 import (
 	"fmt"
 	"net"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"blazar/cmd/util"
@@ -86,6 +88,9 @@ func GetUpgradeListCmd() *cobra.Command {
 			tw.AppendHeader(table.Row{
 				"Height",
 				"Tag",
+				"Services",
+				"Image_digest",
+				"Module_versions",
 				"Network",
 				"Name",
 				"Type",
@@ -107,6 +112,9 @@ func GetUpgradeListCmd() *cobra.Command {
 				tw.AppendRow(table.Row{
 					upgrade.Height,
 					upgrade.Tag,
+					formatServices(upgrade.Services),
+					upgrade.ImageDigest,
+					formatModuleVersionsDiff(upgrade.ModuleVersionsDiff),
 					upgrade.Network,
 					upgrade.Name,
 					upgrade.Type,
@@ -135,6 +143,29 @@ func GetUpgradeListCmd() *cobra.Command {
 	return listCmd
 }
 
+// formatServices renders the additional services in a stable order
+func formatServices(services map[string]string) string {
+	names := make([]string, 0, len(services))
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	formatted := make([]string, 0, len(names))
+	for _, name := range names {
+		formatted = append(formatted, fmt.Sprintf("%s=%s", name, services[name]))
+	}
+	return strings.Join(formatted, "\n")
+}
+
+func formatModuleVersionsDiff(changes []*proto.ModuleVersionChange) string {
+	formatted := make([]string, 0, len(changes))
+	for _, change := range changes {
+		formatted = append(formatted, fmt.Sprintf("%s=%d->%d", change.Name, change.FromVersion, change.ToVersion))
+	}
+	return strings.Join(formatted, "\n")
+}
+
 func parseConfig(cfg *config.Config) error {
 	if cfg != nil {
 		if err := cfg.ValidateBlazarHostGrpcPort(); err != nil {
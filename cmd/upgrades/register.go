@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net"
+	"regexp"
 	"strconv"
 	"strings"
 
@@ -27,6 +28,8 @@ var (
 	priority    int32
 	source      string
 	proposalID  int64
+	services    map[string]string
+	expectedVer string
 
 	// Upgrade request fields
 	overwrite bool
@@ -114,10 +117,18 @@ func GetUpgradeRegisterCmd() *cobra.Command {
 				Name:   name,
 				Type:   urproto.UpgradeType(urproto.UpgradeType_value[upgradeType]),
 				// Status is managed by the blazar registry
-				Status:     urproto.UpgradeStatus_UNKNOWN,
-				Priority:   priority,
-				Source:     urproto.ProviderType(urproto.ProviderType_value[source]),
-				ProposalId: nil,
+				Status:          urproto.UpgradeStatus_UNKNOWN,
+				Priority:        priority,
+				Source:          urproto.ProviderType(urproto.ProviderType_value[source]),
+				ProposalId:      nil,
+				Services:        services,
+				ExpectedVersion: expectedVer,
+			}
+
+			if expectedVer != "" {
+				if _, err := regexp.Compile(expectedVer); err != nil {
+					return fmt.Errorf("invalid expected version pattern: %w", err)
+				}
 			}
 
 			if proposalID != -1 {
@@ -143,7 +154,7 @@ func GetUpgradeRegisterCmd() *cobra.Command {
 	}
 
 	registerUpgradeCmd.Flags().StringVar(&height, "height", "", "Height to register upgrade for (1234 or +100 for 100 blocks from now)")
-	registerUpgradeCmd.Flags().StringVar(&tag, "tag", "", "Tag to upgrade to")
+	registerUpgradeCmd.Flags().StringVar(&tag, "tag", "", "Tag to upgrade to, a digest (sha256:...) or both (<tag>@sha256:...) to pin the image")
 	registerUpgradeCmd.Flags().StringVar(&name, "name", "", "A short text describing the upgrade")
 	registerUpgradeCmd.Flags().StringVar(
 		&upgradeType, "type", "",
@@ -155,6 +166,14 @@ func GetUpgradeRegisterCmd() *cobra.Command {
 		fmt.Sprintf("Upgrade source; valid values: %s", strings.Join(allUpgradeSources, ", ")),
 	)
 	registerUpgradeCmd.Flags().Int64Var(&proposalID, "proposal-id", -1, "Proposal ID")
+	registerUpgradeCmd.Flags().StringToStringVar(
+		&services, "service", nil,
+		"Additional compose service upgraded together with the main service (name=tag or name=image); can be repeated",
+	)
+	registerUpgradeCmd.Flags().StringVar(
+		&expectedVer, "expected-version", "",
+		"Regex matched against the version or commit reported by the upgrade binary (VERIFY_BINARY_VERSION pre-check), defaults to the tag",
+	)
 	registerUpgradeCmd.Flags().BoolVar(&overwrite, "overwrite", false, "Overwrite existing upgrade")
 
 	for _, flagName := range []string{"height", "tag", "type", "source"} {
@@ -77,6 +77,7 @@ func GetVersionsListCmd() *cobra.Command {
 				"Network",
 				"Priority",
 				"Source",
+				"Provenance",
 			})
 
 			for _, version := range listUpgradesResponse.Versions {
@@ -86,6 +87,7 @@ func GetVersionsListCmd() *cobra.Command {
 					version.Network,
 					version.GetPriority(),
 					version.Source,
+					version.Provenance,
 				})
 			}
 
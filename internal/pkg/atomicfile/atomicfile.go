@@ -0,0 +1,52 @@
+package atomicfile
+
+import (
+	"os"
+	"path/filepath"
+
+	"blazar/internal/pkg/errors"
+)
+
+// WriteFile writes the data to a temporary file next to the target and renames it over the target, so a crash in the
+// middle of the write leaves either the old or the new content behind, never a truncated file
+func WriteFile(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+
+	tmpFile, err := os.CreateTemp(dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return errors.Wrapf(err, "failed to create temporary file for %s", path)
+	}
+	// no-op if the file was renamed
+	defer os.Remove(tmpFile.Name())
+
+	if _, err := tmpFile.Write(data); err != nil {
+		tmpFile.Close()
+		return errors.Wrapf(err, "failed to write temporary file %s", tmpFile.Name())
+	}
+
+	if err := tmpFile.Chmod(perm); err != nil {
+		tmpFile.Close()
+		return errors.Wrapf(err, "failed to set permissions of temporary file %s", tmpFile.Name())
+	}
+
+	if err := tmpFile.Sync(); err != nil {
+		tmpFile.Close()
+		return errors.Wrapf(err, "failed to sync temporary file %s", tmpFile.Name())
+	}
+
+	if err := tmpFile.Close(); err != nil {
+		return errors.Wrapf(err, "failed to close temporary file %s", tmpFile.Name())
+	}
+
+	if err := os.Rename(tmpFile.Name(), path); err != nil {
+		return errors.Wrapf(err, "failed to rename temporary file to %s", path)
+	}
+
+	// persist the rename itself, best effort as not every filesystem supports syncing directories
+	if d, err := os.Open(dir); err == nil {
+		_ = d.Sync()
+		d.Close()
+	}
+
+	return nil
+}
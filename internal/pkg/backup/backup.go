@@ -0,0 +1,306 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"blazar/internal/pkg/errors"
+	"blazar/internal/pkg/log"
+)
+
+const (
+	filePrefix = "blazar-backup-"
+	fileSuffix = ".tar.gz"
+)
+
+// FileName returns the archive name for the upgrade height. The name is deterministic, so a restarted
+// blazar finds the archive created before the restart instead of taking a second backup
+func FileName(upgradeHeight int64) string {
+	return fmt.Sprintf("%s%d%s", filePrefix, upgradeHeight, fileSuffix)
+}
+
+// Create archives the paths (relative to chainHome) into <backupDir>/blazar-backup-<height>.tar.gz
+//
+// The archive is written to a temporary file first and renamed once complete, therefore an existing
+// archive is always a complete one and is reused as is.
+func Create(ctx context.Context, chainHome string, paths []string, backupDir string, upgradeHeight int64) (string, error) {
+	logger := log.FromContext(ctx)
+
+	archivePath := filepath.Join(backupDir, FileName(upgradeHeight))
+	if _, err := os.Stat(archivePath); err == nil {
+		logger.Infof("Backup %s already exists, skipping", archivePath)
+		return archivePath, nil
+	}
+
+	if err := os.MkdirAll(backupDir, 0750); err != nil {
+		return "", errors.Wrapf(err, "failed to create backup directory %s", backupDir)
+	}
+
+	tmpFile, err := os.CreateTemp(backupDir, FileName(upgradeHeight)+".*.tmp")
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to create temporary backup file")
+	}
+	// no-op if the file was renamed
+	defer os.Remove(tmpFile.Name())
+
+	if err := writeArchive(tmpFile, chainHome, paths); err != nil {
+		tmpFile.Close()
+		return "", err
+	}
+
+	if err := tmpFile.Sync(); err != nil {
+		tmpFile.Close()
+		return "", errors.Wrapf(err, "failed to sync backup file %s", tmpFile.Name())
+	}
+
+	if err := tmpFile.Close(); err != nil {
+		return "", errors.Wrapf(err, "failed to close backup file %s", tmpFile.Name())
+	}
+
+	if err := os.Rename(tmpFile.Name(), archivePath); err != nil {
+		return "", errors.Wrapf(err, "failed to rename backup file to %s", archivePath)
+	}
+
+	return archivePath, nil
+}
+
+func writeArchive(w io.Writer, chainHome string, paths []string) error {
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+
+	for _, path := range paths {
+		root := filepath.Join(chainHome, path)
+		err := filepath.WalkDir(root, func(file string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+
+			isSymlink := info.Mode()&fs.ModeSymlink != 0
+			// only regular files, directories and symlinks are expected in the chain home
+			if !info.Mode().IsRegular() && !info.IsDir() && !isSymlink {
+				return nil
+			}
+
+			// the restore would replace the link, while the data it points to is neither backed up nor restored
+			if isSymlink && file == root {
+				return fmt.Errorf("%s is a symlink, back up the path it points to instead", file)
+			}
+
+			var link string
+			if isSymlink {
+				if link, err = os.Readlink(file); err != nil {
+					return err
+				}
+			}
+
+			name, err := filepath.Rel(chainHome, file)
+			if err != nil {
+				return err
+			}
+
+			header, err := tar.FileInfoHeader(info, link)
+			if err != nil {
+				return err
+			}
+			header.Name = filepath.ToSlash(name)
+
+			if err := tw.WriteHeader(header); err != nil {
+				return err
+			}
+
+			if !info.Mode().IsRegular() {
+				return nil
+			}
+
+			f, err := os.Open(file)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			_, err = io.Copy(tw, f)
+			return err
+		})
+		if err != nil {
+			return errors.Wrapf(err, "failed to archive %s", root)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return errors.Wrapf(err, "failed to finalize tar archive")
+	}
+	if err := gw.Close(); err != nil {
+		return errors.Wrapf(err, "failed to finalize gzip stream")
+	}
+	return nil
+}
+
+// List returns the backup archives in the backup directory, the newest (highest upgrade height) first
+func List(backupDir string) ([]string, error) {
+	entries, err := os.ReadDir(backupDir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read backup directory %s", backupDir)
+	}
+
+	type archive struct {
+		path   string
+		height int64
+	}
+	archives := []archive{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, filePrefix) || !strings.HasSuffix(name, fileSuffix) {
+			continue
+		}
+
+		var height int64
+		if _, err := fmt.Sscanf(strings.TrimSuffix(strings.TrimPrefix(name, filePrefix), fileSuffix), "%d", &height); err != nil {
+			continue
+		}
+		archives = append(archives, archive{path: filepath.Join(backupDir, name), height: height})
+	}
+
+	slices.SortFunc(archives, func(a, b archive) int {
+		switch {
+		case a.height > b.height:
+			return -1
+		case a.height < b.height:
+			return 1
+		}
+		return 0
+	})
+
+	paths := make([]string, 0, len(archives))
+	for _, a := range archives {
+		paths = append(paths, a.path)
+	}
+	return paths, nil
+}
+
+// Prune removes all but the newest `retention` archives, and returns the removed ones
+func Prune(backupDir string, retention int) ([]string, error) {
+	archives, err := List(backupDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(archives) <= retention {
+		return nil, nil
+	}
+
+	removed := []string{}
+	for _, archive := range archives[retention:] {
+		if err := os.Remove(archive); err != nil {
+			return removed, errors.Wrapf(err, "failed to remove backup %s", archive)
+		}
+		removed = append(removed, archive)
+	}
+	return removed, nil
+}
+
+// Restore extracts the archive into chainHome. Paths contained in the archive are removed before the
+// extraction, so the restored state is exactly the one at the time of the backup
+func Restore(archivePath, chainHome string) error {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open backup %s", archivePath)
+	}
+	defer f.Close()
+
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read backup %s", archivePath)
+	}
+	defer gr.Close()
+
+	tr := tar.NewReader(gr)
+	dirs := map[string]bool{}
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return errors.Wrapf(err, "failed to read backup %s", archivePath)
+		}
+
+		target, err := securePath(chainHome, header.Name)
+		if err != nil {
+			return err
+		}
+
+		// directories are archived before their content, so an entry without its parent directory in the
+		// archive is one of the backed up paths. It is removed before the extraction, to drop files created after the backup
+		name := filepath.Clean(header.Name)
+		if !dirs[filepath.Dir(name)] {
+			if err := os.RemoveAll(target); err != nil {
+				return errors.Wrapf(err, "failed to remove %s", target)
+			}
+		}
+		if header.Typeflag == tar.TypeDir {
+			dirs[name] = true
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, header.FileInfo().Mode().Perm()); err != nil {
+				return errors.Wrapf(err, "failed to create directory %s", target)
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0750); err != nil {
+				return errors.Wrapf(err, "failed to create directory %s", filepath.Dir(target))
+			}
+			if err := extractFile(tr, target, header.FileInfo().Mode().Perm()); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if err := os.MkdirAll(filepath.Dir(target), 0750); err != nil {
+				return errors.Wrapf(err, "failed to create directory %s", filepath.Dir(target))
+			}
+			// the link is restored as is, the archive is created by blazar from the chain home
+			if err := os.Symlink(header.Linkname, target); err != nil {
+				return errors.Wrapf(err, "failed to create symlink %s", target)
+			}
+		default:
+			return fmt.Errorf("unexpected entry type %c for %s in backup %s", header.Typeflag, header.Name, archivePath)
+		}
+	}
+
+	return nil
+}
+
+func extractFile(r io.Reader, target string, perm fs.FileMode) error {
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create file %s", target)
+	}
+	defer f.Close()
+
+	// the archive is created by blazar from the chain home, so its size is trusted
+	if _, err := io.Copy(f, r); err != nil {
+		return errors.Wrapf(err, "failed to write file %s", target)
+	}
+	return nil
+}
+
+// securePath ensures the archive entry doesn't escape the chain home
+func securePath(chainHome, name string) (string, error) {
+	target := filepath.Join(chainHome, name)
+	if target != filepath.Clean(chainHome) && !strings.HasPrefix(target, filepath.Clean(chainHome)+string(filepath.Separator)) {
+		return "", fmt.Errorf("backup entry %s points outside of %s", name, chainHome)
+	}
+	return target, nil
+}
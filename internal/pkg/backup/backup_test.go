@@ -0,0 +1,129 @@
+package backup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0750))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+}
+
+func TestCreateAndRestore(t *testing.T) {
+	ctx := context.Background()
+	chainHome, backupDir := t.TempDir(), t.TempDir()
+
+	writeFile(t, filepath.Join(chainHome, "data", "application.db", "000001.log"), "app state")
+	writeFile(t, filepath.Join(chainHome, "data", "priv_validator_state.json"), `{"height": "100"}`)
+	writeFile(t, filepath.Join(chainHome, "data", "blockstore.db", "000001.log"), "blocks")
+
+	paths := []string{"data/application.db", "data/priv_validator_state.json"}
+	archive, err := Create(ctx, chainHome, paths, backupDir, 100)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(backupDir, "blazar-backup-100.tar.gz"), archive)
+
+	// the existing archive is reused
+	info, err := os.Stat(archive)
+	require.NoError(t, err)
+
+	writeFile(t, filepath.Join(chainHome, "data", "application.db", "000001.log"), "corrupted app state")
+	writeFile(t, filepath.Join(chainHome, "data", "application.db", "000002.log"), "new file")
+	writeFile(t, filepath.Join(chainHome, "data", "priv_validator_state.json"), `{"height": "101"}`)
+	writeFile(t, filepath.Join(chainHome, "data", "blockstore.db", "000001.log"), "more blocks")
+
+	archive, err = Create(ctx, chainHome, paths, backupDir, 100)
+	require.NoError(t, err)
+	newInfo, err := os.Stat(archive)
+	require.NoError(t, err)
+	assert.Equal(t, info.ModTime(), newInfo.ModTime())
+
+	// restore brings back the backed up paths only
+	require.NoError(t, Restore(archive, chainHome))
+
+	content, err := os.ReadFile(filepath.Join(chainHome, "data", "application.db", "000001.log"))
+	require.NoError(t, err)
+	assert.Equal(t, "app state", string(content))
+
+	assert.NoFileExists(t, filepath.Join(chainHome, "data", "application.db", "000002.log"))
+
+	content, err = os.ReadFile(filepath.Join(chainHome, "data", "priv_validator_state.json"))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"height": "100"}`, string(content))
+
+	content, err = os.ReadFile(filepath.Join(chainHome, "data", "blockstore.db", "000001.log"))
+	require.NoError(t, err)
+	assert.Equal(t, "more blocks", string(content))
+}
+
+func TestCreateAndRestoreSymlinks(t *testing.T) {
+	ctx := context.Background()
+	chainHome, backupDir := t.TempDir(), t.TempDir()
+
+	writeFile(t, filepath.Join(chainHome, "data", "application.db", "000001.log"), "app state")
+	require.NoError(t, os.Symlink("000001.log", filepath.Join(chainHome, "data", "application.db", "CURRENT")))
+	require.NoError(t, os.Symlink(backupDir, filepath.Join(chainHome, "data", "application.db", "external")))
+
+	archive, err := Create(ctx, chainHome, []string{"data/application.db"}, backupDir, 100)
+	require.NoError(t, err)
+
+	require.NoError(t, os.Remove(filepath.Join(chainHome, "data", "application.db", "CURRENT")))
+	require.NoError(t, Restore(archive, chainHome))
+
+	link, err := os.Readlink(filepath.Join(chainHome, "data", "application.db", "CURRENT"))
+	require.NoError(t, err)
+	assert.Equal(t, "000001.log", link)
+
+	link, err = os.Readlink(filepath.Join(chainHome, "data", "application.db", "external"))
+	require.NoError(t, err)
+	assert.Equal(t, backupDir, link)
+
+	// the data behind a symlinked path wouldn't be backed up
+	require.NoError(t, os.Symlink(filepath.Join(chainHome, "data", "application.db"), filepath.Join(chainHome, "app")))
+	_, err = Create(ctx, chainHome, []string{"app"}, backupDir, 200)
+	require.ErrorContains(t, err, "is a symlink, back up the path it points to instead")
+}
+
+func TestCreateMissingPath(t *testing.T) {
+	chainHome, backupDir := t.TempDir(), t.TempDir()
+
+	_, err := Create(context.Background(), chainHome, []string{"data/application.db"}, backupDir, 100)
+	require.Error(t, err)
+
+	// no partial archive is left behind
+	entries, err := os.ReadDir(backupDir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestPrune(t *testing.T) {
+	ctx := context.Background()
+	chainHome, backupDir := t.TempDir(), t.TempDir()
+	writeFile(t, filepath.Join(chainHome, "upgrade-info.json"), "{}")
+	writeFile(t, filepath.Join(backupDir, "unrelated.tar.gz"), "")
+
+	for _, height := range []int64{100, 2000, 300} {
+		_, err := Create(ctx, chainHome, []string{"upgrade-info.json"}, backupDir, height)
+		require.NoError(t, err)
+	}
+
+	archives, err := List(backupDir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(backupDir, FileName(2000)),
+		filepath.Join(backupDir, FileName(300)),
+		filepath.Join(backupDir, FileName(100)),
+	}, archives)
+
+	removed, err := Prune(backupDir, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(backupDir, FileName(100))}, removed)
+
+	assert.FileExists(t, filepath.Join(backupDir, "unrelated.tar.gz"))
+}
@@ -134,6 +134,18 @@ func (uiw *UpgradesInfoWatcher) checkIfUpdateIsNeeded(logger *log.MultiLogger) (
 	return nil, nil
 }
 
+// ReadUpgradeInfo returns the plan written to the upgrade-info.json file by the node, or nil if the file doesn't exist
+func ReadUpgradeInfo(upgradeInfoFilePath string) (*upgradetypes.Plan, error) {
+	info, err := parseUpgradeInfoFile(upgradeInfoFilePath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse upgrade-info.json file")
+	}
+	return &info, nil
+}
+
 func parseUpgradeInfoFile(filename string) (upgradetypes.Plan, error) {
 	var ui upgradetypes.Plan
 
@@ -82,6 +82,22 @@ func TestParseUpgradeInfoFile(t *testing.T) {
 	}
 }
 
+func TestReadUpgradeInfo(t *testing.T) {
+	dir := filepath.Join(testutils.TestdataDirPath, "upgrade-files")
+
+	plan, err := ReadUpgradeInfo(filepath.Join(dir, "f1-good.json"))
+	require.NoError(t, err)
+	assert.Equal(t, &upgradetypes.Plan{Name: "upgrade1", Info: "some info", Height: 123}, plan)
+
+	// the node didn't hit any upgrade yet
+	plan, err = ReadUpgradeInfo(filepath.Join(dir, "unknown.json"))
+	require.NoError(t, err)
+	assert.Nil(t, plan)
+
+	_, err = ReadUpgradeInfo(filepath.Join(dir, "f3-empty.json"))
+	require.Error(t, err)
+}
+
 func TestMonitorUpgrade(t *testing.T) {
 	t.Run("NoExistingFile", func(t *testing.T) {
 		chainHome := t.TempDir()
@@ -5,6 +5,7 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"regexp"
 	"slices"
 	"strings"
 	"time"
@@ -14,6 +15,7 @@ import (
 	urproto "blazar/internal/pkg/proto/upgrades_registry"
 
 	"github.com/BurntSushi/toml"
+	"github.com/distribution/reference"
 	"golang.org/x/sys/unix"
 )
 
@@ -26,6 +28,44 @@ const (
 
 var ValidUpgradeModes = []UpgradeMode{UpgradeInEnvFile, UpgradeInComposeFile}
 
+type Executor string
+
+const (
+	ExecutorDockerCompose Executor = "docker-compose"
+	ExecutorSystemd       Executor = "systemd"
+)
+
+var ValidExecutors = []Executor{ExecutorDockerCompose, ExecutorSystemd}
+
+type DigestMismatchAction string
+
+const (
+	DigestMismatchFail DigestMismatchAction = "fail"
+	DigestMismatchWarn DigestMismatchAction = "warn"
+)
+
+var ValidDigestMismatchActions = []DigestMismatchAction{DigestMismatchFail, DigestMismatchWarn}
+
+type LogMatchAction string
+
+const (
+	LogMatchFail   LogMatchAction = "fail"
+	LogMatchNotify LogMatchAction = "notify"
+)
+
+var ValidLogMatchActions = []LogMatchAction{LogMatchFail, LogMatchNotify}
+
+type CheckSeverity string
+
+const (
+	SeverityOff   CheckSeverity = "off"
+	SeverityWarn  CheckSeverity = "warn"
+	SeverityBlock CheckSeverity = "block"
+)
+
+// ordered from the least to the most strict
+var ValidCheckSeverities = []CheckSeverity{SeverityOff, SeverityWarn, SeverityBlock}
+
 type SlackWebhookNotifier struct {
 	WebhookURL string `toml:"webhook-url"`
 }
@@ -64,6 +104,15 @@ type ComposeCli struct {
 	DownTimeout time.Duration `toml:"down-timeout"`
 	UpDeadline  time.Duration `toml:"up-deadline"`
 	EnvPrefix   string        `toml:"env-prefix"`
+	ProjectWide bool          `toml:"project-wide"`
+}
+
+type Systemd struct {
+	Unit        string        `toml:"unit"`
+	BinariesDir string        `toml:"binaries-dir"`
+	BinaryName  string        `toml:"binary-name"`
+	CurrentLink string        `toml:"current-link"`
+	Timeout     time.Duration `toml:"timeout"`
 }
 
 type SslMode string
@@ -79,6 +128,35 @@ const (
 
 type ChainProvider struct {
 	DefaultPriority int32 `toml:"default-priority"`
+	// also poll the x/upgrade current plan, for upgrades scheduled outside of x/gov proposals
+	CurrentPlan bool `toml:"current-plan"`
+	// how version tags are resolved from the upgrade plans, when the chain provider is used as a version resolver
+	PlanInfo *PlanInfo `toml:"plan-info"`
+}
+
+type PlanInfoField string
+
+const (
+	PlanInfoName PlanInfoField = "name"
+	PlanInfoInfo PlanInfoField = "info"
+)
+
+var ValidPlanInfoFields = []PlanInfoField{PlanInfoName, PlanInfoInfo}
+
+type PlanInfoRule struct {
+	// the plan field the regex is matched against
+	Field PlanInfoField `toml:"field"`
+	// Go's regexp syntax, the named groups can be used in the template
+	Regex string `toml:"regex"`
+	// the resulting version tag, "{{name}}", "{{info}}" and "{{<group>}}" are replaced with the plan fields and regex groups
+	Template string `toml:"template"`
+}
+
+type PlanInfo struct {
+	// key of the Cosmovisor "binaries" map the version is extracted from
+	Platform string `toml:"platform"`
+	// rules tried before the Cosmovisor JSON and URLs, the first matching rule wins
+	Rules []PlanInfoRule `toml:"rules"`
 }
 
 type DatabaseProvider struct {
@@ -96,12 +174,54 @@ type DatabaseProvider struct {
 type LocalProvider struct {
 	DefaultPriority int32  `toml:"default-priority"`
 	ConfigPath      string `toml:"config-path"`
+	// number of previous state machine states kept next to the config file, 0 disables the backups
+	StateBackups int `toml:"state-backups"`
+}
+
+// RegistryTagRule maps upgrade names to the tags of the registry image
+type RegistryTagRule struct {
+	// regex matched against the upgrade name
+	UpgradeName string `toml:"upgrade-name"`
+	// regex matched against the tags, the placeholders {{name}} and {{<named group>}} are replaced
+	// with the (escaped) upgrade name and the named groups of the upgrade-name regex
+	Tag string `toml:"tag"`
+}
+
+type RegistryProvider struct {
+	DefaultPriority int32 `toml:"default-priority"`
+	// image whose tags are listed, e.g. ghcr.io/cosmos/gaia
+	Image string `toml:"image"`
+	// use plain http (e.g. a local registry)
+	Insecure bool              `toml:"insecure"`
+	Timeout  time.Duration     `toml:"timeout"`
+	Rules    []RegistryTagRule `toml:"rules"`
+}
+
+type ChainRegistryRule struct {
+	// Go's regexp syntax matched against the recommended_version, the named groups can be used in the template
+	Regex string `toml:"regex"`
+	// the resulting version tag, "{{recommended_version}}", "{{tag}}", "{{name}}" and "{{<group>}}" are replaced
+	// with the chain.json version fields and regex groups
+	Template string `toml:"template"`
+}
+
+type ChainRegistryProvider struct {
+	DefaultPriority int32 `toml:"default-priority"`
+	// directory of the cosmos/chain-registry checkout
+	Path string `toml:"path"`
+	// directory name of the chain in the chain-registry, if empty the chain.json is looked up by chain_id (upgrade-registry.network)
+	ChainName string `toml:"chain-name"`
+	// if 0, the chain.json file is watched with inotify
+	PollInterval time.Duration       `toml:"poll-interval"`
+	Rules        []ChainRegistryRule `toml:"rules"`
 }
 
 type Provider struct {
-	Chain    *ChainProvider    `toml:"chain"`
-	Database *DatabaseProvider `toml:"database"`
-	Local    *LocalProvider    `toml:"local"`
+	Chain         *ChainProvider         `toml:"chain"`
+	Database      *DatabaseProvider      `toml:"database"`
+	Local         *LocalProvider         `toml:"local"`
+	Registry      *RegistryProvider      `toml:"registry"`
+	ChainRegistry *ChainRegistryProvider `toml:"chain-registry"`
 }
 
 type VersionResolvers struct {
@@ -110,6 +230,10 @@ type VersionResolvers struct {
 
 type StateMachine struct {
 	Provider string `toml:"provider"`
+	// identity of the blazar instance the state is stored under in a shared storage, defaults to the hostname
+	InstanceID string `toml:"instance-id"`
+	// refuse to execute an upgrade if the state can't be persisted, the progress would be lost on restart
+	RequirePersistence bool `toml:"require-persistence"`
 }
 
 type PreUpgrade struct {
@@ -117,11 +241,57 @@ type PreUpgrade struct {
 	Blocks          int64            `toml:"blocks"`
 	SetHaltHeight   *SetHaltHeight   `toml:"set-halt-height"`
 	PullDockerImage *PullDockerImage `toml:"pull-docker-image"`
+	BackupData      *BackupData      `toml:"backup-data"`
+
+	VerifyBinaryVersion *VerifyBinaryVersion `toml:"verify-binary-version"`
+	NodeSyncStatus      *NodeSyncStatus      `toml:"node-sync-status"`
 }
 
 type PullDockerImage struct {
-	MaxRetries     int           `toml:"max-retries"`
-	InitialBackoff time.Duration `toml:"initial-backoff"`
+	MaxRetries       int                  `toml:"max-retries"`
+	InitialBackoff   time.Duration        `toml:"initial-backoff"`
+	OnDigestMismatch DigestMismatchAction `toml:"on-digest-mismatch"`
+}
+
+// GetOnDigestMismatch returns the action taken when the upgrade image changed since the pre-check, defaulting to fail
+func (p *PullDockerImage) GetOnDigestMismatch() DigestMismatchAction {
+	if p == nil || p.OnDigestMismatch == "" {
+		return DigestMismatchFail
+	}
+	return p.OnDigestMismatch
+}
+
+type BackupData struct {
+	// paths relative to the chain-home
+	Paths     []string `toml:"paths"`
+	BackupDir string   `toml:"backup-dir"`
+	Retention int      `toml:"retention"`
+}
+
+type VerifyBinaryVersion struct {
+	// name of the binary in the upgrade image (e.g gaiad), defaults to the app name reported by the node
+	AppName string        `toml:"app-name"`
+	Timeout time.Duration `toml:"timeout"`
+	// "block" doesn't execute the upgrade if the binary reports a different version, "warn" only notifies
+	OnMismatch CheckSeverity `toml:"on-mismatch"`
+}
+
+// GetOnMismatch returns the severity of a binary version mismatch, defaulting to block
+func (v *VerifyBinaryVersion) GetOnMismatch() CheckSeverity {
+	if v == nil || v.OnMismatch == "" {
+		return SeverityBlock
+	}
+	return v.OnMismatch
+}
+
+// NodeSyncStatus holds the severity of each condition checked by the NODE_SYNC_STATUS pre-check.
+// "warn" only notifies, "block" holds the following pre-upgrade checks (e.g SET_HALT_HEIGHT) until the condition clears
+type NodeSyncStatus struct {
+	CatchingUp CheckSeverity `toml:"catching-up"`
+	LowPeers   CheckSeverity `toml:"low-peers"`
+	MinPeers   int           `toml:"min-peers"`
+	Jailed     CheckSeverity `toml:"jailed"`
+	Tombstoned CheckSeverity `toml:"tombstoned"`
 }
 
 type SetHaltHeight struct {
@@ -145,11 +315,43 @@ type FirstBlockVoted struct {
 	Timeout       time.Duration `toml:"timeout"`
 }
 
+type NoFatalLogs struct {
+	// regular expressions matched against every log line of the upgraded service
+	Patterns []string `toml:"patterns"`
+	// number of lines before and after the matching line attached to the notification
+	ContextLines int `toml:"context-lines"`
+	// how long the logs are followed for, the other post-upgrade checks run meanwhile
+	Duration time.Duration `toml:"duration"`
+	// fail the upgrade (cancelling the other post-upgrade checks) or only notify about the match
+	OnMatch LogMatchAction `toml:"on-match"`
+}
+
+type AppVersionMatches struct {
+	PollInterval time.Duration `toml:"poll-interval"`
+	Timeout      time.Duration `toml:"timeout"`
+	// most upgrades keep the ABCI app_version unchanged (it is only bumped by apps versioning the protocol),
+	// so by default only the application version and commit must change
+	RequireAppVersionChange bool `toml:"require-app-version-change"`
+}
+
+type UpgradePlanApplied struct {
+	PollInterval time.Duration `toml:"poll-interval"`
+	Timeout      time.Duration `toml:"timeout"`
+}
+
+type Rollback struct {
+	UpgradeTypes []string `toml:"upgrade-types"`
+}
+
 type PostUpgrade struct {
 	Enabled              []string              `toml:"enabled"`
 	GrpcResponsive       *GrpcResponsive       `toml:"grpc-responsive"`
 	ChainHeightIncreased *ChainHeightIncreased `toml:"chain-height-increased"`
 	FirstBlockVoted      *FirstBlockVoted      `toml:"first-block-voted"`
+	NoFatalLogs          *NoFatalLogs          `toml:"no-fatal-logs"`
+	AppVersionMatches    *AppVersionMatches    `toml:"app-version-matches"`
+	UpgradePlanApplied   *UpgradePlanApplied   `toml:"upgrade-plan-applied"`
+	Rollback             *Rollback             `toml:"rollback"`
 }
 
 type Checks struct {
@@ -158,7 +360,11 @@ type Checks struct {
 }
 
 type UpgradeRegistry struct {
-	Network           string            `toml:"network"`
+	Network string `toml:"network"`
+	// upgrades are blocked from executing if their providers failed to sync for longer than this, 0 disables the check
+	MaxStaleness time.Duration `toml:"max-staleness"`
+	// file the last resolved upgrades and versions are persisted to, empty disables the cache
+	CachePath         string            `toml:"cache-path"`
 	Provider          Provider          `toml:"provider"`
 	SelectedProviders []string          `toml:"providers"`
 	VersionResolvers  *VersionResolvers `toml:"version-resolvers"`
@@ -168,6 +374,7 @@ type UpgradeRegistry struct {
 // The validation of the config and the order of prams in the sample
 // toml files follow a DFS traversal of the struct.
 type Config struct {
+	Executor         Executor                `toml:"executor"`
 	ComposeFile      string                  `toml:"compose-file"`
 	ComposeService   string                  `toml:"compose-service"`
 	VersionFile      string                  `toml:"version-file"`
@@ -181,10 +388,17 @@ type Config struct {
 	Watchers         Watchers                `toml:"watchers"`
 	Clients          Clients                 `toml:"clients"`
 	Compose          ComposeCli              `toml:"compose-cli"`
+	Systemd          *Systemd                `toml:"systemd"`
 	Checks           Checks                  `toml:"checks"`
 	Slack            *Slack                  `toml:"slack"`
 	CredentialHelper *DockerCredentialHelper `toml:"docker-credential-helper"`
 	UpgradeRegistry  UpgradeRegistry         `toml:"upgrade-registry"`
+
+	// DryRun is set by the --dry-run flag of the run command, it can't be set in the toml file
+	DryRun bool `toml:"-"`
+
+	// SyncFromGenesis is set by the --sync-from-genesis flag of the run command, it can't be set in the toml file
+	SyncFromGenesis bool `toml:"-"`
 }
 
 func ReadEnvVar(key string) string {
@@ -200,6 +414,14 @@ func ReadConfig(cfgFile string) (*Config, error) {
 	return &config, nil
 }
 
+// GetExecutor returns the configured upgrade executor, defaulting to docker-compose
+func (cfg *Config) GetExecutor() Executor {
+	if cfg.Executor == "" {
+		return ExecutorDockerCompose
+	}
+	return cfg.Executor
+}
+
 func (cfg *Config) UpgradeInfoFilePath() string {
 	return filepath.Join(cfg.ChainHome, "data", "upgrade-info.json")
 }
@@ -323,6 +545,36 @@ func (cfg *Config) ValidateCredentialHelper() error {
 	return nil
 }
 
+func (cfg *Config) ValidateSystemd() error {
+	if cfg.Systemd == nil {
+		return errors.New("systemd cannot be nil when executor is set to systemd")
+	}
+	if cfg.Systemd.Unit == "" {
+		return errors.New("systemd.unit cannot be empty")
+	}
+	if err := validateDir(cfg.Systemd.BinariesDir, unix.R_OK); err != nil {
+		return errors.Wrapf(err, "error validating systemd.binaries-dir")
+	}
+	if cfg.Systemd.BinaryName == "" {
+		return errors.New("systemd.binary-name cannot be empty")
+	}
+	if !filepath.IsAbs(cfg.Systemd.CurrentLink) {
+		return errors.New("systemd.current-link must be an absolute path")
+	}
+	if err := validateDir(path.Dir(cfg.Systemd.CurrentLink), unix.R_OK|unix.W_OK); err != nil {
+		return errors.Wrapf(err, "error validating systemd.current-link")
+	}
+	if cfg.Systemd.Timeout <= 0 {
+		return errors.New("systemd.timeout cannot be less than or equal to 0")
+	}
+	return nil
+}
+
+// normalizeProvider returns the provider type name of the configured provider (e.g. chain-registry -> CHAIN_REGISTRY)
+func normalizeProvider(provider string) string {
+	return strings.ToUpper(strings.ReplaceAll(provider, "-", "_"))
+}
+
 func (cfg *Config) checkProvider(provider string) error {
 	switch provider {
 	case urproto.ProviderType_name[int32(urproto.ProviderType_CHAIN)]:
@@ -337,6 +589,14 @@ func (cfg *Config) checkProvider(provider string) error {
 		if cfg.UpgradeRegistry.Provider.Local == nil {
 			return errors.New("upgrade-registry.provider.local cannot be nil")
 		}
+	case urproto.ProviderType_name[int32(urproto.ProviderType_REGISTRY)]:
+		if cfg.UpgradeRegistry.Provider.Registry == nil {
+			return errors.New("upgrade-registry.provider.registry cannot be nil")
+		}
+	case urproto.ProviderType_name[int32(urproto.ProviderType_CHAIN_REGISTRY)]:
+		if cfg.UpgradeRegistry.Provider.ChainRegistry == nil {
+			return errors.New("upgrade-registry.provider.chain-registry cannot be nil")
+		}
 	default:
 		return fmt.Errorf("unknown provider: %s", provider)
 	}
@@ -383,6 +643,34 @@ func (cfg *Config) ValidatePreUpgradeChecks() error {
 			if cfg.Checks.PreUpgrade.PullDockerImage.InitialBackoff < 0 {
 				return errors.New("checks.pre-upgrade.pull-docker-image.initial-backoff cannot be less than 0")
 			}
+			if cfg.Checks.PreUpgrade.PullDockerImage.OnDigestMismatch != "" &&
+				!slices.Contains(ValidDigestMismatchActions, cfg.Checks.PreUpgrade.PullDockerImage.OnDigestMismatch) {
+				return fmt.Errorf(
+					"checks.pre-upgrade.pull-docker-image.on-digest-mismatch '%s' is invalid, pick one of %+v",
+					cfg.Checks.PreUpgrade.PullDockerImage.OnDigestMismatch, ValidDigestMismatchActions,
+				)
+			}
+		case checksproto.PreCheck_name[int32(checksproto.PreCheck_BACKUP_DATA)]:
+			if err := cfg.ValidateBackupData(); err != nil {
+				return err
+			}
+		case checksproto.PreCheck_name[int32(checksproto.PreCheck_NODE_SYNC_STATUS)]:
+			if err := cfg.ValidateNodeSyncStatus(); err != nil {
+				return err
+			}
+		case checksproto.PreCheck_name[int32(checksproto.PreCheck_VERIFY_BINARY_VERSION)]:
+			if cfg.Checks.PreUpgrade.VerifyBinaryVersion == nil {
+				return errors.New("checks.pre-upgrade.verify-binary-version cannot be nil")
+			}
+			if cfg.Checks.PreUpgrade.VerifyBinaryVersion.Timeout <= 0 {
+				return errors.New("checks.pre-upgrade.verify-binary-version.timeout cannot be less than or equal to 0")
+			}
+			if onMismatch := cfg.Checks.PreUpgrade.VerifyBinaryVersion.GetOnMismatch(); onMismatch != SeverityWarn && onMismatch != SeverityBlock {
+				return fmt.Errorf(
+					"checks.pre-upgrade.verify-binary-version.on-mismatch '%s' is invalid, pick one of %+v",
+					onMismatch, []CheckSeverity{SeverityWarn, SeverityBlock},
+				)
+			}
 		default:
 			return fmt.Errorf("unknown value in checks.pre-upgrade.enabled: %s", check)
 		}
@@ -391,6 +679,52 @@ func (cfg *Config) ValidatePreUpgradeChecks() error {
 	return nil
 }
 
+func (cfg *Config) ValidateBackupData() error {
+	backupData := cfg.Checks.PreUpgrade.BackupData
+	if backupData == nil {
+		return errors.New("checks.pre-upgrade.backup-data cannot be nil")
+	}
+	if len(backupData.Paths) == 0 {
+		return errors.New("checks.pre-upgrade.backup-data.paths cannot be empty")
+	}
+	for _, path := range backupData.Paths {
+		if !filepath.IsLocal(path) {
+			return fmt.Errorf("checks.pre-upgrade.backup-data.paths must be relative to chain-home, got %q", path)
+		}
+	}
+	if !filepath.IsAbs(backupData.BackupDir) {
+		return fmt.Errorf("checks.pre-upgrade.backup-data.backup-dir must be an absolute path, got %q", backupData.BackupDir)
+	}
+	if backupData.Retention < 1 {
+		return errors.New("checks.pre-upgrade.backup-data.retention cannot be less than 1")
+	}
+	return nil
+}
+
+func (cfg *Config) ValidateNodeSyncStatus() error {
+	nodeSyncStatus := cfg.Checks.PreUpgrade.NodeSyncStatus
+	if nodeSyncStatus == nil {
+		return errors.New("checks.pre-upgrade.node-sync-status cannot be nil")
+	}
+
+	severities := map[string]CheckSeverity{
+		"catching-up": nodeSyncStatus.CatchingUp,
+		"low-peers":   nodeSyncStatus.LowPeers,
+		"jailed":      nodeSyncStatus.Jailed,
+		"tombstoned":  nodeSyncStatus.Tombstoned,
+	}
+	for _, name := range []string{"catching-up", "low-peers", "jailed", "tombstoned"} {
+		if !slices.Contains(ValidCheckSeverities, severities[name]) {
+			return fmt.Errorf("checks.pre-upgrade.node-sync-status.%s '%s' is invalid, pick one of %+v", name, severities[name], ValidCheckSeverities)
+		}
+	}
+
+	if nodeSyncStatus.LowPeers != SeverityOff && nodeSyncStatus.MinPeers < 1 {
+		return errors.New("checks.pre-upgrade.node-sync-status.min-peers cannot be less than 1")
+	}
+	return nil
+}
+
 func (cfg *Config) ValidatePostUpgradeChecks() error {
 	for _, check := range cfg.Checks.PostUpgrade.Enabled {
 		switch check {
@@ -430,30 +764,171 @@ func (cfg *Config) ValidatePostUpgradeChecks() error {
 			if cfg.Checks.PostUpgrade.FirstBlockVoted.Timeout <= 0 {
 				return errors.New("checks.post-upgrade.first-block-voted cannot be less than or equal to 0")
 			}
+		case checksproto.PostCheck_name[int32(checksproto.PostCheck_NO_FATAL_LOGS)]:
+			if err := cfg.ValidateNoFatalLogs(); err != nil {
+				return err
+			}
+		case checksproto.PostCheck_name[int32(checksproto.PostCheck_APP_VERSION_MATCHES)]:
+			if cfg.Checks.PostUpgrade.AppVersionMatches == nil {
+				return errors.New("checks.post-upgrade.app-version-matches cannot be nil")
+			}
+			if cfg.Checks.PostUpgrade.AppVersionMatches.PollInterval <= 0 {
+				return errors.New("checks.post-upgrade.app-version-matches.poll-interval cannot be less than or equal to 0")
+			}
+			if cfg.Checks.PostUpgrade.AppVersionMatches.Timeout <= 0 {
+				return errors.New("checks.post-upgrade.app-version-matches.timeout cannot be less than or equal to 0")
+			}
+		case checksproto.PostCheck_name[int32(checksproto.PostCheck_UPGRADE_PLAN_APPLIED)]:
+			if cfg.Checks.PostUpgrade.UpgradePlanApplied == nil {
+				return errors.New("checks.post-upgrade.upgrade-plan-applied cannot be nil")
+			}
+			if cfg.Checks.PostUpgrade.UpgradePlanApplied.PollInterval <= 0 {
+				return errors.New("checks.post-upgrade.upgrade-plan-applied.poll-interval cannot be less than or equal to 0")
+			}
+			if cfg.Checks.PostUpgrade.UpgradePlanApplied.Timeout <= 0 {
+				return errors.New("checks.post-upgrade.upgrade-plan-applied.timeout cannot be less than or equal to 0")
+			}
 		default:
 			return fmt.Errorf("unknown value in checks.post-upgrade.enabled: %s", check)
 		}
 	}
 
+	// rollback is optional
+	if rollback := cfg.Checks.PostUpgrade.Rollback; rollback != nil {
+		if len(rollback.UpgradeTypes) == 0 {
+			return errors.New("checks.post-upgrade.rollback.upgrade-types cannot be empty")
+		}
+		for _, upgradeType := range rollback.UpgradeTypes {
+			if _, ok := urproto.UpgradeType_value[upgradeType]; !ok {
+				return fmt.Errorf("unknown value in checks.post-upgrade.rollback.upgrade-types: %s", upgradeType)
+			}
+			// the previous binary would panic again at the governance upgrade height
+			if upgradeType == urproto.UpgradeType_GOVERNANCE.String() {
+				return errors.New("checks.post-upgrade.rollback.upgrade-types cannot contain GOVERNANCE upgrades")
+			}
+		}
+		if cfg.Checks.PostUpgrade.GrpcResponsive == nil || cfg.Checks.PostUpgrade.ChainHeightIncreased == nil {
+			return errors.New("checks.post-upgrade.rollback requires checks.post-upgrade.grpc-responsive and checks.post-upgrade.chain-height-increased sections")
+		}
+	}
+
 	return nil
 }
 
-func (cfg *Config) ValidateAll() error {
-	if err := cfg.ValidateComposeFile(); err != nil {
-		return err
+func (cfg *Config) ValidateNoFatalLogs() error {
+	noFatalLogs := cfg.Checks.PostUpgrade.NoFatalLogs
+	if noFatalLogs == nil {
+		return errors.New("checks.post-upgrade.no-fatal-logs cannot be nil")
+	}
+	if len(noFatalLogs.Patterns) == 0 {
+		return errors.New("checks.post-upgrade.no-fatal-logs.patterns cannot be empty")
+	}
+	for _, pattern := range noFatalLogs.Patterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("checks.post-upgrade.no-fatal-logs.patterns contains an invalid regex %q: %w", pattern, err)
+		}
+	}
+	if noFatalLogs.ContextLines < 0 {
+		return errors.New("checks.post-upgrade.no-fatal-logs.context-lines cannot be less than 0")
+	}
+	if noFatalLogs.Duration <= 0 {
+		return errors.New("checks.post-upgrade.no-fatal-logs.duration cannot be less than or equal to 0")
 	}
+	if !slices.Contains(ValidLogMatchActions, noFatalLogs.OnMatch) {
+		return fmt.Errorf("checks.post-upgrade.no-fatal-logs.on-match '%s' is invalid, pick one of %+v", noFatalLogs.OnMatch, ValidLogMatchActions)
+	}
+	return nil
+}
 
-	if cfg.ComposeService == "" {
-		return errors.New("compose-service cannot be empty")
+// ValidatePlanInfo validates the optional upgrade-registry.provider.chain.plan-info section
+func (cfg *Config) ValidatePlanInfo() error {
+	planInfo := cfg.UpgradeRegistry.Provider.Chain.PlanInfo
+	if planInfo == nil {
+		return nil
+	}
+	for i, rule := range planInfo.Rules {
+		if !slices.Contains(ValidPlanInfoFields, rule.Field) {
+			return fmt.Errorf("upgrade-registry.provider.chain.plan-info.rules[%d].field '%s' is invalid, pick one of %+v", i, rule.Field, ValidPlanInfoFields)
+		}
+		if _, err := regexp.Compile(rule.Regex); err != nil {
+			return fmt.Errorf("upgrade-registry.provider.chain.plan-info.rules[%d].regex %q is invalid: %w", i, rule.Regex, err)
+		}
+		if rule.Template == "" {
+			return fmt.Errorf("upgrade-registry.provider.chain.plan-info.rules[%d].template cannot be empty", i)
+		}
 	}
+	return nil
+}
+
+func (cfg *Config) ValidateRegistryProvider() error {
+	registry := cfg.UpgradeRegistry.Provider.Registry
+	if registry.DefaultPriority < 1 || registry.DefaultPriority > 99 {
+		return errors.New("upgrade-registry.provider.registry.default-priority must be between 1 and 99")
+	}
+	if _, err := reference.ParseNormalizedNamed(registry.Image); err != nil {
+		return fmt.Errorf("upgrade-registry.provider.registry.image %q is invalid: %w", registry.Image, err)
+	}
+	if registry.Timeout <= 0 {
+		return errors.New("upgrade-registry.provider.registry.timeout cannot be less than or equal to 0")
+	}
+	for i, rule := range registry.Rules {
+		if _, err := regexp.Compile(rule.UpgradeName); err != nil {
+			return fmt.Errorf("upgrade-registry.provider.registry.rules[%d].upgrade-name %q is invalid: %w", i, rule.UpgradeName, err)
+		}
+		if rule.Tag == "" {
+			return fmt.Errorf("upgrade-registry.provider.registry.rules[%d].tag cannot be empty", i)
+		}
+	}
+	return nil
+}
 
-	if !slices.Contains(ValidUpgradeModes, cfg.UpgradeMode) {
-		return fmt.Errorf("invalid upgradeMode '%s', pick one of %+v", cfg.UpgradeMode, ValidUpgradeModes)
+func (cfg *Config) ValidateChainRegistryProvider() error {
+	chainRegistry := cfg.UpgradeRegistry.Provider.ChainRegistry
+	if chainRegistry.DefaultPriority < 1 || chainRegistry.DefaultPriority > 99 {
+		return errors.New("upgrade-registry.provider.chain-registry.default-priority must be between 1 and 99")
+	}
+	if err := validateDir(chainRegistry.Path, unix.R_OK|unix.X_OK); err != nil {
+		return errors.Wrapf(err, "error validating upgrade-registry.provider.chain-registry.path")
+	}
+	if chainRegistry.PollInterval < 0 {
+		return errors.New("upgrade-registry.provider.chain-registry.poll-interval cannot be less than 0")
 	}
-	if cfg.UpgradeMode == "env-file" {
-		if err := cfg.ValidateVersionFile(); err != nil {
+	for i, rule := range chainRegistry.Rules {
+		if _, err := regexp.Compile(rule.Regex); err != nil {
+			return fmt.Errorf("upgrade-registry.provider.chain-registry.rules[%d].regex %q is invalid: %w", i, rule.Regex, err)
+		}
+		if rule.Template == "" {
+			return fmt.Errorf("upgrade-registry.provider.chain-registry.rules[%d].template cannot be empty", i)
+		}
+	}
+	return nil
+}
+
+func (cfg *Config) ValidateAll() error {
+	switch cfg.GetExecutor() {
+	case ExecutorDockerCompose:
+		if err := cfg.ValidateComposeFile(); err != nil {
 			return err
 		}
+
+		if cfg.ComposeService == "" {
+			return errors.New("compose-service cannot be empty")
+		}
+
+		if !slices.Contains(ValidUpgradeModes, cfg.UpgradeMode) {
+			return fmt.Errorf("invalid upgradeMode '%s', pick one of %+v", cfg.UpgradeMode, ValidUpgradeModes)
+		}
+		if cfg.UpgradeMode == "env-file" {
+			if err := cfg.ValidateVersionFile(); err != nil {
+				return err
+			}
+		}
+	case ExecutorSystemd:
+		if err := cfg.ValidateSystemd(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("invalid executor '%s', pick one of %+v", cfg.Executor, ValidExecutors)
 	}
 
 	if err := cfg.ValidateChainHome(); err != nil {
@@ -559,22 +1034,43 @@ func (cfg *Config) ValidateAll() error {
 		return errors.New("upgrade-registry.providers cannot be empty")
 	}
 	for i, provider := range cfg.UpgradeRegistry.SelectedProviders {
-		provider = strings.ToUpper(provider)
+		provider = normalizeProvider(provider)
 		cfg.UpgradeRegistry.SelectedProviders[i] = provider
 
 		if err := cfg.checkProvider(provider); err != nil {
 			return errors.Wrapf(err, "error validating upgrade-registry.providers")
 		}
+		if provider == urproto.ProviderType_name[int32(urproto.ProviderType_REGISTRY)] ||
+			provider == urproto.ProviderType_name[int32(urproto.ProviderType_CHAIN_REGISTRY)] {
+			return fmt.Errorf("upgrade-registry.providers cannot contain %s, it can only be used as a version resolver", strings.ToLower(provider))
+		}
 	}
 
 	if cfg.UpgradeRegistry.Network == "" {
 		return errors.New("upgrade-registry.network cannot be empty")
 	}
 
+	if cfg.UpgradeRegistry.MaxStaleness < 0 {
+		return errors.New("upgrade-registry.max-staleness cannot be less than 0")
+	}
+	// the providers are synced every upgrade-proposals-interval, a lower value would block every upgrade
+	if cfg.UpgradeRegistry.MaxStaleness > 0 && cfg.UpgradeRegistry.MaxStaleness <= cfg.Watchers.UPInterval {
+		return errors.New("upgrade-registry.max-staleness must be greater than watchers.upgrade-proposals-interval")
+	}
+
+	if cfg.UpgradeRegistry.CachePath != "" {
+		if err := validateDir(path.Dir(cfg.UpgradeRegistry.CachePath), unix.R_OK|unix.W_OK); err != nil {
+			return errors.Wrapf(err, "error validating upgrade-registry.cache-path")
+		}
+	}
+
 	if cfg.UpgradeRegistry.Provider.Chain != nil {
 		if cfg.UpgradeRegistry.Provider.Chain.DefaultPriority < 1 || cfg.UpgradeRegistry.Provider.Chain.DefaultPriority > 99 {
 			return errors.New("upgrade-registry.provider.chain.default-priority must be between 1 and 99")
 		}
+		if err := cfg.ValidatePlanInfo(); err != nil {
+			return err
+		}
 	}
 
 	if cfg.UpgradeRegistry.Provider.Database != nil {
@@ -620,6 +1116,21 @@ func (cfg *Config) ValidateAll() error {
 		if cfg.UpgradeRegistry.Provider.Local.ConfigPath == "" {
 			return errors.New("upgrade-registry.provider.local.config-path cannot be empty")
 		}
+		if cfg.UpgradeRegistry.Provider.Local.StateBackups < 0 {
+			return errors.New("upgrade-registry.provider.local.state-backups cannot be less than 0")
+		}
+	}
+
+	if cfg.UpgradeRegistry.Provider.Registry != nil {
+		if err := cfg.ValidateRegistryProvider(); err != nil {
+			return err
+		}
+	}
+
+	if cfg.UpgradeRegistry.Provider.ChainRegistry != nil {
+		if err := cfg.ValidateChainRegistryProvider(); err != nil {
+			return err
+		}
 	}
 
 	// version resolver is optional
@@ -628,7 +1139,7 @@ func (cfg *Config) ValidateAll() error {
 			return errors.New("upgrade-registry.version-resolvers.providers cannot be empty")
 		}
 		for i, provider := range cfg.UpgradeRegistry.VersionResolvers.Providers {
-			provider = strings.ToUpper(provider)
+			provider = normalizeProvider(provider)
 			cfg.UpgradeRegistry.VersionResolvers.Providers[i] = provider
 
 			if err := cfg.checkProvider(provider); err != nil {
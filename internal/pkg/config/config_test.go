@@ -21,6 +21,7 @@ func TestReadConfigToml(t *testing.T) {
 	cfg, err := ReadConfig("../../../blazar.sample.toml")
 	require.NoError(t, err)
 	assert.Equal(t, &Config{
+		Executor:       ExecutorDockerCompose,
 		ComposeFile:    "<path>",
 		ComposeService: "<service>",
 		UpgradeMode:    UpgradeInComposeFile,
@@ -55,8 +56,20 @@ func TestReadConfigToml(t *testing.T) {
 					DelayBlocks: 0,
 				},
 				PullDockerImage: &PullDockerImage{
-					MaxRetries:     0,
-					InitialBackoff: 0,
+					MaxRetries:       0,
+					InitialBackoff:   0,
+					OnDigestMismatch: DigestMismatchFail,
+				},
+				VerifyBinaryVersion: &VerifyBinaryVersion{
+					Timeout:    1 * time.Minute,
+					OnMismatch: SeverityBlock,
+				},
+				NodeSyncStatus: &NodeSyncStatus{
+					CatchingUp: SeverityBlock,
+					LowPeers:   SeverityWarn,
+					MinPeers:   3,
+					Jailed:     SeverityWarn,
+					Tombstoned: SeverityWarn,
 				},
 			},
 			PostUpgrade: PostUpgrade{
@@ -75,6 +88,20 @@ func TestReadConfigToml(t *testing.T) {
 					NotifInterval: 1 * time.Minute,
 					Timeout:       5 * time.Minute,
 				},
+				NoFatalLogs: &NoFatalLogs{
+					Patterns:     []string{"CONSENSUS FAILURE", `wrong Block\.Header\.AppHash`, `UPGRADE ".*" NEEDED`, "panic:"},
+					ContextLines: 10,
+					Duration:     5 * time.Minute,
+					OnMatch:      LogMatchFail,
+				},
+				AppVersionMatches: &AppVersionMatches{
+					PollInterval: time.Second,
+					Timeout:      time.Minute,
+				},
+				UpgradePlanApplied: &UpgradePlanApplied{
+					PollInterval: time.Second,
+					Timeout:      5 * time.Minute,
+				},
 			},
 		},
 		Slack: &Slack{
@@ -104,6 +131,7 @@ func TestReadConfigToml(t *testing.T) {
 				Local: &LocalProvider{
 					ConfigPath:      "./local-provider.db.json",
 					DefaultPriority: int32(2),
+					StateBackups:    3,
 				},
 				Chain: &ChainProvider{
 					DefaultPriority: int32(1),
@@ -463,3 +491,384 @@ func TestLoadWebhookUrl(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateSystemd(t *testing.T) {
+	tempDir := t.TempDir()
+
+	tests := []struct {
+		name        string
+		systemd     *Systemd
+		expectedErr error
+	}{
+		{
+			name: "Valid",
+			systemd: &Systemd{
+				Unit:        "gaiad.service",
+				BinariesDir: tempDir,
+				BinaryName:  "gaiad",
+				CurrentLink: filepath.Join(tempDir, "current"),
+				Timeout:     time.Second,
+			},
+			expectedErr: nil,
+		},
+		{
+			name:        "Nil",
+			systemd:     nil,
+			expectedErr: errors.New("systemd cannot be nil when executor is set to systemd"),
+		},
+		{
+			name: "EmptyUnit",
+			systemd: &Systemd{
+				BinariesDir: tempDir,
+				BinaryName:  "gaiad",
+				CurrentLink: filepath.Join(tempDir, "current"),
+				Timeout:     time.Second,
+			},
+			expectedErr: errors.New("systemd.unit cannot be empty"),
+		},
+		{
+			name: "RelativeCurrentLink",
+			systemd: &Systemd{
+				Unit:        "gaiad.service",
+				BinariesDir: tempDir,
+				BinaryName:  "gaiad",
+				CurrentLink: "current",
+				Timeout:     time.Second,
+			},
+			expectedErr: errors.New("systemd.current-link must be an absolute path"),
+		},
+		{
+			name: "ZeroTimeout",
+			systemd: &Systemd{
+				Unit:        "gaiad.service",
+				BinariesDir: tempDir,
+				BinaryName:  "gaiad",
+				CurrentLink: filepath.Join(tempDir, "current"),
+			},
+			expectedErr: errors.New("systemd.timeout cannot be less than or equal to 0"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := &Config{
+				Executor: ExecutorSystemd,
+				Systemd:  test.systemd,
+			}
+
+			if err := cfg.ValidateSystemd(); test.expectedErr != nil {
+				assert.Equal(t, test.expectedErr.Error(), err.Error())
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateBackupData(t *testing.T) {
+	tests := []struct {
+		name        string
+		backupData  *BackupData
+		expectedErr error
+	}{
+		{
+			name: "Valid",
+			backupData: &BackupData{
+				Paths:     []string{"data/application.db", "data/priv_validator_state.json"},
+				BackupDir: "/backups",
+				Retention: 2,
+			},
+			expectedErr: nil,
+		},
+		{
+			name:        "Nil",
+			backupData:  nil,
+			expectedErr: errors.New("checks.pre-upgrade.backup-data cannot be nil"),
+		},
+		{
+			name: "EmptyPaths",
+			backupData: &BackupData{
+				BackupDir: "/backups",
+				Retention: 2,
+			},
+			expectedErr: errors.New("checks.pre-upgrade.backup-data.paths cannot be empty"),
+		},
+		{
+			name: "PathOutsideChainHome",
+			backupData: &BackupData{
+				Paths:     []string{"../config"},
+				BackupDir: "/backups",
+				Retention: 2,
+			},
+			expectedErr: errors.New("checks.pre-upgrade.backup-data.paths must be relative to chain-home, got \"../config\""),
+		},
+		{
+			name: "RelativeBackupDir",
+			backupData: &BackupData{
+				Paths:     []string{"data/application.db"},
+				BackupDir: "backups",
+				Retention: 2,
+			},
+			expectedErr: errors.New("checks.pre-upgrade.backup-data.backup-dir must be an absolute path, got \"backups\""),
+		},
+		{
+			name: "ZeroRetention",
+			backupData: &BackupData{
+				Paths:     []string{"data/application.db"},
+				BackupDir: "/backups",
+			},
+			expectedErr: errors.New("checks.pre-upgrade.backup-data.retention cannot be less than 1"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := &Config{}
+			cfg.Checks.PreUpgrade.BackupData = test.backupData
+
+			if err := cfg.ValidateBackupData(); test.expectedErr != nil {
+				assert.Equal(t, test.expectedErr.Error(), err.Error())
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateNodeSyncStatus(t *testing.T) {
+	tests := []struct {
+		name           string
+		nodeSyncStatus *NodeSyncStatus
+		expectedErr    error
+	}{
+		{
+			name: "Valid",
+			nodeSyncStatus: &NodeSyncStatus{
+				CatchingUp: SeverityBlock,
+				LowPeers:   SeverityWarn,
+				MinPeers:   3,
+				Jailed:     SeverityWarn,
+				Tombstoned: SeverityOff,
+			},
+			expectedErr: nil,
+		},
+		{
+			name:           "Nil",
+			nodeSyncStatus: nil,
+			expectedErr:    errors.New("checks.pre-upgrade.node-sync-status cannot be nil"),
+		},
+		{
+			name: "InvalidSeverity",
+			nodeSyncStatus: &NodeSyncStatus{
+				CatchingUp: "fail",
+				LowPeers:   SeverityOff,
+				Jailed:     SeverityOff,
+				Tombstoned: SeverityOff,
+			},
+			expectedErr: errors.New("checks.pre-upgrade.node-sync-status.catching-up 'fail' is invalid, pick one of [off warn block]"),
+		},
+		{
+			name: "MissingMinPeers",
+			nodeSyncStatus: &NodeSyncStatus{
+				CatchingUp: SeverityOff,
+				LowPeers:   SeverityWarn,
+				Jailed:     SeverityOff,
+				Tombstoned: SeverityOff,
+			},
+			expectedErr: errors.New("checks.pre-upgrade.node-sync-status.min-peers cannot be less than 1"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := &Config{}
+			cfg.Checks.PreUpgrade.NodeSyncStatus = test.nodeSyncStatus
+
+			if err := cfg.ValidateNodeSyncStatus(); test.expectedErr != nil {
+				assert.Equal(t, test.expectedErr.Error(), err.Error())
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidatePlanInfo(t *testing.T) {
+	tests := []struct {
+		name        string
+		planInfo    *PlanInfo
+		expectedErr error
+	}{
+		{
+			name:        "Nil",
+			planInfo:    nil,
+			expectedErr: nil,
+		},
+		{
+			name: "Valid",
+			planInfo: &PlanInfo{
+				Platform: "linux/arm64",
+				Rules:    []PlanInfoRule{{Field: PlanInfoName, Regex: `^\d+\.\d+\.\d+$`, Template: "v{{name}}"}},
+			},
+			expectedErr: nil,
+		},
+		{
+			name: "InvalidField",
+			planInfo: &PlanInfo{
+				Rules: []PlanInfoRule{{Field: "title", Regex: ".*", Template: "{{name}}"}},
+			},
+			expectedErr: errors.New("upgrade-registry.provider.chain.plan-info.rules[0].field 'title' is invalid, pick one of [name info]"),
+		},
+		{
+			name: "InvalidRegex",
+			planInfo: &PlanInfo{
+				Rules: []PlanInfoRule{{Field: PlanInfoInfo, Regex: "v(", Template: "{{name}}"}},
+			},
+			expectedErr: errors.New("upgrade-registry.provider.chain.plan-info.rules[0].regex \"v(\" is invalid: error parsing regexp: missing closing ): `v(`"),
+		},
+		{
+			name: "EmptyTemplate",
+			planInfo: &PlanInfo{
+				Rules: []PlanInfoRule{{Field: PlanInfoName, Regex: ".*"}},
+			},
+			expectedErr: errors.New("upgrade-registry.provider.chain.plan-info.rules[0].template cannot be empty"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := &Config{}
+			cfg.UpgradeRegistry.Provider.Chain = &ChainProvider{PlanInfo: test.planInfo}
+
+			if err := cfg.ValidatePlanInfo(); test.expectedErr != nil {
+				assert.Equal(t, test.expectedErr.Error(), err.Error())
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateRegistryProvider(t *testing.T) {
+	valid := func() *RegistryProvider {
+		return &RegistryProvider{
+			DefaultPriority: 1,
+			Image:           "ghcr.io/cosmos/gaia",
+			Timeout:         10 * time.Second,
+			Rules:           []RegistryTagRule{{UpgradeName: `^v(?P<major>\d+)$`, Tag: `^v{{major}}\.\d+\.\d+$`}},
+		}
+	}
+
+	tests := []struct {
+		name        string
+		modify      func(*RegistryProvider)
+		expectedErr error
+	}{
+		{
+			name:        "Valid",
+			modify:      func(*RegistryProvider) {},
+			expectedErr: nil,
+		},
+		{
+			name:        "InvalidPriority",
+			modify:      func(r *RegistryProvider) { r.DefaultPriority = 100 },
+			expectedErr: errors.New("upgrade-registry.provider.registry.default-priority must be between 1 and 99"),
+		},
+		{
+			name:        "InvalidImage",
+			modify:      func(r *RegistryProvider) { r.Image = "ghcr.io/Cosmos/gaia" },
+			expectedErr: errors.New("upgrade-registry.provider.registry.image \"ghcr.io/Cosmos/gaia\" is invalid: invalid reference format: repository name (Cosmos/gaia) must be lowercase"),
+		},
+		{
+			name:        "InvalidTimeout",
+			modify:      func(r *RegistryProvider) { r.Timeout = 0 },
+			expectedErr: errors.New("upgrade-registry.provider.registry.timeout cannot be less than or equal to 0"),
+		},
+		{
+			name:        "InvalidUpgradeNameRegex",
+			modify:      func(r *RegistryProvider) { r.Rules[0].UpgradeName = "v(" },
+			expectedErr: errors.New("upgrade-registry.provider.registry.rules[0].upgrade-name \"v(\" is invalid: error parsing regexp: missing closing ): `v(`"),
+		},
+		{
+			name:        "EmptyTag",
+			modify:      func(r *RegistryProvider) { r.Rules[0].Tag = "" },
+			expectedErr: errors.New("upgrade-registry.provider.registry.rules[0].tag cannot be empty"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := &Config{}
+			cfg.UpgradeRegistry.Provider.Registry = valid()
+			test.modify(cfg.UpgradeRegistry.Provider.Registry)
+
+			if err := cfg.ValidateRegistryProvider(); test.expectedErr != nil {
+				assert.Equal(t, test.expectedErr.Error(), err.Error())
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateChainRegistryProvider(t *testing.T) {
+	registryPath := t.TempDir()
+	valid := func() *ChainRegistryProvider {
+		return &ChainRegistryProvider{
+			DefaultPriority: 1,
+			Path:            registryPath,
+			Rules:           []ChainRegistryRule{{Regex: `^v(?P<version>.*)$`, Template: "{{version}}-alpine"}},
+		}
+	}
+
+	tests := []struct {
+		name        string
+		modify      func(*ChainRegistryProvider)
+		expectedErr error
+	}{
+		{
+			name:        "Valid",
+			modify:      func(*ChainRegistryProvider) {},
+			expectedErr: nil,
+		},
+		{
+			name:        "InvalidPriority",
+			modify:      func(c *ChainRegistryProvider) { c.DefaultPriority = 0 },
+			expectedErr: errors.New("upgrade-registry.provider.chain-registry.default-priority must be between 1 and 99"),
+		},
+		{
+			name:        "RelativePath",
+			modify:      func(c *ChainRegistryProvider) { c.Path = "chain-registry" },
+			expectedErr: errors.New("error validating upgrade-registry.provider.chain-registry.path: \"chain-registry\" must be an absolute path"),
+		},
+		{
+			name:        "NegativePollInterval",
+			modify:      func(c *ChainRegistryProvider) { c.PollInterval = -time.Second },
+			expectedErr: errors.New("upgrade-registry.provider.chain-registry.poll-interval cannot be less than 0"),
+		},
+		{
+			name:        "InvalidRegex",
+			modify:      func(c *ChainRegistryProvider) { c.Rules[0].Regex = "v(" },
+			expectedErr: errors.New("upgrade-registry.provider.chain-registry.rules[0].regex \"v(\" is invalid: error parsing regexp: missing closing ): `v(`"),
+		},
+		{
+			name:        "EmptyTemplate",
+			modify:      func(c *ChainRegistryProvider) { c.Rules[0].Template = "" },
+			expectedErr: errors.New("upgrade-registry.provider.chain-registry.rules[0].template cannot be empty"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := &Config{}
+			cfg.UpgradeRegistry.Provider.ChainRegistry = valid()
+			test.modify(cfg.UpgradeRegistry.Provider.ChainRegistry)
+
+			if err := cfg.ValidateChainRegistryProvider(); test.expectedErr != nil {
+				assert.Equal(t, test.expectedErr.Error(), err.Error())
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
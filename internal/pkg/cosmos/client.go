@@ -22,9 +22,13 @@ import (
 	"github.com/cosmos/cosmos-sdk/codec"
 	"github.com/cosmos/cosmos-sdk/codec/types"
 	"github.com/cosmos/cosmos-sdk/std"
+	"github.com/cosmos/cosmos-sdk/types/bech32"
 	"github.com/cosmos/cosmos-sdk/types/query"
 	v1 "github.com/cosmos/cosmos-sdk/x/gov/types/v1"
 	"github.com/cosmos/cosmos-sdk/x/gov/types/v1beta1"
+	slashingtypes "github.com/cosmos/cosmos-sdk/x/slashing/types"
+	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+	upgradetypes "github.com/cosmos/cosmos-sdk/x/upgrade/types"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 )
@@ -35,6 +39,9 @@ type Client struct {
 	tmClient       tmservice.ServiceClient
 	v1Client       v1.QueryClient
 	v1beta1Client  v1beta1.QueryClient
+	stakingClient  stakingtypes.QueryClient
+	slashingClient slashingtypes.QueryClient
+	upgradeClient  upgradetypes.QueryClient
 	cometbftClient *cometbft.HTTP
 
 	isCometbftStarted bool
@@ -52,6 +59,9 @@ func NewCosmosGrpcOnlyClient(host string, grpcPort uint16, timeout time.Duration
 		tmClient:        tmservice.NewServiceClient(grpcConn),
 		v1Client:        v1.NewQueryClient(grpcConn),
 		v1beta1Client:   v1beta1.NewQueryClient(grpcConn),
+		stakingClient:   stakingtypes.NewQueryClient(grpcConn),
+		slashingClient:  slashingtypes.NewQueryClient(grpcConn),
+		upgradeClient:   upgradetypes.NewQueryClient(grpcConn),
 		timeout:         timeout,
 		paginationLimit: defaultPaginationLimit,
 		// https://github.com/cosmos/cosmos-sdk/blob/a86c2a9980ffc4fed1f8c423889e0628193ffaab/server/config/config.go#L140
@@ -74,6 +84,9 @@ func NewClient(host string, grpcPort uint16, cometbftPort uint16, timeout time.D
 		tmClient:        tmservice.NewServiceClient(grpcConn),
 		v1Client:        v1.NewQueryClient(grpcConn),
 		v1beta1Client:   v1beta1.NewQueryClient(grpcConn),
+		stakingClient:   stakingtypes.NewQueryClient(grpcConn),
+		slashingClient:  slashingtypes.NewQueryClient(grpcConn),
+		upgradeClient:   upgradetypes.NewQueryClient(grpcConn),
 		cometbftClient:  cometbftClient,
 		timeout:         timeout,
 		paginationLimit: defaultPaginationLimit,
@@ -178,6 +191,7 @@ type StatusResponse struct {
 	SyncInfo struct {
 		LatestBlockTime   string `json:"latest_block_time"`
 		LatestBlockHeight int64  `json:"latest_block_height,string"`
+		CatchingUp        bool   `json:"catching_up"`
 	} `json:"sync_info"`
 }
 
@@ -238,6 +252,167 @@ func (cc *Client) NodeInfo(ctx context.Context) (*tmservice.GetNodeInfoResponse,
 	return response, nil
 }
 
+// AppVersion is the software version reported by the node
+type AppVersion struct {
+	Version   string `json:"version"`
+	GitCommit string `json:"git_commit"`
+	// ABCI app_version (protocol version), nil if unknown
+	AppVersion *uint64 `json:"app_version,omitempty"`
+}
+
+// GetAppVersion returns the application version and commit from the node info, and the app_version from the ABCI info
+func (cc *Client) GetAppVersion(ctx context.Context) (*AppVersion, error) {
+	ctx, cancel := context.WithTimeout(ctx, cc.timeout)
+	defer cancel()
+
+	nodeInfo, err := cc.NodeInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	abciInfo, err := cc.cometbftClient.ABCIInfo(ctx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get abci info")
+	}
+
+	return &AppVersion{
+		Version:    nodeInfo.ApplicationVersion.Version,
+		GitCommit:  nodeInfo.ApplicationVersion.GitCommit,
+		AppVersion: &abciInfo.Response.AppVersion,
+	}, nil
+}
+
+// GetPeerCount returns the number of peers the node is connected to
+func (cc *Client) GetPeerCount(ctx context.Context) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, cc.timeout)
+	defer cancel()
+
+	netInfo, err := cc.cometbftClient.NetInfo(ctx)
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to get net info")
+	}
+	return netInfo.NPeers, nil
+}
+
+// GetCurrentPlan returns the upgrade plan scheduled in the x/upgrade module, nil if there is none
+func (cc *Client) GetCurrentPlan(ctx context.Context) (*upgradetypes.Plan, error) {
+	ctx, cancel := context.WithTimeout(ctx, cc.timeout)
+	defer cancel()
+
+	res, err := cc.upgradeClient.CurrentPlan(ctx, &upgradetypes.QueryCurrentPlanRequest{}, cc.callOptions...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get current plan")
+	}
+	return res.Plan, nil
+}
+
+// GetAppliedPlanHeight returns the height at which the upgrade plan was applied, 0 if it wasn't applied (yet)
+func (cc *Client) GetAppliedPlanHeight(ctx context.Context, name string) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, cc.timeout)
+	defer cancel()
+
+	res, err := cc.upgradeClient.AppliedPlan(ctx, &upgradetypes.QueryAppliedPlanRequest{Name: name}, cc.callOptions...)
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to get applied plan %s", name)
+	}
+	return res.Height, nil
+}
+
+// GetModuleVersions returns the consensus version of every module (module name -> version)
+func (cc *Client) GetModuleVersions(ctx context.Context) (map[string]uint64, error) {
+	ctx, cancel := context.WithTimeout(ctx, cc.timeout)
+	defer cancel()
+
+	res, err := cc.upgradeClient.ModuleVersions(ctx, &upgradetypes.QueryModuleVersionsRequest{}, cc.callOptions...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get module versions")
+	}
+
+	versions := make(map[string]uint64, len(res.ModuleVersions))
+	for _, moduleVersion := range res.ModuleVersions {
+		versions[moduleVersion.Name] = moduleVersion.Version
+	}
+	return versions, nil
+}
+
+type ValidatorStatus struct {
+	OperatorAddress string
+	Jailed          bool
+	Tombstoned      bool
+}
+
+// GetValidatorStatus returns the staking and slashing status of the validator with the consensus address
+// (as reported in the /status validator_info), or nil if there is no such validator
+func (cc *Client) GetValidatorStatus(ctx context.Context, consAddress []byte) (*ValidatorStatus, error) {
+	validator, err := cc.findValidator(ctx, consAddress)
+	if err != nil || validator == nil {
+		return nil, err
+	}
+
+	// the consensus address prefix is not known upfront, but it follows the operator address one (e.g cosmosvaloper -> cosmosvalcons)
+	hrp, _, err := bech32.DecodeAndConvert(validator.OperatorAddress)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to decode operator address %s", validator.OperatorAddress)
+	}
+	consBech32, err := bech32.ConvertAndEncode(strings.TrimSuffix(hrp, "valoper")+"valcons", consAddress)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to encode consensus address")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cc.timeout)
+	defer cancel()
+
+	res, err := cc.slashingClient.SigningInfo(ctx, &slashingtypes.QuerySigningInfoRequest{ConsAddress: consBech32}, cc.callOptions...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get signing info of %s", consBech32)
+	}
+
+	return &ValidatorStatus{
+		OperatorAddress: validator.OperatorAddress,
+		Jailed:          validator.Jailed,
+		Tombstoned:      res.ValSigningInfo.Tombstoned,
+	}, nil
+}
+
+func (cc *Client) findValidator(ctx context.Context, consAddress []byte) (*stakingtypes.Validator, error) {
+	registry := getCodec().InterfaceRegistry()
+
+	var key []byte
+	for {
+		pageCtx, cancel := context.WithTimeout(ctx, cc.timeout)
+		// the empty status lists bonded, unbonding and unbonded (e.g jailed) validators
+		res, err := cc.stakingClient.Validators(pageCtx, &stakingtypes.QueryValidatorsRequest{
+			Pagination: &query.PageRequest{
+				Key:   key,
+				Limit: cc.paginationLimit,
+			},
+		}, cc.callOptions...)
+		cancel()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get validators")
+		}
+
+		for i := range res.Validators {
+			validator := &res.Validators[i]
+			if err := validator.UnpackInterfaces(registry); err != nil {
+				return nil, errors.Wrapf(err, "failed to unpack consensus pubkey of %s", validator.OperatorAddress)
+			}
+
+			addr, err := validator.GetConsAddr()
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to get consensus address of %s", validator.OperatorAddress)
+			}
+			if bytes.Equal(addr, consAddress) {
+				return validator, nil
+			}
+		}
+
+		if key = res.Pagination.NextKey; key == nil {
+			return nil, nil
+		}
+	}
+}
+
 func (cc *Client) GetCometbftClient() *cometbft.HTTP {
 	return cc.cometbftClient
 }
@@ -0,0 +1,54 @@
+package daemon
+
+import (
+	"context"
+
+	"blazar/internal/pkg/backup"
+	"blazar/internal/pkg/config"
+	"blazar/internal/pkg/errors"
+	"blazar/internal/pkg/log"
+	checksproto "blazar/internal/pkg/proto/daemon"
+)
+
+// backupData archives the configured chain-home paths. It must be called when the node is stopped.
+// The backup is taken only once per upgrade, a restarted blazar reuses the archive recorded in the state machine
+func (d *Daemon) backupData(ctx context.Context, cfg *config.BackupData, chainHome string, upgradeHeight int64) error {
+	logger := log.FromContext(ctx)
+
+	if d.dryRun {
+		logger.Infof("[dry-run] Skipping backup of %v from %s into %s", cfg.Paths, chainHome, cfg.BackupDir)
+		return nil
+	}
+
+	status := d.stateMachine.GetPreCheckStatus(upgradeHeight, checksproto.PreCheck_BACKUP_DATA)
+	if status == checksproto.CheckStatus_FINISHED {
+		logger.Infof("Chain data backup already taken: %s, skipping", d.stateMachine.GetBackup(upgradeHeight)).Notify(ctx)
+		return nil
+	}
+
+	d.SetPreCheckStatus(upgradeHeight, checksproto.PreCheck_BACKUP_DATA, checksproto.CheckStatus_RUNNING)
+	logger.Infof(
+		"Pre upgrade step: %s archiving %v from %s into %s",
+		checksproto.PreCheck_BACKUP_DATA.String(), cfg.Paths, chainHome, cfg.BackupDir,
+	).Notify(ctx)
+
+	archivePath, err := backup.Create(ctx, chainHome, cfg.Paths, cfg.BackupDir, upgradeHeight)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create backup")
+	}
+
+	d.stateMachine.SetBackup(upgradeHeight, archivePath)
+	d.SetPreCheckStatus(upgradeHeight, checksproto.PreCheck_BACKUP_DATA, checksproto.CheckStatus_FINISHED)
+
+	logger.Infof("Chain data backup created: %s", archivePath).Notify(ctx)
+
+	// failing to remove old backups shouldn't stop the upgrade
+	removed, err := backup.Prune(cfg.BackupDir, cfg.Retention)
+	if err != nil {
+		logger.Err(err).Warn("Failed to remove old backups")
+	} else if len(removed) > 0 {
+		logger.Infof("Removed old backups: %v", removed)
+	}
+
+	return nil
+}
@@ -2,12 +2,15 @@ package daemon
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"slices"
+	"strings"
 	"time"
 
 	"blazar/internal/pkg/config"
+	"blazar/internal/pkg/cosmos"
 	"blazar/internal/pkg/daemon/checks"
-	"blazar/internal/pkg/docker"
 	"blazar/internal/pkg/errors"
 	"blazar/internal/pkg/log"
 	"blazar/internal/pkg/log/notification"
@@ -20,7 +23,7 @@ func (d *Daemon) preUpgradeChecks(
 	ctx context.Context,
 	currHeight int64,
 	sm *state_machine.StateMachine,
-	dcc *docker.ComposeClient,
+	executor Executor,
 	composeConfig *config.ComposeCli,
 	cfg *config.PreUpgrade,
 	serviceName string,
@@ -42,6 +45,9 @@ func (d *Daemon) preUpgradeChecks(
 			upgrade.Type, upgrade.Tag, networkName, currHeight, upgrade.Height,
 		).Notify(ctx)
 
+		// the node halts at the upgrade height, so the module versions are recorded in advance
+		d.snapshotModuleVersions(ctx, upgrade.Height)
+
 		if len(cfg.Enabled) == 0 {
 			logger.Info("No pre upgrade checks configured, skipping").Notify(ctx)
 		} else {
@@ -79,14 +85,52 @@ func (d *Daemon) preUpgradeChecks(
 				checksproto.PreCheck_PULL_DOCKER_IMAGE.String(), upgrade.Tag,
 			).Notify(ctx)
 
-			_, newImage, err := checks.PullDockerImage(ctx, d.dcc, serviceName, upgrade.Tag, upgrade.Height,
-				cfg.PullDockerImage.MaxRetries, cfg.PullDockerImage.InitialBackoff)
-			d.reportPreUpgradeRoutine(ctx, upgrade, newImage, err)
+			_, newImage, err := executor.PrepareUpgrade(ctx, serviceName, upgrade, cfg.PullDockerImage)
+
+			// pin the image, the tag may be re-pushed before the upgrade height. Only the image of the main service is
+			// pinned, the images of the services upgraded together with it are not verified
+			digest := ""
+			if err == nil {
+				if digest, err = executor.ArtifactDigest(ctx, newImage); err != nil {
+					logger.Err(err).Warnf("Failed to get the digest of %s, it won't be verified during the upgrade", newImage)
+					digest, err = "", nil
+				} else {
+					d.stateMachine.SetImageDigest(upgrade.Height, newImage, digest)
+				}
+			}
+			d.reportPreUpgradeRoutine(ctx, upgrade, newImage, digest, err)
 
 			d.SetPreCheckStatus(upgrade.Height, checksproto.PreCheck_PULL_DOCKER_IMAGE, checksproto.CheckStatus_FINISHED)
 		}
 	}
 
+	if slices.Contains(cfg.Enabled, checksproto.PreCheck_VERIFY_BINARY_VERSION.String()) {
+		status := sm.GetPreCheckStatus(upgrade.Height, checksproto.PreCheck_VERIFY_BINARY_VERSION)
+		if (status != checksproto.CheckStatus_FINISHED && status != checksproto.CheckStatus_ERROR) || d.isBinaryVersionCheckStale(upgrade) {
+			d.checkBinaryVersion(ctx, executor, cfg, serviceName, upgrade)
+		}
+	}
+
+	if slices.Contains(cfg.Enabled, checksproto.PreCheck_NODE_SYNC_STATUS.String()) {
+		status := sm.GetPreCheckStatus(upgrade.Height, checksproto.PreCheck_NODE_SYNC_STATUS)
+		if status != checksproto.CheckStatus_FINISHED {
+			if status != checksproto.CheckStatus_RUNNING {
+				d.SetPreCheckStatus(upgrade.Height, checksproto.PreCheck_NODE_SYNC_STATUS, checksproto.CheckStatus_RUNNING)
+
+				logger.Infof(
+					"Pre upgrade check: %s Checking if the node is synced, connected to peers and our validator is not jailed",
+					checksproto.PreCheck_NODE_SYNC_STATUS.String(),
+				).Notify(ctx)
+			}
+
+			// the check stays RUNNING (and is re-evaluated on the next block) until no blocking issue is left
+			if blocked := d.checkNodeSyncStatus(ctx, cfg.NodeSyncStatus, upgrade); blocked {
+				return 0, nil
+			}
+			d.SetPreCheckStatus(upgrade.Height, checksproto.PreCheck_NODE_SYNC_STATUS, checksproto.CheckStatus_FINISHED)
+		}
+	}
+
 	if slices.Contains(cfg.Enabled, checksproto.PreCheck_SET_HALT_HEIGHT.String()) {
 		status := sm.GetPreCheckStatus(upgrade.Height, checksproto.PreCheck_SET_HALT_HEIGHT)
 		shouldRun := upgrade.Height <= currHeight+(cfg.Blocks-cfg.SetHaltHeight.DelayBlocks)
@@ -100,7 +144,7 @@ func (d *Daemon) preUpgradeChecks(
 					checksproto.PreCheck_SET_HALT_HEIGHT.String(), upgrade.Height,
 				).Notify(ctx)
 
-				err := dcc.RestartServiceWithHaltHeight(ctx, composeConfig, serviceName, upgrade.Height)
+				err := executor.RestartServiceWithHaltHeight(ctx, composeConfig, serviceName, upgrade.Height)
 				d.reportPreUpgradeHaltHeight(ctx, upgrade, err)
 			} else {
 				logger.Infof(
@@ -116,6 +160,12 @@ func (d *Daemon) preUpgradeChecks(
 		// The trick is that blazar won't receive the block at the upgrade height, because the node will shutdown (depends on the cosmos-sdk version)
 		// Instead we are waiting for the block prior to the upgrade height and then try to assert if the node is still running
 		if upgrade.Type == urproto.UpgradeType_NON_GOVERNANCE_COORDINATED && status == checksproto.CheckStatus_FINISHED && currHeight == upgrade.Height-1 {
+			// the service wasn't restarted with the halt height, it won't stop itself
+			if d.dryRun {
+				logger.Infof("[dry-run] Got block %d, not waiting for the service to stop itself, continuing with the upgrade", currHeight).Notify(ctx)
+				return upgrade.Height, nil
+			}
+
 			ticker := time.NewTicker(time.Second)
 			start := time.Now()
 			countSameUpgradeHeights, countSameUpgradePlusHeights := 0, 0
@@ -125,7 +175,7 @@ func (d *Daemon) preUpgradeChecks(
 			for range ticker.C {
 				logger.Info("Checking if the service has stopped itself")
 
-				isRunning, err := dcc.IsServiceRunning(ctx, serviceName, 5*time.Second)
+				isRunning, err := executor.IsServiceRunning(ctx, serviceName, 5*time.Second)
 				if err != nil {
 					return 0, err
 				}
@@ -170,6 +220,42 @@ func (d *Daemon) preUpgradeChecks(
 	return 0, nil
 }
 
+// checkNodeSyncStatus reports the NODE_SYNC_STATUS issues and returns true if any of them blocks the following pre-upgrade checks
+func (d *Daemon) checkNodeSyncStatus(ctx context.Context, cfg *config.NodeSyncStatus, upgrade *urproto.Upgrade) bool {
+	logger := log.FromContext(ctx)
+
+	state := checks.GetNodeSyncState(ctx, d.cosmosClient, cfg)
+	issues := checks.EvaluateNodeSyncStatus(state, cfg)
+
+	blocked, lines := false, make([]string, 0, len(issues))
+	for _, issue := range issues {
+		blocked = blocked || issue.Severity == config.SeverityBlock
+		lines = append(lines, fmt.Sprintf("[%s] %s", issue.Severity, issue.Message))
+	}
+
+	report := fmt.Sprintf("%d:%s", upgrade.Height, strings.Join(lines, "\n"))
+	if report == d.lastSyncStatusReport {
+		if blocked {
+			logger.Warnf("Node sync status issues persist, holding the pre upgrade checks:\n%s", strings.Join(lines, "\n"))
+		}
+		return blocked
+	}
+	d.lastSyncStatusReport = report
+
+	switch {
+	case blocked:
+		logger.Warnf(
+			"Pre upgrade check %s failed, holding the remaining pre upgrade checks until resolved:\n%s",
+			checksproto.PreCheck_NODE_SYNC_STATUS.String(), strings.Join(lines, "\n"),
+		).Notify(ctx)
+	case len(issues) > 0:
+		logger.Warnf("Pre upgrade check %s reported issues:\n%s", checksproto.PreCheck_NODE_SYNC_STATUS.String(), strings.Join(lines, "\n")).Notify(ctx)
+	default:
+		logger.Info("Node sync status is fine, continuing with the pre upgrade checks").Notify(ctx)
+	}
+	return blocked
+}
+
 func (d *Daemon) reportPreUpgradeHaltHeight(ctx context.Context, upgrade *urproto.Upgrade, err error) {
 	ctx = notification.WithUpgradeHeight(ctx, upgrade.Height)
 	logger := log.FromContext(ctx)
@@ -181,7 +267,102 @@ func (d *Daemon) reportPreUpgradeHaltHeight(ctx context.Context, upgrade *urprot
 	}
 }
 
-func (d *Daemon) reportPreUpgradeRoutine(ctx context.Context, upgrade *urproto.Upgrade, newImage string, err error) {
+// verifyBinaryVersion runs the version command from the upgrade artifact and compares the reported
+// version with the upgrade tag, or the expected version registered with the upgrade
+// checkBinaryVersion runs the VERIFY_BINARY_VERSION check and records the tag and expected version it was run for
+func (d *Daemon) checkBinaryVersion(ctx context.Context, executor Executor, cfg *config.PreUpgrade, serviceName string, upgrade *urproto.Upgrade) {
+	d.SetPreCheckStatus(upgrade.Height, checksproto.PreCheck_VERIFY_BINARY_VERSION, checksproto.CheckStatus_RUNNING)
+
+	log.FromContext(ctx).Infof(
+		"Pre upgrade check: %s Checking if the binary in upgrade tag %s reports the expected version",
+		checksproto.PreCheck_VERIFY_BINARY_VERSION.String(), upgrade.Tag,
+	).Notify(ctx)
+
+	version, err := d.verifyBinaryVersion(ctx, executor, cfg, serviceName, upgrade)
+	blocked := d.reportPreUpgradeBinaryVersion(ctx, cfg.VerifyBinaryVersion, upgrade, version, err)
+
+	// the upgrade is refused if the wrong binary would be started at the upgrade height
+	checkStatus := checksproto.CheckStatus_FINISHED
+	if blocked {
+		checkStatus = checksproto.CheckStatus_ERROR
+	}
+	d.stateMachine.SetBinaryVersionCheck(upgrade.Height, binaryVersionCheckKey(upgrade))
+	d.SetPreCheckStatus(upgrade.Height, checksproto.PreCheck_VERIFY_BINARY_VERSION, checkStatus)
+}
+
+// isBinaryVersionCheckStale reports whether the VERIFY_BINARY_VERSION result was computed for another tag or expected
+// version, e.g. the upgrade was re-registered once the binary was found to report the wrong version
+func (d *Daemon) isBinaryVersionCheckStale(upgrade *urproto.Upgrade) bool {
+	status := d.stateMachine.GetPreCheckStatus(upgrade.Height, checksproto.PreCheck_VERIFY_BINARY_VERSION)
+	if status != checksproto.CheckStatus_FINISHED && status != checksproto.CheckStatus_ERROR {
+		return false
+	}
+	return d.stateMachine.GetBinaryVersionCheck(upgrade.Height) != binaryVersionCheckKey(upgrade)
+}
+
+func binaryVersionCheckKey(upgrade *urproto.Upgrade) string {
+	return fmt.Sprintf("%s (expected version: %s)", upgrade.Tag, upgrade.ExpectedVersion)
+}
+
+func (d *Daemon) verifyBinaryVersion(ctx context.Context, executor Executor, cfg *config.PreUpgrade, serviceName string, upgrade *urproto.Upgrade) (*checks.BinaryVersion, error) {
+	if d.dryRun {
+		log.FromContext(ctx).Info("[dry-run] Skipping binary version verification, the upgrade image is not pulled")
+		return nil, nil
+	}
+
+	// no-op if the image was already pulled by the PULL_DOCKER_IMAGE check
+	_, newArtifact, err := executor.PrepareUpgrade(ctx, serviceName, upgrade, cfg.PullDockerImage)
+	if err != nil {
+		return nil, err
+	}
+
+	appName := cfg.VerifyBinaryVersion.AppName
+	if appName == "" {
+		nodeInfo, err := d.cosmosClient.NodeInfo(ctx)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get the app name from the node, set checks.pre-upgrade.verify-binary-version.app-name")
+		}
+		appName = nodeInfo.ApplicationVersion.AppName
+	}
+
+	output, err := executor.BinaryVersion(ctx, newArtifact, appName, cfg.VerifyBinaryVersion.Timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := checks.ParseBinaryVersion(output)
+	if err != nil {
+		return nil, err
+	}
+
+	return version, checks.MatchBinaryVersion(version, upgrade.Tag, upgrade.ExpectedVersion)
+}
+
+// reportPreUpgradeBinaryVersion notifies about the VERIFY_BINARY_VERSION result and reports whether the upgrade is blocked.
+// The binary reporting a different version is a mismatch, the failure to run it only warns
+func (d *Daemon) reportPreUpgradeBinaryVersion(ctx context.Context, cfg *config.VerifyBinaryVersion, upgrade *urproto.Upgrade, version *checks.BinaryVersion, err error) bool {
+	ctx = notification.WithUpgradeHeight(ctx, upgrade.Height)
+	logger := log.FromContext(ctx)
+
+	switch {
+	case version != nil && err != nil && cfg.GetOnMismatch() == config.SeverityBlock:
+		logger.Errorf(
+			err, "Pre upgrade check %s failed, the upgrade tag %s points to a different build. I'll not perform the upgrade, please register the correct tag",
+			checksproto.PreCheck_VERIFY_BINARY_VERSION.String(), upgrade.Tag,
+		).Notify(ctx)
+		return true
+	case err != nil:
+		logger.Err(err).Warnf(
+			"Pre upgrade check %s failed, the upgrade tag %s may point to a mislabelled build. Please check the image before the upgrade height is hit",
+			checksproto.PreCheck_VERIFY_BINARY_VERSION.String(), upgrade.Tag,
+		).Notify(ctx)
+	case version != nil:
+		logger.Infof("Upgrade binary reports the expected version: %s, commit: %s", version.Version, version.Commit).Notify(ctx)
+	}
+	return false
+}
+
+func (d *Daemon) reportPreUpgradeRoutine(ctx context.Context, upgrade *urproto.Upgrade, newImage, digest string, err error) {
 	ctx = notification.WithUpgradeHeight(ctx, upgrade.Height)
 	logger := log.FromContext(ctx)
 
@@ -194,11 +375,11 @@ func (d *Daemon) reportPreUpgradeRoutine(ctx context.Context, upgrade *urproto.U
 		}
 		logger.Err(err).Warn(msg).Notify(ctx)
 	} else {
-		logger.Infof("Upgrade image: %s\nI'll attempt to upgrade when upgrade height is hit", newImage).Notify(ctx)
+		logger.Infof("Upgrade image: %s\nPinned digest: %s\nI'll attempt to upgrade when upgrade height is hit", newImage, digest).Notify(ctx)
 	}
 }
 
-func (d *Daemon) postUpgradeChecks(ctx context.Context, sm *state_machine.StateMachine, cfg *config.PostUpgrade, upgradeHeight int64) (err error) {
+func (d *Daemon) postUpgradeChecks(ctx context.Context, sm *state_machine.StateMachine, cfg *config.PostUpgrade, serviceName string, upgradeHeight int64) (err error) {
 	defer func() {
 		// ensure we update the status to failed if any error was encountered
 		if err != nil {
@@ -231,6 +412,32 @@ func (d *Daemon) postUpgradeChecks(ctx context.Context, sm *state_machine.StateM
 		return nil
 	}
 
+	// the logs are followed in the background, while the other checks run
+	if slices.Contains(cfg.Enabled, checksproto.PostCheck_NO_FATAL_LOGS.String()) {
+		status := sm.GetPostCheckStatus(upgradeHeight, checksproto.PostCheck_NO_FATAL_LOGS)
+		if status != checksproto.CheckStatus_FINISHED {
+			d.SetPostCheckStatus(upgradeHeight, checksproto.PostCheck_NO_FATAL_LOGS, checksproto.CheckStatus_RUNNING)
+
+			logger.Infof(
+				"Post upgrade check: %s Following the %s logs for %s, looking for %d fatal patterns",
+				checksproto.PostCheck_NO_FATAL_LOGS.String(), serviceName, cfg.NoFatalLogs.Duration.String(), len(cfg.NoFatalLogs.Patterns),
+			).Notify(ctx)
+
+			var waitNoFatalLogs func(abort bool) error
+			ctx, waitNoFatalLogs = d.watchFatalLogs(ctx, cfg.NoFatalLogs, serviceName)
+			defer func() {
+				// no need to follow the logs any longer if another check failed
+				logsErr := waitNoFatalLogs(err != nil)
+				d.SetPostCheckStatus(upgradeHeight, checksproto.PostCheck_NO_FATAL_LOGS, checksproto.CheckStatus_FINISHED)
+
+				// a matching log line is more telling than the other checks timing out or being cancelled
+				if logsErr != nil && !errors.Is(logsErr, context.Canceled) {
+					err = errors.Wrapf(logsErr, "post upgrade no-fatal-logs check failed")
+				}
+			}()
+		}
+	}
+
 	if slices.Contains(cfg.Enabled, checksproto.PostCheck_GRPC_RESPONSIVE.String()) {
 		status := sm.GetPostCheckStatus(upgradeHeight, checksproto.PostCheck_GRPC_RESPONSIVE)
 		if status != checksproto.CheckStatus_FINISHED {
@@ -247,6 +454,31 @@ func (d *Daemon) postUpgradeChecks(ctx context.Context, sm *state_machine.StateM
 		}
 	}
 
+	if slices.Contains(cfg.Enabled, checksproto.PostCheck_APP_VERSION_MATCHES.String()) {
+		status := sm.GetPostCheckStatus(upgradeHeight, checksproto.PostCheck_APP_VERSION_MATCHES)
+		if status != checksproto.CheckStatus_FINISHED {
+			d.SetPostCheckStatus(upgradeHeight, checksproto.PostCheck_APP_VERSION_MATCHES, checksproto.CheckStatus_RUNNING)
+
+			logger.Infof("Post upgrade check: %s Waiting for the node to report the upgraded app version", checksproto.PostCheck_APP_VERSION_MATCHES.String()).Notify(ctx)
+
+			upgrade := d.ur.GetUpgradeWithCache(upgradeHeight)
+			if upgrade == nil {
+				err = fmt.Errorf("upgrade with height %d not found", upgradeHeight)
+			} else {
+				var before *cosmos.AppVersion
+				if stored := sm.GetAppVersion(upgradeHeight); stored != nil {
+					before = &cosmos.AppVersion{Version: stored.Version, GitCommit: stored.GitCommit, AppVersion: stored.AppVersion}
+				}
+				err = checks.AppVersionMatches(ctx, d.cosmosClient, cfg.AppVersionMatches, before, upgrade.Tag, upgrade.ExpectedVersion)
+			}
+			d.SetPostCheckStatus(upgradeHeight, checksproto.PostCheck_APP_VERSION_MATCHES, checksproto.CheckStatus_FINISHED)
+
+			if err != nil {
+				return errors.Wrapf(err, "post upgrade app-version-matches check failed")
+			}
+		}
+	}
+
 	if slices.Contains(cfg.Enabled, checksproto.PostCheck_FIRST_BLOCK_VOTED.String()) {
 		status := sm.GetPostCheckStatus(upgradeHeight, checksproto.PostCheck_FIRST_BLOCK_VOTED)
 		if status != checksproto.CheckStatus_FINISHED {
@@ -281,5 +513,156 @@ func (d *Daemon) postUpgradeChecks(ctx context.Context, sm *state_machine.StateM
 			}
 		}
 	}
+
+	if slices.Contains(cfg.Enabled, checksproto.PostCheck_UPGRADE_PLAN_APPLIED.String()) {
+		status := sm.GetPostCheckStatus(upgradeHeight, checksproto.PostCheck_UPGRADE_PLAN_APPLIED)
+		if status != checksproto.CheckStatus_FINISHED {
+			d.SetPostCheckStatus(upgradeHeight, checksproto.PostCheck_UPGRADE_PLAN_APPLIED, checksproto.CheckStatus_RUNNING)
+
+			err = d.upgradePlanApplied(ctx, sm, cfg.UpgradePlanApplied, upgradeHeight)
+			d.SetPostCheckStatus(upgradeHeight, checksproto.PostCheck_UPGRADE_PLAN_APPLIED, checksproto.CheckStatus_FINISHED)
+
+			if err != nil {
+				return errors.Wrapf(err, "post upgrade upgrade-plan-applied check failed")
+			}
+		}
+	}
 	return nil
 }
+
+// upgradePlanApplied runs the UPGRADE_PLAN_APPLIED check for governance upgrades and records the module versions
+// changed by the upgrade
+func (d *Daemon) upgradePlanApplied(ctx context.Context, sm *state_machine.StateMachine, cfg *config.UpgradePlanApplied, upgradeHeight int64) error {
+	logger := log.FromContext(ctx)
+
+	upgrade := d.ur.GetUpgradeWithCache(upgradeHeight)
+	if upgrade == nil {
+		return fmt.Errorf("upgrade with height %d not found", upgradeHeight)
+	}
+
+	// only governance upgrades are registered in the x/upgrade module
+	if upgrade.Type != urproto.UpgradeType_GOVERNANCE {
+		logger.Infof("Post upgrade check: %s Skipping, the upgrade type is %s", checksproto.PostCheck_UPGRADE_PLAN_APPLIED.String(), upgrade.Type.String()).Notify(ctx)
+		return nil
+	}
+
+	logger.Infof("Post upgrade check: %s Waiting for the upgrade plan %q to be applied at height=%d", checksproto.PostCheck_UPGRADE_PLAN_APPLIED.String(), upgrade.Name, upgradeHeight).Notify(ctx)
+
+	if err := checks.UpgradePlanApplied(ctx, d.cosmosClient, cfg, upgrade.Name, upgradeHeight); err != nil {
+		return err
+	}
+
+	after, err := d.cosmosClient.GetModuleVersions(ctx)
+	if err != nil {
+		// the upgrade did happen, the diff is informational only
+		logger.Err(err).Warn("Failed to get the module versions after the upgrade")
+		return nil
+	}
+	sm.SetModuleVersionsAfter(upgradeHeight, after)
+
+	before := sm.GetModuleVersions(upgradeHeight).Before
+	if before == nil {
+		logger.Warn("Module versions before the upgrade are unknown, can't tell which migrations ran").Notify(ctx)
+		return nil
+	}
+	logger.Infof("Module versions changed by the upgrade:\n%s", formatModuleVersionsDiff(checks.ModuleVersionsDiff(before, after))).Notify(ctx)
+
+	return nil
+}
+
+// snapshotModuleVersions records the module versions before the upgrade, a failure is not fatal as the diff is informational only.
+// The snapshot is taken once, the node may already run the new binary when the upgrade is resumed or performed again
+func (d *Daemon) snapshotModuleVersions(ctx context.Context, upgradeHeight int64) {
+	if d.stateMachine.GetModuleVersions(upgradeHeight).Before != nil {
+		return
+	}
+
+	versions, err := d.cosmosClient.GetModuleVersions(ctx)
+	if err != nil {
+		log.FromContext(ctx).Err(err).Warn("Failed to get the module versions before the upgrade")
+		return
+	}
+	d.stateMachine.SetModuleVersionsBefore(upgradeHeight, versions)
+}
+
+func formatModuleVersionsDiff(changes []*urproto.ModuleVersionChange) string {
+	if len(changes) == 0 {
+		return "none"
+	}
+
+	lines := make([]string, 0, len(changes))
+	for _, change := range changes {
+		lines = append(lines, fmt.Sprintf("%s: %d -> %d", change.Name, change.FromVersion, change.ToVersion))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// watchFatalLogs runs the NO_FATAL_LOGS check in the background. The returned context is cancelled as soon as the
+// check fails, so the other post-upgrade checks don't wait for their timeouts. The returned function waits for the
+// check result, stopping it right away if abort is set.
+func (d *Daemon) watchFatalLogs(ctx context.Context, cfg *config.NoFatalLogs, serviceName string) (context.Context, func(abort bool) error) {
+	checksCtx, cancelChecks := context.WithCancelCause(ctx)
+	logsCtx, cancelLogs := context.WithCancel(ctx)
+	errCh := make(chan error, 1)
+
+	go func() {
+		err := d.noFatalLogs(logsCtx, cfg, serviceName)
+		if err != nil {
+			cancelChecks(err)
+		}
+		errCh <- err
+	}()
+
+	return checksCtx, func(abort bool) error {
+		if abort {
+			cancelLogs()
+		}
+		err := <-errCh
+		cancelLogs()
+		cancelChecks(nil)
+		return err
+	}
+}
+
+// the container may still be starting after the upgrade, attaching to its logs is retried for a bounded time
+var (
+	fatalLogsAttachTimeout  = time.Minute
+	fatalLogsAttachInterval = 2 * time.Second
+)
+
+func (d *Daemon) noFatalLogs(ctx context.Context, cfg *config.NoFatalLogs, serviceName string) error {
+	logs, err := d.followLogs(ctx, serviceName)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		// no fatal log line was seen, the other post-upgrade checks tell whether the node is healthy
+		log.FromContext(ctx).Err(err).Warnf(
+			"Post upgrade check: %s skipped, the logs of service %s can't be followed", checksproto.PostCheck_NO_FATAL_LOGS.String(), serviceName,
+		).Notify(ctx)
+		return nil
+	}
+	defer logs.Close()
+
+	return checks.NoFatalLogs(ctx, logs, cfg)
+}
+
+// followLogs attaches to the logs of the service, retrying until fatalLogsAttachTimeout
+func (d *Daemon) followLogs(ctx context.Context, serviceName string) (io.ReadCloser, error) {
+	timeout := time.After(fatalLogsAttachTimeout)
+	for {
+		logs, err := d.executor.FollowLogs(ctx, serviceName)
+		if err == nil {
+			return logs, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-timeout:
+			return nil, errors.Wrapf(err, "failed to follow the logs of service %s within %s", serviceName, fatalLogsAttachTimeout)
+		case <-time.After(fatalLogsAttachInterval):
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package checks
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"blazar/internal/pkg/config"
+	"blazar/internal/pkg/errors"
+	"blazar/internal/pkg/log"
+)
+
+// cosmos-sdk nodes may log huge lines (e.g. whole blocks in debug mode), we keep the notification readable
+const maxLineLength = 500
+
+// the node may stop logging right after a consensus failure, so we don't wait forever for the lines following the match
+var afterContextTimeout = 5 * time.Second
+
+// FatalLogMatch is a log line matching one of the fatal patterns, together with the surrounding lines
+type FatalLogMatch struct {
+	Pattern string
+	Line    string
+
+	// lines around the match (the matching line included), in the order they were logged
+	Context []string
+}
+
+func (m *FatalLogMatch) String() string {
+	lines := make([]string, 0, len(m.Context))
+	for _, line := range m.Context {
+		if len(line) > maxLineLength {
+			line = line[:maxLineLength] + "..."
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// FindFatalLog reads the log stream line by line until a line matches one of the patterns. The match is returned
+// together with up to contextLines lines before and after it. If the stream ends without a match, nil is returned.
+func FindFatalLog(ctx context.Context, logs io.Reader, patterns []*regexp.Regexp, contextLines int) (*FatalLogMatch, error) {
+	// stops the reader goroutine once we are done
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	lines, errCh := readLines(ctx, logs)
+
+	before := make([]string, 0, contextLines)
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				return nil, <-errCh
+			}
+
+			for _, pattern := range patterns {
+				if pattern.MatchString(line) {
+					match := &FatalLogMatch{
+						Pattern: pattern.String(),
+						Line:    line,
+						Context: append(before, line),
+					}
+					match.Context = append(match.Context, collectLines(ctx, lines, contextLines)...)
+					return match, nil
+				}
+			}
+
+			if contextLines > 0 {
+				if len(before) == contextLines {
+					before = before[1:]
+				}
+				before = append(before, line)
+			}
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// NoFatalLogs follows the logs of the upgraded service for cfg.Duration and reports the first line matching
+// any of the fatal patterns. Depending on cfg.OnMatch the match fails the check or is only notified.
+func NoFatalLogs(ctx context.Context, logs io.Reader, cfg *config.NoFatalLogs) error {
+	logger := log.FromContext(ctx)
+
+	patterns := make([]*regexp.Regexp, 0, len(cfg.Patterns))
+	for _, pattern := range cfg.Patterns {
+		compiled, err := regexp.Compile(pattern)
+		if err != nil {
+			return errors.Wrapf(err, "invalid fatal log pattern %q", pattern)
+		}
+		patterns = append(patterns, compiled)
+	}
+
+	watchCtx, cancel := context.WithTimeout(ctx, cfg.Duration)
+	defer cancel()
+
+	match, err := FindFatalLog(watchCtx, logs, patterns, cfg.ContextLines)
+	switch {
+	case match != nil:
+		if cfg.OnMatch == config.LogMatchNotify {
+			logger.Warnf("Post upgrade check: log line matched fatal pattern %q, continuing as configured:\n%s", match.Pattern, match.String()).Notify(ctx)
+			return nil
+		}
+		logger.Error(fmt.Sprintf("Post upgrade check: log line matched fatal pattern %q:\n%s", match.Pattern, match.String())).Notify(ctx)
+		return fmt.Errorf("log line matched fatal pattern %q: %s, assuming upgrade failed", match.Pattern, match.Line)
+	case ctx.Err() != nil:
+		return errors.Wrapf(ctx.Err(), "fatal logs post-upgrade check cancelled due to context timeout")
+	case errors.Is(err, context.DeadlineExceeded):
+		logger.Infof("Post upgrade check passed, no fatal log lines observed in %s", cfg.Duration.String()).Notify(ctx)
+		return nil
+	case err != nil:
+		return errors.Wrapf(err, "failed to read the service logs")
+	default:
+		// the stream ends when the container stops, the other checks will tell whether the node is healthy
+		logger.Warn("Post upgrade check: the service logs ended before the check finished, no fatal log lines observed").Notify(ctx)
+		return nil
+	}
+}
+
+func readLines(ctx context.Context, logs io.Reader) (<-chan string, <-chan error) {
+	lines, errCh := make(chan string), make(chan error, 1)
+
+	go func() {
+		defer close(lines)
+
+		scanner := bufio.NewScanner(logs)
+		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			select {
+			case lines <- scanner.Text():
+			case <-ctx.Done():
+				errCh <- ctx.Err()
+				return
+			}
+		}
+		errCh <- scanner.Err()
+	}()
+
+	return lines, errCh
+}
+
+func collectLines(ctx context.Context, lines <-chan string, n int) []string {
+	collected := make([]string, 0, n)
+	timeout := time.NewTimer(afterContextTimeout)
+	defer timeout.Stop()
+
+	for len(collected) < n {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				return collected
+			}
+			collected = append(collected, line)
+		case <-timeout.C:
+			return collected
+		case <-ctx.Done():
+			return collected
+		}
+	}
+	return collected
+}
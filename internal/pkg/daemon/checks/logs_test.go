@@ -0,0 +1,118 @@
+package checks
+
+import (
+	"context"
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"blazar/internal/pkg/config"
+	"blazar/internal/pkg/log/logger"
+	"blazar/internal/pkg/log/notification"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindFatalLog(t *testing.T) {
+	patterns := []*regexp.Regexp{
+		regexp.MustCompile(`CONSENSUS FAILURE`),
+		regexp.MustCompile(`wrong Block\.Header\.AppHash`),
+	}
+
+	logs := strings.Join([]string{
+		"1:committed state height=99",
+		"2:committed state height=100",
+		"3:executed block height=101",
+		"4:CONSENSUS FAILURE!!! err=\"wrong Block.Header.AppHash. Expected 7A5B, got 9C1D\"",
+		"5:stack trace",
+		"6:service stop",
+		"7:node halted",
+	}, "\n")
+
+	match, err := FindFatalLog(context.Background(), strings.NewReader(logs), patterns, 2)
+	require.NoError(t, err)
+	require.NotNil(t, match)
+
+	assert.Equal(t, "CONSENSUS FAILURE", match.Pattern)
+	assert.True(t, strings.HasPrefix(match.Line, "4:"))
+	assert.Equal(t, []string{"2", "3", "4", "5", "6"}, lineNumbers(match.Context))
+
+	// no context requested
+	match, err = FindFatalLog(context.Background(), strings.NewReader(logs), patterns[1:], 0)
+	require.NoError(t, err)
+	require.NotNil(t, match)
+	assert.Equal(t, []string{"4"}, lineNumbers(match.Context))
+
+	// the stream ends without a match
+	match, err = FindFatalLog(context.Background(), strings.NewReader("1:all good\n2:still good"), patterns, 2)
+	require.NoError(t, err)
+	assert.Nil(t, match)
+}
+
+func TestFindFatalLogContextTimeout(t *testing.T) {
+	defer func(timeout time.Duration) { afterContextTimeout = timeout }(afterContextTimeout)
+	afterContextTimeout = 100 * time.Millisecond
+
+	// the stream is never closed, as with a followed container which stopped logging
+	r, w := io.Pipe()
+	defer w.Close()
+
+	go func() {
+		_, _ = w.Write([]byte("1:executed block\n2:panic: runtime error\n"))
+	}()
+
+	match, err := FindFatalLog(context.Background(), r, []*regexp.Regexp{regexp.MustCompile(`panic:`)}, 5)
+	require.NoError(t, err)
+	require.NotNil(t, match)
+	assert.Equal(t, []string{"1", "2"}, lineNumbers(match.Context))
+
+	// no match within the deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	match, err = FindFatalLog(ctx, r, []*regexp.Regexp{regexp.MustCompile(`panic:`)}, 5)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Nil(t, match)
+}
+
+func TestNoFatalLogs(t *testing.T) {
+	ctx := testContext()
+	logs := "1:executed block\n2:UPGRADE \"v2\" NEEDED at height: 100\n3:stopping"
+	cfg := &config.NoFatalLogs{
+		Patterns:     []string{`UPGRADE ".*" NEEDED`},
+		ContextLines: 1,
+		Duration:     time.Second,
+		OnMatch:      config.LogMatchFail,
+	}
+
+	err := NoFatalLogs(ctx, strings.NewReader(logs), cfg)
+	require.ErrorContains(t, err, `log line matched fatal pattern "UPGRADE \".*\" NEEDED"`)
+
+	cfg.OnMatch = config.LogMatchNotify
+	require.NoError(t, NoFatalLogs(ctx, strings.NewReader(logs), cfg))
+
+	// the check passes once the duration elapsed
+	r, w := io.Pipe()
+	defer w.Close()
+
+	cfg.OnMatch, cfg.Duration = config.LogMatchFail, 50*time.Millisecond
+	require.NoError(t, NoFatalLogs(ctx, r, cfg))
+}
+
+func lineNumbers(lines []string) []string {
+	numbers := make([]string, 0, len(lines))
+	for _, line := range lines {
+		numbers = append(numbers, strings.SplitN(line, ":", 2)[0])
+	}
+	return numbers
+}
+
+func testContext() context.Context {
+	log := zerolog.Nop()
+	ctx := logger.WithContext(context.Background(), &log)
+	return notification.WithContextFallback(ctx, notification.NewFallbackNotifier(&config.Config{}, nil, &log, "test"))
+}
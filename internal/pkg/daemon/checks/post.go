@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -14,6 +15,7 @@ import (
 	"blazar/internal/pkg/cosmos"
 	"blazar/internal/pkg/errors"
 	"blazar/internal/pkg/log"
+	urproto "blazar/internal/pkg/proto/upgrades_registry"
 
 	"github.com/cometbft/cometbft/libs/bytes"
 )
@@ -279,3 +281,141 @@ func NextBlockSignedPostCheck(ctx context.Context, cosmosClient *cosmos.Client,
 		}
 	}
 }
+
+// CompareAppVersions checks the software version reported by the node after the upgrade. The version (or commit)
+// must match the upgrade tag or the expected version pattern, and differ from the one recorded before the upgrade.
+// Values unknown before the upgrade (nil snapshot or empty fields) are not compared.
+func CompareAppVersions(before, after *cosmos.AppVersion, upgradeTag, expectedVersion string, requireAppVersionChange bool) error {
+	if err := MatchBinaryVersion(&BinaryVersion{Version: after.Version, Commit: after.GitCommit}, upgradeTag, expectedVersion); err != nil {
+		return err
+	}
+	if before == nil {
+		return nil
+	}
+
+	if before.Version != "" && before.Version == after.Version {
+		return fmt.Errorf("version %q didn't change", after.Version)
+	}
+	if before.GitCommit != "" && before.GitCommit == after.GitCommit {
+		return fmt.Errorf("commit %q didn't change", after.GitCommit)
+	}
+	if requireAppVersionChange {
+		if before.AppVersion == nil || after.AppVersion == nil {
+			return errors.New("app_version change is required, but the app_version before the upgrade is unknown")
+		}
+		if *before.AppVersion == *after.AppVersion {
+			return fmt.Errorf("app_version %d didn't change", *after.AppVersion)
+		}
+	}
+	return nil
+}
+
+// AppVersionDiff describes the change of the software version reported by the node
+func AppVersionDiff(before, after *cosmos.AppVersion) string {
+	if before == nil {
+		before = &cosmos.AppVersion{}
+	}
+
+	appVersion := func(v *uint64) string {
+		if v == nil {
+			return "unknown"
+		}
+		return strconv.FormatUint(*v, 10)
+	}
+
+	return fmt.Sprintf(
+		"version %q -> %q, commit %q -> %q, app_version %s -> %s",
+		before.Version, after.Version, before.GitCommit, after.GitCommit, appVersion(before.AppVersion), appVersion(after.AppVersion),
+	)
+}
+
+// AppVersionMatches polls the node until it reports the upgraded software, see CompareAppVersions
+func AppVersionMatches(ctx context.Context, cosmosClient *cosmos.Client, cfg *config.AppVersionMatches, before *cosmos.AppVersion, upgradeTag, expectedVersion string) error {
+	logger := log.FromContext(ctx)
+
+	ticker := time.NewTicker(cfg.PollInterval)
+	defer ticker.Stop()
+	timeout := time.NewTimer(cfg.Timeout)
+	defer timeout.Stop()
+
+	// the node may still be starting, the last error is reported when the check times out
+	var lastErr error
+	for {
+		select {
+		case <-ticker.C:
+			after, err := cosmosClient.GetAppVersion(ctx)
+			if err != nil {
+				logger.Err(err).Warn("Failed to get the app version, will retry")
+				lastErr = err
+				continue
+			}
+
+			if lastErr = CompareAppVersions(before, after, upgradeTag, expectedVersion, cfg.RequireAppVersionChange); lastErr == nil {
+				logger.Infof("Post upgrade check passed, the node runs the upgraded software: %s", AppVersionDiff(before, after)).Notify(ctx)
+				return nil
+			}
+			lastErr = fmt.Errorf("%w (%s)", lastErr, AppVersionDiff(before, after))
+		case <-timeout.C:
+			return fmt.Errorf("app version post-upgrade check timed out after %s: %v, assuming upgrade failed", cfg.Timeout.String(), lastErr)
+		case <-ctx.Done():
+			return errors.Wrapf(ctx.Err(), "app version post-upgrade check cancelled due to context timeout")
+		}
+	}
+}
+
+// UpgradePlanApplied polls the x/upgrade module until the governance upgrade plan is reported as applied.
+// The plan is recorded once the upgrade height is committed, a plan applied at another height fails the check.
+func UpgradePlanApplied(ctx context.Context, cosmosClient *cosmos.Client, cfg *config.UpgradePlanApplied, planName string, upgradeHeight int64) error {
+	logger := log.FromContext(ctx)
+
+	ticker := time.NewTicker(cfg.PollInterval)
+	defer ticker.Stop()
+	timeout := time.NewTimer(cfg.Timeout)
+	defer timeout.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			height, err := cosmosClient.GetAppliedPlanHeight(ctx, planName)
+			if err != nil {
+				logger.Err(err).Warn("Failed to get the applied upgrade plan, will retry")
+				continue
+			}
+
+			switch height {
+			case 0:
+				// not applied yet
+			case upgradeHeight:
+				logger.Infof("Post upgrade check passed, upgrade plan %q was applied at height %d", planName, height).Notify(ctx)
+				return nil
+			default:
+				return fmt.Errorf("upgrade plan %q was applied at height %d, expected height %d, assuming upgrade failed", planName, height, upgradeHeight)
+			}
+		case <-timeout.C:
+			return fmt.Errorf("upgrade plan post-upgrade check timed out after %s, plan %q wasn't applied, assuming upgrade failed", cfg.Timeout.String(), planName)
+		case <-ctx.Done():
+			return errors.Wrapf(ctx.Err(), "upgrade plan post-upgrade check cancelled due to context timeout")
+		}
+	}
+}
+
+// ModuleVersionsDiff returns the modules whose consensus version changed, sorted by name. Modules added or removed
+// by the upgrade are reported with the version 0 before or after the upgrade respectively
+func ModuleVersionsDiff(before, after map[string]uint64) []*urproto.ModuleVersionChange {
+	changes := make([]*urproto.ModuleVersionChange, 0)
+	for name, version := range after {
+		if before[name] != version {
+			changes = append(changes, &urproto.ModuleVersionChange{Name: name, FromVersion: before[name], ToVersion: version})
+		}
+	}
+	for name, version := range before {
+		if _, ok := after[name]; !ok {
+			changes = append(changes, &urproto.ModuleVersionChange{Name: name, FromVersion: version})
+		}
+	}
+
+	slices.SortFunc(changes, func(a, b *urproto.ModuleVersionChange) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return changes
+}
@@ -5,6 +5,9 @@ import (
 	"fmt"
 	"testing"
 
+	"blazar/internal/pkg/cosmos"
+	urproto "blazar/internal/pkg/proto/upgrades_registry"
+
 	"github.com/cometbft/cometbft/libs/bytes"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -61,3 +64,112 @@ func TestBlockSignedOneSigner(t *testing.T) {
 		})
 	}
 }
+
+func TestCompareAppVersions(t *testing.T) {
+	appVersion := func(v uint64) *uint64 { return &v }
+	before := &cosmos.AppVersion{Version: "v15.2.0", GitCommit: "1c2a7d0d26b8", AppVersion: appVersion(0)}
+
+	tests := []struct {
+		name                    string
+		before                  *cosmos.AppVersion
+		after                   *cosmos.AppVersion
+		tag                     string
+		expectedVersion         string
+		requireAppVersionChange bool
+		expectErr               bool
+	}{
+		{
+			name:   "Upgraded",
+			before: before,
+			after:  &cosmos.AppVersion{Version: "v16.0.0", GitCommit: "9f8e7d6c5b4a", AppVersion: appVersion(0)},
+			tag:    "v16.0.0",
+		},
+		{
+			name:   "UnknownBefore",
+			before: nil,
+			after:  &cosmos.AppVersion{Version: "v16.0.0", GitCommit: "9f8e7d6c5b4a"},
+			tag:    "v16.0.0",
+		},
+		{
+			name:      "TagMismatch",
+			before:    before,
+			after:     &cosmos.AppVersion{Version: "v16.0.1", GitCommit: "9f8e7d6c5b4a"},
+			tag:       "v16.0.0",
+			expectErr: true,
+		},
+		{
+			name:      "NotUpgraded",
+			before:    before,
+			after:     before,
+			tag:       "v15.2.0",
+			expectErr: true,
+		},
+		{
+			name:      "SameCommit",
+			before:    before,
+			after:     &cosmos.AppVersion{Version: "v16.0.0", GitCommit: "1c2a7d0d26b8"},
+			tag:       "v16.0.0",
+			expectErr: true,
+		},
+		{
+			name:            "ExpectedVersion",
+			before:          before,
+			after:           &cosmos.AppVersion{Version: "v16.0.0-rc1", GitCommit: "9f8e7d6c5b4a"},
+			tag:             "sha256:3b0f6e9f4c3c2a1c5f1f6d1f0b4f0d7e6c2b3a4d5e6f7a8b9c0d1e2f3a4b5c6d",
+			expectedVersion: `^v16\.`,
+		},
+		{
+			name:                    "AppVersionChanged",
+			before:                  before,
+			after:                   &cosmos.AppVersion{Version: "v16.0.0", GitCommit: "9f8e7d6c5b4a", AppVersion: appVersion(1)},
+			tag:                     "v16.0.0",
+			requireAppVersionChange: true,
+		},
+		{
+			name:                    "AppVersionUnchanged",
+			before:                  before,
+			after:                   &cosmos.AppVersion{Version: "v16.0.0", GitCommit: "9f8e7d6c5b4a", AppVersion: appVersion(0)},
+			tag:                     "v16.0.0",
+			requireAppVersionChange: true,
+			expectErr:               true,
+		},
+		{
+			name:                    "AppVersionUnknownBefore",
+			before:                  &cosmos.AppVersion{Version: "v15.2.0", GitCommit: "1c2a7d0d26b8"},
+			after:                   &cosmos.AppVersion{Version: "v16.0.0", GitCommit: "9f8e7d6c5b4a", AppVersion: appVersion(1)},
+			tag:                     "v16.0.0",
+			requireAppVersionChange: true,
+			expectErr:               true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := CompareAppVersions(test.before, test.after, test.tag, test.expectedVersion, test.requireAppVersionChange)
+			if test.expectErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+
+	assert.Equal(t,
+		`version "v15.2.0" -> "v16.0.0", commit "1c2a7d0d26b8" -> "9f8e7d6c5b4a", app_version 0 -> unknown`,
+		AppVersionDiff(before, &cosmos.AppVersion{Version: "v16.0.0", GitCommit: "9f8e7d6c5b4a"}),
+	)
+}
+
+func TestModuleVersionsDiff(t *testing.T) {
+	before := map[string]uint64{"bank": 3, "staking": 4, "crisis": 2, "ibc": 5}
+	after := map[string]uint64{"bank": 4, "staking": 4, "ibc": 6, "consensus": 1}
+
+	assert.Equal(t, []*urproto.ModuleVersionChange{
+		{Name: "bank", FromVersion: 3, ToVersion: 4},
+		{Name: "consensus", FromVersion: 0, ToVersion: 1},
+		{Name: "crisis", FromVersion: 2, ToVersion: 0},
+		{Name: "ibc", FromVersion: 5, ToVersion: 6},
+	}, ModuleVersionsDiff(before, after))
+
+	assert.Empty(t, ModuleVersionsDiff(before, before))
+}
@@ -1,10 +1,17 @@
 package checks
 
 import (
+	"bufio"
 	"context"
+	"encoding/hex"
 	"fmt"
+	"regexp"
+	"slices"
+	"strings"
 	"time"
 
+	"blazar/internal/pkg/config"
+	"blazar/internal/pkg/cosmos"
 	"blazar/internal/pkg/daemon/util"
 	"blazar/internal/pkg/docker"
 	"blazar/internal/pkg/errors"
@@ -22,22 +29,235 @@ func PullDockerImage(ctx context.Context, dcc *docker.ComposeClient, serviceName
 		return "", "", errors.Wrapf(err, "failed to get new upgrade image for height: %d, tag: %s", upgradeHeight, upgradeTag)
 	}
 
+	if err := pullImage(ctx, dcc, serviceName, newImage, maxRetries, backoff); err != nil {
+		return "", "", err
+	}
+
+	return currImage, newImage, nil
+}
+
+// PullServiceImages ensures the images of the additional services upgraded together with
+// the main service (service name -> tag or image) are present on the host
+//
+// return service name -> upgrade image, error
+func PullServiceImages(ctx context.Context, dcc *docker.ComposeClient, services map[string]string,
+	upgradeHeight int64, maxRetries int, backoff time.Duration) (map[string]string, error) {
+	newImages := make(map[string]string, len(services))
+
+	for serviceName, tagOrImage := range services {
+		if tagOrImage == "" {
+			return nil, fmt.Errorf("failed to check docker image, upgrade tag for service %s is empty, for upgrade height: %d", serviceName, upgradeHeight)
+		}
+
+		_, newImage, err := util.GetCurrImageUpgradeImage(dcc, serviceName, tagOrImage)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get new upgrade image for service %s, height: %d, tag: %s", serviceName, upgradeHeight, tagOrImage)
+		}
+
+		if err := pullImage(ctx, dcc, serviceName, newImage, maxRetries, backoff); err != nil {
+			return nil, err
+		}
+		newImages[serviceName] = newImage
+	}
+
+	return newImages, nil
+}
+
+func pullImage(ctx context.Context, dcc *docker.ComposeClient, serviceName, newImage string, maxRetries int, backoff time.Duration) error {
 	isImagePresent, err := dcc.DockerClient().IsImagePresent(ctx, newImage)
 	if err != nil {
-		return "", "", errors.Wrapf(err, "failed to check if new image %s is present", newImage)
+		return errors.Wrapf(err, "failed to check if new image %s is present", newImage)
 	}
 
 	if !isImagePresent {
 		// let's try to pull once
 		platform, err := dcc.GetPlatform(serviceName)
 		if err != nil {
-			return "", "", errors.Wrapf(err, "new image %s is not present on host and failed to get platform from compose file", newImage)
+			return errors.Wrapf(err, "new image %s is not present on host and failed to get platform from compose file", newImage)
 		}
 
 		if err := dcc.DockerClient().PullImageWithRetry(ctx, newImage, platform, maxRetries, backoff); err != nil {
-			return "", "", errors.Wrapf(err, "new image %s is not present on host and pull failed", newImage)
+			return errors.Wrapf(err, "new image %s is not present on host and pull failed", newImage)
 		}
 	}
 
-	return currImage, newImage, nil
+	return nil
+}
+
+// BinaryVersion is the build information reported by `<app> version --long`
+type BinaryVersion struct {
+	Name       string
+	ServerName string
+	Version    string
+	Commit     string
+}
+
+// ParseBinaryVersion parses the (yaml) output of the cosmos-sdk `version --long` command
+func ParseBinaryVersion(output string) (*BinaryVersion, error) {
+	version := &BinaryVersion{}
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		// nested fields (e.g build_deps) are indented or list items, we only need the top level ones
+		key, value, found := strings.Cut(scanner.Text(), ":")
+		if !found || strings.HasPrefix(key, " ") || strings.HasPrefix(key, "-") {
+			continue
+		}
+		value = strings.Trim(strings.TrimSpace(value), `"'`)
+
+		switch key {
+		case "name":
+			version.Name = value
+		case "server_name":
+			version.ServerName = value
+		case "version":
+			version.Version = value
+		case "commit":
+			version.Commit = value
+		}
+	}
+
+	if version.Version == "" && version.Commit == "" {
+		return nil, fmt.Errorf("no version nor commit found in the version command output: %q", output)
+	}
+	return version, nil
+}
+
+// MatchBinaryVersion checks the version (or commit) reported by the binary against the expected version pattern.
+// If no pattern is set, the version is compared with the upgrade tag (ignoring the "v" prefix), or the commit
+// if the tag is a commit hash.
+func MatchBinaryVersion(version *BinaryVersion, upgradeTag, expectedVersion string) error {
+	if expectedVersion != "" {
+		pattern, err := regexp.Compile(expectedVersion)
+		if err != nil {
+			return errors.Wrapf(err, "invalid expected version pattern %q", expectedVersion)
+		}
+		if pattern.MatchString(version.Version) || (version.Commit != "" && pattern.MatchString(version.Commit)) {
+			return nil
+		}
+		return fmt.Errorf("binary version %q (commit %q) doesn't match the expected version %q", version.Version, version.Commit, expectedVersion)
+	}
+
+	tag, err := tagVersion(upgradeTag)
+	if err != nil {
+		return err
+	}
+
+	if strings.TrimPrefix(tag, "v") == strings.TrimPrefix(version.Version, "v") {
+		return nil
+	}
+	if len(tag) >= 7 && version.Commit != "" && strings.HasPrefix(version.Commit, tag) {
+		return nil
+	}
+	return fmt.Errorf("binary version %q (commit %q) doesn't match the upgrade tag %q", version.Version, version.Commit, tag)
+}
+
+// tagVersion returns the tag part of the upgrade tag, which may be a full image reference or be pinned by digest
+func tagVersion(upgradeTag string) (string, error) {
+	tag := upgradeTag
+	if docker.IsImageReference(upgradeTag) {
+		_, version, err := docker.ParseImageName(upgradeTag)
+		if err != nil {
+			return "", err
+		}
+		tag = version
+	}
+
+	tag, _, _ = strings.Cut(tag, "@")
+	if tag == "" || docker.IsDigestOnly(tag) {
+		return "", fmt.Errorf("upgrade tag %q has no version to compare with, register the upgrade with an expected version", upgradeTag)
+	}
+	return tag, nil
+}
+
+// NodeSyncState is the node state evaluated by the NODE_SYNC_STATUS pre-check. A failed query is
+// recorded in the corresponding error field, as the condition can't be verified
+type NodeSyncState struct {
+	CatchingUp bool
+	StatusErr  error
+
+	Peers    int
+	PeersErr error
+
+	// nil if the node doesn't run a validator
+	Validator    *cosmos.ValidatorStatus
+	ValidatorErr error
+}
+
+// SyncStatusIssue is a NODE_SYNC_STATUS condition not met by the node
+type SyncStatusIssue struct {
+	Severity config.CheckSeverity
+	Message  string
+}
+
+// GetNodeSyncState queries the node for the conditions enabled in the config
+func GetNodeSyncState(ctx context.Context, cosmosClient *cosmos.Client, cfg *config.NodeSyncStatus) *NodeSyncState {
+	state := &NodeSyncState{}
+
+	status, err := cosmosClient.GetStatus(ctx)
+	if err != nil {
+		state.StatusErr, state.ValidatorErr = err, err
+	} else {
+		state.CatchingUp = status.SyncInfo.CatchingUp
+	}
+
+	if cfg.LowPeers != config.SeverityOff {
+		state.Peers, state.PeersErr = cosmosClient.GetPeerCount(ctx)
+	}
+
+	if status != nil && (cfg.Jailed != config.SeverityOff || cfg.Tombstoned != config.SeverityOff) {
+		consAddress, err := hex.DecodeString(status.ValidatorInfo.Address)
+		if err != nil {
+			state.ValidatorErr = errors.Wrapf(err, "failed to parse validator address %q", status.ValidatorInfo.Address)
+		} else {
+			state.Validator, state.ValidatorErr = cosmosClient.GetValidatorStatus(ctx, consAddress)
+		}
+	}
+
+	return state
+}
+
+// EvaluateNodeSyncStatus returns the conditions not met by the node, with their configured severity
+func EvaluateNodeSyncStatus(state *NodeSyncState, cfg *config.NodeSyncStatus) []SyncStatusIssue {
+	issues := []SyncStatusIssue{}
+	report := func(severity config.CheckSeverity, format string, v ...interface{}) {
+		if severity != config.SeverityOff {
+			issues = append(issues, SyncStatusIssue{Severity: severity, Message: fmt.Sprintf(format, v...)})
+		}
+	}
+
+	switch {
+	case state.StatusErr != nil:
+		report(cfg.CatchingUp, "failed to check if the node is catching up: %v", state.StatusErr)
+	case state.CatchingUp:
+		report(cfg.CatchingUp, "the node is catching up (block-syncing)")
+	}
+
+	switch {
+	case state.PeersErr != nil:
+		report(cfg.LowPeers, "failed to get the peer count: %v", state.PeersErr)
+	case state.Peers < cfg.MinPeers:
+		report(cfg.LowPeers, "the node is connected to %d peers, expected at least %d", state.Peers, cfg.MinPeers)
+	}
+
+	switch {
+	case state.ValidatorErr != nil:
+		report(stricterSeverity(cfg.Jailed, cfg.Tombstoned), "failed to get the validator status: %v", state.ValidatorErr)
+	case state.Validator != nil:
+		if state.Validator.Jailed {
+			report(cfg.Jailed, "validator %s is jailed", state.Validator.OperatorAddress)
+		}
+		if state.Validator.Tombstoned {
+			report(cfg.Tombstoned, "validator %s is tombstoned", state.Validator.OperatorAddress)
+		}
+	}
+
+	return issues
+}
+
+func stricterSeverity(a, b config.CheckSeverity) config.CheckSeverity {
+	if slices.Index(config.ValidCheckSeverities, a) > slices.Index(config.ValidCheckSeverities, b) {
+		return a
+	}
+	return b
 }
@@ -0,0 +1,132 @@
+package checks
+
+import (
+	"errors"
+	"testing"
+
+	"blazar/internal/pkg/config"
+	"blazar/internal/pkg/cosmos"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const versionOutput = `name: gaia
+server_name: gaiad
+version: v15.2.0
+commit: 1c2a7d0d26b8d8d1a4ddd6ef1d3db8c9e9b4e6b1
+build_tags: netgo,ledger
+go: go version go1.20.3 linux/amd64
+build_deps:
+- cosmossdk.io/api@v0.3.1
+- github.com/cosmos/cosmos-sdk@v0.45.16 => github.com/cosmos/cosmos-sdk@v0.45.16-ics
+cosmos_sdk_version: v0.45.16
+`
+
+func TestParseBinaryVersion(t *testing.T) {
+	version, err := ParseBinaryVersion(versionOutput)
+	require.NoError(t, err)
+	assert.Equal(t, &BinaryVersion{
+		Name:       "gaia",
+		ServerName: "gaiad",
+		Version:    "v15.2.0",
+		Commit:     "1c2a7d0d26b8d8d1a4ddd6ef1d3db8c9e9b4e6b1",
+	}, version)
+
+	// binaries built without ldflags report empty values
+	_, err = ParseBinaryVersion("name: \"\"\nserver_name: \"\"\nversion: \"\"\ncommit: \"\"\n")
+	require.Error(t, err)
+
+	_, err = ParseBinaryVersion("exec: \"gaiad\": executable file not found in $PATH")
+	require.Error(t, err)
+}
+
+func TestMatchBinaryVersion(t *testing.T) {
+	version := &BinaryVersion{Version: "15.2.0", Commit: "1c2a7d0d26b8d8d1a4ddd6ef1d3db8c9e9b4e6b1"}
+
+	tests := []struct {
+		name            string
+		tag             string
+		expectedVersion string
+		expectErr       bool
+	}{
+		{name: "Tag", tag: "15.2.0"},
+		{name: "TagWithPrefix", tag: "v15.2.0"},
+		{name: "TagWithDigest", tag: "v15.2.0@sha256:3b0f6e9f4c3c2a1c5f1f6d1f0b4f0d7e6c2b3a4d5e6f7a8b9c0d1e2f3a4b5c6d"},
+		{name: "ImageReference", tag: "registry.example.com:5000/cosmos/gaia:v15.2.0"},
+		{name: "CommitTag", tag: "1c2a7d0"},
+		{name: "MislabelledTag", tag: "v15.2.1", expectErr: true},
+		{name: "ShortCommitTag", tag: "1c2a", expectErr: true},
+		{name: "DigestOnlyTag", tag: "sha256:3b0f6e9f4c3c2a1c5f1f6d1f0b4f0d7e6c2b3a4d5e6f7a8b9c0d1e2f3a4b5c6d", expectErr: true},
+		{name: "ExpectedVersion", tag: "v15.2.0-hotfix", expectedVersion: `^v?15\.2\.0$`},
+		{name: "ExpectedCommit", tag: "sha256:3b0f6e9f4c3c2a1c5f1f6d1f0b4f0d7e6c2b3a4d5e6f7a8b9c0d1e2f3a4b5c6d", expectedVersion: "^1c2a7d0"},
+		{name: "ExpectedVersionMismatch", tag: "v15.2.0", expectedVersion: `^v?16\.`, expectErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := MatchBinaryVersion(version, test.tag, test.expectedVersion)
+			if test.expectErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestEvaluateNodeSyncStatus(t *testing.T) {
+	cfg := &config.NodeSyncStatus{
+		CatchingUp: config.SeverityBlock,
+		LowPeers:   config.SeverityWarn,
+		MinPeers:   3,
+		Jailed:     config.SeverityWarn,
+		Tombstoned: config.SeverityOff,
+	}
+
+	tests := []struct {
+		name     string
+		state    *NodeSyncState
+		expected []SyncStatusIssue
+	}{
+		{
+			name:     "Healthy",
+			state:    &NodeSyncState{Peers: 10, Validator: &cosmos.ValidatorStatus{OperatorAddress: "cosmosvaloper1"}},
+			expected: []SyncStatusIssue{},
+		},
+		{
+			name:     "NotValidator",
+			state:    &NodeSyncState{Peers: 10},
+			expected: []SyncStatusIssue{},
+		},
+		{
+			name:  "CatchingUp",
+			state: &NodeSyncState{CatchingUp: true, Peers: 10},
+			expected: []SyncStatusIssue{
+				{Severity: config.SeverityBlock, Message: "the node is catching up (block-syncing)"},
+			},
+		},
+		{
+			name:  "LowPeersAndJailed",
+			state: &NodeSyncState{Peers: 1, Validator: &cosmos.ValidatorStatus{OperatorAddress: "cosmosvaloper1", Jailed: true, Tombstoned: true}},
+			expected: []SyncStatusIssue{
+				{Severity: config.SeverityWarn, Message: "the node is connected to 1 peers, expected at least 3"},
+				{Severity: config.SeverityWarn, Message: "validator cosmosvaloper1 is jailed"},
+			},
+		},
+		{
+			name:  "StatusUnavailable",
+			state: &NodeSyncState{StatusErr: errors.New("connection refused"), Peers: 10, ValidatorErr: errors.New("connection refused")},
+			expected: []SyncStatusIssue{
+				{Severity: config.SeverityBlock, Message: "failed to check if the node is catching up: connection refused"},
+				{Severity: config.SeverityWarn, Message: "failed to get the validator status: connection refused"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, EvaluateNodeSyncStatus(test.state, cfg))
+		})
+	}
+}
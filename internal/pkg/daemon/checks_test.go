@@ -0,0 +1,272 @@
+package daemon
+
+import (
+	"testing"
+	"time"
+
+	"blazar/internal/pkg/config"
+	checksproto "blazar/internal/pkg/proto/daemon"
+	urproto "blazar/internal/pkg/proto/upgrades_registry"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyBinaryVersionMismatch(t *testing.T) {
+	tests := []struct {
+		name         string
+		onMismatch   config.CheckSeverity
+		output       string
+		expectStatus checksproto.CheckStatus
+	}{
+		{
+			name:         "Match",
+			output:       "name: simd\nversion: v2\ncommit: abcdef",
+			expectStatus: checksproto.CheckStatus_FINISHED,
+		},
+		{
+			name:         "MismatchBlocks",
+			output:       "name: simd\nversion: v1\ncommit: abcdef",
+			expectStatus: checksproto.CheckStatus_ERROR,
+		},
+		{
+			name:         "MismatchWarns",
+			onMismatch:   config.SeverityWarn,
+			output:       "name: simd\nversion: v1\ncommit: abcdef",
+			expectStatus: checksproto.CheckStatus_FINISHED,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			executor := newMockExecutor(map[string]string{"simd": "simd:v1"}, true)
+			executor.binaryVersion = test.output
+			daemon, cfg, ctx := newReconcileTestDaemon(t, executor, "")
+
+			cfg.Checks.PreUpgrade.Enabled = []string{checksproto.PreCheck_VERIFY_BINARY_VERSION.String()}
+			cfg.Checks.PreUpgrade.Blocks = 10
+			cfg.Checks.PreUpgrade.VerifyBinaryVersion = &config.VerifyBinaryVersion{
+				AppName:    "simd",
+				Timeout:    time.Second,
+				OnMismatch: test.onMismatch,
+			}
+
+			_, _, _, _, err := daemon.ur.Update(ctx, 5, true)
+			require.NoError(t, err)
+			daemon.stateMachine.SetStep(10, urproto.UpgradeStep_MONITORING)
+
+			upgrade := daemon.ur.GetUpgradeWithCache(10)
+			_, err = daemon.preUpgradeChecks(ctx, 5, daemon.stateMachine, executor, &cfg.Compose, &cfg.Checks.PreUpgrade, cfg.ComposeService, upgrade, "test")
+			require.NoError(t, err)
+			require.Equal(t, test.expectStatus, daemon.stateMachine.GetPreCheckStatus(10, checksproto.PreCheck_VERIFY_BINARY_VERSION))
+
+			// the wrong binary is never started
+			err = daemon.performUpgrade(ctx, &cfg.Compose, &cfg.Checks.PreUpgrade, cfg.ChainHome, cfg.ComposeService, 10)
+			if test.expectStatus == checksproto.CheckStatus_ERROR {
+				require.Error(t, err)
+				require.Equal(t, urproto.UpgradeStatus_FAILED, daemon.stateMachine.GetStatus(10))
+				require.Equal(t, "simd:v1", executor.versions["simd"])
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, "simd:v2", executor.versions["simd"])
+		})
+	}
+}
+
+func TestVerifyBinaryVersionReregistered(t *testing.T) {
+	executor := newMockExecutor(map[string]string{"simd": "simd:v1"}, true)
+	executor.binaryVersion = "name: simd\nversion: v3\ncommit: abcdef"
+	daemon, cfg, ctx := newReconcileTestDaemon(t, executor, "")
+
+	cfg.Checks.PreUpgrade.Enabled = []string{checksproto.PreCheck_VERIFY_BINARY_VERSION.String()}
+	cfg.Checks.PreUpgrade.Blocks = 10
+	cfg.Checks.PreUpgrade.VerifyBinaryVersion = &config.VerifyBinaryVersion{AppName: "simd", Timeout: time.Second}
+
+	reregister := func(tag string) *urproto.Upgrade {
+		require.NoError(t, daemon.ur.AddUpgrade(ctx, &urproto.Upgrade{
+			Height:   10,
+			Tag:      tag,
+			Network:  "test",
+			Name:     "test",
+			Type:     urproto.UpgradeType_NON_GOVERNANCE_COORDINATED,
+			Status:   urproto.UpgradeStatus_UNKNOWN,
+			Source:   urproto.ProviderType_LOCAL,
+			Priority: 1,
+		}, true))
+		_, _, _, _, err := daemon.ur.Update(ctx, 5, true)
+		require.NoError(t, err)
+		return daemon.ur.GetUpgradeWithCache(10)
+	}
+	preUpgradeChecks := func(upgrade *urproto.Upgrade) checksproto.CheckStatus {
+		_, err := daemon.preUpgradeChecks(ctx, 5, daemon.stateMachine, executor, &cfg.Compose, &cfg.Checks.PreUpgrade, cfg.ComposeService, upgrade, "test")
+		require.NoError(t, err)
+		return daemon.stateMachine.GetPreCheckStatus(10, checksproto.PreCheck_VERIFY_BINARY_VERSION)
+	}
+
+	upgrade := reregister("v2")
+	daemon.stateMachine.SetStep(10, urproto.UpgradeStep_MONITORING)
+	require.Equal(t, checksproto.CheckStatus_ERROR, preUpgradeChecks(upgrade))
+
+	// the check is run again for the new tag
+	require.Equal(t, checksproto.CheckStatus_FINISHED, preUpgradeChecks(reregister("v3")))
+
+	// the upgrade is re-registered right before its height, the check is run again before the upgrade
+	reregister("v4")
+	err := daemon.performUpgrade(ctx, &cfg.Compose, &cfg.Checks.PreUpgrade, cfg.ChainHome, cfg.ComposeService, 10)
+	require.ErrorContains(t, err, "doesn't report the expected version")
+	require.Equal(t, "simd:v1", executor.versions["simd"])
+}
+
+func TestVerifyImageDigest(t *testing.T) {
+	tests := []struct {
+		name        string
+		repushed    bool
+		tag         string
+		expectErr   bool
+		expectImage string
+	}{
+		{
+			name:        "Match",
+			tag:         "v2",
+			expectImage: "simd:v2",
+		},
+		{
+			name:      "TagRepushed",
+			tag:       "v2",
+			repushed:  true,
+			expectErr: true,
+		},
+		{
+			// the pinned image is not used anymore, the new one is pinned instead
+			name:        "Reregistered",
+			tag:         "v3",
+			expectImage: "simd:v3",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			executor := newMockExecutor(map[string]string{"simd": "simd:v1"}, true)
+			executor.digests = map[string]string{"simd:v2": "sha256:v2", "simd:v3": "sha256:v3"}
+			daemon, cfg, ctx := newReconcileTestDaemon(t, executor, "")
+
+			cfg.Checks.PreUpgrade.Enabled = []string{checksproto.PreCheck_PULL_DOCKER_IMAGE.String()}
+			cfg.Checks.PreUpgrade.Blocks = 10
+
+			_, _, _, _, err := daemon.ur.Update(ctx, 5, true)
+			require.NoError(t, err)
+			daemon.stateMachine.SetStep(10, urproto.UpgradeStep_MONITORING)
+
+			_, err = daemon.preUpgradeChecks(ctx, 5, daemon.stateMachine, executor, &cfg.Compose, &cfg.Checks.PreUpgrade, cfg.ComposeService, daemon.ur.GetUpgradeWithCache(10), "test")
+			require.NoError(t, err)
+			require.Equal(t, "simd:v2", daemon.stateMachine.GetPinnedImage(10))
+			require.Equal(t, "sha256:v2", daemon.stateMachine.GetImageDigest(10))
+
+			if test.repushed {
+				executor.digests["simd:v2"] = "sha256:other"
+			}
+			if test.tag != "v2" {
+				upgrade := daemon.ur.GetUpgradeWithCache(10)
+				upgrade.Tag = test.tag
+				require.NoError(t, daemon.ur.AddUpgrade(ctx, upgrade, true))
+				_, _, _, _, err = daemon.ur.Update(ctx, 5, true)
+				require.NoError(t, err)
+			}
+
+			err = daemon.performUpgrade(ctx, &cfg.Compose, &cfg.Checks.PreUpgrade, cfg.ChainHome, cfg.ComposeService, 10)
+			if test.expectErr {
+				require.ErrorContains(t, err, "changed since the pre-upgrade check")
+				require.Equal(t, "simd:v1", executor.versions["simd"])
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.expectImage, executor.versions["simd"])
+			require.Equal(t, test.expectImage, daemon.stateMachine.GetPinnedImage(10))
+			require.Equal(t, executor.digests[test.expectImage], daemon.stateMachine.GetImageDigest(10))
+		})
+	}
+}
+
+func TestNoFatalLogsAttach(t *testing.T) {
+	fatalLogsAttachTimeout = 100 * time.Millisecond
+	fatalLogsAttachInterval = 10 * time.Millisecond
+
+	cfg := &config.NoFatalLogs{
+		Patterns: []string{"CONSENSUS FAILURE"},
+		Duration: time.Second,
+	}
+
+	t.Run("AttachFails", func(t *testing.T) {
+		executor := newMockExecutor(map[string]string{"simd": "simd:v2"}, true)
+		daemon, daemonCfg, _ := newReconcileTestDaemon(t, executor, "")
+		outBuffer, ctx := injectTestLogger(daemonCfg)
+
+		// not attaching to the logs is not a fatal log line
+		require.NoError(t, daemon.noFatalLogs(ctx, cfg, "simd"))
+		require.Contains(t, outBuffer.String(), "failed to follow the logs of service simd")
+	})
+
+	t.Run("AttachRetried", func(t *testing.T) {
+		executor := newMockExecutor(map[string]string{"simd": "simd:v2"}, true)
+		executor.logs = "starting\nCONSENSUS FAILURE\n"
+		executor.logsAttachFailures = 3
+		daemon, _, ctx := newReconcileTestDaemon(t, executor, "")
+
+		require.ErrorContains(t, daemon.noFatalLogs(ctx, cfg, "simd"), "log line matched fatal pattern")
+	})
+}
+
+func TestDryRunSkipsHaltHeightWait(t *testing.T) {
+	executor := newMockExecutor(map[string]string{"simd": "simd:v1"}, true)
+	daemon, cfg, ctx := newReconcileTestDaemon(t, executor, "")
+	daemon.dryRun = true
+
+	cfg.Checks.PreUpgrade.Enabled = []string{checksproto.PreCheck_SET_HALT_HEIGHT.String()}
+	cfg.Checks.PreUpgrade.Blocks = 10
+	cfg.Checks.PreUpgrade.SetHaltHeight = &config.SetHaltHeight{}
+
+	_, _, _, _, err := daemon.ur.Update(ctx, 9, true)
+	require.NoError(t, err)
+	daemon.stateMachine.SetStep(10, urproto.UpgradeStep_MONITORING)
+
+	upgrade := daemon.ur.GetUpgradeWithCache(10)
+	dryRunExecutor := newDryRunExecutor(executor)
+
+	// the halt height is set, but the service is not restarted
+	upgradeHeight, err := daemon.preUpgradeChecks(ctx, 9, daemon.stateMachine, dryRunExecutor, &cfg.Compose, &cfg.Checks.PreUpgrade, cfg.ComposeService, upgrade, "test")
+	require.NoError(t, err)
+	require.Equal(t, int64(0), upgradeHeight)
+	require.Equal(t, checksproto.CheckStatus_FINISHED, daemon.stateMachine.GetPreCheckStatus(10, checksproto.PreCheck_SET_HALT_HEIGHT))
+
+	// the still running service is not waited for
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		upgradeHeight, err = daemon.preUpgradeChecks(ctx, 9, daemon.stateMachine, dryRunExecutor, &cfg.Compose, &cfg.Checks.PreUpgrade, cfg.ComposeService, upgrade, "test")
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the dry-run waited for the service to stop itself")
+	}
+	require.NoError(t, err)
+	require.Equal(t, int64(10), upgradeHeight)
+}
+
+func TestSnapshotModuleVersionsOnce(t *testing.T) {
+	daemon, cfg, _ := newReconcileTestDaemon(t, newMockExecutor(map[string]string{"simd": "simd:v1"}, true), "")
+	outBuffer, ctx := injectTestLogger(cfg)
+
+	// the node gRPC is not reachable, the snapshot is attempted
+	daemon.snapshotModuleVersions(ctx, 10)
+	require.Contains(t, outBuffer.String(), "failed to get module versions")
+	outBuffer.Reset()
+
+	// the stored snapshot is not replaced, e.g. by the versions of the already upgraded node
+	before := map[string]uint64{"bank": 1}
+	daemon.stateMachine.SetModuleVersionsBefore(10, before)
+	daemon.snapshotModuleVersions(ctx, 10)
+	require.NotContains(t, outBuffer.String(), "failed to get module versions")
+	require.Equal(t, before, daemon.stateMachine.GetModuleVersions(10).Before)
+}
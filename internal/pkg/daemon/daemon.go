@@ -14,7 +14,6 @@ import (
 	"blazar/internal/pkg/chain_watcher"
 	"blazar/internal/pkg/config"
 	"blazar/internal/pkg/cosmos"
-	"blazar/internal/pkg/daemon/checks"
 	"blazar/internal/pkg/docker"
 	"blazar/internal/pkg/errors"
 	"blazar/internal/pkg/log"
@@ -40,10 +39,25 @@ type Daemon struct {
 	dc           *docker.Client
 	cosmosClient *cosmos.Client
 
+	// performs the actual upgrade (docker compose or systemd)
+	executor Executor
+
+	// if true, nothing on the host is modified, blazar only reports what it would do
+	dryRun bool
+
+	// if true, the node syncs from genesis and the past upgrades are replayed
+	syncFromGenesis bool
+
 	// internal state handling
 	ur           *upgrades_registry.UpgradeRegistry
 	stateMachine *sm.StateMachine
 
+	// upgrades resolved from older provider data are blocked, 0 if disabled
+	maxStaleness time.Duration
+
+	// upgrades are not executed if the state machine state can't be persisted
+	requirePersistence bool
+
 	// telemetry
 	metrics *metrics.Metrics
 
@@ -61,45 +75,46 @@ type Daemon struct {
 	currHeightTime      time.Time
 	observedBlockSpeeds []time.Duration
 	currBlockSpeed      time.Duration
+
+	// last NODE_SYNC_STATUS report, so the same issues are not notified on every block
+	lastSyncStatusReport string
+
+	// height of the last upgrade the images used since genesis were reported for, so they are notified once
+	lastSyncFromGenesisHeight int64
 }
 
 func NewDaemon(ctx context.Context, cfg *config.Config, m *metrics.Metrics) (*Daemon, error) {
-	if _, err := docker.LoadComposeFile(cfg.ComposeFile); err != nil {
-		return nil, errors.Wrapf(err, "failed to parse docker compose file")
-	}
-
 	// setup updates registry
 	ur, err := upgrades_registry.NewUpgradesRegistryFromConfig(cfg)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to load upgrade registry")
 	}
 
-	// setup docker compose client
-	dc, err := docker.NewClientWithConfig(ctx, cfg.CredentialHelper)
+	executor, dc, dcc, err := newExecutor(ctx, cfg)
 	if err != nil {
-		return nil, errors.Wrapf(err, "failed to create docker client")
+		return nil, err
 	}
-	dcc, err := docker.NewComposeClient(dc, cfg.VersionFile, cfg.ComposeFile, cfg.UpgradeMode)
-	if err != nil {
-		return nil, errors.Wrapf(err, "failed to create docker compose client")
+
+	if cfg.DryRun {
+		executor = newDryRunExecutor(executor)
 	}
 
-	// setup new cosmos client
+	// setup new cosmos client, the cometbft websocket is dialed in Init once the node is up
 	cosmosClient, err := cosmos.NewClient(cfg.Clients.Host, cfg.Clients.GrpcPort, cfg.Clients.CometbftPort, cfg.Clients.Timeout)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to create cosmos client")
 	}
 
-	if err := cosmosClient.StartCometbftClient(); err != nil {
-		return nil, errors.Wrapf(err, "failed to start cometbft client")
-	}
-
 	return &Daemon{
 		dcc:          dcc,
 		dc:           dc,
 		cosmosClient: cosmosClient,
+		executor:     executor,
+		dryRun:       cfg.DryRun,
 		metrics:      m,
 
+		syncFromGenesis: cfg.SyncFromGenesis,
+
 		// setup by Init()
 		startupHeight:       0,
 		currHeight:          0,
@@ -109,6 +124,9 @@ func NewDaemon(ctx context.Context, cfg *config.Config, m *metrics.Metrics) (*Da
 
 		ur:           ur,
 		stateMachine: ur.GetStateMachine(),
+		maxStaleness: cfg.UpgradeRegistry.MaxStaleness,
+
+		requirePersistence: cfg.UpgradeRegistry.StateMachine.RequirePersistence,
 	}, nil
 }
 
@@ -119,23 +137,64 @@ func (d *Daemon) Init(ctx context.Context, cfg *config.Config) error {
 	// mark the daemon is up
 	d.metrics.Up.Set(1)
 
+	if d.syncFromGenesis {
+		logger.Warn("Running in sync-from-genesis mode, the past upgrades are replayed instead of being expired")
+		disableSyncFromGenesisChecks(ctx, cfg)
+	}
+
+	// the state is persisted on every change, the failures are reported here as the callers can't handle them
+	d.stateMachine.SetPersistence(ctx, func(err error) {
+		d.metrics.StatePersistErrs.Inc()
+		logger.Err(err).Error("Failed to persist the state machine state, the upgrade progress may be lost on restart").Notify(ctx)
+	})
+
+	// keep the notifications of the upgrades in their original threads after a restart
+	if notifier := notification.FromContextFallback(ctx); notifier != nil {
+		notifier.SetThreadStore(d.stateMachine)
+	}
+
+	// the state storage may be unreachable at startup, the state is then restored from its local copy
+	if err := d.ur.StateRestoreErr(); err != nil {
+		logger.Err(err).Warn(
+			"Started with the local copy of the state machine state as its storage is unreachable, check the upgrades state once it is back",
+		).Notify(ctx)
+	}
+
 	// test docker and docker compose
-	logger.Info("Setting up docker and docker compose clients")
-	if _, err := d.dcc.DockerClient().ContainerList(ctx, true); err != nil {
-		return errors.Wrapf(err, "failed to fetch list of containers from docker client")
+	if d.dcc != nil {
+		logger.Info("Setting up docker and docker compose clients")
+		if _, err := d.dcc.DockerClient().ContainerList(ctx, true); err != nil {
+			return errors.Wrapf(err, "failed to fetch list of containers from docker client")
+		}
 	}
 
-	if _, err := d.dcc.Version(ctx); err != nil {
-		return errors.Wrapf(err, "could not find docker compose plugin")
+	// test the upgrade executor
+	logger.Infof("Setting up %s upgrade executor", cfg.GetExecutor())
+	if _, err := d.executor.Version(ctx); err != nil {
+		return errors.Wrapf(err, "could not find %s executor cli", cfg.GetExecutor())
 	}
 
+	// blazar may have been stopped in the middle of an upgrade, or the node may have halted at the upgrade height while
+	// blazar was down. The node RPC is likely unreachable in both cases, so the node is brought back first
+	recovered := d.recoverUpgrades(ctx, cfg)
+
 	// test cosmos client
 	logger.Info("Attempting to get data from /status endpoint with Cosmos RPC client")
-	status, err := d.cosmosClient.GetStatus(ctx)
+	var status *cosmos.StatusResponse
+	err := d.waitForNode(ctx, func() (err error) {
+		status, err = d.cosmosClient.GetStatus(ctx)
+		return err
+	})
 	if err != nil {
 		return errors.Wrapf(err, "failed to get status response")
 	}
 
+	// the websocket (used by the height watcher) can only be dialed once the node is up
+	err = d.waitForNode(ctx, d.cosmosClient.StartCometbftClient)
+	if err != nil {
+		return errors.Wrapf(err, "failed to start cometbft client")
+	}
+
 	if status.NodeInfo.Network != cfg.ChainID {
 		return fmt.Errorf("chain ID mismatch, expected: %s, got: %s", cfg.ChainID, status.NodeInfo.Network)
 	}
@@ -146,7 +205,10 @@ func (d *Daemon) Init(ctx context.Context, cfg *config.Config) error {
 	// display information about the node
 	if cfg.Compose.EnvPrefix == "" {
 		logger.Infof("No EnvPrefix found in config, fetching NodeInfo from GRPC")
-		d.nodeInfo, err = d.cosmosClient.NodeInfo(ctx)
+		err = d.waitForNode(ctx, func() (err error) {
+			d.nodeInfo, err = d.cosmosClient.NodeInfo(ctx)
+			return err
+		})
 		if err != nil {
 			return errors.Wrapf(err, "failed to get node info")
 		}
@@ -160,8 +222,10 @@ func (d *Daemon) Init(ctx context.Context, cfg *config.Config) error {
 	logger.Infof("Using env prefix: %s", cfg.Compose.EnvPrefix)
 
 	// ensure required settings and flags are present in the compose file
-	if err := validateComposeSettings(cfg); err != nil {
-		return errors.Wrapf(err, "failed to validate docker compose settings")
+	if cfg.GetExecutor() == config.ExecutorDockerCompose {
+		if err := validateComposeSettings(cfg); err != nil {
+			return errors.Wrapf(err, "failed to validate docker compose settings")
+		}
 	}
 
 	logger.Infof("Observed latest block height: %d", status.SyncInfo.LatestBlockHeight)
@@ -182,7 +246,11 @@ func (d *Daemon) Init(ctx context.Context, cfg *config.Config) error {
 
 	// test consensus state endpoint
 	logger.Info("Attempting to get consensus state")
-	pvp, err := d.cosmosClient.GetPrevoteInfo(ctx)
+	var pvp *cosmos.PrevoteInfo
+	err = d.waitForNode(ctx, func() (err error) {
+		pvp, err = d.cosmosClient.GetPrevoteInfo(ctx)
+		return err
+	})
 	if err != nil {
 		return errors.Wrapf(err, "failed to get consensus state")
 	}
@@ -197,6 +265,16 @@ func (d *Daemon) Init(ctx context.Context, cfg *config.Config) error {
 		return errors.Wrapf(err, "failed getting upgrades from all providers")
 	}
 
+	// the update succeeds with the cached data of the unreachable providers, make sure the operator knows about it
+	for providerType, info := range d.ur.SyncInfo().Providers {
+		if info.LastError != nil {
+			logger.Err(info.LastError).Warnf(
+				"Started with the cached upgrades and versions of the unreachable %s provider, last synced %s ago",
+				providerType, info.Staleness().Truncate(time.Second),
+			).Notify(ctx)
+		}
+	}
+
 	totalUpgrades := d.ur.GetAllUpgradesWithCache()
 	logger.Infof("Total %d resolved upgrades from all providers", len(totalUpgrades))
 
@@ -212,6 +290,11 @@ func (d *Daemon) Init(ctx context.Context, cfg *config.Config) error {
 		}
 	}
 
+	// the node is reachable again, finish the recovered upgrades
+	for _, upgradeHeight := range recovered {
+		d.finishUpgrade(ctx, cfg, upgradeHeight)
+	}
+
 	// export metrics related to all future proposal
 	d.updateMetrics()
 
@@ -302,7 +385,7 @@ func (d *Daemon) Run(ctx context.Context, cfg *config.Config) error {
 		ctxWithHeight := notification.WithUpgradeHeight(ctx, upgradeHeight)
 
 		// step 1: perform upgrade
-		err = d.performUpgrade(ctxWithHeight, &cfg.Compose, cfg.Checks.PreUpgrade.PullDockerImage, cfg.ComposeService, upgradeHeight)
+		err = d.performUpgrade(ctxWithHeight, &cfg.Compose, &cfg.Checks.PreUpgrade, cfg.ChainHome, cfg.ComposeService, upgradeHeight)
 		d.updateMetrics()
 
 		if err != nil {
@@ -313,21 +396,38 @@ func (d *Daemon) Run(ctx context.Context, cfg *config.Config) error {
 			continue
 		}
 
-		// step 2: wait for post-upgrade checks
-		err = d.postUpgradeChecks(ctxWithHeight, d.stateMachine, &cfg.Checks.PostUpgrade, upgradeHeight)
-		d.updateMetrics()
+		// step 2 and 3: wait for post-upgrade checks and mark the upgrade as completed
+		d.finishUpgrade(ctxWithHeight, cfg, upgradeHeight)
+	}
+}
 
-		if err != nil {
-			ctxWithHeight := notification.WithUpgradeHeight(ctx, upgradeHeight)
-			logger.Err(err).Error("Post-upgrade check failed").Notify(ctxWithHeight)
+// finishUpgrade waits for the post-upgrade checks and marks the upgrade as completed. If the checks fail, the upgrade
+// is rolled back if the rollback policy covers it
+func (d *Daemon) finishUpgrade(ctx context.Context, cfg *config.Config, upgradeHeight int64) {
+	logger := log.FromContext(ctx)
+	ctx = notification.WithUpgradeHeight(ctx, upgradeHeight)
 
-			// failure of post-upgrade check is not a critical error, therefore we let the daemon continue to run
-			continue
+	err := d.postUpgradeChecks(ctx, d.stateMachine, &cfg.Checks.PostUpgrade, cfg.ComposeService, upgradeHeight)
+	d.updateMetrics()
+
+	if err != nil {
+		logger.Err(err).Error("Post-upgrade check failed").Notify(ctx)
+
+		// restore the previous version if the rollback policy covers this upgrade
+		if d.shouldRollback(cfg.Checks.PostUpgrade.Rollback, upgradeHeight) {
+			err = d.rollback(ctx, &cfg.Compose, &cfg.Checks.PostUpgrade, cfg.ComposeService, upgradeHeight)
+			d.updateMetrics()
+
+			if err != nil {
+				logger.Err(err).Error("Rollback failed, manual intervention is required").Notify(ctx)
+			}
 		}
 
-		// step 3: mark upgrade as completed
-		d.MustSetStatus(upgradeHeight, urproto.UpgradeStatus_COMPLETED)
+		// failure of post-upgrade check is not a critical error, therefore we let the daemon continue to run
+		return
 	}
+
+	d.MustSetStatus(upgradeHeight, urproto.UpgradeStatus_COMPLETED)
 }
 
 func (d *Daemon) waitForUpgrade(ctx context.Context, cfg *config.Config) (int64, error) {
@@ -384,6 +484,11 @@ func (d *Daemon) waitForUpgrade(ctx context.Context, cfg *config.Config) (int64,
 			// move to core logic
 			d.updateMetrics()
 
+			// let the operator know which images the node ran, once all past upgrades are replayed
+			if d.syncFromGenesis {
+				d.reportSyncFromGenesis(ctx, cfg.ComposeService)
+			}
+
 			upcomingUpgrades := d.ur.GetUpcomingUpgradesWithCache(d.currHeight, urproto.UpgradeStatus_ACTIVE)
 			if len(upcomingUpgrades) > 0 {
 				futureUpgrade := upcomingUpgrades[0]
@@ -401,7 +506,7 @@ func (d *Daemon) waitForUpgrade(ctx context.Context, cfg *config.Config) (int64,
 
 				// perform pre upgrade upgrade checks if we are close to the upgrade height
 				if futureUpgrade.Height < d.currHeight+cfg.Checks.PreUpgrade.Blocks {
-					newHeight, preErr := d.preUpgradeChecks(ctx, d.currHeight, d.stateMachine, d.dcc, &cfg.Compose, &cfg.Checks.PreUpgrade, cfg.ComposeService, futureUpgrade, cfg.UpgradeRegistry.Network)
+					newHeight, preErr := d.preUpgradeChecks(ctx, d.currHeight, d.stateMachine, d.executor, &cfg.Compose, &cfg.Checks.PreUpgrade, cfg.ComposeService, futureUpgrade, cfg.UpgradeRegistry.Network)
 					if preErr != nil {
 						d.MustSetStatus(futureUpgrade.Height, urproto.UpgradeStatus_FAILED)
 					}
@@ -450,10 +555,29 @@ func (d *Daemon) waitForUpgrade(ctx context.Context, cfg *config.Config) (int64,
 	}
 }
 
+// currentAppVersion returns the software version reported by the node. At the upgrade height the node may have
+// halted already, in which case the node info fetched at startup is used
+func (d *Daemon) currentAppVersion(ctx context.Context) *cosmos.AppVersion {
+	appVersion, err := d.cosmosClient.GetAppVersion(ctx)
+	if err == nil {
+		return appVersion
+	}
+
+	log.FromContext(ctx).Err(err).Warn("Failed to get the current app version from the node, using the node info fetched at startup")
+	if d.nodeInfo == nil || d.nodeInfo.ApplicationVersion == nil {
+		return nil
+	}
+	return &cosmos.AppVersion{
+		Version:   d.nodeInfo.ApplicationVersion.Version,
+		GitCommit: d.nodeInfo.ApplicationVersion.GitCommit,
+	}
+}
+
 func (d *Daemon) performUpgrade(
 	ctx context.Context,
 	composeConfig *config.ComposeCli,
-	pullImageConfig *config.PullDockerImage,
+	preUpgradeConfig *config.PreUpgrade,
+	chainHome string,
 	serviceName string,
 	upgradeHeight int64,
 ) (err error) {
@@ -463,14 +587,30 @@ func (d *Daemon) performUpgrade(
 			d.MustSetStatus(upgradeHeight, urproto.UpgradeStatus_FAILED)
 		}
 	}()
+
+	// without the persisted state a restart in the middle of the upgrade would start it from scratch
+	if d.requirePersistence {
+		if persistErr := d.stateMachine.Persist(ctx); persistErr != nil {
+			return errors.Wrapf(persistErr, "refusing to execute the upgrade at height %d, the state machine state can't be persisted", upgradeHeight)
+		}
+	}
 	ctx = notification.WithUpgradeHeight(ctx, upgradeHeight)
 
+	if d.syncFromGenesis {
+		d.syncReplayedUpgrade(ctx, upgradeHeight)
+	}
+
 	d.MustSetStatus(upgradeHeight, urproto.UpgradeStatus_EXECUTING)
 
 	logger := log.FromContext(ctx)
 
 	logger.Infof("Upgrade height %d has been detected, performing upgrade", upgradeHeight).Notify(ctx)
 
+	// the upgrade may have been changed or cancelled in a provider we failed to sync with
+	if err = d.checkStaleness(ctx, upgradeHeight); err != nil {
+		return err
+	}
+
 	// ensure the upgrade is still valid
 	upgrade := d.ur.GetUpgradeWithCache(upgradeHeight)
 	if upgrade == nil {
@@ -479,55 +619,216 @@ func (d *Daemon) performUpgrade(
 
 	logger.Infof("Upgrade provided to blazar by %s provider\nType: %s\nTag: %s\nName: %s", upgrade.Source.String(), upgrade.Type.String(), upgrade.Tag, upgrade.Name).Notify(ctx)
 
+	// the upgrade may have been re-registered with another tag or expected version since the check was run
+	if slices.Contains(preUpgradeConfig.Enabled, checksproto.PreCheck_VERIFY_BINARY_VERSION.String()) && d.isBinaryVersionCheckStale(upgrade) {
+		d.checkBinaryVersion(ctx, d.executor, preUpgradeConfig, serviceName, upgrade)
+	}
+	if d.stateMachine.GetPreCheckStatus(upgradeHeight, checksproto.PreCheck_VERIFY_BINARY_VERSION) == checksproto.CheckStatus_ERROR {
+		return fmt.Errorf("pre upgrade check %s failed, the upgrade binary doesn't report the expected version", checksproto.PreCheck_VERIFY_BINARY_VERSION)
+	}
+
 	// sanity check to ensure we are not performing upgrades at wrong times
 	if upgradeHeight < d.currHeight {
 		return fmt.Errorf("upgrade height %d is less than last observed height %d", upgradeHeight, d.currHeight)
 	}
 
-	// ensure the docker image is present on the host (this should be done in a pre-check phase though). Better safe than sorry
+	// ensure the docker image (or binary) is present on the host (this should be done in a pre-check phase though). Better safe than sorry
 	var currImage, newImage string
-	currImage, newImage, err = checks.PullDockerImage(ctx, d.dcc, serviceName, upgrade.Tag, upgrade.Height, pullImageConfig.MaxRetries, pullImageConfig.InitialBackoff)
+	currImage, newImage, err = d.executor.PrepareUpgrade(ctx, serviceName, upgrade, preUpgradeConfig.PullDockerImage)
 	if err != nil {
 		return err
 	}
 
 	logger.Infof("Current image: %s. New image: %s found on the host", currImage, newImage).Notify(ctx)
+	d.stateMachine.SetUpgradeImage(upgradeHeight, newImage)
+
+	// ensure we run the image verified by the pre-upgrade check
+	if err = d.verifyImageDigest(ctx, preUpgradeConfig.PullDockerImage, newImage, upgradeHeight); err != nil {
+		return err
+	}
+
+	newVersions := upgradeVersions(serviceName, upgrade)
+	if len(upgrade.Services) > 0 {
+		logger.Infof("Services upgraded together with %s: %v", serviceName, upgrade.Services).Notify(ctx)
+	}
+
+	// remember the current versions, in case the upgrade needs to be rolled back. A resumed upgrade keeps the versions
+	// recorded before the compose file was changed
+	if d.stateMachine.GetPreviousVersions(upgradeHeight) == nil {
+		prevVersions := make(map[string]string, len(newVersions))
+		for name := range newVersions {
+			prevVersion, err := d.executor.GetCurrentVersion(name)
+			if err != nil {
+				logger.Err(err).Warnf("Failed to get the current version of the service %s, rollback won't be possible", name)
+				prevVersions = nil
+				break
+			}
+			prevVersions[name] = prevVersion
+		}
+		if prevVersions != nil {
+			d.stateMachine.SetPreviousVersions(upgradeHeight, prevVersions)
+		}
+	}
+
+	// remember the software the node runs, the APP_VERSION_MATCHES post-check expects it to change
+	if d.stateMachine.GetAppVersion(upgradeHeight) == nil {
+		if appVersion := d.currentAppVersion(ctx); appVersion != nil {
+			d.stateMachine.SetAppVersion(upgradeHeight, &sm.AppVersion{
+				Version:    appVersion.Version,
+				GitCommit:  appVersion.GitCommit,
+				AppVersion: appVersion.AppVersion,
+			})
+		}
+	}
+	d.snapshotModuleVersions(ctx, upgradeHeight)
+
+	// all changes must be applicable before we take the node down
+	if err = d.executor.ValidateUpgradeImages(ctx, newVersions); err != nil {
+		return errors.Wrapf(err, "upgrade can't be applied")
+	}
+
+	// the versions are needed to resume the upgrade if blazar is stopped while the node is down
+	d.stateMachine.SetUpgradeVersions(upgradeHeight, newVersions)
 	d.MustSetStatusAndStep(upgradeHeight, urproto.UpgradeStatus_EXECUTING, urproto.UpgradeStep_COMPOSE_FILE_UPGRADE)
 
-	// take container down or check if it is down already
-	isRunning, err := d.dcc.IsServiceRunning(ctx, serviceName, composeConfig.DownTimeout)
+	if err = d.applyUpgrade(ctx, composeConfig, preUpgradeConfig, chainHome, upgradeHeight, newVersions); err != nil {
+		return err
+	}
+
+	msg := fmt.Sprintf("Upgrade completed. New image: %s. Now waiting for post-upgrade check to pass", newImage)
+	logger.Info(msg).Notify(ctx)
+
+	return nil
+}
+
+// applyUpgrade stops the services, backs up the chain data if enabled and starts the services with the new versions
+func (d *Daemon) applyUpgrade(
+	ctx context.Context,
+	composeConfig *config.ComposeCli,
+	preUpgradeConfig *config.PreUpgrade,
+	chainHome string,
+	upgradeHeight int64,
+	newVersions map[string]string,
+) error {
+	logger := log.FromContext(ctx)
+
+	// take containers down or check if they are down already
+	serviceNames := docker.ServiceNames(newVersions)
+	isRunning, err := d.isAnyServiceRunning(ctx, serviceNames, composeConfig.DownTimeout)
 	if err != nil {
-		return errors.Wrapf(err, "failed to check if service is running")
+		return err
 	}
 
 	// This check is prone to race conditions, the image could be up at this point
-	// but exits before dcc.Down is called. However, at this point we are certain
+	// but exits before executor.DownServices is called. However, at this point we are certain
 	// that upgrade height has been hit, so, it should be safe to Down an exited
 	// container.
 	if isRunning {
 		logger.Info("Executing compose down").Notify(ctx)
-		if err = d.dcc.Down(ctx, serviceName, composeConfig.DownTimeout); err != nil {
+		if err = d.executor.DownServices(ctx, serviceNames, composeConfig.DownTimeout); err != nil {
 			return errors.Wrapf(err, "failed to down compose")
 		}
 	}
 
+	// the node is stopped at this point, so the chain data is consistent
+	if slices.Contains(preUpgradeConfig.Enabled, checksproto.PreCheck_BACKUP_DATA.String()) {
+		if err = d.backupData(ctx, preUpgradeConfig.BackupData, chainHome, upgradeHeight); err != nil {
+			return errors.Wrapf(err, "failed to backup chain data")
+		}
+	}
+
 	logger.Info("Changing image in compose file").Notify(ctx)
-	if err = d.dcc.UpgradeImage(ctx, serviceName, upgrade.Tag); err != nil {
+	if err = d.executor.UpgradeImages(ctx, newVersions); err != nil {
 		return errors.Wrapf(err, "failed to upgrade image")
 	}
 
 	logger.Info("Executing compose up").Notify(ctx)
 
-	if err = d.dcc.Up(ctx, serviceName, composeConfig.UpDeadline); err != nil {
+	if err = d.executor.UpServices(ctx, serviceNames, composeConfig.UpDeadline); err != nil {
 		return errors.Wrapf(err, "failed to up compose")
 	}
 
-	msg := fmt.Sprintf("Upgrade completed. New image: %s. Now waiting for post-upgrade check to pass", newImage)
-	logger.Info(msg).Notify(ctx)
+	return nil
+}
 
+// checkStaleness returns an error if the upgrade was resolved from provider data older than the configured maximum.
+// The registry is synced once more before giving up, the providers may have recovered since the last sync
+func (d *Daemon) checkStaleness(ctx context.Context, upgradeHeight int64) error {
+	if d.maxStaleness == 0 {
+		return nil
+	}
+	logger := log.FromContext(ctx)
+
+	staleness, source, ok := d.ur.Staleness(upgradeHeight)
+	if !ok || staleness <= d.maxStaleness {
+		return nil
+	}
+
+	logger.Warnf("The %s provider data is %s old, syncing the upgrade registry before the upgrade", source, staleness.Truncate(time.Second))
+	if _, _, _, _, err := d.ur.Update(ctx, d.currHeight, true); err != nil {
+		logger.Err(err).Warn("Failed to sync the upgrade registry")
+	}
+
+	staleness, source, ok = d.ur.Staleness(upgradeHeight)
+	if ok && staleness > d.maxStaleness {
+		return fmt.Errorf(
+			"upgrade blocked, the %s provider didn't sync successfully for %s (max-staleness is %s)",
+			source, staleness.Truncate(time.Second), d.maxStaleness,
+		)
+	}
 	return nil
 }
 
+// verifyImageDigest compares the digest of the upgrade image with the one pinned by the pre-upgrade check. The image
+// is pinned again if the upgrade was re-registered with another image since the check
+func (d *Daemon) verifyImageDigest(ctx context.Context, cfg *config.PullDockerImage, newImage string, upgradeHeight int64) error {
+	logger := log.FromContext(ctx)
+
+	pinnedImage, pinnedDigest := d.stateMachine.GetPinnedImage(upgradeHeight), d.stateMachine.GetImageDigest(upgradeHeight)
+	if pinnedDigest == "" {
+		logger.Infof("No digest was pinned for %s, skipping verification", newImage)
+		return nil
+	}
+
+	digest, err := d.executor.ArtifactDigest(ctx, newImage)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get the digest of %s", newImage)
+	}
+
+	if pinnedImage != newImage {
+		logger.Warnf(
+			"Upgrade image %s differs from the image %s pinned by the pre-upgrade check, the upgrade was likely re-registered. Pinning digest: %s",
+			newImage, pinnedImage, digest,
+		).Notify(ctx)
+		d.stateMachine.SetImageDigest(upgradeHeight, newImage, digest)
+		return nil
+	}
+
+	if digest == pinnedDigest {
+		logger.Infof("Image %s matches the pinned digest: %s", newImage, pinnedDigest).Notify(ctx)
+		return nil
+	}
+
+	if cfg.GetOnDigestMismatch() == config.DigestMismatchWarn {
+		logger.Warnf("Image %s changed since the pre-upgrade check, pinned digest: %s, current digest: %s. Continuing as configured", newImage, pinnedDigest, digest).Notify(ctx)
+		return nil
+	}
+	return fmt.Errorf("image %s changed since the pre-upgrade check, pinned digest: %s, current digest: %s", newImage, pinnedDigest, digest)
+}
+
+func (d *Daemon) isAnyServiceRunning(ctx context.Context, serviceNames []string, timeout time.Duration) (bool, error) {
+	for _, serviceName := range serviceNames {
+		isRunning, err := d.executor.IsServiceRunning(ctx, serviceName, timeout)
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to check if service %s is running", serviceName)
+		}
+		if isRunning {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 func (d *Daemon) updateHeightAndBlockSpeed(newHeight int64) {
 	if d.currHeight == newHeight {
 		return
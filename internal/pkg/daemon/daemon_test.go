@@ -47,19 +47,31 @@ import (
 var (
 	simd1RepoTag string
 	simd2RepoTag string
+
+	buildTestImagesOnce sync.Once
+
+	testMetricsOnce sync.Once
+	testMetrics     *metrics.Metrics
 )
 
-func TestMain(m *testing.M) {
-	dockerProvider, err := testcontainers.NewDockerProvider()
-	if err != nil {
-		fmt.Println("failed to create docker provider")
-		os.Exit(1)
-	}
+// buildTestImages builds the test simapp images (v0.0.1 and v0.0.2), only the integration tests need docker
+func buildTestImages(t *testing.T) {
+	buildTestImagesOnce.Do(func() {
+		dockerProvider, err := testcontainers.NewDockerProvider()
+		if err != nil {
+			t.Fatalf("failed to create docker provider: %v", err)
+		}
 
-	// build test simapp images (v0.0.1 and v0.0.2)
-	simd1RepoTag, simd2RepoTag = testutils.BuildTestImages(context.Background(), dockerProvider)
+		simd1RepoTag, simd2RepoTag = testutils.BuildTestImages(context.Background(), dockerProvider)
+	})
+}
 
-	os.Exit(m.Run())
+// getTestMetrics returns the metrics shared by all tests, as we can't register 2 metrics
+func getTestMetrics() *metrics.Metrics {
+	testMetricsOnce.Do(func() {
+		testMetrics = metrics.NewMetrics("/path/to/docker-compose.yml", "dummy", "test", "chain-id")
+	})
+	return testMetrics
 }
 
 // Blazar end-to-end integration test for LOCAL and DATABASE providers.
@@ -74,8 +86,8 @@ func TestIntegrationDaemon(t *testing.T) {
 		}
 	}()
 
-	// we can't register 2 metrics, but this sharing this should probably cause no problems
-	metrics := metrics.NewMetrics("/path/to/docker-compose.yml", "dummy", "test", "chain-id")
+	buildTestImages(t)
+	metrics := getTestMetrics()
 
 	ports := getFreePorts(t, 6)
 
@@ -239,7 +251,7 @@ func startAndTestGovUpgrade(ctx context.Context, t *testing.T, daemon *Daemon, c
 	requirePreCheckStatus(t, daemon.stateMachine, 10)
 
 	// perform the upgrade
-	err = daemon.performUpgrade(ctx, &cfg.Compose, cfg.Checks.PreUpgrade.PullDockerImage, cfg.ComposeService, height)
+	err = daemon.performUpgrade(ctx, &cfg.Compose, &cfg.Checks.PreUpgrade, cfg.ChainHome, cfg.ComposeService, height)
 	require.NoError(t, err)
 
 	// ensure the upgrade was successful
@@ -254,7 +266,7 @@ func startAndTestGovUpgrade(ctx context.Context, t *testing.T, daemon *Daemon, c
 	require.Contains(t, outBuffer.String(), fmt.Sprintf("Upgrade completed. New image: %s", simd2RepoTag))
 
 	// lets see if post upgrade checks pass
-	err = daemon.postUpgradeChecks(ctx, daemon.stateMachine, &cfg.Checks.PostUpgrade, height)
+	err = daemon.postUpgradeChecks(ctx, daemon.stateMachine, &cfg.Checks.PostUpgrade, cfg.ComposeService, height)
 	require.NoError(t, err)
 
 	requirePostCheckStatus(t, daemon.stateMachine, 10)
@@ -273,7 +285,7 @@ func runNonChain(t *testing.T, metrics *metrics.Metrics, prvdr provider.UpgradeP
 	outBuffer, ctx := injectTestLogger(cfg)
 
 	// compose client with logger
-	dcc, err := docker.NewDefaultComposeClient(ctx, nil, cfg.VersionFile, cfg.ComposeFile, cfg.UpgradeMode)
+	dcc, err := docker.NewDefaultComposeClient(ctx, nil, cfg.VersionFile, cfg.ComposeFile, cfg.UpgradeMode, cfg.Compose.ProjectWide)
 	require.NoError(t, err)
 
 	// ensure we run container with current user (not root!)
@@ -333,6 +345,7 @@ func runNonChain(t *testing.T, metrics *metrics.Metrics, prvdr provider.UpgradeP
 
 	daemon := Daemon{
 		dcc:                 dcc,
+		executor:            newComposeExecutor(dcc),
 		ur:                  ur,
 		stateMachine:        sm,
 		metrics:             metrics,
@@ -365,14 +378,14 @@ func runNonChain(t *testing.T, metrics *metrics.Metrics, prvdr provider.UpgradeP
 
 	requirePreCheckStatus(t, sm, 13)
 
-	err = daemon.performUpgrade(ctx, &cfg.Compose, cfg.Checks.PreUpgrade.PullDockerImage, cfg.ComposeService, height)
+	err = daemon.performUpgrade(ctx, &cfg.Compose, &cfg.Checks.PreUpgrade, cfg.ChainHome, cfg.ComposeService, height)
 	require.NoError(t, err)
 
 	require.Contains(t, outBuffer.String(), "Executing compose up")
 	require.Contains(t, outBuffer.String(), fmt.Sprintf("Upgrade completed. New image: %s", simd2RepoTag))
 
 	// Lets see if post upgrade checks pass
-	err = daemon.postUpgradeChecks(ctx, sm, &cfg.Checks.PostUpgrade, height)
+	err = daemon.postUpgradeChecks(ctx, sm, &cfg.Checks.PostUpgrade, cfg.ComposeService, height)
 	require.NoError(t, err)
 
 	requirePostCheckStatus(t, sm, 13)
@@ -412,11 +425,11 @@ func runNonChain(t *testing.T, metrics *metrics.Metrics, prvdr provider.UpgradeP
 
 	requirePreCheckStatus(t, sm, 19)
 
-	err = daemon.performUpgrade(ctx, &cfg.Compose, cfg.Checks.PreUpgrade.PullDockerImage, cfg.ComposeService, height)
+	err = daemon.performUpgrade(ctx, &cfg.Compose, &cfg.Checks.PreUpgrade, cfg.ChainHome, cfg.ComposeService, height)
 	require.NoError(t, err)
 
 	// lets see if post upgrade checks pass
-	err = daemon.postUpgradeChecks(ctx, sm, &cfg.Checks.PostUpgrade, height)
+	err = daemon.postUpgradeChecks(ctx, sm, &cfg.Checks.PostUpgrade, cfg.ComposeService, height)
 	require.NoError(t, err)
 
 	requirePostCheckStatus(t, sm, 19)
@@ -435,7 +448,7 @@ func runChain(t *testing.T, metrics *metrics.Metrics, tempDir, serviceName strin
 	outBuffer, ctx := injectTestLogger(cfg)
 
 	// compose client with logger
-	dcc, err := docker.NewDefaultComposeClient(ctx, nil, cfg.VersionFile, cfg.ComposeFile, cfg.UpgradeMode)
+	dcc, err := docker.NewDefaultComposeClient(ctx, nil, cfg.VersionFile, cfg.ComposeFile, cfg.UpgradeMode, cfg.Compose.ProjectWide)
 	require.NoError(t, err)
 
 	setUIDEnv(t)
@@ -443,13 +456,14 @@ func runChain(t *testing.T, metrics *metrics.Metrics, tempDir, serviceName strin
 	cosmosClient, err := cosmos.NewClient(cfg.Clients.Host, cfg.Clients.GrpcPort, cfg.Clients.CometbftPort, cfg.Clients.Timeout)
 	require.NoError(t, err)
 
-	prvdr := chain.NewProvider(cosmosClient, "test", 1)
+	prvdr := chain.NewProvider(cosmosClient, "test", 1, false, nil)
 
 	// initialize new upgrade registry
 	ur, sm := initUrSm(t, urproto.ProviderType_CHAIN, prvdr, tempDir)
 
 	daemon := Daemon{
 		dcc:                 dcc,
+		executor:            newComposeExecutor(dcc),
 		ur:                  ur,
 		stateMachine:        sm,
 		metrics:             metrics,
@@ -615,5 +629,5 @@ func prepareMockDatabaseProvider() (*database.Provider, error) {
 	if err != nil {
 		return nil, errors.Wrapf(err, "database migration failed for versions table")
 	}
-	return database.NewDatabaseProviderWithDB(db, "test", 1), nil
+	return database.NewDatabaseProviderWithDB(db, "test", 1, "test"), nil
 }
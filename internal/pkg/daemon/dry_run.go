@@ -0,0 +1,59 @@
+package daemon
+
+import (
+	"context"
+	"time"
+
+	"blazar/internal/pkg/config"
+	"blazar/internal/pkg/log"
+	urproto "blazar/internal/pkg/proto/upgrades_registry"
+)
+
+// dryRunExecutor wraps an executor and replaces all operations modifying the host (image pulls, file edits,
+// stopping and starting services) with logged no-ops. Read-only operations are passed through
+type dryRunExecutor struct {
+	Executor
+}
+
+func newDryRunExecutor(executor Executor) *dryRunExecutor {
+	return &dryRunExecutor{executor}
+}
+
+func (e *dryRunExecutor) PrepareUpgrade(ctx context.Context, serviceName string, upgrade *urproto.Upgrade, _ *config.PullDockerImage) (string, string, error) {
+	log.FromContext(ctx).Infof("[dry-run] Skipping preparation (image pull) of upgrade tag %s, additional services: %v", upgrade.Tag, upgrade.Services)
+
+	currVersion, err := e.GetCurrentVersion(serviceName)
+	if err != nil {
+		return "", "", err
+	}
+	return currVersion, upgrade.Tag, nil
+}
+
+// ValidateUpgradeImages reports validation errors without failing the upgrade, because in the
+// dry-run mode the images are not pulled, hence they are likely missing on the host
+func (e *dryRunExecutor) ValidateUpgradeImages(ctx context.Context, newVersions map[string]string) error {
+	if err := e.Executor.ValidateUpgradeImages(ctx, newVersions); err != nil {
+		log.FromContext(ctx).Err(err).Warnf("[dry-run] Upgrade to %v would fail validation unless the images are pulled", newVersions)
+	}
+	return nil
+}
+
+func (e *dryRunExecutor) UpgradeImages(ctx context.Context, newVersions map[string]string) error {
+	log.FromContext(ctx).Infof("[dry-run] Skipping upgrade of images to %v", newVersions)
+	return nil
+}
+
+func (e *dryRunExecutor) DownServices(ctx context.Context, serviceNames []string, _ time.Duration) error {
+	log.FromContext(ctx).Infof("[dry-run] Skipping stop of services %v", serviceNames)
+	return nil
+}
+
+func (e *dryRunExecutor) UpServices(ctx context.Context, serviceNames []string, _ time.Duration, ephemeralEnvVars ...string) error {
+	log.FromContext(ctx).Infof("[dry-run] Skipping start of services %v with env %v", serviceNames, ephemeralEnvVars)
+	return nil
+}
+
+func (e *dryRunExecutor) RestartServiceWithHaltHeight(ctx context.Context, _ *config.ComposeCli, serviceName string, upgradeHeight int64) error {
+	log.FromContext(ctx).Infof("[dry-run] Skipping restart of service %s with halt height %d", serviceName, upgradeHeight)
+	return nil
+}
@@ -0,0 +1,164 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"blazar/internal/pkg/cmd"
+	"blazar/internal/pkg/config"
+	"blazar/internal/pkg/daemon/checks"
+	"blazar/internal/pkg/docker"
+	"blazar/internal/pkg/errors"
+	"blazar/internal/pkg/log"
+	urproto "blazar/internal/pkg/proto/upgrades_registry"
+	"blazar/internal/pkg/systemd"
+)
+
+// Executor is responsible for stopping, upgrading and starting the chain node process.
+// The state machine, checks and notifications are independent of the executor in use.
+type Executor interface {
+	// PrepareUpgrade ensures the artifacts (docker images or binary) for the upgrade are present on the host
+	// return current artifact, upgrade artifact of the main service, error
+	PrepareUpgrade(ctx context.Context, serviceName string, upgrade *urproto.Upgrade, pullImageConfig *config.PullDockerImage) (string, string, error)
+	// ArtifactDigest returns the digest of the upgrade artifact returned by PrepareUpgrade (docker image ID or binary checksum)
+	ArtifactDigest(ctx context.Context, artifact string) (string, error)
+	// BinaryVersion runs `<appName> version --long` from the upgrade artifact returned by PrepareUpgrade and returns its output
+	BinaryVersion(ctx context.Context, artifact, appName string, timeout time.Duration) (string, error)
+	// GetCurrentVersion returns the version of the service in the form accepted by UpgradeImages,
+	// such that passing it back restores the current state
+	GetCurrentVersion(serviceName string) (string, error)
+	// ValidateUpgradeImages ensures UpgradeImages can be applied, without changing anything
+	ValidateUpgradeImages(ctx context.Context, newVersions map[string]string) error
+	// UpgradeImages switches all services (service name -> tag or image) to the new versions at once
+	UpgradeImages(ctx context.Context, newVersions map[string]string) error
+	IsServiceRunning(ctx context.Context, serviceName string, timeout time.Duration) (bool, error)
+	// DownServices stops the given services (and only them, unless configured otherwise)
+	DownServices(ctx context.Context, serviceNames []string, timeout time.Duration) error
+	// UpServices starts the given services (and only them, unless configured otherwise)
+	UpServices(ctx context.Context, serviceNames []string, timeout time.Duration, ephemeralEnvVars ...string) error
+	RestartServiceWithHaltHeight(ctx context.Context, composeConfig *config.ComposeCli, serviceName string, upgradeHeight int64) error
+	// FollowLogs streams the logs of the running service since it started, until the reader is closed
+	FollowLogs(ctx context.Context, serviceName string) (io.ReadCloser, error)
+	Version(ctx context.Context) (string, error)
+}
+
+// timeout for the docker compose cli calls made outside of the upgrade itself
+const composeCliTimeout = 10 * time.Second
+
+// newExecutor creates the executor configured for the node, the docker clients are nil with the systemd executor
+func newExecutor(ctx context.Context, cfg *config.Config) (Executor, *docker.Client, *docker.ComposeClient, error) {
+	if cfg.GetExecutor() == config.ExecutorSystemd {
+		return systemd.NewClient(cfg.Systemd), nil, nil, nil
+	}
+
+	if _, err := docker.LoadComposeFile(cfg.ComposeFile); err != nil {
+		return nil, nil, nil, errors.Wrapf(err, "failed to parse docker compose file")
+	}
+
+	// setup docker compose client
+	dc, err := docker.NewClientWithConfig(ctx, cfg.CredentialHelper)
+	if err != nil {
+		return nil, nil, nil, errors.Wrapf(err, "failed to create docker client")
+	}
+	dcc, err := docker.NewComposeClient(dc, cfg.VersionFile, cfg.ComposeFile, cfg.UpgradeMode, cfg.Compose.ProjectWide)
+	if err != nil {
+		return nil, nil, nil, errors.Wrapf(err, "failed to create docker compose client")
+	}
+	return newComposeExecutor(dcc), dc, dcc, nil
+}
+
+// IsNodeRunning reports whether the node service is running, e.g. before the chain data is restored from a backup
+func IsNodeRunning(ctx context.Context, cfg *config.Config) (bool, error) {
+	executor, _, _, err := newExecutor(ctx, cfg)
+	if err != nil {
+		return false, err
+	}
+
+	isRunning, err := executor.IsServiceRunning(ctx, cfg.ComposeService, composeCliTimeout)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to check if service %s is running", cfg.ComposeService)
+	}
+	return isRunning, nil
+}
+
+// composeExecutor runs the upgrade with docker compose
+type composeExecutor struct {
+	*docker.ComposeClient
+}
+
+func newComposeExecutor(dcc *docker.ComposeClient) *composeExecutor {
+	return &composeExecutor{dcc}
+}
+
+func (e *composeExecutor) PrepareUpgrade(ctx context.Context, serviceName string, upgrade *urproto.Upgrade, pullImageConfig *config.PullDockerImage) (string, string, error) {
+	currImage, newImage, err := checks.PullDockerImage(ctx, e.ComposeClient, serviceName, upgrade.Tag, upgrade.Height, pullImageConfig.MaxRetries, pullImageConfig.InitialBackoff)
+	if err != nil {
+		return "", "", err
+	}
+
+	if len(upgrade.Services) > 0 {
+		newImages, err := checks.PullServiceImages(ctx, e.ComposeClient, upgrade.Services, upgrade.Height, pullImageConfig.MaxRetries, pullImageConfig.InitialBackoff)
+		if err != nil {
+			return "", "", err
+		}
+		log.FromContext(ctx).Infof("Additional services images: %v found on the host", newImages)
+	}
+
+	return currImage, newImage, nil
+}
+
+func (e *composeExecutor) ArtifactDigest(ctx context.Context, image string) (string, error) {
+	return e.DockerClient().GetImageID(ctx, image)
+}
+
+// BinaryVersion runs the version command in a throwaway container, without network access
+func (e *composeExecutor) BinaryVersion(ctx context.Context, image, appName string, timeout time.Duration) (string, error) {
+	args := []string{"run", "--rm", "--pull", "never", "--network", "none", "--entrypoint", appName, image, "version", "--long"}
+
+	// cosmos-sdk binaries print the version to stderr or stdout depending on the sdk version
+	stdout, stderr, err := cmd.CheckOutputWithDeadline(ctx, timeout, []string{}, "docker", args...)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to run %s version in image %s: %s", appName, image, stderr.String())
+	}
+	return stdout.String() + stderr.String(), nil
+}
+
+func (e *composeExecutor) FollowLogs(ctx context.Context, serviceName string) (io.ReadCloser, error) {
+	containerID, err := e.GetContainerID(ctx, serviceName, composeCliTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if containerID == "" {
+		return nil, fmt.Errorf("no container found for service %s", serviceName)
+	}
+
+	return e.DockerClient().FollowContainerLogs(ctx, containerID)
+}
+
+func (e *composeExecutor) GetCurrentVersion(serviceName string) (string, error) {
+	// in compose-file mode the image may be swapped entirely, so we need the full image reference
+	if e.UpgradeMode() == config.UpgradeInComposeFile {
+		image, version, err := e.GetImageAndVersionFromCompose(serviceName)
+		if err != nil {
+			return "", err
+		}
+		return docker.JoinImageName(image, version), nil
+	}
+
+	return e.GetVersionForService(serviceName)
+}
+
+// upgradeVersions returns all services changed by the upgrade (service name -> tag or image)
+func upgradeVersions(serviceName string, upgrade *urproto.Upgrade) map[string]string {
+	newVersions := make(map[string]string, len(upgrade.Services)+1)
+	for name, tagOrImage := range upgrade.Services {
+		newVersions[name] = tagOrImage
+	}
+
+	// the upgrade tag always wins for the main service
+	newVersions[serviceName] = upgrade.Tag
+
+	return newVersions
+}
@@ -8,6 +8,7 @@ import (
 
 	"blazar/internal/pkg/config"
 	"blazar/internal/pkg/cosmos"
+	"blazar/internal/pkg/daemon/checks"
 	"blazar/internal/pkg/errors"
 	blazarproto "blazar/internal/pkg/proto/blazar"
 	urproto "blazar/internal/pkg/proto/upgrades_registry"
@@ -96,6 +97,10 @@ func (s *Server) ListUpgrades(ctx context.Context, in *urproto.ListUpgradesReque
 
 		upgrade.Status = stateMachine.GetStatus(upgrade.Height)
 		upgrade.Step = stateMachine.GetStep(upgrade.Height)
+		upgrade.ImageDigest = stateMachine.GetImageDigest(upgrade.Height)
+		if moduleVersions := stateMachine.GetModuleVersions(upgrade.Height); moduleVersions.Before != nil && moduleVersions.After != nil {
+			upgrade.ModuleVersionsDiff = checks.ModuleVersionsDiff(moduleVersions.Before, moduleVersions.After)
+		}
 
 		if len(in.Status) > 0 && !slices.Contains(in.Status, upgrade.Status) {
 			continue
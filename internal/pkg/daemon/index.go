@@ -4,6 +4,7 @@ import (
 	"cmp"
 	"encoding/base64"
 	"fmt"
+	"maps"
 	"math"
 	"net/http"
 	"slices"
@@ -19,6 +20,14 @@ import (
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 )
 
+type providerSyncRow struct {
+	Provider          string
+	LastSuccessTime   string
+	Staleness         string
+	ConsecutiveErrors int
+	LastError         string
+}
+
 func RegisterIndexHandler(mux *runtime.ServeMux, d *Daemon, upInterval time.Duration) error {
 	return mux.HandlePath("GET", "/", func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
 		funcs := template.FuncMap{
@@ -98,6 +107,25 @@ func RegisterIndexHandler(mux *runtime.ServeMux, d *Daemon, upInterval time.Dura
 			warning = "Blazar haven't synced with the Cosmos network yet. Please wait for the first sync to complete."
 		}
 
+		providerSync, failingProviders := make([]providerSyncRow, 0, len(syncInfo.Providers)), make([]string, 0)
+		for _, providerType := range slices.Sorted(maps.Keys(syncInfo.Providers)) {
+			info := syncInfo.Providers[providerType]
+			row := providerSyncRow{
+				Provider:          providerType.String(),
+				LastSuccessTime:   info.LastSuccessTime.UTC().Format(time.RFC3339),
+				Staleness:         info.Staleness().Truncate(time.Second).String(),
+				ConsecutiveErrors: info.ConsecutiveErrors,
+			}
+			if info.LastError != nil {
+				row.LastError = info.LastError.Error()
+				failingProviders = append(failingProviders, row.Provider)
+			}
+			providerSync = append(providerSync, row)
+		}
+		if len(failingProviders) > 0 && warning == "" {
+			warning = fmt.Sprintf("Failed to sync with the %s provider(s), serving the last known upgrades and versions. See the providers sync table below.", strings.Join(failingProviders, ", "))
+		}
+
 		err = t.Execute(w, struct {
 			LastUpdateTime      string
 			LastUpdateDiff      string
@@ -106,6 +134,7 @@ func RegisterIndexHandler(mux *runtime.ServeMux, d *Daemon, upInterval time.Dura
 			CurrentBlockHeight  int64
 			AvgBlockTime        string
 			Upgrades            []*urproto.Upgrade
+			ProviderSync        []providerSyncRow
 			BlocksToUpgrade     map[int64]string
 			BlocksToETA         map[int64]string
 			UpgradeProgress     map[int64]string
@@ -123,6 +152,7 @@ func RegisterIndexHandler(mux *runtime.ServeMux, d *Daemon, upInterval time.Dura
 			CurrentBlockHeight:  latestHeight,
 			AvgBlockTime:        fmt.Sprintf("%.3fs", blockSpeed.Seconds()),
 			Upgrades:            upgrades,
+			ProviderSync:        providerSync,
 			BlocksToUpgrade:     blocksToUpgradeMap,
 			BlocksToETA:         blocksToETAMap,
 			Hostname:            util.GetHostname(),
@@ -59,4 +59,11 @@ func (d *Daemon) updateMetrics() {
 
 		d.metrics.BlocksToUpgrade.WithLabelValues(labelValues...).Set(float64(upgrade.Height - d.currHeight))
 	}
+
+	for providerType, info := range d.ur.SyncInfo().Providers {
+		provider := providerType.String()
+		d.metrics.ProviderLastSync.WithLabelValues(provider).Set(float64(info.LastSuccessTime.Unix()))
+		d.metrics.ProviderStaleness.WithLabelValues(provider).Set(info.Staleness().Seconds())
+		d.metrics.ProviderSyncErrors.WithLabelValues(provider).Set(float64(info.ConsecutiveErrors))
+	}
 }
@@ -0,0 +1,294 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"blazar/internal/pkg/chain_watcher"
+	"blazar/internal/pkg/config"
+	"blazar/internal/pkg/cosmos"
+	"blazar/internal/pkg/docker"
+	"blazar/internal/pkg/errors"
+	"blazar/internal/pkg/log"
+	"blazar/internal/pkg/log/notification"
+	urproto "blazar/internal/pkg/proto/upgrades_registry"
+
+	upgradetypes "github.com/cosmos/cosmos-sdk/x/upgrade/types"
+)
+
+var (
+	// time the node has to produce a new block at the upgrade height before it is considered halted
+	haltedNodeCheckDelay = 15 * time.Second
+
+	// time the node has to become reachable while an upgrade is being executed, e.g. after it was brought back on startup
+	executingNodeStartTimeout = 10 * time.Minute
+	executingNodePollInterval = 5 * time.Second
+)
+
+// recoverUpgrades brings the node back before blazar talks to it. It resumes the upgrades blazar was executing when it
+// was stopped and performs the upgrade the node halted at while blazar was down. The node RPC is not used, as it is
+// likely unreachable in both cases.
+//
+// Returns the heights of the upgrades waiting for the post-upgrade checks.
+func (d *Daemon) recoverUpgrades(ctx context.Context, cfg *config.Config) []int64 {
+	logger := log.FromContext(ctx)
+
+	executing := d.stateMachine.GetHeightsWithStatus(urproto.UpgradeStatus_EXECUTING)
+	plan, err := chain_watcher.ReadUpgradeInfo(cfg.UpgradeInfoFilePath())
+	if err != nil {
+		logger.Err(err).Warn("Failed to read the upgrade-info.json file, skipping the halted node detection")
+	}
+	if len(executing) == 0 && plan == nil {
+		return nil
+	}
+
+	// the node can't report its height, the registry is synced with the last block before the upgrade the node stopped at
+	syncHeight := int64(0)
+	if plan != nil {
+		syncHeight = plan.Height - 1
+	}
+	if len(executing) > 0 {
+		syncHeight = max(syncHeight, executing[len(executing)-1]-1)
+	}
+	if _, _, _, _, err := d.ur.Update(ctx, syncHeight, true); err != nil {
+		logger.Err(err).Warn("Failed to sync the upgrade registry before recovering the upgrades")
+	}
+
+	recovered := make([]int64, 0, len(executing)+1)
+	for _, upgradeHeight := range executing {
+		ctxWithHeight := notification.WithUpgradeHeight(ctx, upgradeHeight)
+		step := d.stateMachine.GetStep(upgradeHeight)
+
+		logger.Warnf("Blazar was restarted while executing the upgrade at height %d (step: %s), resuming the upgrade", upgradeHeight, step).Notify(ctxWithHeight)
+
+		if err := d.resumeUpgrade(ctxWithHeight, cfg, upgradeHeight, step); err != nil {
+			logger.Err(err).Error("Failed to resume the upgrade").Notify(ctxWithHeight)
+			continue
+		}
+		recovered = append(recovered, upgradeHeight)
+	}
+
+	upgradeHeight, err := d.haltedUpgradeHeight(ctx, cfg, plan)
+	if err != nil {
+		logger.Err(err).Warn("Failed to check if the node halted at an upgrade height")
+		return recovered
+	}
+	if upgradeHeight == 0 || slices.Contains(executing, upgradeHeight) {
+		return recovered
+	}
+
+	ctxWithHeight := notification.WithUpgradeHeight(ctx, upgradeHeight)
+	logger.Warnf("The node halted at the upgrade height %d while blazar was down, performing the upgrade", upgradeHeight).Notify(ctxWithHeight)
+
+	if err := d.performUpgrade(ctxWithHeight, &cfg.Compose, &cfg.Checks.PreUpgrade, cfg.ChainHome, cfg.ComposeService, upgradeHeight); err != nil {
+		logger.Err(err).Error("Upgrade routine failed").Notify(ctxWithHeight)
+		return recovered
+	}
+
+	return append(recovered, upgradeHeight)
+}
+
+// resumeUpgrade brings the upgrade to the point where the post-upgrade checks can run
+func (d *Daemon) resumeUpgrade(ctx context.Context, cfg *config.Config, upgradeHeight int64, step urproto.UpgradeStep) (err error) {
+	logger := log.FromContext(ctx)
+
+	switch step {
+	case urproto.UpgradeStep_NONE, urproto.UpgradeStep_MONITORING, urproto.UpgradeStep_PRE_UPGRADE_CHECK:
+		// the node wasn't touched yet
+		logger.Info("The services were not changed yet, executing the upgrade again").Notify(ctx)
+		return d.performUpgrade(ctx, &cfg.Compose, &cfg.Checks.PreUpgrade, cfg.ChainHome, cfg.ComposeService, upgradeHeight)
+	case urproto.UpgradeStep_COMPOSE_FILE_UPGRADE, urproto.UpgradeStep_POST_UPGRADE_CHECK:
+	default:
+		return fmt.Errorf("unknown upgrade step %s", step)
+	}
+
+	defer func() {
+		// ensure we update the status to failed if any error was encountered
+		if err != nil {
+			d.MustSetStatus(upgradeHeight, urproto.UpgradeStatus_FAILED)
+		}
+	}()
+
+	newVersions := d.stateMachine.GetUpgradeVersions(upgradeHeight)
+	if newVersions == nil {
+		// the upgrade was started by a blazar version that didn't store the versions
+		upgrade := d.ur.GetUpgradeWithCache(upgradeHeight)
+		if upgrade == nil {
+			return fmt.Errorf("upgrade with height %d not found", upgradeHeight)
+		}
+		newVersions = upgradeVersions(cfg.ComposeService, upgrade)
+	}
+
+	applied, err := d.versionsApplied(newVersions)
+	if err != nil {
+		return err
+	}
+
+	if !applied {
+		// the post-upgrade checks start after the compose file is changed, someone had to revert it in the meantime
+		if step == urproto.UpgradeStep_POST_UPGRADE_CHECK {
+			return fmt.Errorf("the services don't run the upgrade versions %v anymore, was the %s file changed manually?", newVersions, cfg.GetExecutor())
+		}
+
+		// the node may be stopped or running the old version, both are handled by the upgrade
+		logger.Info("The upgrade versions were not applied yet, applying them").Notify(ctx)
+		return d.applyUpgrade(ctx, &cfg.Compose, &cfg.Checks.PreUpgrade, cfg.ChainHome, upgradeHeight, newVersions)
+	}
+
+	return d.ensureServicesUp(ctx, &cfg.Compose, docker.ServiceNames(newVersions))
+}
+
+// versionsApplied reports whether the services are configured with the upgrade versions
+func (d *Daemon) versionsApplied(newVersions map[string]string) (bool, error) {
+	for name, newVersion := range newVersions {
+		currVersion, err := d.executor.GetCurrentVersion(name)
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to get the current version of the service %s", name)
+		}
+
+		if currVersion == newVersion {
+			continue
+		}
+
+		// in compose-file mode the current version is the full image, while the upgrade may carry the tag only
+		resolved, err := docker.ResolveImage(currVersion, newVersion)
+		if err != nil || resolved != currVersion {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// ensureServicesUp starts the services if any of them is down
+func (d *Daemon) ensureServicesUp(ctx context.Context, composeConfig *config.ComposeCli, serviceNames []string) error {
+	logger := log.FromContext(ctx)
+
+	for _, serviceName := range serviceNames {
+		isRunning, err := d.executor.IsServiceRunning(ctx, serviceName, composeConfig.DownTimeout)
+		if err != nil {
+			return errors.Wrapf(err, "failed to check if service %s is running", serviceName)
+		}
+
+		if !isRunning {
+			logger.Infof("The upgrade versions are applied but the service %s is down, executing compose up", serviceName).Notify(ctx)
+			if err := d.executor.UpServices(ctx, serviceNames, composeConfig.UpDeadline); err != nil {
+				return errors.Wrapf(err, "failed to up compose")
+			}
+			return nil
+		}
+	}
+
+	logger.Info("The upgrade versions are applied and the services are running, continuing with the post-upgrade checks").Notify(ctx)
+	return nil
+}
+
+// haltedUpgradeHeight returns the height of the upgrade the node halted at while blazar was down, or 0 if there is none.
+// The upgrade info watcher treats the existing upgrade-info.json file as already handled, so the upgrade would never
+// be triggered otherwise
+func (d *Daemon) haltedUpgradeHeight(ctx context.Context, cfg *config.Config, plan *upgradetypes.Plan) (int64, error) {
+	logger := log.FromContext(ctx)
+
+	if plan == nil {
+		return 0, nil
+	}
+
+	// the completed, failed or cancelled upgrades are not executed again
+	if status := d.stateMachine.GetStatus(plan.Height); status != urproto.UpgradeStatus_ACTIVE {
+		logger.Debugf("The upgrade at height %d from upgrade-info.json has status %s, skipping", plan.Height, status)
+		return 0, nil
+	}
+
+	upgrade := d.ur.GetUpgradeWithCache(plan.Height)
+	if upgrade == nil {
+		return 0, fmt.Errorf("upgrade with height %d from upgrade-info.json not found", plan.Height)
+	}
+
+	// the upgrade-info.json file stays after the upgrade, the state may not have been persisted
+	applied, err := d.versionsApplied(upgradeVersions(cfg.ComposeService, upgrade))
+	if err != nil {
+		return 0, err
+	}
+	if applied {
+		logger.Infof("The upgrade at height %d from upgrade-info.json is already applied, skipping", plan.Height)
+		return 0, nil
+	}
+
+	halted, err := d.isNodeHalted(ctx, &cfg.Compose, cfg.ComposeService, plan.Height)
+	if err != nil {
+		return 0, err
+	}
+	if !halted {
+		logger.Infof("The node wrote upgrade-info.json for the height %d, but it is still producing blocks", plan.Height)
+		return 0, nil
+	}
+
+	return plan.Height, nil
+}
+
+// isNodeHalted reports whether the node stopped at the upgrade height: the service has exited, or it is running but
+// stuck at the upgrade height or not responding at all (e.g. restarted by docker after every upgrade panic)
+func (d *Daemon) isNodeHalted(ctx context.Context, composeConfig *config.ComposeCli, serviceName string, upgradeHeight int64) (bool, error) {
+	isRunning, err := d.executor.IsServiceRunning(ctx, serviceName, composeConfig.DownTimeout)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to check if service %s is running", serviceName)
+	}
+	if !isRunning {
+		return true, nil
+	}
+
+	status, err := d.cosmosClient.GetStatus(ctx)
+	if err == nil && !atUpgradeHeight(status, upgradeHeight) {
+		return false, nil
+	}
+
+	select {
+	case <-ctx.Done():
+		return false, ctx.Err()
+	case <-time.After(haltedNodeCheckDelay):
+	}
+
+	status, err = d.cosmosClient.GetStatus(ctx)
+	if err != nil {
+		log.FromContext(ctx).Err(err).Warnf("The node is running but its RPC is not responding, assuming it panicked at the upgrade height %d", upgradeHeight)
+		return true, nil
+	}
+
+	return atUpgradeHeight(status, upgradeHeight), nil
+}
+
+// atUpgradeHeight reports whether the node stopped at the upgrade height. The block at the upgrade height may or
+// may not be stored by the time the node panics
+func atUpgradeHeight(status *cosmos.StatusResponse, upgradeHeight int64) bool {
+	height := status.SyncInfo.LatestBlockHeight
+	return height == upgradeHeight || height == upgradeHeight-1
+}
+
+// waitForNode calls the node until it responds. The node may still be starting while an upgrade is being executed, in
+// which case the call is retried, otherwise the first error is returned
+func (d *Daemon) waitForNode(ctx context.Context, call func() error) error {
+	err := call()
+	if err == nil || len(d.stateMachine.GetHeightsWithStatus(urproto.UpgradeStatus_EXECUTING)) == 0 {
+		return err
+	}
+
+	log.FromContext(ctx).Err(err).Infof("An upgrade is being executed and the node is not reachable yet, waiting up to %s", executingNodeStartTimeout)
+
+	ticker := time.NewTicker(executingNodePollInterval)
+	defer ticker.Stop()
+	timeout := time.After(executingNodeStartTimeout)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timeout:
+			return errors.Wrapf(err, "node didn't become reachable within %s", executingNodeStartTimeout)
+		case <-ticker.C:
+			if err = call(); err == nil {
+				return nil
+			}
+		}
+	}
+}
@@ -0,0 +1,477 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"blazar/internal/pkg/config"
+	"blazar/internal/pkg/cosmos"
+	"blazar/internal/pkg/docker"
+	urproto "blazar/internal/pkg/proto/upgrades_registry"
+	"blazar/internal/pkg/provider/local"
+
+	"github.com/stretchr/testify/require"
+)
+
+// mockExecutor keeps the services versions and state in memory
+type mockExecutor struct {
+	versions map[string]string
+	running  map[string]bool
+	calls    []string
+
+	// output of the version command of the upgrade binary
+	binaryVersion string
+
+	// digests of the upgrade images, empty if not set
+	digests map[string]string
+
+	// logs of the services, FollowLogs fails logsAttachFailures times before returning them
+	logs               string
+	logsAttachFailures int
+}
+
+func newMockExecutor(versions map[string]string, running bool) *mockExecutor {
+	e := &mockExecutor{versions: versions, running: make(map[string]bool, len(versions))}
+	for name := range versions {
+		e.running[name] = running
+	}
+	return e
+}
+
+func (e *mockExecutor) PrepareUpgrade(_ context.Context, serviceName string, upgrade *urproto.Upgrade, _ *config.PullDockerImage) (string, string, error) {
+	e.calls = append(e.calls, "prepare")
+	newImage, err := docker.ResolveImage(e.versions[serviceName], upgrade.Tag)
+	return e.versions[serviceName], newImage, err
+}
+
+func (e *mockExecutor) ArtifactDigest(_ context.Context, image string) (string, error) {
+	return e.digests[image], nil
+}
+
+func (e *mockExecutor) BinaryVersion(context.Context, string, string, time.Duration) (string, error) {
+	return e.binaryVersion, nil
+}
+
+func (e *mockExecutor) GetCurrentVersion(serviceName string) (string, error) {
+	version, ok := e.versions[serviceName]
+	if !ok {
+		return "", fmt.Errorf("service %s not found", serviceName)
+	}
+	return version, nil
+}
+
+func (e *mockExecutor) ValidateUpgradeImages(context.Context, map[string]string) error {
+	return nil
+}
+
+func (e *mockExecutor) UpgradeImages(_ context.Context, newVersions map[string]string) error {
+	e.calls = append(e.calls, "upgrade")
+	for name, tagOrImage := range newVersions {
+		image, err := docker.ResolveImage(e.versions[name], tagOrImage)
+		if err != nil {
+			return err
+		}
+		e.versions[name] = image
+	}
+	return nil
+}
+
+func (e *mockExecutor) IsServiceRunning(_ context.Context, serviceName string, _ time.Duration) (bool, error) {
+	return e.running[serviceName], nil
+}
+
+func (e *mockExecutor) DownServices(_ context.Context, serviceNames []string, _ time.Duration) error {
+	e.calls = append(e.calls, "down")
+	for _, name := range serviceNames {
+		e.running[name] = false
+	}
+	return nil
+}
+
+func (e *mockExecutor) UpServices(_ context.Context, serviceNames []string, _ time.Duration, _ ...string) error {
+	e.calls = append(e.calls, "up")
+	for _, name := range serviceNames {
+		e.running[name] = true
+	}
+	return nil
+}
+
+func (e *mockExecutor) RestartServiceWithHaltHeight(context.Context, *config.ComposeCli, string, int64) error {
+	return nil
+}
+
+func (e *mockExecutor) FollowLogs(context.Context, string) (io.ReadCloser, error) {
+	if e.logs == "" || e.logsAttachFailures > 0 {
+		e.logsAttachFailures--
+		return nil, fmt.Errorf("no container found")
+	}
+	return io.NopCloser(strings.NewReader(e.logs)), nil
+}
+
+func (e *mockExecutor) Version(context.Context) (string, error) {
+	return "mock", nil
+}
+
+// newStatusServer serves the cometbft /status endpoint, reporting the given height or failing if it is 0
+func newStatusServer(t *testing.T, height *atomic.Int64) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if height.Load() == 0 {
+			http.Error(w, "CONSENSUS FAILURE!!!", http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprintf(w, `{"result":{"node_info":{"network":"test"},"sync_info":{"latest_block_height":"%d"}}}`, height.Load())
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newReconcileTestDaemon(t *testing.T, executor Executor, statusURL string) (*Daemon, *config.Config, context.Context) {
+	tempDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(tempDir, "data"), 0o755))
+
+	cfg := &config.Config{
+		ChainHome:      tempDir,
+		ComposeService: "simd",
+		Compose: config.ComposeCli{
+			DownTimeout: time.Second,
+			UpDeadline:  time.Second,
+		},
+		Checks: config.Checks{
+			PreUpgrade: config.PreUpgrade{
+				PullDockerImage: &config.PullDockerImage{},
+			},
+		},
+	}
+
+	provider, err := local.NewProvider(filepath.Join(tempDir, "local.db.json"), "test", 1)
+	require.NoError(t, err)
+	ur, sm := initUrSm(t, urproto.ProviderType_LOCAL, provider, tempDir)
+
+	_, ctx := injectTestLogger(cfg)
+	require.NoError(t, ur.AddUpgrade(ctx, &urproto.Upgrade{
+		Height:   10,
+		Tag:      "v2",
+		Network:  "test",
+		Name:     "test",
+		Type:     urproto.UpgradeType_NON_GOVERNANCE_COORDINATED,
+		Status:   urproto.UpgradeStatus_UNKNOWN,
+		Source:   urproto.ProviderType_LOCAL,
+		Priority: 1,
+	}, false))
+
+	host, grpcPort, cometbftPort := nodeAddress(t, statusURL)
+	cosmosClient, err := cosmos.NewClient(host, grpcPort, cometbftPort, 100*time.Millisecond)
+	require.NoError(t, err)
+
+	return &Daemon{
+		cosmosClient: cosmosClient,
+		executor:     executor,
+		ur:           ur,
+		stateMachine: sm,
+		metrics:      getTestMetrics(),
+	}, cfg, ctx
+}
+
+// nodeAddress returns the address of the node serving the given status URL, or an unreachable one if it is empty.
+// The node gRPC is never reachable in these tests
+func nodeAddress(t *testing.T, statusURL string) (string, uint16, uint16) {
+	ports := getFreePorts(t, 2)
+	host, grpcPort, cometbftPort := "127.0.0.1", uint16(ports[0]), uint16(ports[1])
+	if statusURL != "" {
+		u, err := url.Parse(statusURL)
+		require.NoError(t, err)
+		port, err := strconv.ParseUint(u.Port(), 10, 16)
+		require.NoError(t, err)
+		host, cometbftPort = u.Hostname(), uint16(port)
+	}
+	return host, grpcPort, cometbftPort
+}
+
+// newConfigTestDaemon builds the daemon the way blazar does, from the config, and swaps the executor afterwards
+func newConfigTestDaemon(t *testing.T, executor Executor, statusURL string) (*Daemon, *config.Config, context.Context) {
+	tempDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(tempDir, "data"), 0o755))
+
+	host, grpcPort, cometbftPort := nodeAddress(t, statusURL)
+	cfg := &config.Config{
+		ChainHome:      tempDir,
+		ComposeService: "simd",
+		Executor:       config.ExecutorSystemd,
+		Systemd:        &config.Systemd{},
+		Compose: config.ComposeCli{
+			DownTimeout: time.Second,
+			UpDeadline:  time.Second,
+		},
+		Clients: config.Clients{
+			Host:         host,
+			GrpcPort:     grpcPort,
+			CometbftPort: cometbftPort,
+			Timeout:      100 * time.Millisecond,
+		},
+		Checks: config.Checks{
+			PreUpgrade: config.PreUpgrade{
+				PullDockerImage: &config.PullDockerImage{},
+			},
+		},
+		UpgradeRegistry: config.UpgradeRegistry{
+			Network: "test",
+			Provider: config.Provider{
+				Local: &config.LocalProvider{
+					ConfigPath:      filepath.Join(tempDir, "local.db.json"),
+					DefaultPriority: 1,
+				},
+			},
+			SelectedProviders: []string{urproto.ProviderType_LOCAL.String()},
+			StateMachine: config.StateMachine{
+				Provider: urproto.ProviderType_LOCAL.String(),
+			},
+		},
+	}
+
+	_, ctx := injectTestLogger(cfg)
+	daemon, err := NewDaemon(ctx, cfg, getTestMetrics())
+	require.NoError(t, err)
+	daemon.executor = executor
+
+	require.NoError(t, daemon.ur.AddUpgrade(ctx, &urproto.Upgrade{
+		Height:   10,
+		Tag:      "v2",
+		Network:  "test",
+		Name:     "test",
+		Type:     urproto.UpgradeType_NON_GOVERNANCE_COORDINATED,
+		Status:   urproto.UpgradeStatus_UNKNOWN,
+		Source:   urproto.ProviderType_LOCAL,
+		Priority: 1,
+	}, false))
+
+	return daemon, cfg, ctx
+}
+
+func writeUpgradeInfo(t *testing.T, cfg *config.Config, height int64) {
+	data := fmt.Sprintf(`{"name":"test","height":%d,"info":""}`, height)
+	require.NoError(t, os.WriteFile(cfg.UpgradeInfoFilePath(), []byte(data), 0o600))
+}
+
+func TestResumeUpgrade(t *testing.T) {
+	tests := []struct {
+		name        string
+		step        urproto.UpgradeStep
+		version     string
+		running     bool
+		expectErr   bool
+		expectCalls []string
+	}{
+		{
+			name:        "PreUpgradeCheck",
+			step:        urproto.UpgradeStep_PRE_UPGRADE_CHECK,
+			version:     "simd:v1",
+			running:     true,
+			expectCalls: []string{"prepare", "down", "upgrade", "up"},
+		},
+		{
+			name:        "ComposeFileUpgradeServicesDown",
+			step:        urproto.UpgradeStep_COMPOSE_FILE_UPGRADE,
+			version:     "simd:v1",
+			running:     false,
+			expectCalls: []string{"upgrade", "up"},
+		},
+		{
+			name:        "ComposeFileUpgradeServicesRunning",
+			step:        urproto.UpgradeStep_COMPOSE_FILE_UPGRADE,
+			version:     "simd:v1",
+			running:     true,
+			expectCalls: []string{"down", "upgrade", "up"},
+		},
+		{
+			name:        "ComposeFileUpgradeAppliedServicesDown",
+			step:        urproto.UpgradeStep_COMPOSE_FILE_UPGRADE,
+			version:     "simd:v2",
+			running:     false,
+			expectCalls: []string{"up"},
+		},
+		{
+			name:    "PostUpgradeCheckApplied",
+			step:    urproto.UpgradeStep_POST_UPGRADE_CHECK,
+			version: "simd:v2",
+			running: true,
+		},
+		{
+			name:        "PostUpgradeCheckAppliedServicesDown",
+			step:        urproto.UpgradeStep_POST_UPGRADE_CHECK,
+			version:     "simd:v2",
+			running:     false,
+			expectCalls: []string{"up"},
+		},
+		{
+			name:      "PostUpgradeCheckReverted",
+			step:      urproto.UpgradeStep_POST_UPGRADE_CHECK,
+			version:   "simd:v1",
+			running:   true,
+			expectErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			executor := newMockExecutor(map[string]string{"simd": test.version}, test.running)
+			daemon, cfg, ctx := newReconcileTestDaemon(t, executor, "")
+
+			_, _, _, _, err := daemon.ur.Update(ctx, 9, true)
+			require.NoError(t, err)
+			daemon.stateMachine.MustSetStatusAndStep(10, urproto.UpgradeStatus_EXECUTING, test.step)
+			if test.step != urproto.UpgradeStep_PRE_UPGRADE_CHECK {
+				daemon.stateMachine.SetUpgradeVersions(10, map[string]string{"simd": "v2"})
+			}
+
+			recovered := daemon.recoverUpgrades(ctx, cfg)
+			require.Equal(t, test.expectCalls, executor.calls)
+
+			if test.expectErr {
+				require.Empty(t, recovered)
+				require.Equal(t, urproto.UpgradeStatus_FAILED, daemon.stateMachine.GetStatus(10))
+				return
+			}
+
+			require.Equal(t, []int64{10}, recovered)
+			require.Equal(t, urproto.UpgradeStatus_EXECUTING, daemon.stateMachine.GetStatus(10))
+			require.Equal(t, "simd:v2", executor.versions["simd"])
+			require.True(t, executor.running["simd"])
+		})
+	}
+}
+
+func TestResumeUpgradeWithoutStoredVersions(t *testing.T) {
+	executor := newMockExecutor(map[string]string{"simd": "simd:v1"}, false)
+	daemon, cfg, ctx := newReconcileTestDaemon(t, executor, "")
+
+	// the state was stored by a blazar version that didn't record the upgrade versions
+	_, _, _, _, err := daemon.ur.Update(ctx, 9, true)
+	require.NoError(t, err)
+	daemon.stateMachine.MustSetStatusAndStep(10, urproto.UpgradeStatus_EXECUTING, urproto.UpgradeStep_COMPOSE_FILE_UPGRADE)
+
+	require.Equal(t, []int64{10}, daemon.recoverUpgrades(ctx, cfg))
+	require.Equal(t, []string{"upgrade", "up"}, executor.calls)
+	require.Equal(t, "simd:v2", executor.versions["simd"])
+}
+
+func TestRecoverHaltedUpgrade(t *testing.T) {
+	haltedNodeCheckDelay = 10 * time.Millisecond
+
+	// the daemon is built from the config, so the node RPC being down (or failing) can't abort the startup
+
+	t.Run("ExitedContainer", func(t *testing.T) {
+		executor := newMockExecutor(map[string]string{"simd": "simd:v1"}, false)
+		daemon, cfg, ctx := newConfigTestDaemon(t, executor, "")
+		writeUpgradeInfo(t, cfg, 10)
+
+		require.Equal(t, []int64{10}, daemon.recoverUpgrades(ctx, cfg))
+		require.Equal(t, []string{"prepare", "upgrade", "up"}, executor.calls)
+		require.Equal(t, "simd:v2", executor.versions["simd"])
+		require.Equal(t, urproto.UpgradeStatus_EXECUTING, daemon.stateMachine.GetStatus(10))
+	})
+
+	t.Run("RPCPanic", func(t *testing.T) {
+		// the container is restarted by docker after every upgrade panic, the RPC never responds
+		var height atomic.Int64
+		server := newStatusServer(t, &height)
+
+		executor := newMockExecutor(map[string]string{"simd": "simd:v1"}, true)
+		daemon, cfg, ctx := newConfigTestDaemon(t, executor, server.URL)
+		writeUpgradeInfo(t, cfg, 10)
+
+		require.Equal(t, []int64{10}, daemon.recoverUpgrades(ctx, cfg))
+		require.Equal(t, []string{"prepare", "down", "upgrade", "up"}, executor.calls)
+		require.Equal(t, "simd:v2", executor.versions["simd"])
+	})
+
+	t.Run("StuckAtUpgradeHeight", func(t *testing.T) {
+		var height atomic.Int64
+		height.Store(10)
+		server := newStatusServer(t, &height)
+
+		executor := newMockExecutor(map[string]string{"simd": "simd:v1"}, true)
+		daemon, cfg, ctx := newConfigTestDaemon(t, executor, server.URL)
+		writeUpgradeInfo(t, cfg, 10)
+
+		require.Equal(t, []int64{10}, daemon.recoverUpgrades(ctx, cfg))
+		require.Equal(t, "simd:v2", executor.versions["simd"])
+	})
+
+	t.Run("NodeProducingBlocks", func(t *testing.T) {
+		var height atomic.Int64
+		height.Store(50)
+		server := newStatusServer(t, &height)
+
+		executor := newMockExecutor(map[string]string{"simd": "simd:v1"}, true)
+		daemon, cfg, ctx := newConfigTestDaemon(t, executor, server.URL)
+		writeUpgradeInfo(t, cfg, 10)
+
+		require.Empty(t, daemon.recoverUpgrades(ctx, cfg))
+		require.Empty(t, executor.calls)
+	})
+
+	t.Run("AlreadyApplied", func(t *testing.T) {
+		executor := newMockExecutor(map[string]string{"simd": "simd:v2"}, false)
+		daemon, cfg, ctx := newConfigTestDaemon(t, executor, "")
+		writeUpgradeInfo(t, cfg, 10)
+
+		require.Empty(t, daemon.recoverUpgrades(ctx, cfg))
+		require.Empty(t, executor.calls)
+	})
+}
+
+func TestRecoverUpgradeWithUnreachableNode(t *testing.T) {
+	// the node is down, blazar must still start and bring it back
+	executor := newMockExecutor(map[string]string{"simd": "simd:v1"}, false)
+	daemon, cfg, ctx := newConfigTestDaemon(t, executor, "")
+
+	_, _, _, _, err := daemon.ur.Update(ctx, 9, true)
+	require.NoError(t, err)
+	daemon.stateMachine.MustSetStatusAndStep(10, urproto.UpgradeStatus_EXECUTING, urproto.UpgradeStep_COMPOSE_FILE_UPGRADE)
+	daemon.stateMachine.SetUpgradeVersions(10, map[string]string{"simd": "v2"})
+
+	require.Equal(t, []int64{10}, daemon.recoverUpgrades(ctx, cfg))
+	require.Equal(t, []string{"upgrade", "up"}, executor.calls)
+	require.Equal(t, "simd:v2", executor.versions["simd"])
+}
+
+func TestWaitForNode(t *testing.T) {
+	executingNodePollInterval = 10 * time.Millisecond
+
+	var height atomic.Int64
+	server := newStatusServer(t, &height)
+	daemon, _, ctx := newReconcileTestDaemon(t, newMockExecutor(map[string]string{"simd": "simd:v2"}, true), server.URL)
+
+	getStatus := func() error {
+		_, err := daemon.cosmosClient.GetStatus(ctx)
+		return err
+	}
+
+	// nothing is being executed, the node is expected to be up
+	require.Error(t, daemon.waitForNode(ctx, getStatus))
+
+	// the node restarted by the resumed upgrade responds after a while
+	_, _, _, _, err := daemon.ur.Update(ctx, 9, true)
+	require.NoError(t, err)
+	daemon.stateMachine.MustSetStatusAndStep(10, urproto.UpgradeStatus_EXECUTING, urproto.UpgradeStep_POST_UPGRADE_CHECK)
+
+	calls := 0
+	require.NoError(t, daemon.waitForNode(ctx, func() error {
+		if calls++; calls == 3 {
+			height.Store(10)
+		}
+		return getStatus()
+	}))
+	require.Equal(t, 3, calls)
+}
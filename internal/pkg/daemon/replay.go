@@ -0,0 +1,106 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"blazar/internal/pkg/config"
+	"blazar/internal/pkg/log"
+	checksproto "blazar/internal/pkg/proto/daemon"
+	urproto "blazar/internal/pkg/proto/upgrades_registry"
+)
+
+var (
+	// checks that make no sense while the node replays the historical blocks: the node halts at the upgrade-info.json
+	// heights on its own, it is catching up by design and doesn't vote on the past blocks
+	syncFromGenesisSkippedPreChecks = []string{
+		checksproto.PreCheck_SET_HALT_HEIGHT.String(),
+		checksproto.PreCheck_NODE_SYNC_STATUS.String(),
+	}
+	syncFromGenesisSkippedPostChecks = []string{
+		checksproto.PostCheck_FIRST_BLOCK_VOTED.String(),
+	}
+)
+
+// disableSyncFromGenesisChecks removes the checks that don't apply to the historical upgrades from the config
+func disableSyncFromGenesisChecks(ctx context.Context, cfg *config.Config) {
+	logger := log.FromContext(ctx)
+
+	for _, check := range syncFromGenesisSkippedPreChecks {
+		if slices.Contains(cfg.Checks.PreUpgrade.Enabled, check) {
+			logger.Infof("Pre upgrade check %s is skipped while syncing from genesis", check)
+		}
+	}
+	for _, check := range syncFromGenesisSkippedPostChecks {
+		if slices.Contains(cfg.Checks.PostUpgrade.Enabled, check) {
+			logger.Infof("Post upgrade check %s is skipped while syncing from genesis", check)
+		}
+	}
+
+	cfg.Checks.PreUpgrade.Enabled = slices.DeleteFunc(slices.Clone(cfg.Checks.PreUpgrade.Enabled), func(check string) bool {
+		return slices.Contains(syncFromGenesisSkippedPreChecks, check)
+	})
+	cfg.Checks.PostUpgrade.Enabled = slices.DeleteFunc(slices.Clone(cfg.Checks.PostUpgrade.Enabled), func(check string) bool {
+		return slices.Contains(syncFromGenesisSkippedPostChecks, check)
+	})
+}
+
+// syncReplayedUpgrade syncs the upgrade registry if the upgrade the node halted at is unknown. The chain provider sees
+// the proposals only once the node replays them, which may be just before the upgrade height
+func (d *Daemon) syncReplayedUpgrade(ctx context.Context, upgradeHeight int64) {
+	if d.ur.GetUpgradeWithCache(upgradeHeight) != nil {
+		return
+	}
+
+	logger := log.FromContext(ctx)
+	logger.Infof("Upgrade at height %d is not known yet, syncing the upgrade registry", upgradeHeight)
+	if _, _, _, _, err := d.ur.Update(ctx, d.currHeight, true); err != nil {
+		logger.Err(err).Warn("Failed to sync the upgrade registry")
+	}
+}
+
+// reportSyncFromGenesis lists the images the node ran since genesis, once the last known upgrade is completed and the
+// node is past its height. The report is sent once per last upgrade, the registry may learn about more upgrades later
+func (d *Daemon) reportSyncFromGenesis(ctx context.Context, serviceName string) {
+	lastHeight := int64(0)
+	for height, upgrade := range d.ur.GetAllUpgradesWithCache() {
+		if upgrade.Status != urproto.UpgradeStatus_CANCELLED && d.stateMachine.GetStatus(height) != urproto.UpgradeStatus_CANCELLED {
+			lastHeight = max(lastHeight, height)
+		}
+	}
+
+	if lastHeight == 0 || lastHeight == d.lastSyncFromGenesisHeight || d.currHeight <= lastHeight {
+		return
+	}
+	if d.stateMachine.GetStatus(lastHeight) != urproto.UpgradeStatus_COMPLETED {
+		return
+	}
+
+	images := d.stateMachine.GetUpgradeImages()
+	if len(images) == 0 {
+		return
+	}
+	d.lastSyncFromGenesisHeight = lastHeight
+
+	heights := make([]int64, 0, len(images))
+	for height := range images {
+		heights = append(heights, height)
+	}
+	slices.Sort(heights)
+
+	lines := make([]string, 0, len(heights)+1)
+	if genesisImage, ok := d.stateMachine.GetPreviousVersions(heights[0])[serviceName]; ok {
+		lines = append(lines, fmt.Sprintf("genesis: %s", genesisImage))
+	}
+	for _, height := range heights {
+		name := "unknown"
+		if upgrade := d.ur.GetUpgradeWithCache(height); upgrade != nil {
+			name = upgrade.Name
+		}
+		lines = append(lines, fmt.Sprintf("%d (%s): %s, %s", height, name, images[height], d.stateMachine.GetStatus(height)))
+	}
+
+	log.FromContext(ctx).Infof("No more upgrades to replay at height %d, images used since genesis:\n%s", d.currHeight, strings.Join(lines, "\n")).Notify(ctx)
+}
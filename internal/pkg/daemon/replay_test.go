@@ -0,0 +1,60 @@
+package daemon
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	urproto "blazar/internal/pkg/proto/upgrades_registry"
+	"blazar/internal/pkg/provider/local"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReportSyncFromGenesis(t *testing.T) {
+	daemon, cfg, _ := newReconcileTestDaemon(t, newMockExecutor(map[string]string{"simd": "simd:v1"}, true), "")
+	outBuffer, ctx := injectTestLogger(cfg)
+
+	reports := func() int {
+		return strings.Count(outBuffer.String(), "No more upgrades to replay")
+	}
+
+	_, _, _, _, err := daemon.ur.Update(ctx, 5, true)
+	require.NoError(t, err)
+	daemon.stateMachine.SetPreviousVersions(10, map[string]string{"simd": "simd:v1"})
+	daemon.stateMachine.SetUpgradeImage(10, "simd:v2")
+
+	// the last upgrade is not completed yet
+	daemon.currHeight = 11
+	daemon.reportSyncFromGenesis(ctx, cfg.ComposeService)
+	require.Equal(t, 0, reports())
+
+	// the node is not past the last upgrade yet
+	daemon.MustSetStatus(10, urproto.UpgradeStatus_COMPLETED)
+	daemon.currHeight = 10
+	daemon.reportSyncFromGenesis(ctx, cfg.ComposeService)
+	require.Equal(t, 0, reports())
+
+	daemon.currHeight = 11
+	daemon.reportSyncFromGenesis(ctx, cfg.ComposeService)
+	require.Equal(t, 1, reports())
+	require.Contains(t, outBuffer.String(), "genesis: simd:v1")
+	require.Contains(t, outBuffer.String(), "10 (test): simd:v2")
+
+	// the report is sent once, even if the registry is synced again
+	_, _, _, _, err = daemon.ur.Update(ctx, 11, true)
+	require.NoError(t, err)
+	daemon.currHeight = 12
+	daemon.reportSyncFromGenesis(ctx, cfg.ComposeService)
+	require.Equal(t, 1, reports())
+
+	// nothing is reported without any known upgrade
+	provider, err := local.NewProvider(filepath.Join(t.TempDir(), "local.db.json"), "test", 1)
+	require.NoError(t, err)
+	daemon.ur, daemon.stateMachine = initUrSm(t, urproto.ProviderType_LOCAL, provider, t.TempDir())
+	daemon.lastSyncFromGenesisHeight = 0
+	daemon.stateMachine.SetUpgradeImage(10, "simd:v2")
+
+	daemon.reportSyncFromGenesis(ctx, cfg.ComposeService)
+	require.Equal(t, 1, reports())
+}
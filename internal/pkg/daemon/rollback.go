@@ -0,0 +1,93 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"blazar/internal/pkg/config"
+	"blazar/internal/pkg/daemon/checks"
+	"blazar/internal/pkg/docker"
+	"blazar/internal/pkg/errors"
+	"blazar/internal/pkg/log"
+	"blazar/internal/pkg/log/notification"
+	checksproto "blazar/internal/pkg/proto/daemon"
+	urproto "blazar/internal/pkg/proto/upgrades_registry"
+)
+
+func (d *Daemon) shouldRollback(cfg *config.Rollback, upgradeHeight int64) bool {
+	// rollback is opt-in
+	if cfg == nil {
+		return false
+	}
+
+	upgrade := d.ur.GetUpgradeWithCache(upgradeHeight)
+	if upgrade == nil {
+		return false
+	}
+
+	return slices.Contains(cfg.UpgradeTypes, upgrade.Type.String())
+}
+
+// rollback restores the version the service was running before the upgrade and ensures the node is healthy again
+func (d *Daemon) rollback(
+	ctx context.Context,
+	composeConfig *config.ComposeCli,
+	cfg *config.PostUpgrade,
+	serviceName string,
+	upgradeHeight int64,
+) error {
+	ctx = notification.WithUpgradeHeight(ctx, upgradeHeight)
+	logger := log.FromContext(ctx)
+
+	prevVersions := d.stateMachine.GetPreviousVersions(upgradeHeight)
+	if len(prevVersions) == 0 {
+		return fmt.Errorf("previous version for upgrade height %d is unknown", upgradeHeight)
+	}
+
+	logger.Warnf("Rolling back the upgrade, restoring previous versions: %v", prevVersions).Notify(ctx)
+
+	serviceNames := docker.ServiceNames(prevVersions)
+	isRunning, err := d.isAnyServiceRunning(ctx, serviceNames, composeConfig.DownTimeout)
+	if err != nil {
+		return err
+	}
+
+	if isRunning {
+		logger.Info("Executing compose down").Notify(ctx)
+		if err = d.executor.DownServices(ctx, serviceNames, composeConfig.DownTimeout); err != nil {
+			return errors.Wrapf(err, "failed to down compose")
+		}
+	}
+
+	logger.Info("Restoring previous image in compose file").Notify(ctx)
+	if err = d.executor.UpgradeImages(ctx, prevVersions); err != nil {
+		return errors.Wrapf(err, "failed to restore previous image")
+	}
+
+	logger.Info("Executing compose up").Notify(ctx)
+	if err = d.executor.UpServices(ctx, serviceNames, composeConfig.UpDeadline); err != nil {
+		return errors.Wrapf(err, "failed to up compose")
+	}
+
+	logger.Infof(
+		"Rollback check: %s Waiting for the grpc and cometbft services to be responsive",
+		checksproto.PostCheck_GRPC_RESPONSIVE.String(),
+	).Notify(ctx)
+	if _, err = checks.GrpcResponsive(ctx, d.cosmosClient, cfg.GrpcResponsive); err != nil {
+		return errors.Wrapf(err, "rollback grpc-endpoint-response check failed")
+	}
+
+	logger.Infof(
+		"Rollback check: %s Waiting for the on-chain latest block height to be > upgrade height=%d",
+		checksproto.PostCheck_CHAIN_HEIGHT_INCREASED.String(), upgradeHeight,
+	).Notify(ctx)
+	if err = checks.ChainHeightIncreased(ctx, d.cosmosClient, cfg.ChainHeightIncreased, upgradeHeight); err != nil {
+		return errors.Wrapf(err, "rollback next-block-height check failed")
+	}
+
+	d.MustSetStatus(upgradeHeight, urproto.UpgradeStatus_ROLLED_BACK)
+	logger.Infof("Rollback completed. The node is running the previous version: %s", prevVersions[serviceName]).Notify(ctx)
+
+	return nil
+}
@@ -1,7 +1,6 @@
 package util
 
 import (
-	"fmt"
 	"os"
 
 	"blazar/internal/pkg/docker"
@@ -18,14 +17,18 @@ func GetHostname() string {
 }
 
 // Get the current image from compose file and the image corresponding to the
-// upgradeTag
+// upgradeTag (which can also be a full image reference)
 func GetCurrImageUpgradeImage(dcc *docker.ComposeClient, serviceName, upgradeTag string) (string, string, error) {
 	currImage, currVersion, err := dcc.GetImageAndVersionFromCompose(serviceName)
 	if err != nil {
 		return "", "", errors.Wrapf(err, "failed to get image for service %s", serviceName)
 	}
-	currComposeImage := fmt.Sprintf("%s:%s", currImage, currVersion)
-	newImage := fmt.Sprintf("%s:%s", currImage, upgradeTag)
+	currComposeImage := docker.JoinImageName(currImage, currVersion)
+
+	newImage, err := docker.ResolveImage(currComposeImage, upgradeTag)
+	if err != nil {
+		return "", "", errors.Wrapf(err, "failed to resolve upgrade image for service %s", serviceName)
+	}
 
 	return currComposeImage, newImage, nil
 }
@@ -3,6 +3,7 @@ package docker
 import (
 	"context"
 	"fmt"
+	"maps"
 	"math"
 	"os"
 	"path/filepath"
@@ -18,6 +19,7 @@ import (
 
 	compose "github.com/compose-spec/compose-go/cli"
 	composeTypes "github.com/compose-spec/compose-go/types"
+	"gopkg.in/yaml.v3"
 )
 
 var (
@@ -30,18 +32,21 @@ type ComposeClient struct {
 	versionFile string
 	composeFile string
 	upgradeMode config.UpgradeMode
+
+	// if true, down/up act on every service in the compose file, not only on the upgraded ones
+	projectWide bool
 }
 
-func NewDefaultComposeClient(ctx context.Context, ch CredentialHelper, versionFile, composeFile string, upgradeMode config.UpgradeMode) (*ComposeClient, error) {
+func NewDefaultComposeClient(ctx context.Context, ch CredentialHelper, versionFile, composeFile string, upgradeMode config.UpgradeMode, projectWide bool) (*ComposeClient, error) {
 	dc, err := NewClient(ctx, ch)
 	if err != nil {
 		return nil, err
 	}
 
-	return NewComposeClient(dc, versionFile, composeFile, upgradeMode)
+	return NewComposeClient(dc, versionFile, composeFile, upgradeMode, projectWide)
 }
 
-func NewComposeClient(dockerClient *Client, versionFile, composeFile string, upgradeMode config.UpgradeMode) (*ComposeClient, error) {
+func NewComposeClient(dockerClient *Client, versionFile, composeFile string, upgradeMode config.UpgradeMode, projectWide bool) (*ComposeClient, error) {
 	if !slices.Contains(config.ValidUpgradeModes, upgradeMode) {
 		return nil, fmt.Errorf("invalid upgradeMode '%s', pick one of %+v", upgradeMode, config.ValidUpgradeModes)
 	}
@@ -51,6 +56,7 @@ func NewComposeClient(dockerClient *Client, versionFile, composeFile string, upg
 		versionFile: versionFile,
 		composeFile: composeFile,
 		upgradeMode: upgradeMode,
+		projectWide: projectWide,
 	}, nil
 }
 
@@ -58,6 +64,10 @@ func (dcc *ComposeClient) DockerClient() *Client {
 	return dcc.client
 }
 
+func (dcc *ComposeClient) UpgradeMode() config.UpgradeMode {
+	return dcc.upgradeMode
+}
+
 func (dcc *ComposeClient) GetImageAndVersionFromCompose(serviceName string) (string, string, error) {
 	project, err := LoadComposeFile(dcc.composeFile)
 	if err != nil {
@@ -117,118 +127,214 @@ func (dcc *ComposeClient) GetPlatform(serviceName string) (string, error) {
 }
 
 func (dcc *ComposeClient) UpgradeImage(ctx context.Context, serviceName, newVersion string) error {
+	return dcc.UpgradeImages(ctx, map[string]string{serviceName: newVersion})
+}
+
+// UpgradeImages updates the images of all services (service name -> tag or full image) in a single
+// compose file or version file edit. Either all services are updated or none of them.
+func (dcc *ComposeClient) UpgradeImages(ctx context.Context, newVersions map[string]string) error {
 	switch dcc.upgradeMode {
 	case config.UpgradeInEnvFile:
-		return dcc.upgradeImageInEnvFile(ctx, serviceName, newVersion)
+		return dcc.upgradeImagesInEnvFile(ctx, newVersions)
 	case config.UpgradeInComposeFile:
-		return dcc.upgradeImageInComposeFile(ctx, serviceName, newVersion)
+		return dcc.upgradeImagesInComposeFile(ctx, newVersions)
+	}
+	return fmt.Errorf("invalid upgrade mode %+v", dcc.upgradeMode)
+}
+
+// ValidateUpgradeImages ensures UpgradeImages would succeed for the given services, without modifying any files
+func (dcc *ComposeClient) ValidateUpgradeImages(ctx context.Context, newVersions map[string]string) error {
+	switch dcc.upgradeMode {
+	case config.UpgradeInEnvFile:
+		_, err := dcc.renderVersionFile(newVersions)
+		return err
+	case config.UpgradeInComposeFile:
+		content, err := dcc.renderComposeFile(ctx, newVersions)
+		if err != nil {
+			return err
+		}
+		return verifyCompose(filepath.Dir(dcc.composeFile), content)
 	}
 	return fmt.Errorf("invalid upgrade mode %+v", dcc.upgradeMode)
 }
 
 // Updates the `version` field in the version file, which is used in docker-compose
 // to determine the image version image to run
-func (dcc *ComposeClient) upgradeImageInEnvFile(ctx context.Context, serviceName, newVersion string) error {
-	oldVersion, err := LoadServiceVersionFile(dcc.versionFile, serviceName)
+func (dcc *ComposeClient) upgradeImagesInEnvFile(ctx context.Context, newVersions map[string]string) error {
+	content, err := dcc.renderVersionFile(newVersions)
 	if err != nil {
-		return errors.Wrapf(err, "loading the service version file failed")
+		return err
+	}
+
+	for _, serviceName := range ServiceNames(newVersions) {
+		oldVersion, err := LoadServiceVersionFile(dcc.versionFile, serviceName)
+		if err != nil {
+			return errors.Wrapf(err, "loading the service version file failed")
+		}
+		log.FromContext(ctx).Infof("Updating version on %s from %s to %s", dcc.versionFile, oldVersion, newVersions[serviceName])
+	}
+
+	err = os.WriteFile(dcc.versionFile, []byte(content), 0600)
+	if err != nil {
+		return errors.Wrapf(err, "failed to update the version file %s", dcc.versionFile)
 	}
-	log.FromContext(ctx).Infof("Updating version on %s from %s to %s", dcc.versionFile, oldVersion, newVersion)
 
-	return dcc.updateVersionFile(serviceName, newVersion)
+	return nil
 }
 
 // Updates version in the `image` field in docker compose
 //
 // This method does not write the parsed config into yaml, but instead uses simple string replacement
 // to preserve user formatting
-func (dcc *ComposeClient) upgradeImageInComposeFile(ctx context.Context, serviceName, newVersion string) error {
-	project, err := LoadComposeFile(dcc.composeFile)
-	if err != nil {
-		return errors.Wrapf(err, "compose file loading failed")
-	}
-
-	currService, err := getServiceFromProject(serviceName, project)
+func (dcc *ComposeClient) upgradeImagesInComposeFile(ctx context.Context, newVersions map[string]string) error {
+	currContent, err := os.ReadFile(dcc.composeFile)
 	if err != nil {
-		return err
+		return errors.Wrapf(err, "failed to read file")
 	}
 
-	image, _, err := ParseImageName(currService.Image)
+	updatedContent, err := dcc.renderComposeFile(ctx, newVersions)
 	if err != nil {
 		return err
 	}
 
-	newImage := fmt.Sprintf("%s:%s", image, newVersion)
-	if currService.Image == newImage {
-		log.FromContext(ctx).Warnf("image %s already registered in compose file", image)
+	if updatedContent == string(currContent) {
 		return nil
 	}
 
-	isImagePresent, err := dcc.client.IsImagePresent(ctx, newImage)
+	return updateComposeFile(dcc.composeFile, updatedContent)
+}
+
+// renderComposeFile returns the compose file content with the images of all services replaced
+func (dcc *ComposeClient) renderComposeFile(ctx context.Context, newVersions map[string]string) (string, error) {
+	project, err := LoadComposeFile(dcc.composeFile)
 	if err != nil {
-		return errors.Wrapf(err, "check for docker image present failed")
+		return "", errors.Wrapf(err, "compose file loading failed")
 	}
 
-	if !isImagePresent {
-		return fmt.Errorf("image %s not present on the system", newImage)
+	contentBytes, err := os.ReadFile(dcc.composeFile)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to read file")
 	}
+	content := string(contentBytes)
 
-	updatedContent, err := readAndReplace(dcc.composeFile, currService.Image, newImage)
-	if err != nil {
-		return err
+	for _, serviceName := range ServiceNames(newVersions) {
+		currService, err := getServiceFromProject(serviceName, project)
+		if err != nil {
+			return "", err
+		}
+
+		newImage, err := ResolveImage(currService.Image, newVersions[serviceName])
+		if err != nil {
+			return "", err
+		}
+
+		if currService.Image == newImage {
+			log.FromContext(ctx).Warnf("image %s already registered in compose file", newImage)
+			continue
+		}
+
+		isImagePresent, err := dcc.client.IsImagePresent(ctx, newImage)
+		if err != nil {
+			return "", errors.Wrapf(err, "check for docker image present failed")
+		}
+
+		if !isImagePresent {
+			return "", fmt.Errorf("image %s not present on the system", newImage)
+		}
+
+		content, err = replaceServiceImage(content, serviceName, currService.Image, newImage)
+		if err != nil {
+			return "", err
+		}
 	}
 
-	return updateComposeFile(dcc.composeFile, updatedContent)
+	return content, nil
 }
 
 func (dcc *ComposeClient) Down(ctx context.Context, serviceName string, timeout time.Duration) error {
+	return dcc.DownServices(ctx, []string{serviceName}, timeout)
+}
+
+// DownServices stops and removes the containers of the given services. Other services defined in the
+// compose file are left untouched, unless the client is configured to act on the whole project.
+func (dcc *ComposeClient) DownServices(ctx context.Context, serviceNames []string, timeout time.Duration) error {
 	// 5 seconds buffer to handle the case when docker timeout (-t)
 	// takes slightly longer than defined timeout (thus delay context cancellation)
 	deadline := timeout + 5*time.Second
-	timeoutSeconds := int(math.Round(timeout.Seconds()))
+	timeoutSeconds := strconv.Itoa(int(math.Round(timeout.Seconds())))
 
-	err := cmd.ExecuteWithDeadlineAndLog(ctx, deadline, []string{}, "docker", "compose", "-f", dcc.composeFile, "down", "--remove-orphans", "-t", strconv.Itoa(timeoutSeconds))
-	if err != nil {
-		return errors.Wrapf(err, "docker compose down failed")
-	}
+	if dcc.projectWide {
+		err := cmd.ExecuteWithDeadlineAndLog(ctx, deadline, []string{}, "docker", "compose", "-f", dcc.composeFile, "down", "--remove-orphans", "-t", timeoutSeconds)
+		if err != nil {
+			return errors.Wrapf(err, "docker compose down failed")
+		}
+	} else {
+		args := append([]string{"compose", "-f", dcc.composeFile, "stop", "-t", timeoutSeconds}, serviceNames...)
+		if err := cmd.ExecuteWithDeadlineAndLog(ctx, deadline, []string{}, "docker", args...); err != nil {
+			return errors.Wrapf(err, "docker compose stop failed")
+		}
 
-	// verify from docker api that the container is down
-	isImageContainerRunning, err := dcc.IsServiceRunning(ctx, serviceName, timeout)
-	if err != nil {
-		return errors.Wrapf(err, "check for container running failed")
+		// remove the stopped containers, so that up recreates them the same way as after compose down
+		args = append([]string{"compose", "-f", dcc.composeFile, "rm", "-f"}, serviceNames...)
+		if err := cmd.ExecuteWithDeadlineAndLog(ctx, deadline, []string{}, "docker", args...); err != nil {
+			return errors.Wrapf(err, "docker compose rm failed")
+		}
 	}
-	if isImageContainerRunning {
-		return errors.Wrapf(ErrContainerRunning, "compose down didn't stop the container")
+
+	// verify from docker api that the containers are down
+	for _, serviceName := range serviceNames {
+		isImageContainerRunning, err := dcc.IsServiceRunning(ctx, serviceName, timeout)
+		if err != nil {
+			return errors.Wrapf(err, "check for container running failed")
+		}
+		if isImageContainerRunning {
+			return errors.Wrapf(ErrContainerRunning, "compose down didn't stop the container of service %s", serviceName)
+		}
 	}
 
 	return nil
 }
 
 func (dcc *ComposeClient) Up(ctx context.Context, serviceName string, timeout time.Duration, ephemeralEnvVars ...string) error {
-	isImageContainerRunning, err := dcc.IsServiceRunning(ctx, serviceName, timeout)
-	if err != nil {
-		return errors.Wrapf(err, "check for container running failed")
-	}
-	if isImageContainerRunning {
-		return errors.Wrapf(ErrContainerRunning, "expected the container to be down before calling docker compose up")
+	return dcc.UpServices(ctx, []string{serviceName}, timeout, ephemeralEnvVars...)
+}
+
+// UpServices (re)creates and starts the containers of the given services without touching their
+// dependencies, unless the client is configured to act on the whole project.
+func (dcc *ComposeClient) UpServices(ctx context.Context, serviceNames []string, timeout time.Duration, ephemeralEnvVars ...string) error {
+	for _, serviceName := range serviceNames {
+		isImageContainerRunning, err := dcc.IsServiceRunning(ctx, serviceName, timeout)
+		if err != nil {
+			return errors.Wrapf(err, "check for container running failed")
+		}
+		if isImageContainerRunning {
+			return errors.Wrapf(ErrContainerRunning, "expected the container of service %s to be down before calling docker compose up", serviceName)
+		}
 	}
 
 	// docker-compose up supports -t flag but it is only used
 	// when containers are already running and need to be shut down
 	// before starting them again, we are ensuring that containers are
 	// not running at this point, so we don't need to use -t flag
-	err = cmd.ExecuteWithDeadlineAndLog(ctx, timeout, ephemeralEnvVars, "docker", "compose", "-f", dcc.composeFile, "up", "-d", "--force-recreate")
-	if err != nil {
-		return errors.Wrapf(err, "docker compose up failed")
+	args := []string{"compose", "-f", dcc.composeFile, "up", "-d", "--force-recreate"}
+	if !dcc.projectWide {
+		args = append(append(args, "--no-deps"), serviceNames...)
 	}
 
-	// verify from docker api that the container is up
-	isImageContainerRunning, err = dcc.IsServiceRunning(ctx, serviceName, timeout)
+	err := cmd.ExecuteWithDeadlineAndLog(ctx, timeout, ephemeralEnvVars, "docker", args...)
 	if err != nil {
-		return errors.Wrapf(err, "check for container running failed")
+		return errors.Wrapf(err, "docker compose up failed")
 	}
-	if !isImageContainerRunning {
-		return errors.Wrapf(ErrContainerNotRunning, "compose up didn't start container")
+
+	// verify from docker api that the containers are up
+	for _, serviceName := range serviceNames {
+		isImageContainerRunning, err := dcc.IsServiceRunning(ctx, serviceName, timeout)
+		if err != nil {
+			return errors.Wrapf(err, "check for container running failed")
+		}
+		if !isImageContainerRunning {
+			return errors.Wrapf(ErrContainerNotRunning, "compose up didn't start the container of service %s", serviceName)
+		}
 	}
 
 	return nil
@@ -312,32 +418,34 @@ func (dcc *ComposeClient) Version(ctx context.Context) (string, error) {
 	return strings.ReplaceAll(stdout.String(), "\n", ""), err
 }
 
-func (dcc *ComposeClient) updateVersionFile(serviceName, newContent string) error {
+// renderVersionFile returns the version file content with the versions of all services replaced
+func (dcc *ComposeClient) renderVersionFile(newVersions map[string]string) (string, error) {
 	versions, err := GetServiceVersions(dcc.versionFile)
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	found := false
+	found := make(map[string]bool, len(newVersions))
 	lines := []string{}
 	for _, service := range versions {
-		if service.Name == serviceName {
-			found = true
-			service.Version = newContent
+		if newVersion, ok := newVersions[service.Name]; ok {
+			// the version is substituted after the colon in the compose file (image: repo:${VERSION_x}), hence a tag is required
+			if IsImageReference(newVersion) || IsDigestOnly(newVersion) {
+				return "", fmt.Errorf("env-file upgrade mode supports only image tags (optionally with a digest, e.g v1.0.0@sha256:...), got %s for service %s", newVersion, service.Name)
+			}
+			found[service.Name] = true
+			service.Version = newVersion
 		}
 		lines = append(lines, fmt.Sprintf("VERSION_%s=%s", service.Name, service.Version))
 	}
 
-	if !found {
-		return fmt.Errorf("could not find VERSION_%s on %s", serviceName, dcc.versionFile)
-	}
-
-	err = os.WriteFile(dcc.versionFile, []byte(strings.Join(lines, "\n")), 0600)
-	if err != nil {
-		return errors.Wrapf(err, "failed to update the version file %s", dcc.versionFile)
+	for _, serviceName := range ServiceNames(newVersions) {
+		if !found[serviceName] {
+			return "", fmt.Errorf("could not find VERSION_%s on %s", serviceName, dcc.versionFile)
+		}
 	}
 
-	return nil
+	return strings.Join(lines, "\n"), nil
 }
 
 type ServiceVersionLine struct {
@@ -439,18 +547,48 @@ func verifyCompose(baseDir string, content string) error {
 	return nil
 }
 
-func readAndReplace(path, from, to string) (string, error) {
-	contentBytes, err := os.ReadFile(path)
-	if err != nil {
-		return "", errors.Wrapf(err, "failed to read file")
+// replaceServiceImage replaces the image of a single service in the compose file content. Only the image node of the
+// service is edited, the other services using the same image are left untouched, as well as the file formatting
+func replaceServiceImage(content, serviceName, from, to string) (string, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal([]byte(content), &root); err != nil {
+		return "", errors.Wrapf(err, "failed to parse compose file")
 	}
-	content := string(contentBytes)
 
-	if strings.Count(content, from) != 1 {
-		return "", fmt.Errorf("file contains multiple instances of %s string", from)
+	var image *yaml.Node
+	if len(root.Content) > 0 {
+		image = mappingValue(mappingValue(mappingValue(root.Content[0], "services"), serviceName), "image")
+	}
+	if image == nil || image.Kind != yaml.ScalarNode {
+		return "", fmt.Errorf("service %s has no image set in the compose file", serviceName)
 	}
+	if image.Value != from {
+		return "", fmt.Errorf("service %s image is %s in the compose file, expected %s", serviceName, image.Value, from)
+	}
+
+	// the node position is 1-based, the column is counted in characters
+	lines := strings.SplitAfter(content, "\n")
+	line := []rune(lines[image.Line-1])
+	prefix, rest := string(line[:image.Column-1]), string(line[image.Column-1:])
+	if !strings.Contains(rest, from) {
+		return "", fmt.Errorf("service %s image %s not found at line %d of the compose file", serviceName, from, image.Line)
+	}
+	lines[image.Line-1] = prefix + strings.Replace(rest, from, to, 1)
+
+	return strings.Join(lines, ""), nil
+}
 
-	return strings.Replace(content, from, to, 1), nil
+// mappingValue returns the value of the key in the yaml mapping node, or nil if there is none
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
 }
 
 func updateComposeFile(composeFile, newContent string) error {
@@ -477,6 +615,11 @@ func updateComposeFile(composeFile, newContent string) error {
 	return nil
 }
 
+// ServiceNames returns the names of the services (service name -> tag or image) in a stable order
+func ServiceNames(versions map[string]string) []string {
+	return slices.Sorted(maps.Keys(versions))
+}
+
 func getServiceFromProject(serviceName string, project *composeTypes.Project) (*composeTypes.ServiceConfig, error) {
 	for _, service := range project.Services {
 		if service.Name == serviceName {
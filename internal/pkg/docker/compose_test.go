@@ -158,6 +158,40 @@ func TestUpgradeImageMultipleVersionFile(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, "5.4", version)
 }
+
+func TestUpgradeImagesMultipleVersionFile(t *testing.T) {
+	tempDir := testutils.PrepareTestData(t, "docker", "compose-upgrade-test", "compose-upgrade-test")
+	composePath := filepath.Join(tempDir, "docker-compose.yml")
+
+	envDir := testutils.PrepareTestData(t, "docker", "envfile", "envfile")
+	envPath := filepath.Join(envDir, "env-with-multiple-services")
+
+	ctx, dcc := newDockerComposeClientWithCtx(t, envPath, composePath, config.UpgradeInEnvFile)
+
+	// step 1: unknown service fails validation and nothing is changed
+	newVersions := map[string]string{"s1": "6.0", "unknown": "6.0"}
+	require.Error(t, dcc.ValidateUpgradeImages(ctx, newVersions))
+	require.Error(t, dcc.UpgradeImages(ctx, newVersions))
+
+	version, err := dcc.GetVersionForService("s1")
+	require.NoError(t, err)
+	assert.Equal(t, "5.3", version)
+
+	// step 2: full image references are not supported in env file mode
+	require.Error(t, dcc.ValidateUpgradeImages(ctx, map[string]string{"s1": "abcd/efgh:6.0"}))
+
+	// step 3: upgrade both services at once
+	newVersions = map[string]string{"s1": "6.0", "S1": "6.1"}
+	require.NoError(t, dcc.ValidateUpgradeImages(ctx, newVersions))
+	require.NoError(t, dcc.UpgradeImages(ctx, newVersions))
+
+	for serviceName, expected := range newVersions {
+		version, err = dcc.GetVersionForService(serviceName)
+		require.NoError(t, err)
+		assert.Equal(t, expected, version)
+	}
+}
+
 func TestUpgradeImageComposeFile(t *testing.T) {
 	tempDir := testutils.PrepareTestData(t, "docker", "compose-upgrade-test", "compose-upgrade-test")
 	composePath := filepath.Join(tempDir, "docker-compose.yml")
@@ -183,6 +217,37 @@ func TestUpgradeImageComposeFile(t *testing.T) {
 	assert.Equal(t, testVersion, version)
 }
 
+func TestReplaceServiceImage(t *testing.T) {
+	content := `services:
+  s1:
+    image: "abcd/efgh:ijkl"
+    command: ["start"]
+  s2:
+    # same image as s1
+    image: abcd/efgh:ijkl
+  s3:
+    image: abcd/mnop:ijkl
+`
+
+	// the services sharing the image are upgraded separately
+	updated, err := replaceServiceImage(content, "s2", "abcd/efgh:ijkl", "abcd/efgh:new-version")
+	require.NoError(t, err)
+	assert.Equal(t, strings.Replace(content, "image: abcd/efgh:ijkl", "image: abcd/efgh:new-version", 1), updated)
+
+	updated, err = replaceServiceImage(updated, "s1", "abcd/efgh:ijkl", "abcd/efgh:new-version")
+	require.NoError(t, err)
+	assert.Contains(t, updated, `image: "abcd/efgh:new-version"`)
+	assert.NotContains(t, updated, "abcd/efgh:ijkl")
+	assert.Contains(t, updated, "image: abcd/mnop:ijkl")
+
+	// the compose file changed since it was loaded
+	_, err = replaceServiceImage(content, "s3", "abcd/efgh:ijkl", "abcd/efgh:new-version")
+	require.Error(t, err)
+
+	_, err = replaceServiceImage(content, "s4", "abcd/efgh:ijkl", "abcd/efgh:new-version")
+	require.Error(t, err)
+}
+
 func TestUpDownCompose(t *testing.T) {
 	tempDir := testutils.PrepareTestData(t, "docker", "compose-valid-template", "compose-valid-template")
 	composePath := filepath.Join(tempDir, "docker-compose.yml")
@@ -279,6 +344,47 @@ func TestUpDownCompose(t *testing.T) {
 	}
 }
 
+func TestUpDownComposeServiceScoped(t *testing.T) {
+	tempDir := testutils.PrepareTestData(t, "docker", "compose-multiple-services-template", "compose-multiple-services-template")
+	composePath := filepath.Join(tempDir, "docker-compose.yml")
+
+	ctx, dcc := newDockerComposeClientWithCtx(t, "", composePath, config.UpgradeInComposeFile)
+	testutils.MakeImageWith(t, "image", "version", dockerProvider)
+
+	err := testutils.WriteTmpl(
+		filepath.Join(tempDir, "docker-compose.yml.tmpl"),
+		struct{ Image string }{Image: "image:version"},
+	)
+	require.NoError(t, err)
+
+	// step 1: start both services
+	err = exec.Command("docker", "compose", "-f", composePath, "up", "-d").Run()
+	require.NoError(t, err)
+
+	// step 2: take only s1 down, s2 must keep running
+	err = dcc.Down(ctx, "s1", 0)
+	require.NoError(t, err)
+
+	isRunning, err := dcc.IsServiceRunning(ctx, "s2", 5*time.Second)
+	require.NoError(t, err)
+	assert.True(t, isRunning)
+
+	// step 3: bring s1 up, s2 is already running and must not be recreated
+	containerID, err := dcc.GetContainerID(ctx, "s2", 5*time.Second)
+	require.NoError(t, err)
+
+	err = dcc.Up(ctx, "s1", 10*time.Second)
+	require.NoError(t, err)
+
+	newContainerID, err := dcc.GetContainerID(ctx, "s2", 5*time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, containerID, newContainerID)
+
+	// cleanup
+	err = exec.Command("docker", "compose", "-f", composePath, "down").Run()
+	require.NoError(t, err)
+}
+
 func TestRestartEnvCompose(t *testing.T) {
 	tempDir := testutils.PrepareTestData(t, "docker", "compose-env-echo", "compose-env-echo")
 	composePath := filepath.Join(tempDir, "docker-compose.yml")
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"regexp"
 	"strings"
 	"time"
 
@@ -11,9 +12,11 @@ import (
 	"blazar/internal/pkg/errors"
 	"blazar/internal/pkg/log"
 
+	"github.com/distribution/reference"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/image"
 	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
 )
 
 type Client struct {
@@ -45,15 +48,35 @@ func NewClient(ctx context.Context, ch CredentialHelper) (*Client, error) {
 	}, nil
 }
 
+// IsImagePresent checks if the image is present on the host. Images referenced by a digest are matched against
+// the repo digests (the tag is ignored, the same way docker does), otherwise against the repo tags
 func (dc *Client) IsImagePresent(ctx context.Context, name string) (bool, error) {
+	ref, err := reference.ParseNormalizedNamed(name)
+	if err != nil {
+		return false, errors.Wrapf(err, "invalid image reference: %s", name)
+	}
+
+	var expected string
+	if digested, ok := ref.(reference.Digested); ok {
+		expected = ref.Name() + "@" + digested.Digest().String()
+	} else {
+		expected = reference.TagNameOnly(ref).String()
+	}
+
 	images, err := dc.client.ImageList(ctx, image.ListOptions{})
 	if err != nil {
 		return false, err
 	}
 
 	for _, image := range images {
-		for _, repoTag := range image.RepoTags {
-			if repoTag == name {
+		candidates := image.RepoTags
+		if _, ok := ref.(reference.Digested); ok {
+			candidates = image.RepoDigests
+		}
+
+		for _, candidate := range candidates {
+			// the docker daemon stores the familiar names (e.g "ubuntu:22.04"), therefore both sides are normalized
+			if candidateRef, err := reference.ParseNormalizedNamed(candidate); err == nil && candidateRef.String() == expected {
 				return true, nil
 			}
 		}
@@ -62,6 +85,15 @@ func (dc *Client) IsImagePresent(ctx context.Context, name string) (bool, error)
 	return false, nil
 }
 
+// GetImageID returns the ID (content digest of the image config) of the local image
+func (dc *Client) GetImageID(ctx context.Context, name string) (string, error) {
+	resp, err := dc.client.ImageInspect(ctx, name)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to inspect image %s", name)
+	}
+	return resp.ID, nil
+}
+
 func (dc *Client) PullImage(ctx context.Context, name string, platform string) error {
 	imagePullOptions := image.PullOptions{
 		Platform: platform,
@@ -147,15 +179,133 @@ func (dc *Client) IsContainerRunning(ctx context.Context, containerID string) (b
 	return false, nil
 }
 
+// FollowContainerLogs streams the stdout and stderr of the container, from its start, until the container stops
+// or the returned reader is closed
+func (dc *Client) FollowContainerLogs(ctx context.Context, containerID string) (io.ReadCloser, error) {
+	inspect, err := dc.client.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to inspect container %s", containerID)
+	}
+
+	logs, err := dc.client.ContainerLogs(ctx, containerID, container.LogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Follow:     true,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to follow logs of container %s", containerID)
+	}
+
+	// with a tty the output is sent as is, otherwise stdout and stderr are multiplexed in a single stream
+	if inspect.Config != nil && inspect.Config.Tty {
+		return logs, nil
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		_, err := stdcopy.StdCopy(pw, pw, logs)
+		pw.CloseWithError(err)
+	}()
+
+	return &demuxedLogs{PipeReader: pr, logs: logs}, nil
+}
+
+type demuxedLogs struct {
+	*io.PipeReader
+	logs io.ReadCloser
+}
+
+func (d *demuxedLogs) Close() error {
+	// closing the source stops the demultiplexing goroutine
+	err := d.logs.Close()
+	d.PipeReader.Close()
+	return err
+}
+
 func (dc *Client) ContainerList(ctx context.Context, all bool) ([]container.Summary, error) {
 	return dc.client.ContainerList(ctx, container.ListOptions{All: all})
 }
 
+// IsImageReference returns true if the value is a full image reference (e.g "registry:5000/repo/image:tag")
+// rather than just a version. A version is a tag, a digest or both (e.g "v1.0.0", "sha256:...", "v1.0.0@sha256:...")
+func IsImageReference(value string) bool {
+	return !isVersion(value)
+}
+
+var (
+	anchoredTagRegexp    = regexp.MustCompile(`^` + reference.TagRegexp.String() + `$`)
+	anchoredDigestRegexp = regexp.MustCompile(`^` + reference.DigestRegexp.String() + `$`)
+)
+
+func isVersion(value string) bool {
+	tag, digest, hasDigest := strings.Cut(value, "@")
+	if hasDigest {
+		return (tag == "" || anchoredTagRegexp.MatchString(tag)) && anchoredDigestRegexp.MatchString(digest)
+	}
+
+	return anchoredTagRegexp.MatchString(value) || anchoredDigestRegexp.MatchString(value)
+}
+
+// IsDigestOnly returns true if the version pins a digest without a tag (e.g "sha256:...")
+func IsDigestOnly(version string) bool {
+	return anchoredDigestRegexp.MatchString(version)
+}
+
+// ResolveImage returns the image to run given the current image and the version (tag and/or digest)
+// or full image to upgrade to
+func ResolveImage(currImage, tagOrImage string) (string, error) {
+	if IsImageReference(tagOrImage) {
+		if _, err := reference.Parse(tagOrImage); err != nil {
+			return "", errors.Wrapf(err, "invalid image reference: %s", tagOrImage)
+		}
+		return tagOrImage, nil
+	}
+
+	image, _, err := ParseImageName(currImage)
+	if err != nil {
+		return "", err
+	}
+
+	return JoinImageName(image, tagOrImage), nil
+}
+
+// ParseImageName splits the image reference into the repository (including the registry host and port, if any)
+// and the version, which is the tag, the digest or "<tag>@<digest>" if both are present.
+// The repository is returned as written, without the docker.io normalization.
 func ParseImageName(imageName string) (string, string, error) {
-	parts := strings.Split(imageName, ":")
-	if len(parts) != 2 {
+	ref, err := reference.Parse(imageName)
+	if err != nil {
 		return "", "", fmt.Errorf("invalid image name: %s", imageName)
 	}
 
-	return parts[0], parts[1], nil
+	named, ok := ref.(reference.Named)
+	if !ok {
+		return "", "", fmt.Errorf("invalid image name: %s", imageName)
+	}
+
+	var tag, digest string
+	if tagged, ok := named.(reference.Tagged); ok {
+		tag = tagged.Tag()
+	}
+	if digested, ok := named.(reference.Digested); ok {
+		digest = digested.Digest().String()
+	}
+
+	switch {
+	case tag != "" && digest != "":
+		return named.Name(), tag + "@" + digest, nil
+	case tag != "":
+		return named.Name(), tag, nil
+	case digest != "":
+		return named.Name(), digest, nil
+	}
+	return "", "", fmt.Errorf("invalid image name: %s", imageName)
+}
+
+// JoinImageName is the inverse of ParseImageName
+func JoinImageName(image, version string) string {
+	if IsDigestOnly(version) {
+		return image + "@" + version
+	}
+	return image + ":" + version
 }
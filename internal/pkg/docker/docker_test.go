@@ -11,6 +11,8 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
+const testDigest = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
 func TestIsImagePresent(t *testing.T) {
 	img, ver := "img", "tag"
 	testutils.MakeImageWith(t, img, ver, dockerProvider)
@@ -26,6 +28,11 @@ func TestIsImagePresent(t *testing.T) {
 			image:     fmt.Sprintf("%s:%sinvalidtag", img, ver),
 			isPresent: false,
 		},
+		{
+			// the docker daemon stores the familiar name
+			image:     fmt.Sprintf("docker.io/library/%s:%s", img, ver),
+			isPresent: true,
+		},
 	}
 
 	ctx, dc := newDockerClientWithCtx(t)
@@ -50,6 +57,24 @@ func TestParseImageName(t *testing.T) {
 			tag:       "latest",
 			err:       nil,
 		},
+		{
+			imageName: "registry.internal:5000/testrepo/testimage:v17",
+			image:     "registry.internal:5000/testrepo/testimage",
+			tag:       "v17",
+			err:       nil,
+		},
+		{
+			imageName: "testrepo/testimage@" + testDigest,
+			image:     "testrepo/testimage",
+			tag:       testDigest,
+			err:       nil,
+		},
+		{
+			imageName: "registry.internal:5000/testimage:v17@" + testDigest,
+			image:     "registry.internal:5000/testimage",
+			tag:       "v17@" + testDigest,
+			err:       nil,
+		},
 		{
 			imageName: "testrepo/testimage:latest:invalid",
 			image:     "",
@@ -69,6 +94,45 @@ func TestParseImageName(t *testing.T) {
 		assert.Equal(t, test.image, image)
 		assert.Equal(t, test.tag, tag)
 		assert.Equal(t, test.err, err)
+
+		if err == nil {
+			assert.Equal(t, test.imageName, JoinImageName(image, tag))
+		}
+	}
+}
+
+func TestResolveImage(t *testing.T) {
+	tests := []struct {
+		currImage  string
+		tagOrImage string
+		expected   string
+	}{
+		{
+			currImage:  "registry.internal:5000/gaia:v16",
+			tagOrImage: "v17",
+			expected:   "registry.internal:5000/gaia:v17",
+		},
+		{
+			currImage:  "registry.internal:5000/gaia:v16",
+			tagOrImage: testDigest,
+			expected:   "registry.internal:5000/gaia@" + testDigest,
+		},
+		{
+			currImage:  "gaia:v16@" + testDigest,
+			tagOrImage: "v17@" + testDigest,
+			expected:   "gaia:v17@" + testDigest,
+		},
+		{
+			currImage:  "gaia:v16",
+			tagOrImage: "registry.internal:5000/gaia:v17",
+			expected:   "registry.internal:5000/gaia:v17",
+		},
+	}
+
+	for _, test := range tests {
+		image, err := ResolveImage(test.currImage, test.tagOrImage)
+		require.NoError(t, err)
+		assert.Equal(t, test.expected, image)
 	}
 }
 
@@ -39,7 +39,7 @@ func newDockerClientWithCtx(t *testing.T) (context.Context, *Client) {
 
 func newDockerComposeClientWithCtx(t *testing.T, versionFile, composeFile string, upgradeMode config.UpgradeMode) (context.Context, *ComposeClient) {
 	ctx := testutils.NewContext()
-	dcc, err := NewDefaultComposeClient(ctx, nil, versionFile, composeFile, upgradeMode)
+	dcc, err := NewDefaultComposeClient(ctx, nil, versionFile, composeFile, upgradeMode, false)
 	require.NoError(t, err)
 
 	return ctx, dcc
@@ -15,12 +15,23 @@ type FallbackNotifier struct {
 	logger   *zerolog.Logger
 	notifier Notifier
 
+	// prepended to every message, e.g. to mark the dry-run mode
+	prefix string
+
 	// map the first message of the thread to the upgrade height
 	// and group the mssages into threaded conversation
 	// if the underlying notifier supports it
-	// TODO: the thread mapping is not persisted, so it will be lost on restart
 	lock           sync.RWMutex
 	upgradeThreads map[int64]string
+
+	// persists the thread mapping, so the threads are continued after a restart
+	threadStore ThreadStore
+}
+
+// ThreadStore persists the first message of the upgrade threads
+type ThreadStore interface {
+	GetNotificationThreads() map[int64]string
+	SetNotificationThread(height int64, messageID string)
 }
 
 // NewFallbackNotifier creates a new notifier with fallback to logger
@@ -29,6 +40,7 @@ func NewFallbackNotifier(cfg *config.Config, metrics *metrics.Metrics, logger *z
 		metrics:  metrics,
 		logger:   logger,
 		notifier: NewNotifier(cfg, hostname),
+		prefix:   messagePrefix(cfg),
 
 		lock:           sync.RWMutex{},
 		upgradeThreads: make(map[int64]string),
@@ -38,7 +50,7 @@ func NewFallbackNotifier(cfg *config.Config, metrics *metrics.Metrics, logger *z
 func (cn *FallbackNotifier) NotifyInfo(ctx context.Context, message string) {
 	if cn.notifier != nil {
 		parentMessageID, upgradeHeight := cn.getParentMessage(ctx)
-		messageID, err := cn.notifier.NotifyInfo(message, MsgOptionParent(parentMessageID))
+		messageID, err := cn.notifier.NotifyInfo(cn.prefix+message, MsgOptionParent(parentMessageID))
 		if err != nil {
 			if cn.metrics != nil {
 				cn.metrics.NotifErrs.Inc()
@@ -53,7 +65,7 @@ func (cn *FallbackNotifier) NotifyInfo(ctx context.Context, message string) {
 func (cn *FallbackNotifier) NotifyWarnWithErr(ctx context.Context, message string, err error) {
 	if cn.notifier != nil {
 		parentMessageID, upgradeHeight := cn.getParentMessage(ctx)
-		messageID, err := cn.notifier.NotifyWarn(message, MsgOptionParent(parentMessageID), MsgOptionError(err))
+		messageID, err := cn.notifier.NotifyWarn(cn.prefix+message, MsgOptionParent(parentMessageID), MsgOptionError(err))
 		if err != nil {
 			if cn.metrics != nil {
 				cn.metrics.NotifErrs.Inc()
@@ -68,7 +80,7 @@ func (cn *FallbackNotifier) NotifyWarnWithErr(ctx context.Context, message strin
 func (cn *FallbackNotifier) NotifyWarn(ctx context.Context, message string) {
 	if cn.notifier != nil {
 		parentMessageID, upgradeHeight := cn.getParentMessage(ctx)
-		messageID, err := cn.notifier.NotifyWarn(message, MsgOptionParent(parentMessageID))
+		messageID, err := cn.notifier.NotifyWarn(cn.prefix+message, MsgOptionParent(parentMessageID))
 		if err != nil {
 			if cn.metrics != nil {
 				cn.metrics.NotifErrs.Inc()
@@ -83,7 +95,7 @@ func (cn *FallbackNotifier) NotifyWarn(ctx context.Context, message string) {
 func (cn *FallbackNotifier) NotifyErr(ctx context.Context, message string, err error) {
 	if cn.notifier != nil {
 		parentMessageID, upgradeHeight := cn.getParentMessage(ctx)
-		messageID, err := cn.notifier.NotifyErr(message, MsgOptionParent(parentMessageID), MsgOptionError(err))
+		messageID, err := cn.notifier.NotifyErr(cn.prefix+message, MsgOptionParent(parentMessageID), MsgOptionError(err))
 		if err != nil {
 			if cn.metrics != nil {
 				cn.metrics.NotifErrs.Inc()
@@ -95,12 +107,39 @@ func (cn *FallbackNotifier) NotifyErr(ctx context.Context, message string, err e
 	}
 }
 
+func messagePrefix(cfg *config.Config) string {
+	if cfg.DryRun {
+		return "[DRY-RUN] "
+	}
+	return ""
+}
+
+// SetThreadStore restores the upgrade threads from the store and saves the new ones to it
+func (cn *FallbackNotifier) SetThreadStore(store ThreadStore) {
+	threads := store.GetNotificationThreads()
+
+	cn.lock.Lock()
+	defer cn.lock.Unlock()
+
+	for upgradeHeight, messageID := range threads {
+		if _, ok := cn.upgradeThreads[upgradeHeight]; !ok {
+			cn.upgradeThreads[upgradeHeight] = messageID
+		}
+	}
+	cn.threadStore = store
+}
+
 func (cn *FallbackNotifier) registerUpgradeThread(upgradeHeight int64, parentMessageID, messageID string) {
 	if upgradeHeight != 0 && parentMessageID == "" {
 		cn.lock.Lock()
-		defer cn.lock.Unlock()
-
 		cn.upgradeThreads[upgradeHeight] = messageID
+		store := cn.threadStore
+		cn.lock.Unlock()
+
+		// the store may notify about its own failures, it can't be called with the lock held
+		if store != nil {
+			store.SetNotificationThread(upgradeHeight, messageID)
+		}
 	}
 }
 
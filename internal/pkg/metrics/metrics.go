@@ -24,6 +24,12 @@ type Metrics struct {
 	UiwErrs            prometheus.Counter
 	HwErrs             prometheus.Counter
 	NotifErrs          prometheus.Counter
+	StatePersistErrs   prometheus.Counter
+
+	// upgrade registry providers sync state
+	ProviderLastSync   *prometheus.GaugeVec
+	ProviderStaleness  *prometheus.GaugeVec
+	ProviderSyncErrors *prometheus.GaugeVec
 }
 
 func NewMetrics(composeFile, hostname, version, chainID string) *Metrics {
@@ -101,6 +107,41 @@ func NewMetrics(composeFile, hostname, version, chainID string) *Metrics {
 				ConstLabels: labels,
 			},
 		),
+		StatePersistErrs: promauto.NewCounter(
+			prometheus.CounterOpts{
+				Namespace:   namespace,
+				Name:        "state_persist_errors",
+				Help:        "State machine persistence error count",
+				ConstLabels: labels,
+			},
+		),
+		ProviderLastSync: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Name:        "provider_last_sync_timestamp_seconds",
+				Help:        "Unix time of the last successful sync of the upgrade registry provider",
+				ConstLabels: labels,
+			},
+			[]string{"provider"},
+		),
+		ProviderStaleness: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Name:        "provider_staleness_seconds",
+				Help:        "Age of the upgrades and versions served for the upgrade registry provider",
+				ConstLabels: labels,
+			},
+			[]string{"provider"},
+		),
+		ProviderSyncErrors: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Name:        "provider_consecutive_sync_errors",
+				Help:        "Number of failed syncs in a row of the upgrade registry provider",
+				ConstLabels: labels,
+			},
+			[]string{"provider"},
+		),
 	}
 
 	return metrics
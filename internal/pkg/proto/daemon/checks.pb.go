@@ -28,6 +28,12 @@ const (
 	PreCheck_PULL_DOCKER_IMAGE PreCheck = 0
 	// Set the node's halt-height before non-governance coordinated upgrades
 	PreCheck_SET_HALT_HEIGHT PreCheck = 1
+	// Archive selected chain-home paths once the node is stopped, before the upgrade is applied
+	PreCheck_BACKUP_DATA PreCheck = 2
+	// Run the upgrade binary (version --long) and compare the reported version with the upgrade tag
+	PreCheck_VERIFY_BINARY_VERSION PreCheck = 3
+	// Check if the node is synced, has enough peers and our validator is neither jailed nor tombstoned
+	PreCheck_NODE_SYNC_STATUS PreCheck = 4
 )
 
 // Enum value maps for PreCheck.
@@ -35,10 +41,16 @@ var (
 	PreCheck_name = map[int32]string{
 		0: "PULL_DOCKER_IMAGE",
 		1: "SET_HALT_HEIGHT",
+		2: "BACKUP_DATA",
+		3: "VERIFY_BINARY_VERSION",
+		4: "NODE_SYNC_STATUS",
 	}
 	PreCheck_value = map[string]int32{
-		"PULL_DOCKER_IMAGE": 0,
-		"SET_HALT_HEIGHT":   1,
+		"PULL_DOCKER_IMAGE":     0,
+		"SET_HALT_HEIGHT":       1,
+		"BACKUP_DATA":           2,
+		"VERIFY_BINARY_VERSION": 3,
+		"NODE_SYNC_STATUS":      4,
 	}
 )
 
@@ -78,6 +90,12 @@ const (
 	PostCheck_CHAIN_HEIGHT_INCREASED PostCheck = 1
 	// Check if we signed the first block post upgrade
 	PostCheck_FIRST_BLOCK_VOTED PostCheck = 2
+	// Follow the upgraded service logs for fatal patterns (e.g app hash mismatch, panics) while the other checks run
+	PostCheck_NO_FATAL_LOGS PostCheck = 3
+	// Check if the node reports the upgrade version, different from the one recorded before the upgrade
+	PostCheck_APP_VERSION_MATCHES PostCheck = 4
+	// Check if the x/upgrade module applied the governance upgrade plan at the upgrade height
+	PostCheck_UPGRADE_PLAN_APPLIED PostCheck = 5
 )
 
 // Enum value maps for PostCheck.
@@ -86,11 +104,17 @@ var (
 		0: "GRPC_RESPONSIVE",
 		1: "CHAIN_HEIGHT_INCREASED",
 		2: "FIRST_BLOCK_VOTED",
+		3: "NO_FATAL_LOGS",
+		4: "APP_VERSION_MATCHES",
+		5: "UPGRADE_PLAN_APPLIED",
 	}
 	PostCheck_value = map[string]int32{
 		"GRPC_RESPONSIVE":        0,
 		"CHAIN_HEIGHT_INCREASED": 1,
 		"FIRST_BLOCK_VOTED":      2,
+		"NO_FATAL_LOGS":          3,
+		"APP_VERSION_MATCHES":    4,
+		"UPGRADE_PLAN_APPLIED":   5,
 	}
 )
 
@@ -130,6 +154,8 @@ const (
 	CheckStatus_RUNNING CheckStatus = 1
 	// Check execution has finished
 	CheckStatus_FINISHED CheckStatus = 2
+	// Check execution has finished with an error, the upgrade is not executed
+	CheckStatus_ERROR CheckStatus = 3
 )
 
 // Enum value maps for CheckStatus.
@@ -138,11 +164,13 @@ var (
 		0: "PENDING",
 		1: "RUNNING",
 		2: "FINISHED",
+		3: "ERROR",
 	}
 	CheckStatus_value = map[string]int32{
 		"PENDING":  0,
 		"RUNNING":  1,
 		"FINISHED": 2,
+		"ERROR":    3,
 	}
 )
 
@@ -177,18 +205,25 @@ var File_checks_proto protoreflect.FileDescriptor
 
 const file_checks_proto_rawDesc = "" +
 	"\n" +
-	"\fchecks.proto*6\n" +
+	"\fchecks.proto*x\n" +
 	"\bPreCheck\x12\x15\n" +
 	"\x11PULL_DOCKER_IMAGE\x10\x00\x12\x13\n" +
-	"\x0fSET_HALT_HEIGHT\x10\x01*S\n" +
+	"\x0fSET_HALT_HEIGHT\x10\x01\x12\x0f\n" +
+	"\vBACKUP_DATA\x10\x02\x12\x19\n" +
+	"\x15VERIFY_BINARY_VERSION\x10\x03\x12\x14\n" +
+	"\x10NODE_SYNC_STATUS\x10\x04*\x99\x01\n" +
 	"\tPostCheck\x12\x13\n" +
 	"\x0fGRPC_RESPONSIVE\x10\x00\x12\x1a\n" +
 	"\x16CHAIN_HEIGHT_INCREASED\x10\x01\x12\x15\n" +
-	"\x11FIRST_BLOCK_VOTED\x10\x02*5\n" +
+	"\x11FIRST_BLOCK_VOTED\x10\x02\x12\x11\n" +
+	"\rNO_FATAL_LOGS\x10\x03\x12\x17\n" +
+	"\x13APP_VERSION_MATCHES\x10\x04\x12\x18\n" +
+	"\x14UPGRADE_PLAN_APPLIED\x10\x05*@\n" +
 	"\vCheckStatus\x12\v\n" +
 	"\aPENDING\x10\x00\x12\v\n" +
 	"\aRUNNING\x10\x01\x12\f\n" +
-	"\bFINISHED\x10\x02B\x1bZ\x19internal/pkg/proto/daemonb\x06proto3"
+	"\bFINISHED\x10\x02\x12\t\n" +
+	"\x05ERROR\x10\x03B\x1bZ\x19internal/pkg/proto/daemonb\x06proto3"
 
 var (
 	file_checks_proto_rawDescOnce sync.Once
@@ -10,7 +10,6 @@ import (
 	_ "google.golang.org/genproto/googleapis/api/annotations"
 	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
 	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
-	_ "google.golang.org/protobuf/types/known/timestamppb"
 	reflect "reflect"
 	sync "sync"
 	unsafe "unsafe"
@@ -105,6 +104,8 @@ const (
 	UpgradeStatus_CANCELLED UpgradeStatus = 6
 	// EXPIRED means that the upgrade time has passed and blazar did not do anything about it (e.g historical upgrade from the chain governance)
 	UpgradeStatus_EXPIRED UpgradeStatus = 7
+	// ROLLED_BACK means that the post-upgrade checks failed and blazar restored the previous version of the node
+	UpgradeStatus_ROLLED_BACK UpgradeStatus = 8
 )
 
 // Enum value maps for UpgradeStatus.
@@ -118,16 +119,18 @@ var (
 		5: "FAILED",
 		6: "CANCELLED",
 		7: "EXPIRED",
+		8: "ROLLED_BACK",
 	}
 	UpgradeStatus_value = map[string]int32{
-		"UNKNOWN":   0,
-		"SCHEDULED": 1,
-		"ACTIVE":    2,
-		"EXECUTING": 3,
-		"COMPLETED": 4,
-		"FAILED":    5,
-		"CANCELLED": 6,
-		"EXPIRED":   7,
+		"UNKNOWN":     0,
+		"SCHEDULED":   1,
+		"ACTIVE":      2,
+		"EXECUTING":   3,
+		"COMPLETED":   4,
+		"FAILED":      5,
+		"CANCELLED":   6,
+		"EXPIRED":     7,
+		"ROLLED_BACK": 8,
 	}
 )
 
@@ -234,6 +237,10 @@ const (
 	ProviderType_LOCAL ProviderType = 1
 	// DATABASE means that the upgrade is coming from the database (e.g PostgreSQL)
 	ProviderType_DATABASE ProviderType = 2
+	// REGISTRY means that the version is resolved from the tags of a container registry (version resolver only)
+	ProviderType_REGISTRY ProviderType = 3
+	// CHAIN_REGISTRY means that the version is resolved from a local cosmos/chain-registry checkout (version resolver only)
+	ProviderType_CHAIN_REGISTRY ProviderType = 4
 )
 
 // Enum value maps for ProviderType.
@@ -242,11 +249,15 @@ var (
 		0: "CHAIN",
 		1: "LOCAL",
 		2: "DATABASE",
+		3: "REGISTRY",
+		4: "CHAIN_REGISTRY",
 	}
 	ProviderType_value = map[string]int32{
-		"CHAIN":    0,
-		"LOCAL":    1,
-		"DATABASE": 2,
+		"CHAIN":          0,
+		"LOCAL":          1,
+		"DATABASE":       2,
+		"REGISTRY":       3,
+		"CHAIN_REGISTRY": 4,
 	}
 )
 
@@ -310,9 +321,21 @@ type Upgrade struct {
 	ProposalId *int64 `protobuf:"varint,10,opt,name=proposal_id,json=proposalId,proto3,oneof" json:"proposal_id,omitempty"`
 	// created_at timestamp
 
-	CreatedAt     uint64 `protobuf:"varint,11,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty" gorm:"not null,serializer:timestamppb"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+	CreatedAt uint64 `protobuf:"varint,11,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty" gorm:"not null"`
+	// additional compose services upgraded together with the main service (service name -> image tag or full image)
+
+	Services map[string]string `protobuf:"bytes,12,rep,name=services,proto3" json:"services,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value" gorm:"type:text;serializer:json"`
+	// digest of the upgrade image pinned by the PULL_DOCKER_IMAGE pre-check (DONT set this field manually, it's managed by the registry)
+
+	ImageDigest string `protobuf:"bytes,13,opt,name=image_digest,json=imageDigest,proto3" json:"image_digest,omitempty" gorm:"-"`
+	// regex matched against the version or commit reported by the upgrade binary, the upgrade tag is compared if empty
+
+	ExpectedVersion string `protobuf:"bytes,14,opt,name=expected_version,json=expectedVersion,proto3" json:"expected_version,omitempty" gorm:"type:text"`
+	// module versions changed by the upgrade, as reported by the x/upgrade module before and after it (DONT set this field manually, it's managed by the registry)
+
+	ModuleVersionsDiff []*ModuleVersionChange `protobuf:"bytes,15,rep,name=module_versions_diff,json=moduleVersionsDiff,proto3" json:"module_versions_diff,omitempty" gorm:"-"`
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
 }
 
 func (x *Upgrade) Reset() {
@@ -422,6 +445,95 @@ func (x *Upgrade) GetCreatedAt() uint64 {
 	return 0
 }
 
+func (x *Upgrade) GetServices() map[string]string {
+	if x != nil {
+		return x.Services
+	}
+	return nil
+}
+
+func (x *Upgrade) GetImageDigest() string {
+	if x != nil {
+		return x.ImageDigest
+	}
+	return ""
+}
+
+func (x *Upgrade) GetExpectedVersion() string {
+	if x != nil {
+		return x.ExpectedVersion
+	}
+	return ""
+}
+
+func (x *Upgrade) GetModuleVersionsDiff() []*ModuleVersionChange {
+	if x != nil {
+		return x.ModuleVersionsDiff
+	}
+	return nil
+}
+
+// A module consensus version changed by the upgrade, 0 means the module didn't exist
+type ModuleVersionChange struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	FromVersion   uint64                 `protobuf:"varint,2,opt,name=from_version,json=fromVersion,proto3" json:"from_version,omitempty"`
+	ToVersion     uint64                 `protobuf:"varint,3,opt,name=to_version,json=toVersion,proto3" json:"to_version,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ModuleVersionChange) Reset() {
+	*x = ModuleVersionChange{}
+	mi := &file_upgrades_registry_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ModuleVersionChange) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ModuleVersionChange) ProtoMessage() {}
+
+func (x *ModuleVersionChange) ProtoReflect() protoreflect.Message {
+	mi := &file_upgrades_registry_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ModuleVersionChange.ProtoReflect.Descriptor instead.
+func (*ModuleVersionChange) Descriptor() ([]byte, []int) {
+	return file_upgrades_registry_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ModuleVersionChange) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ModuleVersionChange) GetFromVersion() uint64 {
+	if x != nil {
+		return x.FromVersion
+	}
+	return 0
+}
+
+func (x *ModuleVersionChange) GetToVersion() uint64 {
+	if x != nil {
+		return x.ToVersion
+	}
+	return 0
+}
+
 // This is the structure of <chain-home>/blazar/upgrades.json
 type Upgrades struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
@@ -432,7 +544,7 @@ type Upgrades struct {
 
 func (x *Upgrades) Reset() {
 	*x = Upgrades{}
-	mi := &file_upgrades_registry_proto_msgTypes[1]
+	mi := &file_upgrades_registry_proto_msgTypes[2]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -444,7 +556,7 @@ func (x *Upgrades) String() string {
 func (*Upgrades) ProtoMessage() {}
 
 func (x *Upgrades) ProtoReflect() protoreflect.Message {
-	mi := &file_upgrades_registry_proto_msgTypes[1]
+	mi := &file_upgrades_registry_proto_msgTypes[2]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -457,7 +569,7 @@ func (x *Upgrades) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Upgrades.ProtoReflect.Descriptor instead.
 func (*Upgrades) Descriptor() ([]byte, []int) {
-	return file_upgrades_registry_proto_rawDescGZIP(), []int{1}
+	return file_upgrades_registry_proto_rawDescGZIP(), []int{2}
 }
 
 func (x *Upgrades) GetUpgrades() []*Upgrade {
@@ -479,7 +591,7 @@ type AddUpgradeRequest struct {
 
 func (x *AddUpgradeRequest) Reset() {
 	*x = AddUpgradeRequest{}
-	mi := &file_upgrades_registry_proto_msgTypes[2]
+	mi := &file_upgrades_registry_proto_msgTypes[3]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -491,7 +603,7 @@ func (x *AddUpgradeRequest) String() string {
 func (*AddUpgradeRequest) ProtoMessage() {}
 
 func (x *AddUpgradeRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_upgrades_registry_proto_msgTypes[2]
+	mi := &file_upgrades_registry_proto_msgTypes[3]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -504,7 +616,7 @@ func (x *AddUpgradeRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use AddUpgradeRequest.ProtoReflect.Descriptor instead.
 func (*AddUpgradeRequest) Descriptor() ([]byte, []int) {
-	return file_upgrades_registry_proto_rawDescGZIP(), []int{2}
+	return file_upgrades_registry_proto_rawDescGZIP(), []int{3}
 }
 
 func (x *AddUpgradeRequest) GetUpgrade() *Upgrade {
@@ -529,7 +641,7 @@ type AddUpgradeResponse struct {
 
 func (x *AddUpgradeResponse) Reset() {
 	*x = AddUpgradeResponse{}
-	mi := &file_upgrades_registry_proto_msgTypes[3]
+	mi := &file_upgrades_registry_proto_msgTypes[4]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -541,7 +653,7 @@ func (x *AddUpgradeResponse) String() string {
 func (*AddUpgradeResponse) ProtoMessage() {}
 
 func (x *AddUpgradeResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_upgrades_registry_proto_msgTypes[3]
+	mi := &file_upgrades_registry_proto_msgTypes[4]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -554,7 +666,7 @@ func (x *AddUpgradeResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use AddUpgradeResponse.ProtoReflect.Descriptor instead.
 func (*AddUpgradeResponse) Descriptor() ([]byte, []int) {
-	return file_upgrades_registry_proto_rawDescGZIP(), []int{3}
+	return file_upgrades_registry_proto_rawDescGZIP(), []int{4}
 }
 
 type ListUpgradesRequest struct {
@@ -571,7 +683,7 @@ type ListUpgradesRequest struct {
 
 func (x *ListUpgradesRequest) Reset() {
 	*x = ListUpgradesRequest{}
-	mi := &file_upgrades_registry_proto_msgTypes[4]
+	mi := &file_upgrades_registry_proto_msgTypes[5]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -583,7 +695,7 @@ func (x *ListUpgradesRequest) String() string {
 func (*ListUpgradesRequest) ProtoMessage() {}
 
 func (x *ListUpgradesRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_upgrades_registry_proto_msgTypes[4]
+	mi := &file_upgrades_registry_proto_msgTypes[5]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -596,7 +708,7 @@ func (x *ListUpgradesRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ListUpgradesRequest.ProtoReflect.Descriptor instead.
 func (*ListUpgradesRequest) Descriptor() ([]byte, []int) {
-	return file_upgrades_registry_proto_rawDescGZIP(), []int{4}
+	return file_upgrades_registry_proto_rawDescGZIP(), []int{5}
 }
 
 func (x *ListUpgradesRequest) GetDisableCache() bool {
@@ -650,7 +762,7 @@ type ListUpgradesResponse struct {
 
 func (x *ListUpgradesResponse) Reset() {
 	*x = ListUpgradesResponse{}
-	mi := &file_upgrades_registry_proto_msgTypes[5]
+	mi := &file_upgrades_registry_proto_msgTypes[6]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -662,7 +774,7 @@ func (x *ListUpgradesResponse) String() string {
 func (*ListUpgradesResponse) ProtoMessage() {}
 
 func (x *ListUpgradesResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_upgrades_registry_proto_msgTypes[5]
+	mi := &file_upgrades_registry_proto_msgTypes[6]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -675,7 +787,7 @@ func (x *ListUpgradesResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ListUpgradesResponse.ProtoReflect.Descriptor instead.
 func (*ListUpgradesResponse) Descriptor() ([]byte, []int) {
-	return file_upgrades_registry_proto_rawDescGZIP(), []int{5}
+	return file_upgrades_registry_proto_rawDescGZIP(), []int{6}
 }
 
 func (x *ListUpgradesResponse) GetUpgrades() []*Upgrade {
@@ -697,7 +809,7 @@ type CancelUpgradeRequest struct {
 
 func (x *CancelUpgradeRequest) Reset() {
 	*x = CancelUpgradeRequest{}
-	mi := &file_upgrades_registry_proto_msgTypes[6]
+	mi := &file_upgrades_registry_proto_msgTypes[7]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -709,7 +821,7 @@ func (x *CancelUpgradeRequest) String() string {
 func (*CancelUpgradeRequest) ProtoMessage() {}
 
 func (x *CancelUpgradeRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_upgrades_registry_proto_msgTypes[6]
+	mi := &file_upgrades_registry_proto_msgTypes[7]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -722,7 +834,7 @@ func (x *CancelUpgradeRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use CancelUpgradeRequest.ProtoReflect.Descriptor instead.
 func (*CancelUpgradeRequest) Descriptor() ([]byte, []int) {
-	return file_upgrades_registry_proto_rawDescGZIP(), []int{6}
+	return file_upgrades_registry_proto_rawDescGZIP(), []int{7}
 }
 
 func (x *CancelUpgradeRequest) GetHeight() int64 {
@@ -754,7 +866,7 @@ type CancelUpgradeResponse struct {
 
 func (x *CancelUpgradeResponse) Reset() {
 	*x = CancelUpgradeResponse{}
-	mi := &file_upgrades_registry_proto_msgTypes[7]
+	mi := &file_upgrades_registry_proto_msgTypes[8]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -766,7 +878,7 @@ func (x *CancelUpgradeResponse) String() string {
 func (*CancelUpgradeResponse) ProtoMessage() {}
 
 func (x *CancelUpgradeResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_upgrades_registry_proto_msgTypes[7]
+	mi := &file_upgrades_registry_proto_msgTypes[8]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -779,7 +891,7 @@ func (x *CancelUpgradeResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use CancelUpgradeResponse.ProtoReflect.Descriptor instead.
 func (*CancelUpgradeResponse) Descriptor() ([]byte, []int) {
-	return file_upgrades_registry_proto_rawDescGZIP(), []int{7}
+	return file_upgrades_registry_proto_rawDescGZIP(), []int{8}
 }
 
 // ForceSyncRequest is used to force the registry to sync the upgrades from all registered providers
@@ -791,7 +903,7 @@ type ForceSyncRequest struct {
 
 func (x *ForceSyncRequest) Reset() {
 	*x = ForceSyncRequest{}
-	mi := &file_upgrades_registry_proto_msgTypes[8]
+	mi := &file_upgrades_registry_proto_msgTypes[9]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -803,7 +915,7 @@ func (x *ForceSyncRequest) String() string {
 func (*ForceSyncRequest) ProtoMessage() {}
 
 func (x *ForceSyncRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_upgrades_registry_proto_msgTypes[8]
+	mi := &file_upgrades_registry_proto_msgTypes[9]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -816,7 +928,7 @@ func (x *ForceSyncRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ForceSyncRequest.ProtoReflect.Descriptor instead.
 func (*ForceSyncRequest) Descriptor() ([]byte, []int) {
-	return file_upgrades_registry_proto_rawDescGZIP(), []int{8}
+	return file_upgrades_registry_proto_rawDescGZIP(), []int{9}
 }
 
 type ForceSyncResponse struct {
@@ -829,7 +941,7 @@ type ForceSyncResponse struct {
 
 func (x *ForceSyncResponse) Reset() {
 	*x = ForceSyncResponse{}
-	mi := &file_upgrades_registry_proto_msgTypes[9]
+	mi := &file_upgrades_registry_proto_msgTypes[10]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -841,7 +953,7 @@ func (x *ForceSyncResponse) String() string {
 func (*ForceSyncResponse) ProtoMessage() {}
 
 func (x *ForceSyncResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_upgrades_registry_proto_msgTypes[9]
+	mi := &file_upgrades_registry_proto_msgTypes[10]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -854,7 +966,7 @@ func (x *ForceSyncResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ForceSyncResponse.ProtoReflect.Descriptor instead.
 func (*ForceSyncResponse) Descriptor() ([]byte, []int) {
-	return file_upgrades_registry_proto_rawDescGZIP(), []int{9}
+	return file_upgrades_registry_proto_rawDescGZIP(), []int{10}
 }
 
 func (x *ForceSyncResponse) GetHeight() int64 {
@@ -868,7 +980,7 @@ var File_upgrades_registry_proto protoreflect.FileDescriptor
 
 const file_upgrades_registry_proto_rawDesc = "" +
 	"\n" +
-	"\x17upgrades_registry.proto\x1a\x1cgoogle/api/annotations.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"\xe5\x02\n" +
+	"\x17upgrades_registry.proto\x1a\x1cgoogle/api/annotations.proto\"\xec\x04\n" +
 	"\aUpgrade\x12\x16\n" +
 	"\x06height\x18\x01 \x01(\x03R\x06height\x12\x10\n" +
 	"\x03tag\x18\x02 \x01(\tR\x03tag\x12\x18\n" +
@@ -883,8 +995,20 @@ const file_upgrades_registry_proto_rawDesc = "" +
 	" \x01(\x03H\x00R\n" +
 	"proposalId\x88\x01\x01\x12\x1d\n" +
 	"\n" +
-	"created_at\x18\v \x01(\x04R\tcreatedAtB\x0e\n" +
-	"\f_proposal_id\"0\n" +
+	"created_at\x18\v \x01(\x04R\tcreatedAt\x122\n" +
+	"\bservices\x18\f \x03(\v2\x16.Upgrade.ServicesEntryR\bservices\x12!\n" +
+	"\fimage_digest\x18\r \x01(\tR\vimageDigest\x12)\n" +
+	"\x10expected_version\x18\x0e \x01(\tR\x0fexpectedVersion\x12F\n" +
+	"\x14module_versions_diff\x18\x0f \x03(\v2\x14.ModuleVersionChangeR\x12moduleVersionsDiff\x1a;\n" +
+	"\rServicesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01B\x0e\n" +
+	"\f_proposal_id\"k\n" +
+	"\x13ModuleVersionChange\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12!\n" +
+	"\ffrom_version\x18\x02 \x01(\x04R\vfromVersion\x12\x1d\n" +
+	"\n" +
+	"to_version\x18\x03 \x01(\x04R\ttoVersion\"0\n" +
 	"\bUpgrades\x12$\n" +
 	"\bupgrades\x18\x01 \x03(\v2\b.UpgradeR\bupgrades\"U\n" +
 	"\x11AddUpgradeRequest\x12\"\n" +
@@ -918,7 +1042,7 @@ const file_upgrades_registry_proto_rawDesc = "" +
 	"MONITORING\x10\x01\x12\x18\n" +
 	"\x14COMPOSE_FILE_UPGRADE\x10\x02\x12\x15\n" +
 	"\x11PRE_UPGRADE_CHECK\x10\x03\x12\x16\n" +
-	"\x12POST_UPGRADE_CHECK\x10\x04*}\n" +
+	"\x12POST_UPGRADE_CHECK\x10\x04*\x8e\x01\n" +
 	"\rUpgradeStatus\x12\v\n" +
 	"\aUNKNOWN\x10\x00\x12\r\n" +
 	"\tSCHEDULED\x10\x01\x12\n" +
@@ -929,16 +1053,19 @@ const file_upgrades_registry_proto_rawDesc = "" +
 	"\n" +
 	"\x06FAILED\x10\x05\x12\r\n" +
 	"\tCANCELLED\x10\x06\x12\v\n" +
-	"\aEXPIRED\x10\a*_\n" +
+	"\aEXPIRED\x10\a\x12\x0f\n" +
+	"\vROLLED_BACK\x10\b*_\n" +
 	"\vUpgradeType\x12\x0e\n" +
 	"\n" +
 	"GOVERNANCE\x10\x00\x12\x1e\n" +
 	"\x1aNON_GOVERNANCE_COORDINATED\x10\x01\x12 \n" +
-	"\x1cNON_GOVERNANCE_UNCOORDINATED\x10\x02*2\n" +
+	"\x1cNON_GOVERNANCE_UNCOORDINATED\x10\x02*T\n" +
 	"\fProviderType\x12\t\n" +
 	"\x05CHAIN\x10\x00\x12\t\n" +
 	"\x05LOCAL\x10\x01\x12\f\n" +
-	"\bDATABASE\x10\x022\xf5\x02\n" +
+	"\bDATABASE\x10\x02\x12\f\n" +
+	"\bREGISTRY\x10\x03\x12\x12\n" +
+	"\x0eCHAIN_REGISTRY\x10\x042\xf5\x02\n" +
 	"\x0fUpgradeRegistry\x12R\n" +
 	"\n" +
 	"AddUpgrade\x12\x12.AddUpgradeRequest\x1a\x13.AddUpgradeResponse\"\x1b\x82\xd3\xe4\x93\x02\x15:\x01*\"\x10/v1/upgrades/add\x12V\n" +
@@ -959,48 +1086,52 @@ func file_upgrades_registry_proto_rawDescGZIP() []byte {
 }
 
 var file_upgrades_registry_proto_enumTypes = make([]protoimpl.EnumInfo, 4)
-var file_upgrades_registry_proto_msgTypes = make([]protoimpl.MessageInfo, 10)
+var file_upgrades_registry_proto_msgTypes = make([]protoimpl.MessageInfo, 12)
 var file_upgrades_registry_proto_goTypes = []any{
 	(UpgradeStep)(0),              // 0: UpgradeStep
 	(UpgradeStatus)(0),            // 1: UpgradeStatus
 	(UpgradeType)(0),              // 2: UpgradeType
 	(ProviderType)(0),             // 3: ProviderType
 	(*Upgrade)(nil),               // 4: Upgrade
-	(*Upgrades)(nil),              // 5: Upgrades
-	(*AddUpgradeRequest)(nil),     // 6: AddUpgradeRequest
-	(*AddUpgradeResponse)(nil),    // 7: AddUpgradeResponse
-	(*ListUpgradesRequest)(nil),   // 8: ListUpgradesRequest
-	(*ListUpgradesResponse)(nil),  // 9: ListUpgradesResponse
-	(*CancelUpgradeRequest)(nil),  // 10: CancelUpgradeRequest
-	(*CancelUpgradeResponse)(nil), // 11: CancelUpgradeResponse
-	(*ForceSyncRequest)(nil),      // 12: ForceSyncRequest
-	(*ForceSyncResponse)(nil),     // 13: ForceSyncResponse
+	(*ModuleVersionChange)(nil),   // 5: ModuleVersionChange
+	(*Upgrades)(nil),              // 6: Upgrades
+	(*AddUpgradeRequest)(nil),     // 7: AddUpgradeRequest
+	(*AddUpgradeResponse)(nil),    // 8: AddUpgradeResponse
+	(*ListUpgradesRequest)(nil),   // 9: ListUpgradesRequest
+	(*ListUpgradesResponse)(nil),  // 10: ListUpgradesResponse
+	(*CancelUpgradeRequest)(nil),  // 11: CancelUpgradeRequest
+	(*CancelUpgradeResponse)(nil), // 12: CancelUpgradeResponse
+	(*ForceSyncRequest)(nil),      // 13: ForceSyncRequest
+	(*ForceSyncResponse)(nil),     // 14: ForceSyncResponse
+	nil,                           // 15: Upgrade.ServicesEntry
 }
 var file_upgrades_registry_proto_depIdxs = []int32{
 	2,  // 0: Upgrade.type:type_name -> UpgradeType
 	1,  // 1: Upgrade.status:type_name -> UpgradeStatus
 	0,  // 2: Upgrade.step:type_name -> UpgradeStep
 	3,  // 3: Upgrade.source:type_name -> ProviderType
-	4,  // 4: Upgrades.upgrades:type_name -> Upgrade
-	4,  // 5: AddUpgradeRequest.upgrade:type_name -> Upgrade
-	2,  // 6: ListUpgradesRequest.type:type_name -> UpgradeType
-	3,  // 7: ListUpgradesRequest.source:type_name -> ProviderType
-	1,  // 8: ListUpgradesRequest.status:type_name -> UpgradeStatus
-	4,  // 9: ListUpgradesResponse.upgrades:type_name -> Upgrade
-	3,  // 10: CancelUpgradeRequest.source:type_name -> ProviderType
-	6,  // 11: UpgradeRegistry.AddUpgrade:input_type -> AddUpgradeRequest
-	8,  // 12: UpgradeRegistry.ListUpgrades:input_type -> ListUpgradesRequest
-	10, // 13: UpgradeRegistry.CancelUpgrade:input_type -> CancelUpgradeRequest
-	12, // 14: UpgradeRegistry.ForceSync:input_type -> ForceSyncRequest
-	7,  // 15: UpgradeRegistry.AddUpgrade:output_type -> AddUpgradeResponse
-	9,  // 16: UpgradeRegistry.ListUpgrades:output_type -> ListUpgradesResponse
-	11, // 17: UpgradeRegistry.CancelUpgrade:output_type -> CancelUpgradeResponse
-	13, // 18: UpgradeRegistry.ForceSync:output_type -> ForceSyncResponse
-	15, // [15:19] is the sub-list for method output_type
-	11, // [11:15] is the sub-list for method input_type
-	11, // [11:11] is the sub-list for extension type_name
-	11, // [11:11] is the sub-list for extension extendee
-	0,  // [0:11] is the sub-list for field type_name
+	15, // 4: Upgrade.services:type_name -> Upgrade.ServicesEntry
+	5,  // 5: Upgrade.module_versions_diff:type_name -> ModuleVersionChange
+	4,  // 6: Upgrades.upgrades:type_name -> Upgrade
+	4,  // 7: AddUpgradeRequest.upgrade:type_name -> Upgrade
+	2,  // 8: ListUpgradesRequest.type:type_name -> UpgradeType
+	3,  // 9: ListUpgradesRequest.source:type_name -> ProviderType
+	1,  // 10: ListUpgradesRequest.status:type_name -> UpgradeStatus
+	4,  // 11: ListUpgradesResponse.upgrades:type_name -> Upgrade
+	3,  // 12: CancelUpgradeRequest.source:type_name -> ProviderType
+	7,  // 13: UpgradeRegistry.AddUpgrade:input_type -> AddUpgradeRequest
+	9,  // 14: UpgradeRegistry.ListUpgrades:input_type -> ListUpgradesRequest
+	11, // 15: UpgradeRegistry.CancelUpgrade:input_type -> CancelUpgradeRequest
+	13, // 16: UpgradeRegistry.ForceSync:input_type -> ForceSyncRequest
+	8,  // 17: UpgradeRegistry.AddUpgrade:output_type -> AddUpgradeResponse
+	10, // 18: UpgradeRegistry.ListUpgrades:output_type -> ListUpgradesResponse
+	12, // 19: UpgradeRegistry.CancelUpgrade:output_type -> CancelUpgradeResponse
+	14, // 20: UpgradeRegistry.ForceSync:output_type -> ForceSyncResponse
+	17, // [17:21] is the sub-list for method output_type
+	13, // [13:17] is the sub-list for method input_type
+	13, // [13:13] is the sub-list for extension type_name
+	13, // [13:13] is the sub-list for extension extendee
+	0,  // [0:13] is the sub-list for field type_name
 }
 
 func init() { file_upgrades_registry_proto_init() }
@@ -1009,14 +1140,14 @@ func file_upgrades_registry_proto_init() {
 		return
 	}
 	file_upgrades_registry_proto_msgTypes[0].OneofWrappers = []any{}
-	file_upgrades_registry_proto_msgTypes[4].OneofWrappers = []any{}
+	file_upgrades_registry_proto_msgTypes[5].OneofWrappers = []any{}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_upgrades_registry_proto_rawDesc), len(file_upgrades_registry_proto_rawDesc)),
 			NumEnums:      4,
-			NumMessages:   10,
+			NumMessages:   12,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
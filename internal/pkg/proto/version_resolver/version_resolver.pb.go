@@ -10,7 +10,6 @@ import (
 	_ "google.golang.org/genproto/googleapis/api/annotations"
 	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
 	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
-	_ "google.golang.org/protobuf/types/known/timestamppb"
 	upgrades_registry "blazar/internal/pkg/proto/upgrades_registry"
 	reflect "reflect"
 	sync "sync"
@@ -42,7 +41,10 @@ type Version struct {
 	Priority int32 `protobuf:"varint,5,opt,name=priority,proto3" json:"priority,omitempty" gorm:"primaryKey;not null"`
 	// created_at timestamp
 
-	CreatedAt     uint64 `protobuf:"varint,11,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty" gorm:"not null"`
+	CreatedAt uint64 `protobuf:"varint,11,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty" gorm:"not null"`
+	// how the version tag was resolved (e.g. from the upgrade plan info), empty for registered versions
+
+	Provenance    string `protobuf:"bytes,12,opt,name=provenance,proto3" json:"provenance,omitempty" gorm:"-"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -119,6 +121,13 @@ func (x *Version) GetCreatedAt() uint64 {
 	return 0
 }
 
+func (x *Version) GetProvenance() string {
+	if x != nil {
+		return x.Provenance
+	}
+	return ""
+}
+
 type RegisterVersionRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Version       *Version               `protobuf:"bytes,1,opt,name=version,proto3" json:"version,omitempty"`
@@ -411,7 +420,7 @@ var File_version_resolver_proto protoreflect.FileDescriptor
 
 const file_version_resolver_proto_rawDesc = "" +
 	"\n" +
-	"\x16version_resolver.proto\x1a\x17upgrades_registry.proto\x1a\x1cgoogle/api/annotations.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"\xaf\x01\n" +
+	"\x16version_resolver.proto\x1a\x17upgrades_registry.proto\x1a\x1cgoogle/api/annotations.proto\"\xcf\x01\n" +
 	"\aVersion\x12\x16\n" +
 	"\x06height\x18\x01 \x01(\x03R\x06height\x12\x18\n" +
 	"\anetwork\x18\x02 \x01(\tR\anetwork\x12\x10\n" +
@@ -419,7 +428,10 @@ const file_version_resolver_proto_rawDesc = "" +
 	"\x06source\x18\x04 \x01(\x0e2\r.ProviderTypeR\x06source\x12\x1a\n" +
 	"\bpriority\x18\x05 \x01(\x05R\bpriority\x12\x1d\n" +
 	"\n" +
-	"created_at\x18\v \x01(\x04R\tcreatedAt\"Z\n" +
+	"created_at\x18\v \x01(\x04R\tcreatedAt\x12\x1e\n" +
+	"\n" +
+	"provenance\x18\f \x01(\tR\n" +
+	"provenance\"Z\n" +
 	"\x16RegisterVersionRequest\x12\"\n" +
 	"\aversion\x18\x01 \x01(\v2\b.VersionR\aversion\x12\x1c\n" +
 	"\toverwrite\x18\x02 \x01(\bR\toverwrite\"\x19\n" +
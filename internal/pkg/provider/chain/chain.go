@@ -2,49 +2,116 @@ package chain
 
 import (
 	"context"
+	"slices"
 	"sort"
+	"sync"
 	"time"
 
 	"blazar/internal/pkg/errors"
 	urproto "blazar/internal/pkg/proto/upgrades_registry"
+	vrproto "blazar/internal/pkg/proto/version_resolver"
 	"blazar/internal/pkg/provider"
 
 	v1 "github.com/cosmos/cosmos-sdk/x/gov/types/v1"
 	"github.com/cosmos/cosmos-sdk/x/gov/types/v1beta1"
+	upgradetypes "github.com/cosmos/cosmos-sdk/x/upgrade/types"
 )
 
 type CosmosProposalsProvider interface {
 	GetProposalsV1(ctx context.Context) (v1.Proposals, error)
 	GetProposalsV1beta1(ctx context.Context) (v1beta1.Proposals, error)
+	GetCurrentPlan(ctx context.Context) (*upgradetypes.Plan, error)
+	GetAppliedPlanHeight(ctx context.Context, name string) (int64, error)
 }
 
 type Provider struct {
 	cosmosClient CosmosProposalsProvider
 	chain        string
 	priority     int32
+
+	// resolves the version tags from the upgrade plans
+	planInfoResolver *PlanInfoResolver
+
+	// poll the x/upgrade current plan in addition to the gov proposals
+	currentPlan bool
+	// upgrades seen in the current plan so far (height -> upgrade), the plan itself doesn't keep any history
+	plans     map[int64]*planUpgrade
+	plansLock sync.Mutex
+
+	// upgrades returned by the last GetUpgrades call, GetVersions resolves the tags from them instead of fetching the
+	// proposals again
+	lastUpgrades     []chainUpgrade
+	lastUpgradesLock sync.Mutex
 }
 
-func NewProvider(cosmosClient CosmosProposalsProvider, chain string, priority int32) *Provider {
+type planUpgrade struct {
+	chainUpgrade
+
+	// the plan was applied by the x/upgrade module, no need to query it anymore
+	applied bool
+}
+
+// NewProvider creates the chain provider, the default plan info resolver is used if planInfoResolver is nil
+func NewProvider(cosmosClient CosmosProposalsProvider, chain string, priority int32, currentPlan bool, planInfoResolver *PlanInfoResolver) *Provider {
+	if planInfoResolver == nil {
+		planInfoResolver = &PlanInfoResolver{platform: defaultPlatform}
+	}
+
 	return &Provider{
-		cosmosClient: cosmosClient,
-		chain:        chain,
-		priority:     priority,
+		cosmosClient:     cosmosClient,
+		chain:            chain,
+		priority:         priority,
+		planInfoResolver: planInfoResolver,
+		currentPlan:      currentPlan,
+		plans:            make(map[int64]*planUpgrade),
 	}
 }
 
 func (p *Provider) GetUpgrades(ctx context.Context) ([]*urproto.Upgrade, error) {
-	upgrades, err := p.fetchAllUpgrades(ctx)
+	upgrades, err := p.getUpgrades(ctx)
 	if err != nil {
 		return []*urproto.Upgrade{}, err
 	}
 
+	return toProto(upgrades, p.priority), nil
+}
+
+// getUpgrades fetches the upgrades and remembers them for GetVersions
+func (p *Provider) getUpgrades(ctx context.Context) ([]chainUpgrade, error) {
+	upgrades, err := p.resolveUpgrades(ctx)
+	if err != nil {
+		return []chainUpgrade{}, err
+	}
+
+	p.lastUpgradesLock.Lock()
+	p.lastUpgrades = upgrades
+	p.lastUpgradesLock.Unlock()
+
+	return upgrades, nil
+}
+
+func (p *Provider) resolveUpgrades(ctx context.Context) ([]chainUpgrade, error) {
+	proposals, err := p.fetchAllUpgrades(ctx)
+	if err != nil {
+		return []chainUpgrade{}, err
+	}
+
+	upgrades, cancels := make([]chainUpgrade, 0, len(proposals)), make([]chainUpgrade, 0)
+	for _, proposal := range proposals {
+		if proposal.Cancel {
+			cancels = append(cancels, proposal)
+		} else {
+			upgrades = append(upgrades, proposal)
+		}
+	}
+
 	// Blazar expects one upgrade per height, but the governance allows to create multiple proposals for the same height
 	// In the end only one upgrade will be expecuted at given height, no matter how many software upgrades proposals are registered onchain
 	// The most common case for having more than one proposal is when someone create a new proposal and asks everyone to vote-no on the previous one
 	// due to invalid data etc.
 	//
 	// To handle this case we pick the last proposal for each height with some conditions:
-	// 1. if there is a proposal in PASSED state, we pick it
+	// 1. if there is a proposal in PASSED state, we pick it (the last executed one if there are more)
 	// 2. if there are two equal proposals say in VOTING_PERIOD state, we pick the one with the highest proposal id
 
 	// sort upgrades in descending order by proposal id
@@ -68,54 +135,72 @@ func (p *Provider) GetUpgrades(ctx context.Context) ([]*urproto.Upgrade, error)
 			continue
 		}
 
-		// if there is a passed upgrade, we pick it
-		foundPassed := false
-		for _, upgrade := range upgradesForHeight {
-			// the upgrades are sorted by proposal id in descending order
-			// so the first upgrade in the list is the one with the highest
-			// proposal id (in case there are two PASSED proposals for the same height)
-			if upgrade.Status == PASSED {
-				foundPassed = true
-				filtered = append(filtered, upgrade)
-				break
-			}
+		// if there is a passed upgrade, we pick the last executed one
+		if passed, ok := lastExecuted(upgradesForHeight); ok {
+			filtered = append(filtered, passed)
+			continue
 		}
 
 		// if there is no passed upgrade, we pick the one with the highest proposal id
-		if !foundPassed {
-			filtered = append(filtered, upgradesForHeight[0])
-		}
+		filtered = append(filtered, upgradesForHeight[0])
 	}
 
 	// If multiple passed upgrade proposals are in the "passed" state,
-	// the cosmos upgrade handler only treats the one with the highest proposal ID
-	// as "passed" and all other passed proposals as "cancelled".
+	// the cosmos upgrade handler only keeps the plan of the last executed one
+	// and treats all other passed proposals as "cancelled".
 	// This is not to be confused with the code above, which handles the
 	// case where multiple upgrade proposals exist for the same upgrade height
 	// https://github.com/cosmos/cosmos-sdk/blob/f007a4ea0711da2bac20afc6283885c1b2496ae5/x/upgrade/keeper/keeper.go#L189-L193
-	latestPassedProposal := uint64(0)
-	isAnyProposalPassed := false
-	for _, upgrade := range filtered {
-		if upgrade.Status == PASSED {
-			latestPassedProposal = max(latestPassedProposal, upgrade.ProposalID)
-			isAnyProposalPassed = true
+	if latestPassed, ok := lastExecuted(filtered); ok {
+		for i := range filtered {
+			if filtered[i].Status == PASSED && filtered[i].ProposalID != latestPassed.ProposalID {
+				filtered[i].Status = CANCELLED
+			}
+		}
+
+		// The x/upgrade module keeps a single plan, and a passed cancel proposal clears whatever plan is scheduled at the time
+		// (see ClearUpgradePlan in the x/upgrade keeper). Therefore the only upgrade a cancel proposal can affect is the latest
+		// passed upgrade proposal executed before it. A proposal carrying both messages is assumed to reschedule the upgrade.
+		cancelled, err := p.isCancelled(ctx, latestPassed, cancels)
+		if err != nil {
+			return []chainUpgrade{}, err
+		}
+		if cancelled {
+			for i := range filtered {
+				if filtered[i].Status == PASSED && filtered[i].ProposalID == latestPassed.ProposalID {
+					filtered[i].Status = CANCELLED
+				}
+			}
 		}
 	}
 
-	if isAnyProposalPassed {
-		for i := range filtered {
-			if filtered[i].Status == PASSED && filtered[i].ProposalID < latestPassedProposal {
-				filtered[i].Status = CANCELLED
+	if p.currentPlan {
+		planUpgrades, err := p.getCurrentPlanUpgrades(ctx)
+		if err != nil {
+			return []chainUpgrade{}, err
+		}
+
+		// the plan scheduled by a gov proposal is already known, the proposal carries more information
+		proposalHeights := make(map[int64]struct{}, len(filtered))
+		for _, upgrade := range filtered {
+			proposalHeights[upgrade.Height] = struct{}{}
+		}
+		for _, upgrade := range planUpgrades {
+			if _, ok := proposalHeights[upgrade.Height]; !ok {
+				filtered = append(filtered, upgrade)
 			}
 		}
 	}
 
 	// sort upgrades in descending order by proposal id because iterating over map doesn't guarantee order
-	sort.Slice(filtered, func(i, j int) bool {
+	sort.SliceStable(filtered, func(i, j int) bool {
+		if filtered[i].ProposalID == filtered[j].ProposalID {
+			return filtered[i].Height > filtered[j].Height
+		}
 		return filtered[i].ProposalID > filtered[j].ProposalID
 	})
 
-	return toProto(filtered, p.priority), nil
+	return filtered, nil
 }
 
 func (p *Provider) GetUpgradesByType(ctx context.Context, upgradeType urproto.UpgradeType) ([]*urproto.Upgrade, error) {
@@ -154,12 +239,63 @@ func (p *Provider) AddUpgrade(_ context.Context, _ *urproto.Upgrade, _ bool) err
 	return errors.New("add upgrade is not supported for chain provider")
 }
 
-func (p *Provider) RegisterVersion(_ uint64, _ string) error {
+func (p *Provider) RegisterVersion(_ context.Context, _ *vrproto.Version, _ bool) error {
 	return errors.New("register version is not supported for chain provider")
 }
 
-func (p *Provider) GetVersion(_ uint64) (string, error) {
-	return "", errors.New("get version is not supported for chain provider")
+// GetVersions returns the version tags resolved from the plans of the upgrades that are not cancelled. The upgrades
+// of the last GetUpgrades call are used, the proposals are fetched only if there are none yet
+func (p *Provider) GetVersions(ctx context.Context) ([]*vrproto.Version, error) {
+	p.lastUpgradesLock.Lock()
+	upgrades := p.lastUpgrades
+	p.lastUpgradesLock.Unlock()
+
+	if upgrades == nil {
+		var err error
+		if upgrades, err = p.getUpgrades(ctx); err != nil {
+			return []*vrproto.Version{}, err
+		}
+	}
+
+	versions := make([]*vrproto.Version, 0, len(upgrades))
+	for _, upgrade := range upgrades {
+		if upgrade.Status == CANCELLED {
+			continue
+		}
+
+		tag, provenance := p.planInfoResolver.Resolve(upgrade.Name, upgrade.Info)
+		if tag == "" {
+			continue
+		}
+
+		version := &vrproto.Version{
+			Height:     upgrade.Height,
+			Network:    p.chain,
+			Tag:        tag,
+			Provenance: provenance,
+		}
+		provider.PostProcessVersion(version, urproto.ProviderType_CHAIN, p.priority)
+		versions = append(versions, version)
+	}
+
+	return versions, nil
+}
+
+func (p *Provider) GetVersionsByHeight(ctx context.Context, height uint64) ([]*vrproto.Version, error) {
+	versions, err := p.GetVersions(ctx)
+	if err != nil {
+		return []*vrproto.Version{}, err
+	}
+
+	filtered := make([]*vrproto.Version, 0, len(versions))
+	for _, version := range versions {
+		// #nosec G115
+		if version.Height == int64(height) {
+			filtered = append(filtered, version)
+		}
+	}
+
+	return filtered, nil
 }
 
 func (p *Provider) CancelUpgrade(_ context.Context, _ int64, _ string) error {
@@ -201,6 +337,7 @@ func (p *Provider) getUpgradeProposalsV1beta1(ctx context.Context) ([]chainUpgra
 				proposal.ProposalId,
 				p.chain,
 				proposal.SubmitTime,
+				proposal.VotingEndTime,
 			)
 			if err != nil {
 				return nil, err
@@ -208,7 +345,6 @@ func (p *Provider) getUpgradeProposalsV1beta1(ctx context.Context) ([]chainUpgra
 			if upgrade != nil {
 				upgrades = append(upgrades, *upgrade)
 			}
-			// NOTE: Blazar doesn't support MsgCancelUpgrade because we haven't seen it ever to be used
 		}
 	}
 	return upgrades, nil
@@ -224,6 +360,12 @@ func (p *Provider) getUpgradeProposalsV1(ctx context.Context) ([]chainUpgrade, e
 	for _, proposal := range proposals {
 		status := fromV1(proposal.Status)
 		if status != REJECTED && status != FAILED {
+			// the voting period didn't start yet in the deposit period
+			var votingEndTime time.Time
+			if proposal.VotingEndTime != nil {
+				votingEndTime = *proposal.VotingEndTime
+			}
+
 			for _, msg := range proposal.Messages {
 				var (
 					typeURL = msg.GetTypeUrl()
@@ -246,6 +388,7 @@ func (p *Provider) getUpgradeProposalsV1(ctx context.Context) ([]chainUpgrade, e
 					proposal.GetId(),
 					p.chain,
 					*proposal.SubmitTime,
+					votingEndTime,
 				)
 				if err != nil {
 					return nil, err
@@ -253,20 +396,110 @@ func (p *Provider) getUpgradeProposalsV1(ctx context.Context) ([]chainUpgrade, e
 				if upgrade != nil {
 					upgrades = append(upgrades, *upgrade)
 				}
-				// NOTE: Blazar doesn't support MsgCancelUpgrade because we haven't seen it ever to be used
 			}
 		}
 	}
 	return upgrades, nil
 }
 
-func parseProposal(typeURL string, content []byte, status ProposalStatus, proposalID uint64, chain string, submitTime time.Time) (*chainUpgrade, error) {
+// getCurrentPlanUpgrades returns the upgrades seen in the x/upgrade current plan. The module keeps a single plan
+// and forgets it once applied or cancelled, so the upgrades are tracked across polls:
+// - the current plan is PASSED (ACTIVE)
+// - a plan that is no longer current was either applied (stays PASSED) or replaced/cancelled (CANCELLED)
+//
+// NOTE: the history is kept in memory only, after a restart previously seen plans are not returned anymore
+func (p *Provider) getCurrentPlanUpgrades(ctx context.Context) ([]chainUpgrade, error) {
+	plan, err := p.cosmosClient.GetCurrentPlan(ctx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get the current upgrade plan")
+	}
+
+	p.plansLock.Lock()
+	defer p.plansLock.Unlock()
+
+	if plan != nil {
+		tracked, ok := p.plans[plan.Height]
+		if !ok || tracked.Name != plan.Name || tracked.Status != PASSED {
+			p.plans[plan.Height] = &planUpgrade{
+				chainUpgrade: chainUpgrade{
+					Height:    plan.Height,
+					Name:      plan.Name,
+					Info:      plan.Info,
+					Status:    PASSED,
+					Network:   p.chain,
+					CreatedAt: uint64(time.Now().Unix()),
+				},
+			}
+		}
+	}
+
+	upgrades := make([]chainUpgrade, 0, len(p.plans))
+	for height, tracked := range p.plans {
+		isCurrent := plan != nil && plan.Height == height
+		if !isCurrent && tracked.Status == PASSED && !tracked.applied {
+			appliedHeight, err := p.cosmosClient.GetAppliedPlanHeight(ctx, tracked.Name)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to check if the upgrade plan %s was applied", tracked.Name)
+			}
+
+			if appliedHeight == tracked.Height {
+				tracked.applied = true
+			} else {
+				tracked.Status = CANCELLED
+			}
+		}
+		upgrades = append(upgrades, tracked.chainUpgrade)
+	}
+	return upgrades, nil
+}
+
+// isCancelled reports whether a passed cancel proposal was executed after the upgrade was scheduled, but before its
+// height. A cancel executed once the upgrade was applied finds no plan to clear
+func (p *Provider) isCancelled(ctx context.Context, upgrade chainUpgrade, cancels []chainUpgrade) (bool, error) {
+	if !slices.ContainsFunc(cancels, func(cancel chainUpgrade) bool {
+		return cancel.Status == PASSED && executedAfter(cancel, upgrade)
+	}) {
+		return false, nil
+	}
+
+	appliedHeight, err := p.cosmosClient.GetAppliedPlanHeight(ctx, upgrade.Name)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to check if the upgrade plan %s was applied", upgrade.Name)
+	}
+	return appliedHeight != upgrade.Height, nil
+}
+
+// lastExecuted returns the passed proposal executed last
+func lastExecuted(upgrades []chainUpgrade) (chainUpgrade, bool) {
+	var (
+		last  chainUpgrade
+		found bool
+	)
+	for _, upgrade := range upgrades {
+		if upgrade.Status == PASSED && (!found || executedAfter(upgrade, last)) {
+			last, found = upgrade, true
+		}
+	}
+	return last, found
+}
+
+// executedAfter reports whether the passed proposal a was executed after b. The proposals are executed at the end of
+// their voting period, in the order of their ids if they end at the same time
+func executedAfter(a, b chainUpgrade) bool {
+	if !a.VotingEndTime.Equal(b.VotingEndTime) {
+		return a.VotingEndTime.After(b.VotingEndTime)
+	}
+	return a.ProposalID > b.ProposalID
+}
+
+func parseProposal(typeURL string, content []byte, status ProposalStatus, proposalID uint64, chain string, submitTime, votingEndTime time.Time) (*chainUpgrade, error) {
 	upgrade, err := trySoftwareUpgradeProposal(typeURL, content, status, chain, submitTime)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to process proposal id %d", proposalID)
 	}
 	if upgrade != nil {
 		upgrade.ProposalID = proposalID
+		upgrade.VotingEndTime = votingEndTime
 		return upgrade, nil
 	}
 
@@ -276,6 +509,17 @@ func parseProposal(typeURL string, content []byte, status ProposalStatus, propos
 	}
 	if upgrade != nil {
 		upgrade.ProposalID = proposalID
+		upgrade.VotingEndTime = votingEndTime
+		return upgrade, nil
+	}
+
+	upgrade, err = tryCancelUpgrade(typeURL, content, status, chain, submitTime)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to process proposal id %d", proposalID)
+	}
+	if upgrade != nil {
+		upgrade.ProposalID = proposalID
+		upgrade.VotingEndTime = votingEndTime
 		return upgrade, nil
 	}
 
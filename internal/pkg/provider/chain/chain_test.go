@@ -19,9 +19,14 @@ import (
 type mockCosmosClient struct {
 	v1Proposals      v1.Proposals
 	v1beta1Proposals v1beta1.Proposals
+	currentPlan      *upgradetypes.Plan
+	appliedPlans     map[string]int64
+
+	proposalsV1Calls int
 }
 
 func (m *mockCosmosClient) GetProposalsV1(_ context.Context) (v1.Proposals, error) {
+	m.proposalsV1Calls++
 	return m.v1Proposals, nil
 }
 
@@ -29,11 +34,22 @@ func (m *mockCosmosClient) GetProposalsV1beta1(_ context.Context) (v1beta1.Propo
 	return m.v1beta1Proposals, nil
 }
 
+func (m *mockCosmosClient) GetCurrentPlan(_ context.Context) (*upgradetypes.Plan, error) {
+	return m.currentPlan, nil
+}
+
+func (m *mockCosmosClient) GetAppliedPlanHeight(_ context.Context, name string) (int64, error) {
+	return m.appliedPlans[name], nil
+}
+
 func TestGetUpgrades(t *testing.T) {
+	votingEnd := time.Now()
+
 	tests := []struct {
-		name      string
-		proposals v1.Proposals
-		expected  []*urproto.Upgrade
+		name         string
+		proposals    v1.Proposals
+		appliedPlans map[string]int64
+		expected     []*urproto.Upgrade
 	}{
 		{
 			name:      "EmptyProposals",
@@ -155,14 +171,196 @@ func TestGetUpgrades(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "CancelledUpgrade",
+			proposals: v1.Proposals{
+				newProposal(t, 1, 100, v1.StatusPassed),
+				newCancelProposal(t, 2, v1.StatusPassed),
+			},
+			expected: []*urproto.Upgrade{
+				{
+					Height:     100,
+					Type:       urproto.UpgradeType_GOVERNANCE,
+					Status:     urproto.UpgradeStatus_CANCELLED,
+					Source:     urproto.ProviderType_CHAIN,
+					ProposalId: int64ptr(1),
+				},
+			},
+		},
+		{
+			name: "CancelledUpgradeLegacyProposal",
+			proposals: v1.Proposals{
+				newProposal(t, 1, 100, v1.StatusPassed),
+				newLegacyCancelProposal(t, 2, v1.StatusPassed),
+			},
+			expected: []*urproto.Upgrade{
+				{
+					Height:     100,
+					Type:       urproto.UpgradeType_GOVERNANCE,
+					Status:     urproto.UpgradeStatus_CANCELLED,
+					Source:     urproto.ProviderType_CHAIN,
+					ProposalId: int64ptr(1),
+				},
+			},
+		},
+		{
+			name: "CancelInVotingPeriod",
+			proposals: v1.Proposals{
+				newProposal(t, 1, 100, v1.StatusPassed),
+				newCancelProposal(t, 2, v1.StatusVotingPeriod),
+			},
+			expected: []*urproto.Upgrade{
+				{
+					Height:     100,
+					Type:       urproto.UpgradeType_GOVERNANCE,
+					Status:     urproto.UpgradeStatus_ACTIVE,
+					Source:     urproto.ProviderType_CHAIN,
+					ProposalId: int64ptr(1),
+				},
+			},
+		},
+		{
+			name: "UpgradeAfterCancel",
+			proposals: v1.Proposals{
+				newProposal(t, 1, 100, v1.StatusPassed),
+				newCancelProposal(t, 2, v1.StatusPassed),
+				newProposal(t, 3, 200, v1.StatusPassed),
+			},
+			expected: []*urproto.Upgrade{
+				{
+					Height:     200,
+					Type:       urproto.UpgradeType_GOVERNANCE,
+					Status:     urproto.UpgradeStatus_ACTIVE,
+					Source:     urproto.ProviderType_CHAIN,
+					ProposalId: int64ptr(3),
+				},
+				{
+					Height:     100,
+					Type:       urproto.UpgradeType_GOVERNANCE,
+					Status:     urproto.UpgradeStatus_CANCELLED,
+					Source:     urproto.ProviderType_CHAIN,
+					ProposalId: int64ptr(1),
+				},
+			},
+		},
+		{
+			name: "CancelAfterMultiplePassed",
+			proposals: v1.Proposals{
+				newProposal(t, 1, 100, v1.StatusPassed),
+				newProposal(t, 2, 200, v1.StatusPassed),
+				newCancelProposal(t, 3, v1.StatusPassed),
+				newProposal(t, 4, 300, v1.StatusVotingPeriod),
+			},
+			expected: []*urproto.Upgrade{
+				{
+					Height:     300,
+					Type:       urproto.UpgradeType_GOVERNANCE,
+					Status:     urproto.UpgradeStatus_SCHEDULED,
+					Source:     urproto.ProviderType_CHAIN,
+					ProposalId: int64ptr(4),
+				},
+				{
+					Height:     200,
+					Type:       urproto.UpgradeType_GOVERNANCE,
+					Status:     urproto.UpgradeStatus_CANCELLED,
+					Source:     urproto.ProviderType_CHAIN,
+					ProposalId: int64ptr(2),
+				},
+				{
+					Height:     100,
+					Type:       urproto.UpgradeType_GOVERNANCE,
+					Status:     urproto.UpgradeStatus_CANCELLED,
+					Source:     urproto.ProviderType_CHAIN,
+					ProposalId: int64ptr(1),
+				},
+			},
+		},
+		{
+			// the cancel proposal was submitted later, but its voting period ended before the upgrade was scheduled
+			name: "CancelExecutedBeforeUpgrade",
+			proposals: v1.Proposals{
+				withVotingEndTime(newProposal(t, 1, 100, v1.StatusPassed), votingEnd.Add(2*time.Hour)),
+				withVotingEndTime(newCancelProposal(t, 2, v1.StatusPassed), votingEnd.Add(time.Hour)),
+			},
+			expected: []*urproto.Upgrade{
+				{
+					Height:     100,
+					Type:       urproto.UpgradeType_GOVERNANCE,
+					Status:     urproto.UpgradeStatus_ACTIVE,
+					Source:     urproto.ProviderType_CHAIN,
+					ProposalId: int64ptr(1),
+				},
+			},
+		},
+		{
+			// the cancel proposal was executed once the upgrade was already applied, there was no plan to clear
+			name: "CancelExecutedAfterUpgradeApplied",
+			proposals: v1.Proposals{
+				withVotingEndTime(newProposal(t, 1, 100, v1.StatusPassed), votingEnd),
+				withVotingEndTime(newCancelProposal(t, 2, v1.StatusPassed), votingEnd.Add(time.Hour)),
+			},
+			appliedPlans: map[string]int64{"test upgrade: 100": 100},
+			expected: []*urproto.Upgrade{
+				{
+					Height:     100,
+					Type:       urproto.UpgradeType_GOVERNANCE,
+					Status:     urproto.UpgradeStatus_ACTIVE,
+					Source:     urproto.ProviderType_CHAIN,
+					ProposalId: int64ptr(1),
+				},
+			},
+		},
+		{
+			// the plan of the proposal executed last wins, not the one with the highest id
+			name: "PassedOutOfOrder",
+			proposals: v1.Proposals{
+				withVotingEndTime(newProposal(t, 1, 100, v1.StatusPassed), votingEnd.Add(2*time.Hour)),
+				withVotingEndTime(newProposal(t, 2, 200, v1.StatusPassed), votingEnd.Add(time.Hour)),
+				withVotingEndTime(newCancelProposal(t, 3, v1.StatusPassed), votingEnd.Add(90*time.Minute)),
+			},
+			expected: []*urproto.Upgrade{
+				{
+					Height:     200,
+					Type:       urproto.UpgradeType_GOVERNANCE,
+					Status:     urproto.UpgradeStatus_CANCELLED,
+					Source:     urproto.ProviderType_CHAIN,
+					ProposalId: int64ptr(2),
+				},
+				{
+					Height:     100,
+					Type:       urproto.UpgradeType_GOVERNANCE,
+					Status:     urproto.UpgradeStatus_ACTIVE,
+					Source:     urproto.ProviderType_CHAIN,
+					ProposalId: int64ptr(1),
+				},
+			},
+		},
+		{
+			// the proposals ending in the same block are executed in the order of their ids
+			name: "SameVotingEndTime",
+			proposals: v1.Proposals{
+				withVotingEndTime(newProposal(t, 1, 100, v1.StatusPassed), votingEnd),
+				withVotingEndTime(newCancelProposal(t, 2, v1.StatusPassed), votingEnd),
+			},
+			expected: []*urproto.Upgrade{
+				{
+					Height:     100,
+					Type:       urproto.UpgradeType_GOVERNANCE,
+					Status:     urproto.UpgradeStatus_CANCELLED,
+					Source:     urproto.ProviderType_CHAIN,
+					ProposalId: int64ptr(1),
+				},
+			},
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			cosmosClient := &mockCosmosClient{
-				v1Proposals: tt.proposals,
+				v1Proposals:  tt.proposals,
+				appliedPlans: tt.appliedPlans,
 			}
-			provider := NewProvider(cosmosClient, "test-chain", 1)
+			provider := NewProvider(cosmosClient, "test-chain", 1, false, nil)
 
 			upgrades, err := provider.GetUpgrades(context.Background())
 			require.NoError(t, err)
@@ -183,6 +381,135 @@ func TestGetUpgrades(t *testing.T) {
 	}
 }
 
+func TestGetUpgradesCurrentPlan(t *testing.T) {
+	cosmosClient := &mockCosmosClient{
+		v1Proposals:  v1.Proposals{newProposal(t, 1, 100, v1.StatusPassed)},
+		currentPlan:  &upgradetypes.Plan{Name: "v2", Height: 200},
+		appliedPlans: map[string]int64{},
+	}
+	provider := NewProvider(cosmosClient, "test-chain", 1, true, nil)
+
+	type upgradeState struct {
+		Height     int64
+		Name       string
+		Status     urproto.UpgradeStatus
+		ProposalID *int64
+	}
+	poll := func() []upgradeState {
+		upgrades, err := provider.GetUpgrades(context.Background())
+		require.NoError(t, err)
+
+		states := make([]upgradeState, 0, len(upgrades))
+		for _, upgrade := range upgrades {
+			assert.Equal(t, urproto.UpgradeType_GOVERNANCE, upgrade.Type)
+			states = append(states, upgradeState{upgrade.Height, upgrade.Name, upgrade.Status, upgrade.ProposalId})
+		}
+		return states
+	}
+
+	// the plan is registered next to the proposals
+	assert.Equal(t, []upgradeState{
+		{100, "test upgrade: 100", urproto.UpgradeStatus_ACTIVE, int64ptr(1)},
+		{200, "v2", urproto.UpgradeStatus_ACTIVE, nil},
+	}, poll())
+
+	// the plan is replaced by another one at a different height
+	cosmosClient.currentPlan = &upgradetypes.Plan{Name: "v2", Height: 250}
+	assert.Equal(t, []upgradeState{
+		{100, "test upgrade: 100", urproto.UpgradeStatus_ACTIVE, int64ptr(1)},
+		{250, "v2", urproto.UpgradeStatus_ACTIVE, nil},
+		{200, "v2", urproto.UpgradeStatus_CANCELLED, nil},
+	}, poll())
+
+	// the plan was applied
+	cosmosClient.currentPlan = nil
+	cosmosClient.appliedPlans["v2"] = 250
+	assert.Equal(t, []upgradeState{
+		{100, "test upgrade: 100", urproto.UpgradeStatus_ACTIVE, int64ptr(1)},
+		{250, "v2", urproto.UpgradeStatus_ACTIVE, nil},
+		{200, "v2", urproto.UpgradeStatus_CANCELLED, nil},
+	}, poll())
+
+	// a new plan is cancelled before its height
+	cosmosClient.currentPlan = &upgradetypes.Plan{Name: "v3", Height: 300}
+	assert.Contains(t, poll(), upgradeState{300, "v3", urproto.UpgradeStatus_ACTIVE, nil})
+
+	cosmosClient.currentPlan = nil
+	assert.Contains(t, poll(), upgradeState{300, "v3", urproto.UpgradeStatus_CANCELLED, nil})
+
+	// the plan scheduled by a proposal is reported once, with the proposal id
+	cosmosClient.currentPlan = &upgradetypes.Plan{Name: "test upgrade: 100", Height: 100}
+	states := poll()
+	assert.Len(t, states, 4)
+	assert.Contains(t, states, upgradeState{100, "test upgrade: 100", urproto.UpgradeStatus_ACTIVE, int64ptr(1)})
+}
+
+func TestGetVersions(t *testing.T) {
+	cancelled := newProposal(t, 1, 100, v1.StatusPassed)
+	withInfo := newProposal(t, 2, 200, v1.StatusPassed)
+	withoutInfo := newProposal(t, 3, 300, v1.StatusVotingPeriod)
+
+	setPlanInfo(t, cancelled, `{"binaries": {"linux/amd64": "https://github.com/cosmos/gaia/releases/download/v15.0.0/gaiad"}}`)
+	setPlanInfo(t, withInfo, `{"binaries": {"linux/amd64": "https://github.com/cosmos/gaia/releases/download/v16.0.0/gaiad"}}`)
+
+	cosmosClient := &mockCosmosClient{
+		v1Proposals: v1.Proposals{cancelled, withInfo, withoutInfo},
+	}
+	provider := NewProvider(cosmosClient, "test-chain", 1, false, nil)
+
+	versions, err := provider.GetVersions(context.Background())
+	require.NoError(t, err)
+	require.Len(t, versions, 1)
+
+	assert.Equal(t, int64(200), versions[0].Height)
+	assert.Equal(t, "v16.0.0", versions[0].Tag)
+	assert.Equal(t, "test-chain", versions[0].Network)
+	assert.Equal(t, urproto.ProviderType_CHAIN, versions[0].Source)
+	assert.Equal(t, int32(1), versions[0].Priority)
+	assert.Equal(t, "plan-info binaries[linux/amd64]", versions[0].Provenance)
+}
+
+func TestGetVersionsReusesUpgrades(t *testing.T) {
+	upgrade := newProposal(t, 1, 100, v1.StatusPassed)
+	setPlanInfo(t, upgrade, `{"binaries": {"linux/amd64": "https://github.com/cosmos/gaia/releases/download/v15.0.0/gaiad"}}`)
+
+	cosmosClient := &mockCosmosClient{
+		v1Proposals: v1.Proposals{upgrade},
+	}
+	provider := NewProvider(cosmosClient, "test-chain", 1, false, nil)
+
+	_, err := provider.GetUpgrades(context.Background())
+	require.NoError(t, err)
+
+	// the cancel proposal is not seen until the next GetUpgrades call
+	cosmosClient.v1Proposals = v1.Proposals{upgrade, newCancelProposal(t, 2, v1.StatusPassed)}
+
+	versions, err := provider.GetVersions(context.Background())
+	require.NoError(t, err)
+	require.Len(t, versions, 1)
+	assert.Equal(t, "v15.0.0", versions[0].Tag)
+	assert.Equal(t, 1, cosmosClient.proposalsV1Calls)
+
+	_, err = provider.GetUpgrades(context.Background())
+	require.NoError(t, err)
+
+	versions, err = provider.GetVersions(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, versions)
+	assert.Equal(t, 2, cosmosClient.proposalsV1Calls)
+}
+
+func setPlanInfo(t *testing.T, proposal *v1.Proposal, info string) {
+	msg := &upgradetypes.MsgSoftwareUpgrade{}
+	require.NoError(t, msg.Unmarshal(proposal.Messages[0].Value))
+
+	msg.Plan.Info = info
+
+	value, err := msg.Marshal()
+	require.NoError(t, err)
+	proposal.Messages[0].Value = value
+}
+
 func newProposal(t *testing.T, id uint64, height int64, status v1.ProposalStatus) *v1.Proposal {
 	sup := &upgradetypes.MsgSoftwareUpgrade{
 		Authority: "x/gov",
@@ -202,6 +529,36 @@ func newProposal(t *testing.T, id uint64, height int64, status v1.ProposalStatus
 	return &proposal
 }
 
+func withVotingEndTime(proposal *v1.Proposal, votingEndTime time.Time) *v1.Proposal {
+	proposal.VotingEndTime = &votingEndTime
+	return proposal
+}
+
+func newCancelProposal(t *testing.T, id uint64, status v1.ProposalStatus) *v1.Proposal {
+	cancel := &upgradetypes.MsgCancelUpgrade{
+		Authority: "x/gov",
+	}
+
+	proposal, err := v1.NewProposal([]sdk.Msg{cancel}, id, time.Now(), time.Now(), "", "title", "summary", sdk.AccAddress{})
+	require.NoError(t, err)
+
+	proposal.Status = status
+
+	return &proposal
+}
+
+func newLegacyCancelProposal(t *testing.T, id uint64, status v1.ProposalStatus) *v1.Proposal {
+	content, err := v1.NewLegacyContent(upgradetypes.NewCancelSoftwareUpgradeProposal("title", "description"), "x/gov")
+	require.NoError(t, err)
+
+	proposal, err := v1.NewProposal([]sdk.Msg{content}, id, time.Now(), time.Now(), "", "title", "summary", sdk.AccAddress{})
+	require.NoError(t, err)
+
+	proposal.Status = status
+
+	return &proposal
+}
+
 func int64ptr(i int64) *int64 {
 	return &i
 }
@@ -0,0 +1,150 @@
+package chain
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"blazar/internal/pkg/config"
+	"blazar/internal/pkg/errors"
+	"blazar/internal/pkg/provider"
+)
+
+// Cosmovisor downloads the binaries for the host platform, most validators run on linux/amd64
+const defaultPlatform = "linux/amd64"
+
+var (
+	// release tag in an URL path segment, e.g. https://github.com/cosmos/gaia/releases/download/v15.2.0/gaiad-v15.2.0-linux-amd64
+	versionSegmentRegex = regexp.MustCompile(`^v?\d+\.\d+\.\d+(-[0-9A-Za-z.]+)?$`)
+
+	urlRegex = regexp.MustCompile(`https?://[^\s"']+`)
+)
+
+// PlanInfoResolver resolves the version tag of an upgrade from its on-chain plan. The following sources are tried in order:
+// 1. the rules configured by the operator, matched against the plan name or info
+// 2. the Cosmovisor `{"binaries": {"<platform>": "<url>"}}` JSON in the plan info
+// 3. a version segment of an URL found in the plan info
+type PlanInfoResolver struct {
+	platform string
+	rules    []planInfoRule
+}
+
+type planInfoRule struct {
+	field    config.PlanInfoField
+	regex    *regexp.Regexp
+	template string
+}
+
+func NewPlanInfoResolver(cfg *config.PlanInfo) (*PlanInfoResolver, error) {
+	resolver := &PlanInfoResolver{platform: defaultPlatform}
+	if cfg == nil {
+		return resolver, nil
+	}
+
+	if cfg.Platform != "" {
+		resolver.platform = cfg.Platform
+	}
+
+	for i, rule := range cfg.Rules {
+		regex, err := regexp.Compile(rule.Regex)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid regex in plan-info rule #%d", i+1)
+		}
+
+		if err := provider.ValidateTemplate(rule.Template, regex, string(config.PlanInfoName), string(config.PlanInfoInfo)); err != nil {
+			return nil, errors.Wrapf(err, "invalid template in plan-info rule #%d", i+1)
+		}
+
+		resolver.rules = append(resolver.rules, planInfoRule{
+			field:    rule.Field,
+			regex:    regex,
+			template: rule.Template,
+		})
+	}
+
+	return resolver, nil
+}
+
+// Resolve returns the version tag for the plan and a description of how it was resolved, empty strings if the plan
+// doesn't tell the version
+func (r *PlanInfoResolver) Resolve(name, info string) (string, string) {
+	for i, rule := range r.rules {
+		value := name
+		if rule.field == config.PlanInfoInfo {
+			value = info
+		}
+
+		match := rule.regex.FindStringSubmatch(value)
+		if match == nil {
+			continue
+		}
+
+		values := map[string]string{
+			string(config.PlanInfoName): name,
+			string(config.PlanInfoInfo): info,
+		}
+		tag := provider.ExpandTemplate(rule.template, rule.regex, match, values, nil)
+		if tag != "" {
+			return tag, fmt.Sprintf("plan-info rule #%d (%s)", i+1, rule.field)
+		}
+	}
+
+	if tag, platform := r.fromBinaries(info); tag != "" {
+		return tag, fmt.Sprintf("plan-info binaries[%s]", platform)
+	}
+
+	for _, rawURL := range urlRegex.FindAllString(info, -1) {
+		if tag := versionFromURL(rawURL); tag != "" {
+			return tag, "plan-info url"
+		}
+	}
+
+	return "", ""
+}
+
+// fromBinaries extracts the version from the Cosmovisor binaries JSON, preferring the configured platform
+func (r *PlanInfoResolver) fromBinaries(info string) (string, string) {
+	var planInfo struct {
+		Binaries map[string]string `json:"binaries"`
+	}
+	if err := json.Unmarshal([]byte(info), &planInfo); err != nil || len(planInfo.Binaries) == 0 {
+		return "", ""
+	}
+
+	// all platforms are built from the same release, so any of them will do if the preferred one is missing
+	platforms := make([]string, 0, len(planInfo.Binaries))
+	for platform := range planInfo.Binaries {
+		if platform != r.platform && platform != "any" {
+			platforms = append(platforms, platform)
+		}
+	}
+	slices.Sort(platforms)
+	platforms = append([]string{r.platform, "any"}, platforms...)
+
+	for _, platform := range platforms {
+		if rawURL, ok := planInfo.Binaries[platform]; ok {
+			if tag := versionFromURL(rawURL); tag != "" {
+				return tag, platform
+			}
+		}
+	}
+	return "", ""
+}
+
+// versionFromURL returns the first path segment of the URL that looks like a release tag
+func versionFromURL(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+
+	for _, segment := range strings.Split(parsed.Path, "/") {
+		if versionSegmentRegex.MatchString(segment) {
+			return segment
+		}
+	}
+	return ""
+}
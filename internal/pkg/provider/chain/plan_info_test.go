@@ -0,0 +1,88 @@
+package chain
+
+import (
+	"testing"
+
+	"blazar/internal/pkg/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlanInfoResolver(t *testing.T) {
+	resolver, err := NewPlanInfoResolver(&config.PlanInfo{
+		Rules: []config.PlanInfoRule{
+			{Field: config.PlanInfoName, Regex: `^\d+\.\d+\.\d+$`, Template: "v{{name}}"},
+			{Field: config.PlanInfoInfo, Regex: `image tag: (?P<tag>\S+)`, Template: "{{ tag }}"},
+		},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name               string
+		planName           string
+		planInfo           string
+		expectedTag        string
+		expectedProvenance string
+	}{
+		{
+			name:               "NameRule",
+			planName:           "15.2.0",
+			expectedTag:        "v15.2.0",
+			expectedProvenance: "plan-info rule #1 (name)",
+		},
+		{
+			name:               "InfoRule",
+			planName:           "v16",
+			planInfo:           "Gaia v16 upgrade, image tag: v16.0.0-rc1",
+			expectedTag:        "v16.0.0-rc1",
+			expectedProvenance: "plan-info rule #2 (info)",
+		},
+		{
+			name:     "CosmovisorBinaries",
+			planName: "v15",
+			planInfo: `{"binaries": {
+				"darwin/arm64": "https://github.com/cosmos/gaia/releases/download/v15.2.1/gaiad-v15.2.1-darwin-arm64",
+				"linux/amd64": "https://github.com/cosmos/gaia/releases/download/v15.2.0/gaiad-v15.2.0-linux-amd64?checksum=sha256:1c2a7d0d"
+			}}`,
+			expectedTag:        "v15.2.0",
+			expectedProvenance: "plan-info binaries[linux/amd64]",
+		},
+		{
+			name:               "CosmovisorBinariesOtherPlatform",
+			planName:           "v15",
+			planInfo:           `{"binaries": {"darwin/arm64": "https://github.com/cosmos/gaia/releases/download/v15.2.0/gaiad-v15.2.0-darwin-arm64"}}`,
+			expectedTag:        "v15.2.0",
+			expectedProvenance: "plan-info binaries[darwin/arm64]",
+		},
+		{
+			name:               "URL",
+			planName:           "v15",
+			planInfo:           "https://raw.githubusercontent.com/cosmos/gaia/v15.2.0/upgrades/v15.json",
+			expectedTag:        "v15.2.0",
+			expectedProvenance: "plan-info url",
+		},
+		{
+			name:     "NoVersion",
+			planName: "v15",
+			planInfo: `{"binaries": {"linux/amd64": "https://example.com/gaiad"}}`,
+		},
+		{
+			name:     "Empty",
+			planName: "v15",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tag, provenance := resolver.Resolve(test.planName, test.planInfo)
+			assert.Equal(t, test.expectedTag, tag)
+			assert.Equal(t, test.expectedProvenance, provenance)
+		})
+	}
+
+	_, err = NewPlanInfoResolver(&config.PlanInfo{
+		Rules: []config.PlanInfoRule{{Field: config.PlanInfoName, Regex: `^v(?P<major>\d+)$`, Template: "v{{minor}}.0.0"}},
+	})
+	require.ErrorContains(t, err, "unknown placeholder {{minor}}")
+}
@@ -51,10 +51,17 @@ func (ps ProposalStatus) String() string {
 type chainUpgrade struct {
 	Height     int64
 	Name       string
+	Info       string
 	Status     ProposalStatus
 	Network    string
 	ProposalID uint64
 	CreatedAt  uint64
+
+	// a passed proposal is executed at the end of its voting period
+	VotingEndTime time.Time
+
+	// the proposal cancels the scheduled upgrade (MsgCancelUpgrade or CancelSoftwareUpgradeProposal), height and name are not set
+	Cancel bool
 }
 
 func (cu chainUpgrade) ToProto() urproto.Upgrade {
@@ -79,8 +86,14 @@ func (cu chainUpgrade) ToProto() urproto.Upgrade {
 		upgradeStatus = urproto.UpgradeStatus_CANCELLED
 	}
 
-	// #nosec G115
-	proposalID := int64(cu.ProposalID)
+	// upgrades coming from the current plan have no proposal
+	var proposalID *int64
+	if cu.ProposalID != 0 {
+		// #nosec G115
+		id := int64(cu.ProposalID)
+		proposalID = &id
+	}
+
 	return urproto.Upgrade{
 		Height:     cu.Height,
 		Tag:        "",
@@ -89,7 +102,7 @@ func (cu chainUpgrade) ToProto() urproto.Upgrade {
 		Type:       urproto.UpgradeType_GOVERNANCE,
 		Status:     upgradeStatus,
 		Source:     source,
-		ProposalId: &proposalID,
+		ProposalId: proposalID,
 		CreatedAt:  cu.CreatedAt,
 	}
 }
@@ -142,6 +155,7 @@ func trySoftwareUpgradeProposal(typeURL string, value []byte, status ProposalSta
 		return &chainUpgrade{
 			Height:    upgrade.Plan.Height,
 			Name:      upgrade.Plan.Name,
+			Info:      upgrade.Plan.Info,
 			Status:    status,
 			Network:   chain,
 			CreatedAt: uint64(submitTime.Unix()),
@@ -150,6 +164,31 @@ func trySoftwareUpgradeProposal(typeURL string, value []byte, status ProposalSta
 	return nil, nil
 }
 
+func tryCancelUpgrade(typeURL string, value []byte, status ProposalStatus, chain string, submitTime time.Time) (*chainUpgrade, error) {
+	switch typeURL {
+	case "/cosmos.upgrade.v1beta1.CancelSoftwareUpgradeProposal":
+		// this is deprecated but still widely used on chains
+		cancel := &upgradetypes.CancelSoftwareUpgradeProposal{}
+		if err := cancel.Unmarshal(value); err != nil {
+			return nil, errors.Wrapf(err, "failed to unmarshal CancelSoftwareUpgradeProposal")
+		}
+	case "/cosmos.upgrade.v1beta1.MsgCancelUpgrade":
+		cancel := &upgradetypes.MsgCancelUpgrade{}
+		if err := cancel.Unmarshal(value); err != nil {
+			return nil, errors.Wrapf(err, "failed to unmarshal MsgCancelUpgrade")
+		}
+	default:
+		return nil, nil
+	}
+
+	return &chainUpgrade{
+		Status:    status,
+		Network:   chain,
+		CreatedAt: uint64(submitTime.Unix()),
+		Cancel:    true,
+	}, nil
+}
+
 func tryMsgSoftwareUpgrade(typeURL string, value []byte, status ProposalStatus, chain string, submitTime time.Time) (*chainUpgrade, error) {
 	if typeURL == "/cosmos.upgrade.v1beta1.MsgSoftwareUpgrade" {
 		upgrade := &upgradetypes.MsgSoftwareUpgrade{}
@@ -159,6 +198,7 @@ func tryMsgSoftwareUpgrade(typeURL string, value []byte, status ProposalStatus,
 		return &chainUpgrade{
 			Height:    upgrade.Plan.Height,
 			Name:      upgrade.Plan.Name,
+			Info:      upgrade.Plan.Info,
 			Status:    status,
 			Network:   chain,
 			CreatedAt: uint64(submitTime.Unix()),
@@ -0,0 +1,318 @@
+package chain_registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"blazar/internal/pkg/config"
+	"blazar/internal/pkg/errors"
+	"blazar/internal/pkg/file_watcher"
+	"blazar/internal/pkg/log"
+	urproto "blazar/internal/pkg/proto/upgrades_registry"
+	vrproto "blazar/internal/pkg/proto/version_resolver"
+	"blazar/internal/pkg/provider"
+
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	placeholderRecommendedVersion = "recommended_version"
+	placeholderTag                = "tag"
+	placeholderName               = "name"
+)
+
+// chainJSON is the subset of the chain-registry chain.json schema used by the provider
+type chainJSON struct {
+	ChainName string `json:"chain_name"`
+	ChainID   string `json:"chain_id"`
+	Codebase  struct {
+		Versions []codebaseVersion `json:"versions"`
+	} `json:"codebase"`
+}
+
+type codebaseVersion struct {
+	Name               string `json:"name"`
+	Tag                string `json:"tag"`
+	Height             int64  `json:"height"`
+	RecommendedVersion string `json:"recommended_version"`
+}
+
+type versionRule struct {
+	regex    *regexp.Regexp
+	template string
+}
+
+// Provider is a version resolver reading the codebase versions from the chain.json file of a cosmos/chain-registry
+// checkout. The file is parsed again whenever it changes, e.g. after a `git pull`.
+type Provider struct {
+	chainJSONPath string
+	network       string
+	priority      int32
+	rules         []versionRule
+
+	lock     *sync.RWMutex
+	versions []*vrproto.Version
+}
+
+func NewProvider(ctx context.Context, cfg *config.ChainRegistryProvider, network string) (*Provider, error) {
+	var (
+		chainJSONPath string
+		err           error
+	)
+	if cfg.ChainName != "" {
+		chainJSONPath, err = findChainJSON(cfg.Path, cfg.ChainName)
+	} else {
+		chainJSONPath, err = findChainJSONByChainID(cfg.Path, network)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Provider{
+		chainJSONPath: chainJSONPath,
+		network:       network,
+		priority:      cfg.DefaultPriority,
+		lock:          &sync.RWMutex{},
+	}
+
+	for i, rule := range cfg.Rules {
+		regex, err := regexp.Compile(rule.Regex)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid regex in chain-registry rule #%d", i+1)
+		}
+
+		if err := provider.ValidateTemplate(rule.Template, regex, placeholderRecommendedVersion, placeholderTag, placeholderName); err != nil {
+			return nil, errors.Wrapf(err, "invalid template in chain-registry rule #%d", i+1)
+		}
+
+		p.rules = append(p.rules, versionRule{regex: regex, template: rule.Template})
+	}
+
+	if err := p.load(); err != nil {
+		return nil, err
+	}
+
+	if err := p.watch(ctx, cfg.PollInterval); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// findChainJSON returns the path of the chain.json of the chain, mainnets are looked up before testnets
+func findChainJSON(registryPath, chainName string) (string, error) {
+	for _, path := range []string{
+		filepath.Join(registryPath, chainName, "chain.json"),
+		filepath.Join(registryPath, "testnets", chainName, "chain.json"),
+	} {
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("chain.json of %s not found in %s", chainName, registryPath)
+}
+
+// findChainJSONByChainID returns the path of the chain.json whose chain_id matches, the directories are named after
+// the chain_name so every chain.json is read. Mainnets are looked up before testnets
+func findChainJSONByChainID(registryPath, chainID string) (string, error) {
+	for _, pattern := range []string{
+		filepath.Join(registryPath, "*", "chain.json"),
+		filepath.Join(registryPath, "testnets", "*", "chain.json"),
+	} {
+		paths, err := filepath.Glob(pattern)
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to list chain.json files in %s", registryPath)
+		}
+
+		for _, path := range paths {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				continue
+			}
+
+			var chain chainJSON
+			// files of other chains may be broken, they are not the ones we are looking for
+			if err := json.Unmarshal(data, &chain); err != nil {
+				continue
+			}
+			if chain.ChainID == chainID {
+				return path, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("chain.json with chain_id %s not found in %s, set chain-name to look it up by directory", chainID, registryPath)
+}
+
+func (p *Provider) watch(ctx context.Context, interval time.Duration) error {
+	logger := log.FromContext(ctx)
+
+	var (
+		fw  file_watcher.FileWatcher
+		err error
+	)
+	if interval == 0 {
+		_, fw, err = file_watcher.NewNotifyFileWatcher(logger, p.chainJSONPath)
+	} else {
+		_, fw, err = file_watcher.NewPollingFileWatcher(logger, p.chainJSONPath, interval)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "error creating file watcher for %s", p.chainJSONPath)
+	}
+
+	go func() {
+		for newEvent := range fw.ChangeEvents() {
+			if newEvent.Error != nil {
+				logger.Err(newEvent.Error).Warn("Chain registry file watcher observed an error")
+				continue
+			}
+
+			// the previous versions are kept while the file is missing or broken, e.g. in the middle of a checkout
+			if e := newEvent.Event; e == file_watcher.FileCreated || e == file_watcher.FileModified {
+				if err := p.load(); err != nil {
+					logger.Err(err).Warnf("Failed to reload %s, keeping the previous versions", p.chainJSONPath)
+					continue
+				}
+				logger.Debugf("Reloaded chain registry versions from %s", p.chainJSONPath)
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (p *Provider) load() error {
+	data, err := os.ReadFile(p.chainJSONPath)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read %s", p.chainJSONPath)
+	}
+
+	var chain chainJSON
+	if err := json.Unmarshal(data, &chain); err != nil {
+		return errors.Wrapf(err, "failed to parse %s", p.chainJSONPath)
+	}
+
+	versions := make([]*vrproto.Version, 0, len(chain.Codebase.Versions))
+	seen := make(map[int64]struct{}, len(chain.Codebase.Versions))
+	for _, codebaseVersion := range chain.Codebase.Versions {
+		// the genesis version has no upgrade height
+		if codebaseVersion.Height <= 0 {
+			continue
+		}
+		if _, ok := seen[codebaseVersion.Height]; ok {
+			continue
+		}
+
+		tag, provenance := p.resolve(codebaseVersion)
+		if tag == "" {
+			continue
+		}
+		seen[codebaseVersion.Height] = struct{}{}
+
+		version := &vrproto.Version{
+			Height:     codebaseVersion.Height,
+			Network:    p.network,
+			Tag:        tag,
+			Provenance: fmt.Sprintf("chain-registry %s %s", chain.ChainName, provenance),
+		}
+		provider.PostProcessVersion(version, urproto.ProviderType_CHAIN_REGISTRY, p.priority)
+		versions = append(versions, version)
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.versions = versions
+	return nil
+}
+
+// resolve returns the version tag of the codebase version and a description of how it was resolved
+func (p *Provider) resolve(version codebaseVersion) (string, string) {
+	for i, rule := range p.rules {
+		match := rule.regex.FindStringSubmatch(version.RecommendedVersion)
+		if match == nil {
+			continue
+		}
+
+		values := map[string]string{
+			placeholderRecommendedVersion: version.RecommendedVersion,
+			placeholderTag:                version.Tag,
+			placeholderName:               version.Name,
+		}
+		tag := provider.ExpandTemplate(rule.template, rule.regex, match, values, nil)
+		if tag != "" {
+			return tag, fmt.Sprintf("rule #%d (%s)", i+1, version.Name)
+		}
+	}
+
+	if version.RecommendedVersion != "" {
+		return version.RecommendedVersion, fmt.Sprintf("recommended_version (%s)", version.Name)
+	}
+	if version.Tag != "" {
+		return version.Tag, fmt.Sprintf("tag (%s)", version.Name)
+	}
+	return "", ""
+}
+
+func (p *Provider) GetUpgrades(_ context.Context) ([]*urproto.Upgrade, error) {
+	return []*urproto.Upgrade{}, nil
+}
+
+func (p *Provider) GetUpgradesByType(_ context.Context, _ urproto.UpgradeType) ([]*urproto.Upgrade, error) {
+	return []*urproto.Upgrade{}, nil
+}
+
+func (p *Provider) GetUpgradesByHeight(_ context.Context, _ int64) ([]*urproto.Upgrade, error) {
+	return []*urproto.Upgrade{}, nil
+}
+
+func (p *Provider) AddUpgrade(_ context.Context, _ *urproto.Upgrade, _ bool) error {
+	return errors.New("add upgrade is not supported for chain-registry provider")
+}
+
+func (p *Provider) CancelUpgrade(_ context.Context, _ int64, _ string) error {
+	return errors.New("cancel upgrade is not supported for chain-registry provider")
+}
+
+func (p *Provider) RegisterVersion(_ context.Context, _ *vrproto.Version, _ bool) error {
+	return errors.New("register version is not supported for chain-registry provider")
+}
+
+// GetVersions returns the versions of the last successfully parsed chain.json
+func (p *Provider) GetVersions(_ context.Context) ([]*vrproto.Version, error) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	versions := make([]*vrproto.Version, 0, len(p.versions))
+	for _, version := range p.versions {
+		versions = append(versions, proto.Clone(version).(*vrproto.Version))
+	}
+
+	return versions, nil
+}
+
+func (p *Provider) GetVersionsByHeight(ctx context.Context, height uint64) ([]*vrproto.Version, error) {
+	versions, err := p.GetVersions(ctx)
+	if err != nil {
+		return []*vrproto.Version{}, err
+	}
+
+	filtered := make([]*vrproto.Version, 0, len(versions))
+	for _, version := range versions {
+		// #nosec G115
+		if version.Height == int64(height) {
+			filtered = append(filtered, version)
+		}
+	}
+
+	return filtered, nil
+}
+
+func (p *Provider) Type() urproto.ProviderType {
+	return urproto.ProviderType_CHAIN_REGISTRY
+}
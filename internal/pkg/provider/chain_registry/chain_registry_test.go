@@ -0,0 +1,170 @@
+package chain_registry
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"blazar/internal/pkg/config"
+	urproto "blazar/internal/pkg/proto/upgrades_registry"
+	vrproto "blazar/internal/pkg/proto/version_resolver"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testRegistryPath = "../../../../testdata/provider/chain_registry"
+
+func TestGetVersions(t *testing.T) {
+	p, err := NewProvider(context.Background(), &config.ChainRegistryProvider{
+		DefaultPriority: 3,
+		Path:            testRegistryPath,
+		PollInterval:    time.Hour,
+	}, "cosmoshub-4")
+	require.NoError(t, err)
+
+	versions, err := p.GetVersions(context.Background())
+	require.NoError(t, err)
+	require.Len(t, versions, 3)
+	for _, version := range versions {
+		assert.Equal(t, urproto.ProviderType_CHAIN_REGISTRY, version.Source)
+		assert.Equal(t, int32(3), version.Priority)
+		assert.Equal(t, "cosmoshub-4", version.Network)
+	}
+	assert.Equal(t, [][]any{
+		{int64(19939000), "v15.2.0", "chain-registry cosmoshub recommended_version (v15)"},
+		{int64(20440500), "v16.0.0", "chain-registry cosmoshub recommended_version (v16)"},
+		// the recommended_version is missing
+		{int64(20739800), "v17.2.0", "chain-registry cosmoshub tag (v17)"},
+	}, summarize(versions))
+
+	versions, err = p.GetVersionsByHeight(context.Background(), 20440500)
+	require.NoError(t, err)
+	require.Len(t, versions, 1)
+	assert.Equal(t, "v16.0.0", versions[0].Tag)
+
+	// the testnets are looked up too, the network name can be overridden
+	p, err = NewProvider(context.Background(), &config.ChainRegistryProvider{
+		DefaultPriority: 3,
+		Path:            testRegistryPath,
+		ChainName:       "cosmoshubtestnet",
+		PollInterval:    time.Hour,
+	}, "theta")
+	require.NoError(t, err)
+
+	versions, err = p.GetVersions(context.Background())
+	require.NoError(t, err)
+	require.Len(t, versions, 1)
+	assert.Equal(t, "v17.0.0-rc0", versions[0].Tag)
+	assert.Equal(t, "theta", versions[0].Network)
+
+	// without chain-name the chain.json is looked up by chain_id, the directories are named after the chain_name
+	p, err = NewProvider(context.Background(), &config.ChainRegistryProvider{
+		Path:         testRegistryPath,
+		PollInterval: time.Hour,
+	}, "theta-testnet-001")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(testRegistryPath, "testnets", "cosmoshubtestnet", "chain.json"), p.chainJSONPath)
+
+	_, err = NewProvider(context.Background(), &config.ChainRegistryProvider{Path: testRegistryPath}, "cosmoshub")
+	require.ErrorContains(t, err, "chain.json with chain_id cosmoshub not found")
+
+	_, err = NewProvider(context.Background(), &config.ChainRegistryProvider{Path: testRegistryPath, ChainName: "osmosis"}, "osmosis-1")
+	require.ErrorContains(t, err, "chain.json of osmosis not found")
+}
+
+func TestRules(t *testing.T) {
+	p, err := NewProvider(context.Background(), &config.ChainRegistryProvider{
+		DefaultPriority: 3,
+		Path:            testRegistryPath,
+		PollInterval:    time.Hour,
+		Rules: []config.ChainRegistryRule{
+			{Regex: `^v(?P<version>15\..*)$`, Template: "{{version}}-alpine"},
+			{Regex: `^v16\.`, Template: "{{name}}-{{recommended_version}}"},
+		},
+	}, "cosmoshub-4")
+	require.NoError(t, err)
+
+	versions, err := p.GetVersions(context.Background())
+	require.NoError(t, err)
+	require.Len(t, versions, 3)
+
+	assert.Equal(t, "15.2.0-alpine", versions[0].Tag)
+	assert.Equal(t, "chain-registry cosmoshub rule #1 (v15)", versions[0].Provenance)
+	assert.Equal(t, "v16-v16.0.0", versions[1].Tag)
+	assert.Equal(t, "chain-registry cosmoshub rule #2 (v16)", versions[1].Provenance)
+	// no rule matches, the tag is used as the recommended_version is missing
+	assert.Equal(t, "v17.2.0", versions[2].Tag)
+
+	_, err = NewProvider(context.Background(), &config.ChainRegistryProvider{
+		Path:  testRegistryPath,
+		Rules: []config.ChainRegistryRule{{Regex: `^v(\d+)`, Template: "{{major}}"}},
+	}, "cosmoshub-4")
+	require.ErrorContains(t, err, "invalid template in chain-registry rule #1: unknown placeholder {{major}}")
+}
+
+func TestReloadOnChange(t *testing.T) {
+	registryPath := t.TempDir()
+	chainJSONPath := filepath.Join(registryPath, "test", "chain.json")
+	require.NoError(t, os.MkdirAll(filepath.Dir(chainJSONPath), 0755))
+
+	writeChainJSON := func(contents string) {
+		// replace the file the way git does
+		tmpPath := chainJSONPath + ".tmp"
+		require.NoError(t, os.WriteFile(tmpPath, []byte(contents), 0600))
+		require.NoError(t, os.Rename(tmpPath, chainJSONPath))
+	}
+	writeChainJSON(`{"chain_name": "test", "chain_id": "test", "codebase": {"versions": [{"name": "v2", "height": 100, "recommended_version": "v2.0.0"}]}}`)
+
+	for name, interval := range map[string]time.Duration{"Notify": 0, "Polling": 10 * time.Millisecond} {
+		t.Run(name, func(t *testing.T) {
+			p, err := NewProvider(context.Background(), &config.ChainRegistryProvider{
+				DefaultPriority: 1,
+				Path:            registryPath,
+				PollInterval:    interval,
+			}, "test")
+			require.NoError(t, err)
+
+			tags := func() []string {
+				versions, err := p.GetVersions(context.Background())
+				require.NoError(t, err)
+
+				tags := make([]string, 0, len(versions))
+				for _, version := range versions {
+					tags = append(tags, version.Tag)
+				}
+				return tags
+			}
+			assert.Equal(t, []string{"v2.0.0"}, tags())
+
+			// the polling watcher compares the modification times
+			time.Sleep(20 * time.Millisecond)
+			writeChainJSON(`{"chain_name": "test", "chain_id": "test", "codebase": {"versions": [` +
+				`{"name": "v2", "height": 100, "recommended_version": "v2.0.1"}, {"name": "v3", "height": 200, "recommended_version": "v3.0.0"}]}}`)
+
+			assert.Eventually(t, func() bool {
+				versions, err := p.GetVersions(context.Background())
+				return err == nil && len(versions) == 2 && versions[0].Tag == "v2.0.1"
+			}, 2*time.Second, 10*time.Millisecond)
+
+			// a broken file keeps the previous versions
+			time.Sleep(20 * time.Millisecond)
+			writeChainJSON(`{"chain_name": `)
+			time.Sleep(100 * time.Millisecond)
+			assert.Equal(t, []string{"v2.0.1", "v3.0.0"}, tags())
+
+			// restore the initial state for the next watcher
+			writeChainJSON(`{"chain_name": "test", "chain_id": "test", "codebase": {"versions": [{"name": "v2", "height": 100, "recommended_version": "v2.0.0"}]}}`)
+		})
+	}
+}
+
+func summarize(versions []*vrproto.Version) [][]any {
+	summary := make([][]any, 0, len(versions))
+	for _, version := range versions {
+		summary = append(summary, []any{version.Height, version.Tag, version.Provenance})
+	}
+	return summary
+}
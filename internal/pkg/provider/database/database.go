@@ -7,6 +7,7 @@ import (
 
 	"blazar/internal/pkg/config"
 	"blazar/internal/pkg/errors"
+	"blazar/internal/pkg/log"
 	urproto "blazar/internal/pkg/proto/upgrades_registry"
 	vrproto "blazar/internal/pkg/proto/version_resolver"
 	"blazar/internal/pkg/provider"
@@ -20,32 +21,40 @@ type Provider struct {
 	db       *gorm.DB
 	priority int32
 	network  string
+
+	// the state machine state is stored per blazar instance
+	instanceID string
 }
 
-func NewDatabaseProviderWithDB(db *gorm.DB, network string, priority int32) *Provider {
+func NewDatabaseProviderWithDB(db *gorm.DB, network string, priority int32, instanceID string) *Provider {
 	return &Provider{
-		db:       db,
-		network:  network,
-		priority: priority,
+		db:         db,
+		network:    network,
+		priority:   priority,
+		instanceID: instanceID,
 	}
 }
 
-func NewDatabaseProvider(cfg *config.DatabaseProvider, network string) (*Provider, error) {
-	db, err := InitDB(cfg, &gorm.Config{})
+func NewDatabaseProvider(cfg *config.DatabaseProvider, network, instanceID string) (*Provider, error) {
+	// the database may be down while blazar starts, the upgrades are served from the registry cache until it is back
+	db, err := InitDB(cfg, &gorm.Config{DisableAutomaticPing: true})
 	if err != nil {
 		return nil, err
 	}
 
 	if cfg.AutoMigrate {
-		if err := AutoMigrate(db); err != nil {
+		if err := ping(db); err != nil {
+			log.FromContext(context.Background()).Err(err).Warn("The database is unreachable, skipping the auto-migration until the next start")
+		} else if err := AutoMigrate(db); err != nil {
 			return nil, err
 		}
 	}
 
 	provider := &Provider{
-		db:       db,
-		network:  network,
-		priority: cfg.DefaultPriority,
+		db:         db,
+		network:    network,
+		priority:   cfg.DefaultPriority,
+		instanceID: instanceID,
 	}
 
 	return provider, nil
@@ -91,7 +100,7 @@ func (dp Provider) AddUpgrade(ctx context.Context, upgrade *urproto.Upgrade, ove
 			Columns: []clause.Column{{Name: "height"}, {Name: "network"}, {Name: "priority"}},
 			// this should include the rest of the columns
 			// NOTE: status and step is managed by blazar state machine and should not be updated
-			DoUpdates: clause.AssignmentColumns([]string{"tag", "name", "type" /* "status", */ /* step,  */, "source", "proposal_id"}),
+			DoUpdates: clause.AssignmentColumns([]string{"tag", "name", "type" /* "status", */ /* step,  */, "source", "proposal_id", "services", "expected_version"}),
 		}).Create(upgrade)
 		return result.Error
 	}
@@ -200,6 +209,12 @@ func (dp Provider) Type() urproto.ProviderType {
 	return urproto.ProviderType_DATABASE
 }
 
+// WithInstanceID returns a copy of the provider storing the state machine state under another instance id
+func (dp Provider) WithInstanceID(instanceID string) *Provider {
+	dp.instanceID = instanceID
+	return &dp
+}
+
 func InitDB(cfg *config.DatabaseProvider, gcfg *gorm.Config) (*gorm.DB, error) {
 	mode := string(cfg.SslMode)
 
@@ -212,6 +227,17 @@ func InitDB(cfg *config.DatabaseProvider, gcfg *gorm.Config) (*gorm.DB, error) {
 	return db, nil
 }
 
+func ping(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return errors.Wrapf(err, "failed to get database connection")
+	}
+	if err := sqlDB.Ping(); err != nil {
+		return errors.Wrapf(err, "failed to connect database")
+	}
+	return nil
+}
+
 func AutoMigrate(db *gorm.DB) error {
 	if err := db.AutoMigrate(&urproto.Upgrade{}); err != nil {
 		return errors.Wrapf(err, "database migration failed for upgrades table")
@@ -221,5 +247,9 @@ func AutoMigrate(db *gorm.DB) error {
 		return errors.Wrapf(err, "database migration failed for versions table")
 	}
 
+	if err := db.AutoMigrate(&StateMachineState{}); err != nil {
+		return errors.Wrapf(err, "database migration failed for state machine states table")
+	}
+
 	return nil
 }
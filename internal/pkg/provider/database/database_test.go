@@ -0,0 +1,42 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	urproto "blazar/internal/pkg/proto/upgrades_registry"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestAddUpgradeOverwrite(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, AutoMigrate(db))
+
+	dp := NewDatabaseProviderWithDB(db, "test", 1, "node-1")
+
+	upgrade := func(tag, expectedVersion string) *urproto.Upgrade {
+		return &urproto.Upgrade{
+			Height:          100,
+			Tag:             tag,
+			ExpectedVersion: expectedVersion,
+			Network:         "test",
+			Name:            "test",
+			Type:            urproto.UpgradeType_NON_GOVERNANCE_COORDINATED,
+			Priority:        1,
+		}
+	}
+	require.NoError(t, dp.AddUpgrade(context.Background(), upgrade("v1.0.0", "v1.0.0"), false))
+	require.Error(t, dp.AddUpgrade(context.Background(), upgrade("v1.0.1", "v1.0.1"), false))
+	require.NoError(t, dp.AddUpgrade(context.Background(), upgrade("v1.0.1", "v1.0.1"), true))
+
+	upgrades, err := dp.GetUpgrades(context.Background())
+	require.NoError(t, err)
+	require.Len(t, upgrades, 1)
+	assert.Equal(t, "v1.0.1", upgrades[0].Tag)
+	assert.Equal(t, "v1.0.1", upgrades[0].ExpectedVersion)
+}
@@ -0,0 +1,66 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"blazar/internal/pkg/errors"
+	sm "blazar/internal/pkg/state_machine"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// StateMachineState is the state machine of a single blazar instance. The state is stored as a JSON document, so
+// it can be queried with the postgres JSON operators, e.g. state->'status'->>'1000'
+type StateMachineState struct {
+	Network    string    `gorm:"primaryKey;not null"`
+	InstanceID string    `gorm:"primaryKey;not null"`
+	State      string    `gorm:"type:jsonb;not null"`
+	UpdatedAt  time.Time `gorm:"not null"`
+}
+
+func (StateMachineState) TableName() string {
+	return "state_machine_states"
+}
+
+func (dp Provider) StoreState(ctx context.Context, state *sm.State) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return errors.Wrapf(err, "could not marshal state machine state")
+	}
+
+	result := dp.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "network"}, {Name: "instance_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"state", "updated_at"}),
+	}).Create(&StateMachineState{
+		Network:    dp.network,
+		InstanceID: dp.instanceID,
+		State:      string(data),
+		UpdatedAt:  time.Now(),
+	})
+	if result.Error != nil {
+		return errors.Wrapf(result.Error, "failed to store state machine state in database")
+	}
+
+	return nil
+}
+
+func (dp Provider) RestoreState(ctx context.Context) (*sm.State, error) {
+	var row StateMachineState
+	result := dp.db.WithContext(ctx).Where("network = ? AND instance_id = ?", dp.network, dp.instanceID).Take(&row)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if result.Error != nil {
+		return nil, errors.Wrapf(result.Error, "failed to restore state machine state from database")
+	}
+
+	var state sm.State
+	if err := json.Unmarshal([]byte(row.State), &state); err != nil {
+		return nil, errors.Wrapf(err, "could not unmarshal state machine state of %s instance", dp.instanceID)
+	}
+
+	return &state, nil
+}
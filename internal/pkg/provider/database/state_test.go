@@ -0,0 +1,60 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	checksproto "blazar/internal/pkg/proto/daemon"
+	urproto "blazar/internal/pkg/proto/upgrades_registry"
+	sm "blazar/internal/pkg/state_machine"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestStateStorage(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, AutoMigrate(db))
+
+	dp := NewDatabaseProviderWithDB(db, "test", 1, "node-1")
+
+	// nothing stored yet
+	state, err := dp.RestoreState(context.Background())
+	require.NoError(t, err)
+	assert.Nil(t, state)
+
+	stateMachine := sm.NewStateMachine(dp)
+	stateMachine.MustSetStatusAndStep(100, urproto.UpgradeStatus_EXECUTING, urproto.UpgradeStep_PRE_UPGRADE_CHECK)
+	stateMachine.SetPreCheckStatus(100, checksproto.PreCheck_PULL_DOCKER_IMAGE, checksproto.CheckStatus_FINISHED)
+	stateMachine.MustSetStatus(100, urproto.UpgradeStatus_COMPLETED)
+
+	state, err = dp.RestoreState(context.Background())
+	require.NoError(t, err)
+	require.NotNil(t, state)
+	assert.Equal(t, urproto.UpgradeStatus_COMPLETED, state.UpgradeStatus[100])
+	assert.Equal(t, urproto.UpgradeStep_PRE_UPGRADE_CHECK, state.UpgradeStep[100])
+	assert.Equal(t, checksproto.CheckStatus_FINISHED, state.PreCheckStatus[100][checksproto.PreCheck_PULL_DOCKER_IMAGE])
+
+	// the state survives a restart
+	restored := sm.NewStateMachine(dp)
+	require.NoError(t, restored.Restore(context.Background()))
+	assert.Equal(t, urproto.UpgradeStatus_COMPLETED, restored.GetStatus(100))
+
+	// other instances and networks have their own state
+	for _, other := range []*Provider{
+		NewDatabaseProviderWithDB(db, "test", 1, "node-2"),
+		NewDatabaseProviderWithDB(db, "other", 1, "node-1"),
+		dp.WithInstanceID("node-1.dry-run"),
+	} {
+		state, err = other.RestoreState(context.Background())
+		require.NoError(t, err)
+		assert.Nil(t, state)
+	}
+
+	var total int64
+	require.NoError(t, db.Model(&StateMachineState{}).Count(&total).Error)
+	assert.Equal(t, int64(1), total)
+}
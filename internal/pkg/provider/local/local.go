@@ -1,6 +1,7 @@
 package local
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -8,6 +9,7 @@ import (
 	"slices"
 	"sync"
 
+	"blazar/internal/pkg/atomicfile"
 	"blazar/internal/pkg/errors"
 	urproto "blazar/internal/pkg/proto/upgrades_registry"
 	vrproto "blazar/internal/pkg/proto/version_resolver"
@@ -33,6 +35,9 @@ type Provider struct {
 	network    string
 	priority   int32
 	lock       *sync.RWMutex
+
+	// number of previous state machine states kept next to the config file, 0 disables the backups
+	stateBackups int
 }
 
 func NewProvider(configPath, network string, priority int32) (*Provider, error) {
@@ -42,7 +47,7 @@ func NewProvider(configPath, network string, priority int32) (*Provider, error)
 		if err != nil {
 			return nil, errors.Wrapf(err, "could not marshal local provider data into json")
 		}
-		if err := os.WriteFile(configPath, jsonData, 0600); err != nil {
+		if err := atomicfile.WriteFile(configPath, jsonData, 0600); err != nil {
 			return nil, errors.Wrapf(err, "could not create local provider data file")
 		}
 	}
@@ -57,6 +62,17 @@ func NewProvider(configPath, network string, priority int32) (*Provider, error)
 	return ur, nil
 }
 
+// WithStateBackups enables the rolling backups of the last n state machine states
+func (lp *Provider) WithStateBackups(n int) *Provider {
+	lp.stateBackups = n
+	return lp
+}
+
+// StateBackupPath returns the path of the n-th previous state machine state, 1 being the most recent one
+func StateBackupPath(configPath string, n int) string {
+	return fmt.Sprintf("%s.state.%d", configPath, n)
+}
+
 func (lp *Provider) GetUpgrades(_ context.Context) ([]*urproto.Upgrade, error) {
 	data, err := lp.readData(true)
 	if err != nil {
@@ -127,12 +143,7 @@ func (lp *Provider) AddUpgrade(_ context.Context, upgrade *urproto.Upgrade, over
 	upgrades = append(upgrades, upgrade)
 	data.Upgrades = upgrades
 
-	jsonData, err := json.Marshal(&data)
-	if err != nil {
-		return err
-	}
-
-	return os.WriteFile(lp.configPath, jsonData, 0600)
+	return lp.writeData(data)
 }
 
 func (lp *Provider) RegisterVersion(_ context.Context, version *vrproto.Version, overwrite bool) error {
@@ -164,12 +175,7 @@ func (lp *Provider) RegisterVersion(_ context.Context, version *vrproto.Version,
 	versions = append(versions, version)
 	data.Versions = versions
 
-	jsonData, err := json.Marshal(&data)
-	if err != nil {
-		return err
-	}
-
-	return os.WriteFile(lp.configPath, jsonData, 0600)
+	return lp.writeData(data)
 }
 
 func (lp *Provider) GetVersions(_ context.Context) ([]*vrproto.Version, error) {
@@ -206,14 +212,47 @@ func (lp *Provider) StoreState(_ context.Context, state *sm.State) error {
 	if err != nil {
 		return err
 	}
-	data.State = state
 
-	jsonData, err := json.Marshal(&data)
+	// the state is stored on every registry sync, skip the write (and the backup rotation) if nothing changed
+	previous, err := json.Marshal(data.State)
 	if err != nil {
-		return err
+		return errors.Wrapf(err, "could not marshal previous state")
+	}
+	current, err := json.Marshal(state)
+	if err != nil {
+		return errors.Wrapf(err, "could not marshal state")
+	}
+	if bytes.Equal(previous, current) {
+		return nil
+	}
+
+	if lp.stateBackups > 0 && data.State != nil {
+		if err := lp.rotateStateBackups(previous); err != nil {
+			return err
+		}
 	}
 
-	return os.WriteFile(lp.configPath, jsonData, 0600)
+	data.State = state
+	return lp.writeData(data)
+}
+
+// rotateStateBackups shifts the state backups by one and stores the previous state as the most recent backup
+func (lp *Provider) rotateStateBackups(previous []byte) error {
+	if err := os.Remove(StateBackupPath(lp.configPath, lp.stateBackups)); err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "could not remove the oldest state backup")
+	}
+
+	for n := lp.stateBackups - 1; n >= 1; n-- {
+		if err := os.Rename(StateBackupPath(lp.configPath, n), StateBackupPath(lp.configPath, n+1)); err != nil && !os.IsNotExist(err) {
+			return errors.Wrapf(err, "could not rotate state backup %d", n)
+		}
+	}
+
+	if err := atomicfile.WriteFile(StateBackupPath(lp.configPath, 1), previous, 0600); err != nil {
+		return errors.Wrapf(err, "could not write state backup")
+	}
+
+	return nil
 }
 
 func (lp *Provider) checkUniqueKey(data *localProviderData) error {
@@ -310,18 +349,23 @@ func (lp *Provider) CancelUpgrade(_ context.Context, height int64, network strin
 		data.Upgrades = upgrades
 	}
 
-	jsonData, err := json.Marshal(&data)
-	if err != nil {
-		return err
-	}
-
-	return os.WriteFile(lp.configPath, jsonData, 0600)
+	return lp.writeData(data)
 }
 
 func (lp *Provider) Type() urproto.ProviderType {
 	return urproto.ProviderType_LOCAL
 }
 
+// writeData replaces the data file atomically, the caller must hold the write lock
+func (lp *Provider) writeData(data *localProviderData) error {
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	return atomicfile.WriteFile(lp.configPath, jsonData, 0600)
+}
+
 func (lp *Provider) readData(lock bool) (*localProviderData, error) {
 	if lock {
 		lp.lock.RLock()
@@ -337,7 +381,7 @@ func (lp *Provider) readData(lock bool) (*localProviderData, error) {
 			return nil, errors.Wrapf(err, "could not marshal new upgrades file to protobuf")
 		}
 
-		if err := os.WriteFile(lp.configPath, jsonData, 0600); err != nil {
+		if err := atomicfile.WriteFile(lp.configPath, jsonData, 0600); err != nil {
 			return nil, errors.Wrapf(err, "could not create new upgrades file")
 		}
 		return &localData, nil
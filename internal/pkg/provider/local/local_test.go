@@ -2,12 +2,15 @@ package local
 
 import (
 	"context"
+	"encoding/json"
+	"os"
 	"path"
 	"sync"
 	"testing"
 
 	urproto "blazar/internal/pkg/proto/upgrades_registry"
 	vrproto "blazar/internal/pkg/proto/version_resolver"
+	sm "blazar/internal/pkg/state_machine"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -139,3 +142,50 @@ func TestLoadFailing(t *testing.T) {
 		})
 	}
 }
+
+func TestStateBackups(t *testing.T) {
+	configPath := path.Join(t.TempDir(), "local.db.json")
+
+	lp, err := NewProvider(configPath, "test", 1)
+	require.NoError(t, err)
+	lp.WithStateBackups(2)
+
+	stateWithStatus := func(status urproto.UpgradeStatus) *sm.State {
+		return &sm.State{UpgradeStatus: map[int64]urproto.UpgradeStatus{100: status}}
+	}
+	backupStatus := func(n int) urproto.UpgradeStatus {
+		data, err := os.ReadFile(StateBackupPath(configPath, n))
+		require.NoError(t, err)
+
+		var state sm.State
+		require.NoError(t, json.Unmarshal(data, &state))
+		return state.UpgradeStatus[100]
+	}
+
+	// there is nothing to back up on the first write
+	require.NoError(t, lp.StoreState(context.Background(), stateWithStatus(urproto.UpgradeStatus_ACTIVE)))
+	assert.NoFileExists(t, StateBackupPath(configPath, 1))
+
+	require.NoError(t, lp.StoreState(context.Background(), stateWithStatus(urproto.UpgradeStatus_EXECUTING)))
+	assert.Equal(t, urproto.UpgradeStatus_ACTIVE, backupStatus(1))
+
+	// an unchanged state doesn't rotate the backups
+	require.NoError(t, lp.StoreState(context.Background(), stateWithStatus(urproto.UpgradeStatus_EXECUTING)))
+	assert.Equal(t, urproto.UpgradeStatus_ACTIVE, backupStatus(1))
+	assert.NoFileExists(t, StateBackupPath(configPath, 2))
+
+	require.NoError(t, lp.StoreState(context.Background(), stateWithStatus(urproto.UpgradeStatus_COMPLETED)))
+	require.NoError(t, lp.StoreState(context.Background(), stateWithStatus(urproto.UpgradeStatus_FAILED)))
+	assert.Equal(t, urproto.UpgradeStatus_COMPLETED, backupStatus(1))
+	assert.Equal(t, urproto.UpgradeStatus_EXECUTING, backupStatus(2))
+	assert.NoFileExists(t, StateBackupPath(configPath, 3))
+
+	state, err := lp.RestoreState(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, urproto.UpgradeStatus_FAILED, state.UpgradeStatus[100])
+
+	// the temporary files are cleaned up
+	entries, err := os.ReadDir(path.Dir(configPath))
+	require.NoError(t, err)
+	assert.Len(t, entries, 3)
+}
@@ -15,6 +15,13 @@ type VersionResolver interface {
 	GetVersionsByHeight(ctx context.Context, height uint64) ([]*vrproto.Version, error)
 }
 
+// UpgradesVersionResolver is a version resolver mapping the upgrades of the other providers to versions. The upgrades
+// resolved by the upgrade registry are passed in, so the other providers are not queried again
+type UpgradesVersionResolver interface {
+	VersionResolver
+	GetVersionsForUpgrades(ctx context.Context, upgrades []*urproto.Upgrade) ([]*vrproto.Version, error)
+}
+
 // UpgradesProvider is an interface for fetching upgrades from an external source
 type UpgradeProvider interface {
 	GetUpgrades(ctx context.Context) ([]*urproto.Upgrade, error)
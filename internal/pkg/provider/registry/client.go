@@ -0,0 +1,295 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"blazar/internal/pkg/docker"
+	"blazar/internal/pkg/errors"
+
+	"github.com/distribution/reference"
+	"github.com/docker/docker/api/types/registry"
+)
+
+const (
+	// the docker hub API is not served from the docker.io domain used in the image names
+	dockerHubDomain   = "docker.io"
+	dockerHubRegistry = "registry-1.docker.io"
+
+	// number of tags requested per page, registries are free to return fewer
+	tagsPageSize = 1000
+)
+
+var (
+	// <url>; rel="next"
+	linkNextRegex = regexp.MustCompile(`<([^>]+)>\s*;\s*rel="?next"?`)
+
+	// key="value" pairs of the WWW-Authenticate header
+	challengeParamRegex = regexp.MustCompile(`([a-zA-Z]+)="([^"]*)"`)
+)
+
+// TagsClient lists the tags of a repository through the OCI distribution API (/v2/<name>/tags/list).
+// It authenticates with the credentials of the docker credential helper (if any), either with
+// basic auth or the bearer token flow used by docker hub, ghcr.io and the distribution registry.
+type TagsClient struct {
+	baseURL    string
+	repository string
+
+	credentialHelper docker.CredentialHelper
+	httpClient       *http.Client
+
+	// token obtained in the last bearer challenge, reused until the registry rejects it
+	token     string
+	tokenLock sync.Mutex
+}
+
+func NewTagsClient(image string, insecure bool, timeout time.Duration, credentialHelper docker.CredentialHelper) (*TagsClient, error) {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid image name: %s", image)
+	}
+
+	domain := reference.Domain(named)
+	if domain == dockerHubDomain {
+		domain = dockerHubRegistry
+	}
+
+	scheme := "https"
+	if insecure {
+		scheme = "http"
+	}
+
+	return &TagsClient{
+		baseURL:          fmt.Sprintf("%s://%s", scheme, domain),
+		repository:       reference.Path(named),
+		credentialHelper: credentialHelper,
+		httpClient:       &http.Client{Timeout: timeout},
+	}, nil
+}
+
+// Repository returns the repository path the tags are listed for (e.g. library/ubuntu)
+func (c *TagsClient) Repository() string {
+	return c.repository
+}
+
+// ListTags returns all tags of the repository, following the pagination links
+func (c *TagsClient) ListTags(ctx context.Context) ([]string, error) {
+	tags := make([]string, 0)
+	next := fmt.Sprintf("%s/v2/%s/tags/list?n=%d", c.baseURL, c.repository, tagsPageSize)
+
+	for next != "" {
+		resp, err := c.get(ctx, next)
+		if err != nil {
+			return nil, err
+		}
+
+		var page struct {
+			Tags []string `json:"tags"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to decode the tags list of %s", c.repository)
+		}
+		tags = append(tags, page.Tags...)
+
+		next, err = c.nextPage(next, resp.Header.Get("Link"))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return tags, nil
+}
+
+func (c *TagsClient) nextPage(current, link string) (string, error) {
+	match := linkNextRegex.FindStringSubmatch(link)
+	if match == nil {
+		return "", nil
+	}
+
+	base, err := url.Parse(current)
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid url: %s", current)
+	}
+	ref, err := url.Parse(match[1])
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid pagination link: %s", link)
+	}
+
+	// the link is usually relative to the registry
+	return base.ResolveReference(ref).String(), nil
+}
+
+// get sends the request, answering the authentication challenge of the registry if needed
+func (c *TagsClient) get(ctx context.Context, rawURL string) (*http.Response, error) {
+	c.tokenLock.Lock()
+	token := c.token
+	c.tokenLock.Unlock()
+
+	resp, err := c.do(ctx, rawURL, func(req *http.Request) {
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+
+		authorization, err := c.authorize(ctx, challenge)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err = c.do(ctx, rawURL, func(req *http.Request) {
+			req.Header.Set("Authorization", authorization)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		return nil, fmt.Errorf("registry returned %s for %s: %s", resp.Status, rawURL, strings.TrimSpace(string(body)))
+	}
+
+	return resp, nil
+}
+
+func (c *TagsClient) do(ctx context.Context, rawURL string, setAuth func(*http.Request)) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create request for %s", rawURL)
+	}
+	req.Header.Set("Accept", "application/json")
+	setAuth(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to query the registry")
+	}
+	return resp, nil
+}
+
+// authorize returns the Authorization header answering the WWW-Authenticate challenge
+func (c *TagsClient) authorize(ctx context.Context, challenge string) (string, error) {
+	username, password, err := c.credentials(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	scheme, params, _ := strings.Cut(challenge, " ")
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if username == "" {
+			return "", errors.New("registry requires basic auth but no credentials are configured")
+		}
+		req := &http.Request{Header: http.Header{}}
+		req.SetBasicAuth(username, password)
+		return req.Header.Get("Authorization"), nil
+	case "bearer":
+		token, err := c.fetchToken(ctx, params, username, password)
+		if err != nil {
+			return "", err
+		}
+
+		c.tokenLock.Lock()
+		c.token = token
+		c.tokenLock.Unlock()
+
+		return "Bearer " + token, nil
+	default:
+		return "", fmt.Errorf("unsupported registry authentication challenge: %q", challenge)
+	}
+}
+
+func (c *TagsClient) fetchToken(ctx context.Context, challengeParams, username, password string) (string, error) {
+	params := make(map[string]string)
+	for _, match := range challengeParamRegex.FindAllStringSubmatch(challengeParams, -1) {
+		params[strings.ToLower(match[1])] = match[2]
+	}
+
+	realm, ok := params["realm"]
+	if !ok {
+		return "", fmt.Errorf("registry bearer challenge has no realm: %q", challengeParams)
+	}
+
+	tokenURL, err := url.Parse(realm)
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid registry token realm: %s", realm)
+	}
+
+	query := tokenURL.Query()
+	if service, ok := params["service"]; ok {
+		query.Set("service", service)
+	}
+	scope, ok := params["scope"]
+	if !ok {
+		scope = fmt.Sprintf("repository:%s:pull", c.repository)
+	}
+	query.Set("scope", scope)
+	tokenURL.RawQuery = query.Encode()
+
+	resp, err := c.do(ctx, tokenURL.String(), func(req *http.Request) {
+		if username != "" {
+			req.SetBasicAuth(username, password)
+		}
+	})
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to fetch the registry token")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("registry token endpoint returned %s", resp.Status)
+	}
+
+	var tokenResp struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+		return "", errors.Wrapf(err, "failed to decode the registry token")
+	}
+
+	if tokenResp.Token != "" {
+		return tokenResp.Token, nil
+	}
+	if tokenResp.AccessToken != "" {
+		return tokenResp.AccessToken, nil
+	}
+	return "", errors.New("registry token endpoint returned an empty token")
+}
+
+// credentials returns the username and password from the docker credential helper, empty if there is none
+func (c *TagsClient) credentials(ctx context.Context) (string, string, error) {
+	if c.credentialHelper == nil {
+		return "", "", nil
+	}
+
+	encoded, err := c.credentialHelper.GetRegistryAuth(ctx)
+	if err != nil {
+		return "", "", errors.Wrapf(err, "failed to get registry credentials")
+	}
+
+	auth, err := registry.DecodeAuthConfig(encoded)
+	if err != nil {
+		return "", "", errors.Wrapf(err, "failed to decode registry credentials")
+	}
+
+	return auth.Username, auth.Password, nil
+}
@@ -0,0 +1,352 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"blazar/internal/pkg/config"
+	"blazar/internal/pkg/docker"
+	"blazar/internal/pkg/errors"
+	urproto "blazar/internal/pkg/proto/upgrades_registry"
+	vrproto "blazar/internal/pkg/proto/version_resolver"
+	"blazar/internal/pkg/provider"
+)
+
+var (
+	// upgrade names the default rule applies to, e.g. v17, v17.1 or v17.1.0-rc1
+	semverPrefixRegex = regexp.MustCompile(`^v?(\d+)(?:\.(\d+))?(?:\.(\d+))?(?:-([0-9A-Za-z.-]+))?$`)
+
+	semverRegex = regexp.MustCompile(`^v?(\d+)\.(\d+)\.(\d+)(?:-([0-9A-Za-z.-]+))?$`)
+
+	// pre-release identifier with a trailing number, e.g. rc10 or 10
+	numericSuffixRegex = regexp.MustCompile(`^([0-9A-Za-z-]*?)(\d+)$`)
+)
+
+type tagLister interface {
+	ListTags(ctx context.Context) ([]string, error)
+}
+
+type tagRule struct {
+	upgradeName *regexp.Regexp
+	tag         string
+}
+
+// Provider is a version resolver mapping the upgrades of the other providers to the tags of a container image.
+// It doesn't provide any upgrades on its own, the upgrade registry passes in the upgrades it resolved.
+//
+// Without rules, the upgrade name is treated as a semver prefix and the newest matching release wins
+// (e.g. v17 -> v17.2.1, v17.1 -> v17.1.3). The rules map any other naming scheme to a tag regex, in which
+// case the newest tag matching the regex wins.
+type Provider struct {
+	client tagLister
+	image  string
+
+	rules    []tagRule
+	network  string
+	priority int32
+}
+
+func NewProvider(cfg *config.RegistryProvider, credentialHelper docker.CredentialHelper, network string) (*Provider, error) {
+	client, err := NewTagsClient(cfg.Image, cfg.Insecure, cfg.Timeout, credentialHelper)
+	if err != nil {
+		return nil, err
+	}
+
+	return newProvider(client, cfg, network)
+}
+
+func newProvider(client tagLister, cfg *config.RegistryProvider, network string) (*Provider, error) {
+	p := &Provider{
+		client:   client,
+		image:    cfg.Image,
+		network:  network,
+		priority: cfg.DefaultPriority,
+	}
+
+	for i, rule := range cfg.Rules {
+		regex, err := regexp.Compile(rule.UpgradeName)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid upgrade-name regex in registry rule #%d", i+1)
+		}
+
+		if err := provider.ValidateTemplate(rule.Tag, regex, "name"); err != nil {
+			return nil, errors.Wrapf(err, "invalid tag in registry rule #%d", i+1)
+		}
+
+		p.rules = append(p.rules, tagRule{upgradeName: regex, tag: rule.Tag})
+	}
+
+	return p, nil
+}
+
+func (p *Provider) GetUpgrades(_ context.Context) ([]*urproto.Upgrade, error) {
+	return []*urproto.Upgrade{}, nil
+}
+
+func (p *Provider) GetUpgradesByType(_ context.Context, _ urproto.UpgradeType) ([]*urproto.Upgrade, error) {
+	return []*urproto.Upgrade{}, nil
+}
+
+func (p *Provider) GetUpgradesByHeight(_ context.Context, _ int64) ([]*urproto.Upgrade, error) {
+	return []*urproto.Upgrade{}, nil
+}
+
+func (p *Provider) AddUpgrade(_ context.Context, _ *urproto.Upgrade, _ bool) error {
+	return errors.New("add upgrade is not supported for registry provider")
+}
+
+func (p *Provider) CancelUpgrade(_ context.Context, _ int64, _ string) error {
+	return errors.New("cancel upgrade is not supported for registry provider")
+}
+
+func (p *Provider) RegisterVersion(_ context.Context, _ *vrproto.Version, _ bool) error {
+	return errors.New("register version is not supported for registry provider")
+}
+
+// GetVersions returns no versions, the tags are resolved only for the upgrades passed to GetVersionsForUpgrades
+func (p *Provider) GetVersions(_ context.Context) ([]*vrproto.Version, error) {
+	return []*vrproto.Version{}, nil
+}
+
+// GetVersionsForUpgrades returns the tags resolved for the upgrades, skipping the cancelled ones
+func (p *Provider) GetVersionsForUpgrades(ctx context.Context, upgrades []*urproto.Upgrade) ([]*vrproto.Version, error) {
+	upgrades = slices.DeleteFunc(slices.Clone(upgrades), func(upgrade *urproto.Upgrade) bool {
+		return upgrade.Status == urproto.UpgradeStatus_CANCELLED || upgrade.Name == ""
+	})
+	if len(upgrades) == 0 {
+		return []*vrproto.Version{}, nil
+	}
+
+	tags, err := p.client.ListTags(ctx)
+	if err != nil {
+		return []*vrproto.Version{}, errors.Wrapf(err, "failed to list tags of %s", p.image)
+	}
+
+	versions := make([]*vrproto.Version, 0, len(upgrades))
+	for _, upgrade := range upgrades {
+		tag, provenance := p.Resolve(upgrade.Name, tags)
+		if tag == "" {
+			continue
+		}
+
+		version := &vrproto.Version{
+			Height:     upgrade.Height,
+			Network:    p.network,
+			Tag:        tag,
+			Provenance: provenance,
+		}
+		provider.PostProcessVersion(version, urproto.ProviderType_REGISTRY, p.priority)
+		versions = append(versions, version)
+	}
+
+	return versions, nil
+}
+
+func (p *Provider) GetVersionsByHeight(ctx context.Context, height uint64) ([]*vrproto.Version, error) {
+	versions, err := p.GetVersions(ctx)
+	if err != nil {
+		return []*vrproto.Version{}, err
+	}
+
+	filtered := make([]*vrproto.Version, 0, len(versions))
+	for _, version := range versions {
+		// #nosec G115
+		if version.Height == int64(height) {
+			filtered = append(filtered, version)
+		}
+	}
+
+	return filtered, nil
+}
+
+func (p *Provider) Type() urproto.ProviderType {
+	return urproto.ProviderType_REGISTRY
+}
+
+// Resolve returns the newest tag for the upgrade name and a description of how it was resolved, empty strings
+// if no tag matches
+func (p *Provider) Resolve(name string, tags []string) (string, string) {
+	for i, rule := range p.rules {
+		match := rule.upgradeName.FindStringSubmatch(name)
+		if match == nil {
+			continue
+		}
+
+		// the values are escaped, so a dot in the upgrade name only matches a dot in the tag
+		expr := provider.ExpandTemplate(rule.tag, rule.upgradeName, match, map[string]string{"name": name}, regexp.QuoteMeta)
+		tagRegex, err := regexp.Compile(expr)
+		if err != nil {
+			continue
+		}
+
+		if tag := newestTag(tags, tagRegex.MatchString); tag != "" {
+			return tag, fmt.Sprintf("registry %s rule #%d", p.image, i+1)
+		}
+		// the first matching rule wins, even if no tag exists yet
+		return "", ""
+	}
+
+	if tag := newestTag(tags, semverPrefixMatcher(name)); tag != "" {
+		return tag, fmt.Sprintf("registry %s semver", p.image)
+	}
+
+	return "", ""
+}
+
+// semverPrefixMatcher matches the releases starting with the version in the upgrade name. A name with a
+// pre-release (e.g. v17.0.0-rc1) only matches the same pre-release.
+func semverPrefixMatcher(name string) func(string) bool {
+	match := semverPrefixRegex.FindStringSubmatch(name)
+	if match == nil {
+		return func(string) bool { return false }
+	}
+
+	// the components missing from the name match any value
+	prefix := make([]int, 0, 3)
+	for _, part := range match[1:4] {
+		if part == "" {
+			break
+		}
+		number, err := strconv.Atoi(part)
+		if err != nil {
+			return func(string) bool { return false }
+		}
+		prefix = append(prefix, number)
+	}
+	prerelease := match[4]
+
+	return func(tag string) bool {
+		version, ok := parseSemver(tag)
+		if !ok || version.prerelease != prerelease {
+			return false
+		}
+		if prerelease != "" && len(prefix) != len(version.parts) {
+			return false
+		}
+
+		for i, number := range prefix {
+			if version.parts[i] != number {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// newestTag returns the newest tag accepted by the matcher. Semantic versions are compared by precedence and
+// always win over the other tags, which are compared lexically.
+func newestTag(tags []string, matches func(string) bool) string {
+	newest := ""
+	for _, tag := range tags {
+		if matches(tag) && (newest == "" || compareTags(tag, newest) > 0) {
+			newest = tag
+		}
+	}
+	return newest
+}
+
+type semver struct {
+	parts      [3]int
+	prerelease string
+}
+
+func parseSemver(tag string) (semver, bool) {
+	match := semverRegex.FindStringSubmatch(tag)
+	if match == nil {
+		return semver{}, false
+	}
+
+	var version semver
+	for i := range version.parts {
+		part, err := strconv.Atoi(match[i+1])
+		if err != nil {
+			return semver{}, false
+		}
+		version.parts[i] = part
+	}
+	version.prerelease = match[4]
+
+	return version, true
+}
+
+func compareTags(a, b string) int {
+	versionA, okA := parseSemver(a)
+	versionB, okB := parseSemver(b)
+
+	switch {
+	case okA && !okB:
+		return 1
+	case !okA && okB:
+		return -1
+	case !okA && !okB:
+		return strings.Compare(a, b)
+	}
+
+	for i := range versionA.parts {
+		if versionA.parts[i] != versionB.parts[i] {
+			return versionA.parts[i] - versionB.parts[i]
+		}
+	}
+
+	// a release has a higher precedence than its pre-releases
+	switch {
+	case versionA.prerelease == versionB.prerelease:
+		// v1.0.0 and 1.0.0 are the same version, prefer the tag with the prefix for a deterministic result
+		return strings.Compare(a, b)
+	case versionA.prerelease == "":
+		return 1
+	case versionB.prerelease == "":
+		return -1
+	}
+	return comparePrereleases(versionA.prerelease, versionB.prerelease)
+}
+
+// comparePrereleases compares the pre-releases by the semver precedence: the dot separated identifiers are compared
+// one by one, numerically if both are numbers (rc.9 < rc.10, rc9 < rc10), the numbers are lower than the other
+// identifiers and a longer pre-release wins if all the preceding identifiers are equal
+func comparePrereleases(a, b string) int {
+	identifiersA, identifiersB := strings.Split(a, "."), strings.Split(b, ".")
+	for i := range min(len(identifiersA), len(identifiersB)) {
+		if result := compareIdentifiers(identifiersA[i], identifiersB[i]); result != 0 {
+			return result
+		}
+	}
+	return len(identifiersA) - len(identifiersB)
+}
+
+// compareIdentifiers compares the pre-release identifiers. The identifiers made of a prefix and a number, e.g. rc9,
+// are not numeric by the semver spec, but they are compared by the number as the tags are commonly named that way
+func compareIdentifiers(a, b string) int {
+	prefixA, numberA, okA := splitNumericSuffix(a)
+	prefixB, numberB, okB := splitNumericSuffix(b)
+
+	numericA, numericB := okA && prefixA == "", okB && prefixB == ""
+
+	switch {
+	case okA && okB && prefixA == prefixB && numberA != numberB:
+		return numberA - numberB
+	case numericA && !numericB:
+		return -1
+	case !numericA && numericB:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+// splitNumericSuffix splits the identifier into its prefix and trailing number, e.g. rc10 -> rc, 10
+func splitNumericSuffix(identifier string) (string, int, bool) {
+	match := numericSuffixRegex.FindStringSubmatch(identifier)
+	if match == nil {
+		return "", 0, false
+	}
+
+	number, err := strconv.Atoi(match[2])
+	if err != nil {
+		return "", 0, false
+	}
+	return match[1], number, true
+}
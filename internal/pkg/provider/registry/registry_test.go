@@ -0,0 +1,211 @@
+package registry
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"blazar/internal/pkg/config"
+	urproto "blazar/internal/pkg/proto/upgrades_registry"
+
+	"github.com/docker/docker/api/types/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testTags = []string{
+	"latest", "v16.0.0", "v17.0.0-rc1", "v17.0.0", "v17.1.0", "v17.1.1", "v17.2.0-rc0", "v18.0.0-rc1",
+	"v19.0.0-rc9", "v19.0.0-rc10",
+	"neutron-4.0.1", "neutron-4.1.0", "neutron-4.0.9",
+}
+
+type tagsStub []string
+
+func (s tagsStub) ListTags(context.Context) ([]string, error) {
+	return s, nil
+}
+
+func TestResolve(t *testing.T) {
+	p, err := newProvider(tagsStub(testTags), &config.RegistryProvider{
+		Image: "ghcr.io/cosmos/gaia",
+		Rules: []config.RegistryTagRule{
+			{UpgradeName: `^neutron-v(?P<major>\d+)$`, Tag: `^neutron-{{major}}\.\d+\.\d+$`},
+			{UpgradeName: `^pinned$`, Tag: `^v16\.`},
+			{UpgradeName: `^(?P<major>\d+)-rc$`, Tag: `^v{{major}}\.0\.0-rc\d+$`},
+		},
+	}, "test")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name               string
+		upgradeName        string
+		expectedTag        string
+		expectedProvenance string
+	}{
+		{name: "Major", upgradeName: "v17", expectedTag: "v17.1.1", expectedProvenance: "registry ghcr.io/cosmos/gaia semver"},
+		{name: "Minor", upgradeName: "v17.1", expectedTag: "v17.1.1", expectedProvenance: "registry ghcr.io/cosmos/gaia semver"},
+		{name: "Exact", upgradeName: "v17.0.0", expectedTag: "v17.0.0", expectedProvenance: "registry ghcr.io/cosmos/gaia semver"},
+		{name: "PreRelease", upgradeName: "v17.0.0-rc1", expectedTag: "v17.0.0-rc1", expectedProvenance: "registry ghcr.io/cosmos/gaia semver"},
+		{name: "OnlyPreReleases", upgradeName: "v18"},
+		{name: "NoMatch", upgradeName: "v19"},
+		{name: "NotSemver", upgradeName: "lambda"},
+		{name: "Rule", upgradeName: "neutron-v4", expectedTag: "neutron-4.1.0", expectedProvenance: "registry ghcr.io/cosmos/gaia rule #1"},
+		{name: "RuleWithoutTag", upgradeName: "neutron-v5"},
+		{name: "RuleWithoutPlaceholders", upgradeName: "pinned", expectedTag: "v16.0.0", expectedProvenance: "registry ghcr.io/cosmos/gaia rule #2"},
+		{name: "NewestPreRelease", upgradeName: "19-rc", expectedTag: "v19.0.0-rc10", expectedProvenance: "registry ghcr.io/cosmos/gaia rule #3"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tag, provenance := p.Resolve(test.upgradeName, testTags)
+			assert.Equal(t, test.expectedTag, tag)
+			assert.Equal(t, test.expectedProvenance, provenance)
+		})
+	}
+
+	_, err = newProvider(tagsStub(testTags), &config.RegistryProvider{
+		Rules: []config.RegistryTagRule{{UpgradeName: `^v(\d+)$`, Tag: `^v{{major}}\.`}},
+	}, "test")
+	require.ErrorContains(t, err, "invalid tag in registry rule #1: unknown placeholder {{major}}")
+}
+
+func TestGetVersionsForUpgrades(t *testing.T) {
+	upgrades := []*urproto.Upgrade{
+		{Height: 100, Name: "v17", Priority: 1, Status: urproto.UpgradeStatus_ACTIVE},
+		{Height: 200, Name: "v17.1", Priority: 1, Status: urproto.UpgradeStatus_CANCELLED},
+		{Height: 300, Name: "v17.0", Priority: 2, Status: urproto.UpgradeStatus_ACTIVE},
+		{Height: 400, Priority: 2, Status: urproto.UpgradeStatus_ACTIVE},
+	}
+
+	p, err := newProvider(tagsStub(testTags), &config.RegistryProvider{
+		Image:           "ghcr.io/cosmos/gaia",
+		DefaultPriority: 5,
+	}, "test")
+	require.NoError(t, err)
+
+	versions, err := p.GetVersionsForUpgrades(context.Background(), upgrades)
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+
+	tags := make(map[int64]string)
+	for _, version := range versions {
+		assert.Equal(t, urproto.ProviderType_REGISTRY, version.Source)
+		assert.Equal(t, int32(5), version.Priority)
+		assert.Equal(t, "test", version.Network)
+		tags[version.Height] = version.Tag
+	}
+	assert.Equal(t, map[int64]string{100: "v17.1.1", 300: "v17.0.0"}, tags)
+
+	// the provider doesn't know any upgrades on its own
+	versions, err = p.GetVersions(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, versions)
+}
+
+func TestCompareTags(t *testing.T) {
+	tests := []struct {
+		a, b string
+	}{
+		{a: "v17.0.0", b: "v16.0.0"},
+		{a: "v17.0.0", b: "v17.0.0-rc1"},
+		{a: "v17.0.0-rc10", b: "v17.0.0-rc9"},
+		{a: "v17.0.0-rc.10", b: "v17.0.0-rc.9"},
+		{a: "v17.0.0-rc.1", b: "v17.0.0-rc"},
+		{a: "v17.0.0-beta", b: "v17.0.0-alpha"},
+		{a: "v17.0.0-rc1", b: "v17.0.0-beta2"},
+		{a: "v17.0.0-alpha", b: "v17.0.0-1"},
+		{a: "v17.0.0", b: "latest"},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%s>%s", test.a, test.b), func(t *testing.T) {
+			assert.Positive(t, compareTags(test.a, test.b))
+			assert.Negative(t, compareTags(test.b, test.a))
+		})
+	}
+}
+
+type credentialsStub struct{}
+
+func (credentialsStub) GetRegistryAuth(context.Context) (string, error) {
+	auth, err := json.Marshal(registry.AuthConfig{Username: "user", Password: "secret"})
+	if err != nil {
+		return "", err
+	}
+	return base64.URLEncoding.EncodeToString(auth), nil
+}
+
+func TestTagsClient(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			if username, password, ok := r.BasicAuth(); !ok || username != "user" || password != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			assert.Equal(t, "repository:cosmos/gaia:pull", r.URL.Query().Get("scope"))
+			assert.Equal(t, "test-registry", r.URL.Query().Get("service"))
+			_ = json.NewEncoder(w).Encode(map[string]string{"token": "test-token"})
+
+		case "/v2/cosmos/gaia/tags/list":
+			if r.Header.Get("Authorization") != "Bearer test-token" {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(
+					`Bearer realm="%s/token",service="test-registry",scope="repository:cosmos/gaia:pull"`, server.URL,
+				))
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			// two tags per page, as the distribution registry does with ?n=2
+			tags := testTags
+			start := 0
+			for i, tag := range tags {
+				if tag == r.URL.Query().Get("last") {
+					start = i + 1
+				}
+			}
+			end := min(start+2, len(tags))
+			if end < len(tags) {
+				w.Header().Set("Link", fmt.Sprintf(`</v2/cosmos/gaia/tags/list?n=2&last=%s>; rel="next"`, tags[end-1]))
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{"name": "cosmos/gaia", "tags": tags[start:end]})
+
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	image := strings.TrimPrefix(server.URL, "http://") + "/cosmos/gaia"
+
+	client, err := NewTagsClient(image, true, time.Second, credentialsStub{})
+	require.NoError(t, err)
+
+	tags, err := client.ListTags(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, testTags, tags)
+
+	// the token endpoint rejects anonymous requests
+	client, err = NewTagsClient(image, true, time.Second, nil)
+	require.NoError(t, err)
+
+	_, err = client.ListTags(context.Background())
+	require.ErrorContains(t, err, "registry token endpoint returned 401 Unauthorized")
+
+	// docker hub images are served by registry-1.docker.io
+	client, err = NewTagsClient("cosmos/gaia", false, time.Second, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "https://registry-1.docker.io", client.baseURL)
+	assert.Equal(t, "cosmos/gaia", client.Repository())
+
+	client, err = NewTagsClient("ubuntu", false, time.Second, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "library/ubuntu", client.Repository())
+}
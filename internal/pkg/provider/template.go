@@ -0,0 +1,45 @@
+package provider
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+)
+
+// rule template placeholder, e.g. {{name}}
+var placeholderRegex = regexp.MustCompile(`{{\s*([A-Za-z0-9_]+)\s*}}`)
+
+// ValidateTemplate checks that every placeholder of the rule template is either one of the fixed values or a named
+// group of the rule regex
+func ValidateTemplate(template string, regex *regexp.Regexp, values ...string) error {
+	for _, placeholder := range placeholderRegex.FindAllStringSubmatch(template, -1) {
+		if name := placeholder[1]; !slices.Contains(values, name) && regex.SubexpIndex(name) == -1 {
+			return fmt.Errorf("unknown placeholder %s", placeholder[0])
+		}
+	}
+	return nil
+}
+
+// ExpandTemplate replaces the placeholders of the rule template with the fixed values and the named groups of the
+// regex match, the groups win over the fixed values of the same name. The escape function, if set, is applied to
+// every substituted value
+func ExpandTemplate(template string, regex *regexp.Regexp, match []string, values map[string]string, escape func(string) string) string {
+	values = maps.Clone(values)
+	if values == nil {
+		values = make(map[string]string)
+	}
+	for n, group := range regex.SubexpNames() {
+		if group != "" {
+			values[group] = match[n]
+		}
+	}
+
+	return placeholderRegex.ReplaceAllStringFunc(template, func(placeholder string) string {
+		value := values[placeholderRegex.FindStringSubmatch(placeholder)[1]]
+		if escape != nil {
+			value = escape(value)
+		}
+		return value
+	})
+}
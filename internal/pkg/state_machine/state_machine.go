@@ -2,10 +2,14 @@ package state_machine
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"maps"
 	"slices"
 	"sync"
+	"time"
 
+	"blazar/internal/pkg/errors"
 	checksproto "blazar/internal/pkg/proto/daemon"
 	urproto "blazar/internal/pkg/proto/upgrades_registry"
 )
@@ -33,6 +37,14 @@ var (
 		urproto.UpgradeStatus_COMPLETED,
 		urproto.UpgradeStatus_FAILED,
 		urproto.UpgradeStatus_EXPIRED,
+		urproto.UpgradeStatus_ROLLED_BACK,
+	}
+
+	// statuses of the executed upgrades, the outcome can't be undone by a provider cancelling the upgrade afterwards
+	finalStatuses = []urproto.UpgradeStatus{
+		urproto.UpgradeStatus_COMPLETED,
+		urproto.UpgradeStatus_FAILED,
+		urproto.UpgradeStatus_ROLLED_BACK,
 	}
 )
 
@@ -54,14 +66,73 @@ type State struct {
 
 	PreCheckStatus  map[int64]map[checksproto.PreCheck]checksproto.CheckStatus  `json:"pre_check_status"`
 	PostCheckStatus map[int64]map[checksproto.PostCheck]checksproto.CheckStatus `json:"post_check_status"`
+
+	// versions (service name -> tag or image) the services were running before the upgrade, used for rollbacks
+	PreviousVersions map[int64]map[string]string `json:"previous_versions"`
+
+	// versions (service name -> tag or image) the upgrade switches the services to, used to resume the upgrade
+	UpgradeVersions map[int64]map[string]string `json:"upgrade_versions"`
+
+	// path of the chain data backup taken during the upgrade
+	Backups map[int64]string `json:"backups"`
+
+	// upgrade image (or binary) and its digest pinned by the pre-upgrade check
+	PinnedImages map[int64]string `json:"pinned_images"`
+	ImageDigests map[int64]string `json:"image_digests"`
+
+	// tag and expected version of the upgrade the VERIFY_BINARY_VERSION check result was computed for
+	BinaryVersionChecks map[int64]string `json:"binary_version_checks"`
+
+	// software version reported by the node before the upgrade
+	AppVersions map[int64]*AppVersion `json:"app_versions"`
+
+	// x/upgrade module versions before and after the upgrade
+	ModuleVersions map[int64]*ModuleVersions `json:"module_versions"`
+
+	// id of the first notification of the upgrade, the following notifications are sent to its thread
+	NotificationThreads map[int64]string `json:"notification_threads"`
+
+	// image (or binary) the node was upgraded to
+	UpgradeImages map[int64]string `json:"upgrade_images"`
 }
 
+// AppVersion is the software version reported by the node
+type AppVersion struct {
+	Version   string `json:"version"`
+	GitCommit string `json:"git_commit"`
+	// ABCI app_version (protocol version), nil if unknown
+	AppVersion *uint64 `json:"app_version,omitempty"`
+}
+
+// ModuleVersions holds the consensus versions of the modules (module name -> version)
+type ModuleVersions struct {
+	Before map[string]uint64 `json:"before"`
+	After  map[string]uint64 `json:"after"`
+}
+
+const (
+	// number of attempts to store the state before giving up
+	persistAttempts = 3
+	// delay between the attempts, multiplied by the attempt number
+	persistRetryDelay = 200 * time.Millisecond
+)
+
 // Simple, unsphisitcated state machine for managing upgrades
 type StateMachine struct {
 	lock  *sync.RWMutex
 	state *State
 
 	storage StateMachineStorage
+
+	// serializes the stores, the state is stored without holding the state lock
+	persistLock *sync.Mutex
+	// context the state is stored with
+	persistCtx context.Context
+	// called when the state couldn't be stored after all attempts
+	onPersistError func(error)
+
+	// the node replays the chain from genesis, the past upgrades are executed instead of being expired
+	syncFromGenesis bool
 }
 
 func NewStateMachine(storage StateMachineStorage) *StateMachine {
@@ -73,15 +144,31 @@ func NewStateMachine(storage StateMachineStorage) *StateMachine {
 
 			PreCheckStatus:  make(map[int64]map[checksproto.PreCheck]checksproto.CheckStatus, 0),
 			PostCheckStatus: make(map[int64]map[checksproto.PostCheck]checksproto.CheckStatus, 0),
+
+			PreviousVersions: make(map[int64]map[string]string, 0),
+			UpgradeVersions:  make(map[int64]map[string]string, 0),
+			Backups:          make(map[int64]string, 0),
+			PinnedImages:     make(map[int64]string, 0),
+			ImageDigests:     make(map[int64]string, 0),
+			AppVersions:      make(map[int64]*AppVersion, 0),
+			ModuleVersions:   make(map[int64]*ModuleVersions, 0),
+
+			BinaryVersionChecks: make(map[int64]string, 0),
+
+			NotificationThreads: make(map[int64]string, 0),
+			UpgradeImages:       make(map[int64]string, 0),
 		},
 		storage: storage,
+
+		persistLock: &sync.Mutex{},
+		persistCtx:  context.Background(),
 	}
 }
 
 func (sm *StateMachine) UpdateStatus(currentHeight int64, upgrades map[int64]*urproto.Upgrade) {
+	defer sm.persist()
 	sm.lock.Lock()
 	defer sm.lock.Unlock()
-	defer sm.persist()
 
 	for _, upgrade := range upgrades {
 		if !slices.Contains(allowedInputStatuses, upgrade.Status) {
@@ -111,7 +198,9 @@ func (sm *StateMachine) UpdateStatus(currentHeight int64, upgrades map[int64]*ur
 		// if the upgrade is cancelled then there is nothing to do, we simply update the status
 		// NOTE: the upgrade.status is set by provider (eg. chain provider) and the state machine state cancelled is set by a human through rpc etc
 		if upgrade.Status == urproto.UpgradeStatus_CANCELLED || sm.state.UpgradeStatus[upgrade.Height] == urproto.UpgradeStatus_CANCELLED {
-			sm.state.UpgradeStatus[upgrade.Height] = urproto.UpgradeStatus_CANCELLED
+			if !slices.Contains(finalStatuses, sm.state.UpgradeStatus[upgrade.Height]) {
+				sm.state.UpgradeStatus[upgrade.Height] = urproto.UpgradeStatus_CANCELLED
+			}
 			continue
 		}
 
@@ -126,8 +215,9 @@ func (sm *StateMachine) UpdateStatus(currentHeight int64, upgrades map[int64]*ur
 				//
 				// We want to handle the case where the GOVERNANCE upgrade is not coming from the chain itself but from anoter provider.
 				// In this case, we want to mark the upgrade as ACTIVE as there is no onchain component (blazar is aware of) that manages the upgrade status.
+				// (unless the chain provider tracks the x/upgrade current plan, see upgrade-registry.provider.chain.current-plan)
 				if upgrade.Source != urproto.ProviderType_CHAIN {
-					if upgrade.Height > currentHeight {
+					if upgrade.Height > currentHeight || sm.syncFromGenesis {
 						sm.state.UpgradeStatus[upgrade.Height] = urproto.UpgradeStatus_ACTIVE
 					}
 				} else {
@@ -138,7 +228,7 @@ func (sm *StateMachine) UpdateStatus(currentHeight int64, upgrades map[int64]*ur
 		case urproto.UpgradeType_NON_GOVERNANCE_COORDINATED, urproto.UpgradeType_NON_GOVERNANCE_UNCOORDINATED:
 			// mark the upgrade as 'ready for exection' (active)
 			if !slices.Contains(statusManagedByStateMachine, sm.state.UpgradeStatus[upgrade.Height]) {
-				if upgrade.Height > currentHeight {
+				if upgrade.Height > currentHeight || sm.syncFromGenesis {
 					sm.state.UpgradeStatus[upgrade.Height] = urproto.UpgradeStatus_ACTIVE
 				}
 			}
@@ -158,10 +248,16 @@ func (sm *StateMachine) UpdateStatus(currentHeight int64, upgrades map[int64]*ur
 		status := sm.state.UpgradeStatus[upgrade.Height]
 
 		// handle expired upgrades
-		isPastUpgrade := upgrade.Height < currentHeight
+		// while syncing from genesis, the chain provider may learn about the upgrade after the node passed its height
+		isPastUpgrade := upgrade.Height < currentHeight && !sm.syncFromGenesis
 		if isPastUpgrade && status != urproto.UpgradeStatus_CANCELLED && !slices.Contains(statusManagedByStateMachine, status) {
 			sm.state.UpgradeStatus[upgrade.Height] = urproto.UpgradeStatus_EXPIRED
 		}
+
+		// the upgrades expired before the node was resynced from genesis are ahead of the node again
+		if sm.syncFromGenesis && status == urproto.UpgradeStatus_EXPIRED && upgrade.Height >= currentHeight {
+			sm.state.UpgradeStatus[upgrade.Height] = urproto.UpgradeStatus_ACTIVE
+		}
 	}
 }
 
@@ -172,17 +268,17 @@ func (sm *StateMachine) MustSetStatus(height int64, status urproto.UpgradeStatus
 }
 
 func (sm *StateMachine) SetStatus(height int64, status urproto.UpgradeStatus) error {
+	defer sm.persist()
 	sm.lock.Lock()
 	defer sm.lock.Unlock()
-	defer sm.persist()
 
 	return sm.setStatus(height, status, false)
 }
 
 func (sm *StateMachine) SetStep(height int64, step urproto.UpgradeStep) {
+	defer sm.persist()
 	sm.lock.Lock()
 	defer sm.lock.Unlock()
-	defer sm.persist()
 
 	sm.state.UpgradeStep[height] = step
 }
@@ -194,9 +290,9 @@ func (sm *StateMachine) MustSetStatusAndStep(height int64, status urproto.Upgrad
 }
 
 func (sm *StateMachine) SetStatusAndStep(height int64, status urproto.UpgradeStatus, step urproto.UpgradeStep) error {
+	defer sm.persist()
 	sm.lock.Lock()
 	defer sm.lock.Unlock()
-	defer sm.persist()
 
 	if err := sm.setStatus(height, status, false); err != nil {
 		return err
@@ -217,6 +313,22 @@ func (sm *StateMachine) GetStatus(height int64) urproto.UpgradeStatus {
 	return urproto.UpgradeStatus_UNKNOWN
 }
 
+// GetHeightsWithStatus returns the heights of all upgrades with the given status in ascending order
+func (sm *StateMachine) GetHeightsWithStatus(status urproto.UpgradeStatus) []int64 {
+	sm.lock.RLock()
+	defer sm.lock.RUnlock()
+
+	heights := make([]int64, 0)
+	for height, upgradeStatus := range sm.state.UpgradeStatus {
+		if upgradeStatus == status {
+			heights = append(heights, height)
+		}
+	}
+	slices.Sort(heights)
+
+	return heights
+}
+
 func (sm *StateMachine) GetStep(height int64) urproto.UpgradeStep {
 	sm.lock.RLock()
 	defer sm.lock.RUnlock()
@@ -229,9 +341,9 @@ func (sm *StateMachine) GetStep(height int64) urproto.UpgradeStep {
 }
 
 func (sm *StateMachine) SetPreCheckStatus(height int64, check checksproto.PreCheck, status checksproto.CheckStatus) {
+	defer sm.persist()
 	sm.lock.Lock()
 	defer sm.lock.Unlock()
-	defer sm.persist()
 
 	if _, ok := sm.state.PreCheckStatus[height]; !ok {
 		sm.state.PreCheckStatus[height] = make(map[checksproto.PreCheck]checksproto.CheckStatus)
@@ -240,9 +352,9 @@ func (sm *StateMachine) SetPreCheckStatus(height int64, check checksproto.PreChe
 }
 
 func (sm *StateMachine) SetPostCheckStatus(height int64, check checksproto.PostCheck, status checksproto.CheckStatus) {
+	defer sm.persist()
 	sm.lock.Lock()
 	defer sm.lock.Unlock()
-	defer sm.persist()
 
 	if _, ok := sm.state.PostCheckStatus[height]; !ok {
 		sm.state.PostCheckStatus[height] = make(map[checksproto.PostCheck]checksproto.CheckStatus)
@@ -272,6 +384,169 @@ func (sm *StateMachine) GetPostCheckStatus(height int64, check checksproto.PostC
 	return checksproto.CheckStatus_PENDING
 }
 
+func (sm *StateMachine) SetPreviousVersions(height int64, versions map[string]string) {
+	defer sm.persist()
+	sm.lock.Lock()
+	defer sm.lock.Unlock()
+
+	sm.state.PreviousVersions[height] = versions
+}
+
+func (sm *StateMachine) GetPreviousVersions(height int64) map[string]string {
+	sm.lock.RLock()
+	defer sm.lock.RUnlock()
+
+	return sm.state.PreviousVersions[height]
+}
+
+func (sm *StateMachine) SetUpgradeVersions(height int64, versions map[string]string) {
+	defer sm.persist()
+	sm.lock.Lock()
+	defer sm.lock.Unlock()
+
+	sm.state.UpgradeVersions[height] = versions
+}
+
+func (sm *StateMachine) GetUpgradeVersions(height int64) map[string]string {
+	sm.lock.RLock()
+	defer sm.lock.RUnlock()
+
+	return sm.state.UpgradeVersions[height]
+}
+
+func (sm *StateMachine) SetBackup(height int64, path string) {
+	defer sm.persist()
+	sm.lock.Lock()
+	defer sm.lock.Unlock()
+
+	sm.state.Backups[height] = path
+}
+
+func (sm *StateMachine) GetBackup(height int64) string {
+	sm.lock.RLock()
+	defer sm.lock.RUnlock()
+
+	return sm.state.Backups[height]
+}
+
+// SetImageDigest pins the digest of the upgrade image (or binary)
+func (sm *StateMachine) SetImageDigest(height int64, image, digest string) {
+	defer sm.persist()
+	sm.lock.Lock()
+	defer sm.lock.Unlock()
+
+	sm.state.PinnedImages[height] = image
+	sm.state.ImageDigests[height] = digest
+}
+
+// GetPinnedImage returns the upgrade image (or binary) the digest was pinned for
+func (sm *StateMachine) GetPinnedImage(height int64) string {
+	sm.lock.RLock()
+	defer sm.lock.RUnlock()
+
+	return sm.state.PinnedImages[height]
+}
+
+func (sm *StateMachine) GetImageDigest(height int64) string {
+	sm.lock.RLock()
+	defer sm.lock.RUnlock()
+
+	return sm.state.ImageDigests[height]
+}
+
+// SetBinaryVersionCheck records the tag and expected version the VERIFY_BINARY_VERSION check was run for
+func (sm *StateMachine) SetBinaryVersionCheck(height int64, checkedFor string) {
+	defer sm.persist()
+	sm.lock.Lock()
+	defer sm.lock.Unlock()
+
+	sm.state.BinaryVersionChecks[height] = checkedFor
+}
+
+func (sm *StateMachine) GetBinaryVersionCheck(height int64) string {
+	sm.lock.RLock()
+	defer sm.lock.RUnlock()
+
+	return sm.state.BinaryVersionChecks[height]
+}
+
+func (sm *StateMachine) SetAppVersion(height int64, version *AppVersion) {
+	defer sm.persist()
+	sm.lock.Lock()
+	defer sm.lock.Unlock()
+
+	sm.state.AppVersions[height] = version
+}
+
+func (sm *StateMachine) GetAppVersion(height int64) *AppVersion {
+	sm.lock.RLock()
+	defer sm.lock.RUnlock()
+
+	return sm.state.AppVersions[height]
+}
+
+func (sm *StateMachine) SetModuleVersionsBefore(height int64, versions map[string]uint64) {
+	defer sm.persist()
+	sm.lock.Lock()
+	defer sm.lock.Unlock()
+
+	if _, ok := sm.state.ModuleVersions[height]; !ok {
+		sm.state.ModuleVersions[height] = &ModuleVersions{}
+	}
+	sm.state.ModuleVersions[height].Before = versions
+}
+
+func (sm *StateMachine) SetModuleVersionsAfter(height int64, versions map[string]uint64) {
+	defer sm.persist()
+	sm.lock.Lock()
+	defer sm.lock.Unlock()
+
+	if _, ok := sm.state.ModuleVersions[height]; !ok {
+		sm.state.ModuleVersions[height] = &ModuleVersions{}
+	}
+	sm.state.ModuleVersions[height].After = versions
+}
+
+func (sm *StateMachine) GetModuleVersions(height int64) ModuleVersions {
+	sm.lock.RLock()
+	defer sm.lock.RUnlock()
+
+	if versions, ok := sm.state.ModuleVersions[height]; ok {
+		return *versions
+	}
+	return ModuleVersions{}
+}
+
+func (sm *StateMachine) SetNotificationThread(height int64, messageID string) {
+	defer sm.persist()
+	sm.lock.Lock()
+	defer sm.lock.Unlock()
+
+	sm.state.NotificationThreads[height] = messageID
+}
+
+func (sm *StateMachine) GetNotificationThreads() map[int64]string {
+	sm.lock.RLock()
+	defer sm.lock.RUnlock()
+
+	return maps.Clone(sm.state.NotificationThreads)
+}
+
+func (sm *StateMachine) SetUpgradeImage(height int64, image string) {
+	defer sm.persist()
+	sm.lock.Lock()
+	defer sm.lock.Unlock()
+
+	sm.state.UpgradeImages[height] = image
+}
+
+func (sm *StateMachine) GetUpgradeImages() map[int64]string {
+	sm.lock.RLock()
+	defer sm.lock.RUnlock()
+
+	return maps.Clone(sm.state.UpgradeImages)
+}
+
 func (sm *StateMachine) Restore(ctx context.Context) error {
 	if sm.storage == nil {
 		// if it wasn't configured then we don't need to restore the state
@@ -305,6 +580,37 @@ func (sm *StateMachine) Restore(ctx context.Context) error {
 		state.PostCheckStatus = make(map[int64]map[checksproto.PostCheck]checksproto.CheckStatus, 0)
 	}
 
+	if state.PreviousVersions == nil {
+		state.PreviousVersions = make(map[int64]map[string]string, 0)
+	}
+	if state.UpgradeVersions == nil {
+		state.UpgradeVersions = make(map[int64]map[string]string, 0)
+	}
+	if state.Backups == nil {
+		state.Backups = make(map[int64]string, 0)
+	}
+	if state.PinnedImages == nil {
+		state.PinnedImages = make(map[int64]string, 0)
+	}
+	if state.ImageDigests == nil {
+		state.ImageDigests = make(map[int64]string, 0)
+	}
+	if state.BinaryVersionChecks == nil {
+		state.BinaryVersionChecks = make(map[int64]string, 0)
+	}
+	if state.AppVersions == nil {
+		state.AppVersions = make(map[int64]*AppVersion, 0)
+	}
+	if state.ModuleVersions == nil {
+		state.ModuleVersions = make(map[int64]*ModuleVersions, 0)
+	}
+	if state.NotificationThreads == nil {
+		state.NotificationThreads = make(map[int64]string, 0)
+	}
+	if state.UpgradeImages == nil {
+		state.UpgradeImages = make(map[int64]string, 0)
+	}
+
 	sm.lock.Lock()
 	defer sm.lock.Unlock()
 	sm.state = state
@@ -314,9 +620,9 @@ func (sm *StateMachine) Restore(ctx context.Context) error {
 
 func (sm *StateMachine) setStatus(height int64, status urproto.UpgradeStatus, lock bool) error {
 	if lock {
+		defer sm.persist()
 		sm.lock.Lock()
 		defer sm.lock.Unlock()
-		defer sm.persist()
 	}
 
 	// we can't cancel the upgrade if it's already being executed, expired, failed etc
@@ -332,6 +638,7 @@ func (sm *StateMachine) setStatus(height int64, status urproto.UpgradeStatus, lo
 				urproto.UpgradeStatus_EXPIRED,
 				urproto.UpgradeStatus_COMPLETED,
 				urproto.UpgradeStatus_FAILED,
+				urproto.UpgradeStatus_ROLLED_BACK,
 			}, currentStatus) {
 				return fmt.Errorf("cannot cancel upgrade %d with status %s and step %s", height, currentStatus.String(), currentStep.String())
 			}
@@ -342,10 +649,12 @@ func (sm *StateMachine) setStatus(height int64, status urproto.UpgradeStatus, lo
 	if currentStatus, ok := sm.state.UpgradeStatus[height]; ok {
 		executingTransition := currentStatus == urproto.UpgradeStatus_EXECUTING && (status == urproto.UpgradeStatus_SCHEDULED || status == urproto.UpgradeStatus_ACTIVE)
 		completedTransition := currentStatus == urproto.UpgradeStatus_COMPLETED && status != urproto.UpgradeStatus_COMPLETED
-		failedTransition := currentStatus == urproto.UpgradeStatus_FAILED && status != urproto.UpgradeStatus_FAILED
+		// a failed upgrade can only be rolled back to the previous version
+		failedTransition := currentStatus == urproto.UpgradeStatus_FAILED && status != urproto.UpgradeStatus_FAILED && status != urproto.UpgradeStatus_ROLLED_BACK
 		cancelledTransition := currentStatus == urproto.UpgradeStatus_CANCELLED && status != urproto.UpgradeStatus_CANCELLED
+		rolledBackTransition := currentStatus == urproto.UpgradeStatus_ROLLED_BACK && status != urproto.UpgradeStatus_ROLLED_BACK
 
-		if executingTransition || completedTransition || failedTransition || cancelledTransition {
+		if executingTransition || completedTransition || failedTransition || cancelledTransition || rolledBackTransition {
 			return fmt.Errorf("staus transition from %s to %s is not allowed", currentStatus.String(), status.String())
 		}
 	}
@@ -354,10 +663,83 @@ func (sm *StateMachine) setStatus(height int64, status urproto.UpgradeStatus, lo
 	return nil
 }
 
+// SetPersistence sets the context the state is stored with and the function called when the state couldn't be stored
+func (sm *StateMachine) SetPersistence(ctx context.Context, onError func(error)) {
+	sm.lock.Lock()
+	defer sm.lock.Unlock()
+
+	sm.persistCtx = ctx
+	sm.onPersistError = onError
+}
+
+// SetSyncFromGenesis makes the state machine treat the past upgrades as the replay plan of a node syncing from genesis,
+// they are marked as ACTIVE instead of EXPIRED
+func (sm *StateMachine) SetSyncFromGenesis(enabled bool) {
+	sm.lock.Lock()
+	defer sm.lock.Unlock()
+
+	sm.syncFromGenesis = enabled
+}
+
+// Persist stores the current state and returns the error, if any
+func (sm *StateMachine) Persist(ctx context.Context) error {
+	return sm.store(ctx)
+}
+
+// persist stores the state after a change. The caller must not hold the lock, a slow storage would block all readers.
+// The in-memory state stays authoritative, the failure is only reported
 func (sm *StateMachine) persist() {
-	// TODO: For now we ignore writing to the storage errors because this is not a critical operation
-	// NOTE: The caller must hold the lock
-	if sm.storage != nil {
-		_ = sm.storage.StoreState(context.TODO(), sm.state)
+	sm.lock.RLock()
+	ctx, onPersistError := sm.persistCtx, sm.onPersistError
+	sm.lock.RUnlock()
+
+	if err := sm.store(ctx); err != nil && onPersistError != nil {
+		onPersistError(err)
+	}
+}
+
+func (sm *StateMachine) store(ctx context.Context) error {
+	if sm.storage == nil {
+		return nil
+	}
+
+	// the latest state is taken once the previous store is done, so an older state never overwrites a newer one
+	sm.persistLock.Lock()
+	defer sm.persistLock.Unlock()
+
+	var err error
+	for attempt := 1; attempt <= persistAttempts; attempt++ {
+		var state *State
+		if state, err = sm.snapshot(); err != nil {
+			return err
+		}
+		if err = sm.storage.StoreState(ctx, state); err == nil {
+			return nil
+		}
+		if attempt < persistAttempts {
+			select {
+			case <-ctx.Done():
+				return errors.Wrapf(ctx.Err(), "failed to store the state machine state")
+			case <-time.After(time.Duration(attempt) * persistRetryDelay):
+			}
+		}
+	}
+
+	return errors.Wrapf(err, "failed to store the state machine state after %d attempts", persistAttempts)
+}
+
+// snapshot returns a deep copy of the state, which can be stored without holding the lock
+func (sm *StateMachine) snapshot() (*State, error) {
+	sm.lock.RLock()
+	data, err := json.Marshal(sm.state)
+	sm.lock.RUnlock()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to copy the state machine state")
+	}
+
+	var state State
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, errors.Wrapf(err, "failed to copy the state machine state")
 	}
+	return &state, nil
 }
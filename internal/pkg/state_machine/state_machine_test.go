@@ -1,11 +1,14 @@
 package state_machine
 
 import (
+	"context"
 	"testing"
 
+	"blazar/internal/pkg/errors"
 	urproto "blazar/internal/pkg/proto/upgrades_registry"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // Asserts that the state machine panics when it receives an upgrade with an initial status that is not managed by the state machine
@@ -22,6 +25,8 @@ func TestStateMachineInitialUpgradeStates(t *testing.T) {
 		urproto.UpgradeStatus_COMPLETED: true,
 		urproto.UpgradeStatus_FAILED:    true,
 		urproto.UpgradeStatus_EXPIRED:   true,
+
+		urproto.UpgradeStatus_ROLLED_BACK: true,
 	} {
 		currentHeight := int64(100)
 		upgrades := []*urproto.Upgrade{
@@ -261,6 +266,35 @@ func TestStateMachineCancellation(t *testing.T) {
 	}
 }
 
+func TestStateMachineCancelledAfterExecution(t *testing.T) {
+	// the provider reports the upgrade as cancelled once it was executed, e.g. a cancel proposal passed after the upgrade
+	for _, status := range []urproto.UpgradeStatus{
+		urproto.UpgradeStatus_COMPLETED,
+		urproto.UpgradeStatus_FAILED,
+		urproto.UpgradeStatus_ROLLED_BACK,
+	} {
+		upgrades := map[int64]*urproto.Upgrade{
+			150: {
+				Height: 150,
+				Tag:    "v1.0.0",
+				Name:   "test upgrade",
+				Type:   urproto.UpgradeType_GOVERNANCE,
+				Status: urproto.UpgradeStatus_ACTIVE,
+				Source: urproto.ProviderType_CHAIN,
+			},
+		}
+
+		stateMachine := NewStateMachine(nil)
+		stateMachine.UpdateStatus(100, upgrades)
+		stateMachine.state.UpgradeStatus[150] = status
+
+		upgrades[150].Status = urproto.UpgradeStatus_CANCELLED
+		stateMachine.UpdateStatus(200, upgrades)
+
+		assert.Equal(t, status, stateMachine.GetStatus(150))
+	}
+}
+
 // Asserts clearly invalid upgrade state transitions are not allowed
 func TestStateMachineInvalidStateTransitions(t *testing.T) {
 	type testType struct {
@@ -297,6 +331,17 @@ func TestStateMachineInvalidStateTransitions(t *testing.T) {
 		{urproto.UpgradeStatus_FAILED, urproto.UpgradeStatus_CANCELLED, true},
 		{urproto.UpgradeStatus_FAILED, urproto.UpgradeStatus_EXPIRED, true},
 
+		// except for the rollback of the failed upgrade
+		{urproto.UpgradeStatus_FAILED, urproto.UpgradeStatus_ROLLED_BACK, false},
+
+		// upgrade in rolled back state is final and can't be changed
+		{urproto.UpgradeStatus_ROLLED_BACK, urproto.UpgradeStatus_ACTIVE, true},
+		{urproto.UpgradeStatus_ROLLED_BACK, urproto.UpgradeStatus_EXECUTING, true},
+		{urproto.UpgradeStatus_ROLLED_BACK, urproto.UpgradeStatus_COMPLETED, true},
+		{urproto.UpgradeStatus_ROLLED_BACK, urproto.UpgradeStatus_FAILED, true},
+		{urproto.UpgradeStatus_ROLLED_BACK, urproto.UpgradeStatus_CANCELLED, true},
+		{urproto.UpgradeStatus_ROLLED_BACK, urproto.UpgradeStatus_ROLLED_BACK, false},
+
 		// upgrade in cancelled state is final and can't be changed
 		{urproto.UpgradeStatus_CANCELLED, urproto.UpgradeStatus_UNKNOWN, true},
 		{urproto.UpgradeStatus_CANCELLED, urproto.UpgradeStatus_SCHEDULED, true},
@@ -346,3 +391,152 @@ func TestStateMachineInvalidStateTransitions(t *testing.T) {
 		}
 	}
 }
+
+type flakyStorage struct {
+	failures int
+	stored   *State
+}
+
+func (s *flakyStorage) StoreState(_ context.Context, state *State) error {
+	if s.failures > 0 {
+		s.failures--
+		return errors.New("disk full")
+	}
+	s.stored = state
+	return nil
+}
+
+func (s *flakyStorage) RestoreState(context.Context) (*State, error) {
+	return s.stored, nil
+}
+
+func TestStateMachinePersistErrors(t *testing.T) {
+	storage := &flakyStorage{failures: persistAttempts - 1}
+	sm := NewStateMachine(storage)
+
+	var persistErrs []error
+	sm.SetPersistence(context.Background(), func(err error) {
+		persistErrs = append(persistErrs, err)
+	})
+
+	// the failed attempts are retried
+	sm.MustSetStatus(100, urproto.UpgradeStatus_ACTIVE)
+	assert.Empty(t, persistErrs)
+	require.NotNil(t, storage.stored)
+
+	// the error is reported once all attempts failed, the in-memory state is still updated
+	storage.failures = persistAttempts
+	sm.MustSetStatus(100, urproto.UpgradeStatus_EXECUTING)
+	require.Len(t, persistErrs, 1)
+	require.ErrorContains(t, persistErrs[0], "disk full")
+	assert.Equal(t, urproto.UpgradeStatus_EXECUTING, sm.GetStatus(100))
+
+	storage.failures = persistAttempts
+	require.ErrorContains(t, sm.Persist(context.Background()), "failed to store the state machine state after 3 attempts")
+	require.NoError(t, sm.Persist(context.Background()))
+}
+
+// blockingStorage blocks every store until it is released
+type blockingStorage struct {
+	storing chan struct{}
+	release chan struct{}
+}
+
+func (s *blockingStorage) StoreState(ctx context.Context, _ *State) error {
+	s.storing <- struct{}{}
+	select {
+	case <-s.release:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *blockingStorage) RestoreState(context.Context) (*State, error) {
+	return nil, nil
+}
+
+func TestStateMachinePersistOutsideLock(t *testing.T) {
+	storage := &blockingStorage{storing: make(chan struct{}, 1), release: make(chan struct{})}
+	sm := NewStateMachine(storage)
+
+	done := make(chan struct{})
+	go func() {
+		sm.MustSetStatus(100, urproto.UpgradeStatus_ACTIVE)
+		close(done)
+	}()
+	<-storage.storing
+
+	// the readers are not blocked by the slow storage
+	assert.Equal(t, urproto.UpgradeStatus_ACTIVE, sm.GetStatus(100))
+
+	close(storage.release)
+	<-done
+}
+
+func TestStateMachinePersistCancelled(t *testing.T) {
+	storage := &flakyStorage{failures: persistAttempts}
+	sm := NewStateMachine(storage)
+
+	// the retries stop once the context is cancelled
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.ErrorIs(t, sm.Persist(ctx), context.Canceled)
+	assert.Equal(t, persistAttempts-1, storage.failures)
+}
+
+func TestStateMachineResumeState(t *testing.T) {
+	storage := &flakyStorage{}
+	sm := NewStateMachine(storage)
+
+	sm.MustSetStatusAndStep(300, urproto.UpgradeStatus_EXECUTING, urproto.UpgradeStep_POST_UPGRADE_CHECK)
+	sm.MustSetStatusAndStep(100, urproto.UpgradeStatus_EXECUTING, urproto.UpgradeStep_COMPOSE_FILE_UPGRADE)
+	sm.MustSetStatus(200, urproto.UpgradeStatus_COMPLETED)
+	sm.SetNotificationThread(100, "1700000000.000100")
+
+	// the executing upgrades and their threads survive a restart
+	restored := NewStateMachine(storage)
+	require.NoError(t, restored.Restore(context.Background()))
+
+	assert.Equal(t, []int64{100, 300}, restored.GetHeightsWithStatus(urproto.UpgradeStatus_EXECUTING))
+	assert.Equal(t, []int64{200}, restored.GetHeightsWithStatus(urproto.UpgradeStatus_COMPLETED))
+	assert.Empty(t, restored.GetHeightsWithStatus(urproto.UpgradeStatus_FAILED))
+	assert.Equal(t, map[int64]string{100: "1700000000.000100"}, restored.GetNotificationThreads())
+}
+
+// Asserts that the past upgrades are replayed instead of expired, when the node syncs from genesis
+func TestStateMachineSyncFromGenesis(t *testing.T) {
+	upgradesMap := map[int64]*urproto.Upgrade{
+		50:  {Height: 50, Tag: "v1.0.0", Type: urproto.UpgradeType_GOVERNANCE, Source: urproto.ProviderType_DATABASE, Status: urproto.UpgradeStatus_UNKNOWN},
+		60:  {Height: 60, Tag: "v1.1.0", Type: urproto.UpgradeType_NON_GOVERNANCE_COORDINATED, Source: urproto.ProviderType_LOCAL, Status: urproto.UpgradeStatus_UNKNOWN},
+		70:  {Height: 70, Tag: "v2.0.0", Type: urproto.UpgradeType_GOVERNANCE, Source: urproto.ProviderType_CHAIN, Status: urproto.UpgradeStatus_ACTIVE},
+		300: {Height: 300, Tag: "v3.0.0", Type: urproto.UpgradeType_GOVERNANCE, Source: urproto.ProviderType_DATABASE, Status: urproto.UpgradeStatus_CANCELLED},
+	}
+
+	stateMachine := NewStateMachine(nil)
+	stateMachine.UpdateStatus(100, upgradesMap)
+	for _, height := range []int64{50, 60, 70} {
+		assert.Equal(t, urproto.UpgradeStatus_EXPIRED, stateMachine.GetStatus(height))
+	}
+
+	// the node was resynced from genesis, the expired upgrades are ahead of it again
+	stateMachine.SetSyncFromGenesis(true)
+	stateMachine.UpdateStatus(10, upgradesMap)
+	for _, height := range []int64{50, 60, 70} {
+		assert.Equal(t, urproto.UpgradeStatus_ACTIVE, stateMachine.GetStatus(height))
+	}
+	assert.Equal(t, urproto.UpgradeStatus_CANCELLED, stateMachine.GetStatus(300))
+
+	// the upgrades the chain provider found out about late are not expired
+	stateMachine = NewStateMachine(nil)
+	stateMachine.SetSyncFromGenesis(true)
+	stateMachine.UpdateStatus(100, upgradesMap)
+	for _, height := range []int64{50, 60, 70} {
+		assert.Equal(t, urproto.UpgradeStatus_ACTIVE, stateMachine.GetStatus(height))
+	}
+
+	// the executed upgrades are left alone
+	stateMachine.MustSetStatus(50, urproto.UpgradeStatus_COMPLETED)
+	stateMachine.UpdateStatus(100, upgradesMap)
+	assert.Equal(t, urproto.UpgradeStatus_COMPLETED, stateMachine.GetStatus(50))
+}
@@ -0,0 +1,350 @@
+package systemd
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"blazar/internal/pkg/cmd"
+	"blazar/internal/pkg/config"
+	"blazar/internal/pkg/errors"
+	"blazar/internal/pkg/log"
+	urproto "blazar/internal/pkg/proto/upgrades_registry"
+)
+
+var (
+	ErrUnitRunning    = errors.New("unit running")
+	ErrUnitNotRunning = errors.New("unit not running")
+
+	ErrMultipleServices = errors.New("systemd executor doesn't support upgrading multiple services at once")
+)
+
+// Client manages a chain node running as a systemd unit. The upgrade is performed by swapping
+// a symlink (current-link) to a versioned binary stored in a Cosmovisor-like layout:
+//
+//	<binaries-dir>/<upgrade tag>/bin/<binary-name>
+type Client struct {
+	unit        string
+	binariesDir string
+	binaryName  string
+	currentLink string
+	timeout     time.Duration
+}
+
+func NewClient(cfg *config.Systemd) *Client {
+	return &Client{
+		unit:        cfg.Unit,
+		binariesDir: cfg.BinariesDir,
+		binaryName:  cfg.BinaryName,
+		currentLink: cfg.CurrentLink,
+		timeout:     cfg.Timeout,
+	}
+}
+
+// BinaryPath returns the path of the binary corresponding to the upgrade tag
+func (sc *Client) BinaryPath(tag string) string {
+	return filepath.Join(sc.binariesDir, tag, "bin", sc.binaryName)
+}
+
+// GetCurrentBinary returns the binary the current-link points to
+func (sc *Client) GetCurrentBinary() (string, error) {
+	target, err := os.Readlink(sc.currentLink)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to read symlink %s", sc.currentLink)
+	}
+	return target, nil
+}
+
+// GetCurrentVersion returns the upgrade tag of the binary the current-link points to
+func (sc *Client) GetCurrentVersion(_ string) (string, error) {
+	currBinary, err := sc.GetCurrentBinary()
+	if err != nil {
+		return "", err
+	}
+
+	rel, err := filepath.Rel(sc.binariesDir, currBinary)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return "", fmt.Errorf("binary %s is not located in %s", currBinary, sc.binariesDir)
+	}
+
+	return strings.SplitN(rel, string(filepath.Separator), 2)[0], nil
+}
+
+// PrepareUpgrade ensures the binary for the upgrade tag is present on the host. There is nothing
+// to pull in this case, the binary has to be placed in the binaries-dir by the operator.
+//
+// return current binary, upgrade binary, error
+func (sc *Client) PrepareUpgrade(_ context.Context, _ string, upgrade *urproto.Upgrade, _ *config.PullDockerImage) (string, string, error) {
+	if upgrade.Tag == "" {
+		return "", "", fmt.Errorf("failed to check binary, upgrade tag is empty, for upgrade height: %d", upgrade.Height)
+	}
+
+	if len(upgrade.Services) > 0 {
+		return "", "", ErrMultipleServices
+	}
+
+	currBinary, err := sc.GetCurrentBinary()
+	if err != nil {
+		return "", "", err
+	}
+
+	newBinary := sc.BinaryPath(upgrade.Tag)
+	stat, err := os.Stat(newBinary)
+	if err != nil {
+		return "", "", errors.Wrapf(err, "new binary %s is not present on host", newBinary)
+	}
+
+	if stat.IsDir() || stat.Mode().Perm()&0111 == 0 {
+		return "", "", fmt.Errorf("new binary %s is not an executable file", newBinary)
+	}
+
+	return currBinary, newBinary, nil
+}
+
+// ArtifactDigest returns the sha256 checksum of the binary
+func (sc *Client) ArtifactDigest(_ context.Context, binary string) (string, error) {
+	f, err := os.Open(binary)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to open binary %s", binary)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", errors.Wrapf(err, "failed to read binary %s", binary)
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// BinaryVersion runs the version command of the upgrade binary, the app name is the binary itself
+func (sc *Client) BinaryVersion(ctx context.Context, binary, _ string, timeout time.Duration) (string, error) {
+	// cosmos-sdk binaries print the version to stderr or stdout depending on the sdk version
+	stdout, stderr, err := cmd.CheckOutputWithDeadline(ctx, timeout, []string{}, binary, "version", "--long")
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to run %s version: %s", binary, stderr.String())
+	}
+	return stdout.String() + stderr.String(), nil
+}
+
+// ValidateUpgradeImages ensures the binary for the new version is present
+func (sc *Client) ValidateUpgradeImages(_ context.Context, newVersions map[string]string) error {
+	newVersion, err := singleVersion(newVersions)
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(sc.BinaryPath(newVersion)); err != nil {
+		return errors.Wrapf(err, "binary %s not present on the system", sc.BinaryPath(newVersion))
+	}
+	return nil
+}
+
+// UpgradeImages swaps the binary of the unit, there is only one service managed by the systemd executor
+func (sc *Client) UpgradeImages(ctx context.Context, newVersions map[string]string) error {
+	newVersion, err := singleVersion(newVersions)
+	if err != nil {
+		return err
+	}
+
+	return sc.UpgradeImage(ctx, "", newVersion)
+}
+
+// UpgradeImage atomically points the current-link to the binary corresponding to the new version
+func (sc *Client) UpgradeImage(ctx context.Context, _, newVersion string) error {
+	newBinary := sc.BinaryPath(newVersion)
+	if _, err := os.Stat(newBinary); err != nil {
+		return errors.Wrapf(err, "binary %s not present on the system", newBinary)
+	}
+
+	if currBinary, err := sc.GetCurrentBinary(); err == nil {
+		if currBinary == newBinary {
+			log.FromContext(ctx).Warnf("binary %s already linked as %s", newBinary, sc.currentLink)
+			return nil
+		}
+		log.FromContext(ctx).Infof("Updating %s from %s to %s", sc.currentLink, currBinary, newBinary)
+	}
+
+	// create the new symlink next to the old one and rename it, so the link is never missing
+	tmpLink := fmt.Sprintf("%s.%d.blazar", sc.currentLink, time.Now().UnixNano())
+	if err := os.Symlink(newBinary, tmpLink); err != nil {
+		return errors.Wrapf(err, "failed to create symlink %s", tmpLink)
+	}
+
+	if err := os.Rename(tmpLink, sc.currentLink); err != nil {
+		_ = os.Remove(tmpLink)
+		return errors.Wrapf(err, "failed to replace symlink %s", sc.currentLink)
+	}
+
+	return nil
+}
+
+func (sc *Client) Down(ctx context.Context, serviceName string, timeout time.Duration) error {
+	// 5 seconds buffer to handle the case when systemd takes slightly longer than the timeout
+	deadline := timeout + 5*time.Second
+
+	err := cmd.ExecuteWithDeadlineAndLog(ctx, deadline, []string{}, "systemctl", "stop", sc.unit)
+	if err != nil {
+		return errors.Wrapf(err, "systemctl stop failed")
+	}
+
+	isRunning, err := sc.IsServiceRunning(ctx, serviceName, timeout)
+	if err != nil {
+		return errors.Wrapf(err, "check for unit running failed")
+	}
+	if isRunning {
+		return errors.Wrapf(ErrUnitRunning, "systemctl stop didn't stop the unit")
+	}
+
+	return nil
+}
+
+// DownServices stops the unit, there is only one service managed by the systemd executor
+func (sc *Client) DownServices(ctx context.Context, _ []string, timeout time.Duration) error {
+	return sc.Down(ctx, "", timeout)
+}
+
+// UpServices starts the unit, there is only one service managed by the systemd executor
+func (sc *Client) UpServices(ctx context.Context, _ []string, timeout time.Duration, ephemeralEnvVars ...string) error {
+	return sc.Up(ctx, "", timeout, ephemeralEnvVars...)
+}
+
+// Up starts the unit. The ephemeral env vars are exported to the systemd manager environment only
+// for the duration of the start, so they are not inherited by any subsequent restarts
+func (sc *Client) Up(ctx context.Context, serviceName string, timeout time.Duration, ephemeralEnvVars ...string) error {
+	isRunning, err := sc.IsServiceRunning(ctx, serviceName, timeout)
+	if err != nil {
+		return errors.Wrapf(err, "check for unit running failed")
+	}
+	if isRunning {
+		return errors.Wrapf(ErrUnitRunning, "expected the unit to be stopped before calling systemctl start")
+	}
+
+	if len(ephemeralEnvVars) > 0 {
+		args := append([]string{"set-environment"}, ephemeralEnvVars...)
+		if err := cmd.ExecuteWithDeadlineAndLog(ctx, sc.timeout, []string{}, "systemctl", args...); err != nil {
+			return errors.Wrapf(err, "systemctl set-environment failed")
+		}
+
+		defer func() {
+			names := []string{"unset-environment"}
+			for _, envVar := range ephemeralEnvVars {
+				names = append(names, strings.SplitN(envVar, "=", 2)[0])
+			}
+			if err := cmd.ExecuteWithDeadlineAndLog(ctx, sc.timeout, []string{}, "systemctl", names...); err != nil {
+				log.FromContext(ctx).Err(err).Warn("Failed to unset ephemeral environment variables in the systemd manager")
+			}
+		}()
+	}
+
+	err = cmd.ExecuteWithDeadlineAndLog(ctx, timeout, []string{}, "systemctl", "start", sc.unit)
+	if err != nil {
+		return errors.Wrapf(err, "systemctl start failed")
+	}
+
+	isRunning, err = sc.IsServiceRunning(ctx, serviceName, timeout)
+	if err != nil {
+		return errors.Wrapf(err, "check for unit running failed")
+	}
+	if !isRunning {
+		return errors.Wrapf(ErrUnitNotRunning, "systemctl start didn't start the unit")
+	}
+
+	return nil
+}
+
+func (sc *Client) RestartServiceWithHaltHeight(ctx context.Context, composeConfig *config.ComposeCli, serviceName string, upgradeHeight int64) error {
+	isRunning, err := sc.IsServiceRunning(ctx, serviceName, composeConfig.DownTimeout)
+	if err != nil {
+		return errors.Wrapf(err, "check for unit running failed")
+	}
+	if !isRunning {
+		return errors.Wrapf(ErrUnitNotRunning, "expected the unit to run before restarting with halt height")
+	}
+
+	err = sc.Down(ctx, serviceName, composeConfig.DownTimeout)
+	if err != nil && !errors.Is(err, ErrUnitNotRunning) {
+		return errors.Wrapf(err, "systemctl stop failed")
+	}
+
+	// there is no compose file to map HALT_HEIGHT to the prefixed variable, so we set the prefixed one directly
+	return sc.Up(ctx, serviceName, composeConfig.UpDeadline, fmt.Sprintf("%sHALT_HEIGHT=%d", composeConfig.EnvPrefix, upgradeHeight))
+}
+
+func (sc *Client) IsServiceRunning(ctx context.Context, _ string, timeout time.Duration) (bool, error) {
+	// +1s to give some wiggle room for the systemctl cli to respond
+	stdout, stderr, err := cmd.CheckOutputWithDeadline(ctx, timeout+time.Second, []string{}, "systemctl", "show", "--property=ActiveState", "--value", sc.unit)
+	if err != nil {
+		return false, errors.Wrapf(err, "systemctl show failed: %s", stderr.String())
+	}
+
+	// we treat transitional states the same way as the compose client treats restarting/removing containers
+	state := strings.TrimSpace(stdout.String())
+	return slices.Contains([]string{"active", "activating", "deactivating", "reloading"}, state), nil
+}
+
+// FollowLogs streams the journal of the current unit invocation, from its start, until the returned reader is closed
+func (sc *Client) FollowLogs(ctx context.Context, _ string) (io.ReadCloser, error) {
+	stdout, stderr, err := cmd.CheckOutputWithDeadline(ctx, sc.timeout, []string{}, "systemctl", "show", "--property=InvocationID", "--value", sc.unit)
+	if err != nil {
+		return nil, errors.Wrapf(err, "systemctl show failed: %s", stderr.String())
+	}
+
+	invocationID := strings.TrimSpace(stdout.String())
+	if invocationID == "" {
+		return nil, errors.Wrapf(ErrUnitNotRunning, "no invocation found for unit %s", sc.unit)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	// --follow shows only the last 10 lines by default, we want all lines logged since the unit started
+	journalctl := exec.CommandContext(ctx, "journalctl", "--follow", "--lines=all", "--output=cat", "--no-pager", "_SYSTEMD_INVOCATION_ID="+invocationID)
+
+	logs, err := journalctl.StdoutPipe()
+	if err != nil {
+		cancel()
+		return nil, errors.Wrapf(err, "failed to open journalctl output")
+	}
+	if err := journalctl.Start(); err != nil {
+		cancel()
+		return nil, errors.Wrapf(err, "failed to start journalctl")
+	}
+
+	return &journalLogs{ReadCloser: logs, cmd: journalctl, cancel: cancel}, nil
+}
+
+type journalLogs struct {
+	io.ReadCloser
+	cmd    *exec.Cmd
+	cancel context.CancelFunc
+}
+
+func (j *journalLogs) Close() error {
+	// journalctl never exits on its own with --follow, the error from the killed process is expected
+	j.cancel()
+	_ = j.cmd.Wait()
+	return nil
+}
+
+func singleVersion(newVersions map[string]string) (string, error) {
+	if len(newVersions) != 1 {
+		return "", ErrMultipleServices
+	}
+
+	for _, version := range newVersions {
+		return version, nil
+	}
+	return "", nil
+}
+
+func (sc *Client) Version(ctx context.Context) (string, error) {
+	stdout, _, err := cmd.CheckOutputWithDeadline(ctx, 2*time.Second, []string{}, "systemctl", "--version")
+
+	return strings.SplitN(stdout.String(), "\n", 2)[0], err
+}
@@ -0,0 +1,84 @@
+package systemd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"blazar/internal/pkg/config"
+	urproto "blazar/internal/pkg/proto/upgrades_registry"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrepareAndUpgradeBinary(t *testing.T) {
+	tempDir := t.TempDir()
+	binariesDir := filepath.Join(tempDir, "upgrades")
+
+	for _, tag := range []string{"v1.0.0", "v2.0.0"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(binariesDir, tag, "bin"), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(binariesDir, tag, "bin", "simd"), []byte("#!/bin/sh\n"), 0755))
+	}
+
+	// present but not executable
+	require.NoError(t, os.MkdirAll(filepath.Join(binariesDir, "v3.0.0", "bin"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(binariesDir, "v3.0.0", "bin", "simd"), []byte("#!/bin/sh\n"), 0644))
+
+	currentLink := filepath.Join(tempDir, "current")
+	require.NoError(t, os.Symlink(filepath.Join(binariesDir, "v1.0.0", "bin", "simd"), currentLink))
+
+	sc := NewClient(&config.Systemd{
+		Unit:        "simd.service",
+		BinariesDir: binariesDir,
+		BinaryName:  "simd",
+		CurrentLink: currentLink,
+		Timeout:     time.Second,
+	})
+	ctx := context.Background()
+
+	_, _, err := sc.PrepareUpgrade(ctx, "", &urproto.Upgrade{Height: 10, Tag: ""}, nil)
+	require.Error(t, err)
+
+	_, _, err = sc.PrepareUpgrade(ctx, "", &urproto.Upgrade{Height: 10, Tag: "v4.0.0"}, nil)
+	require.Error(t, err)
+
+	_, _, err = sc.PrepareUpgrade(ctx, "", &urproto.Upgrade{Height: 10, Tag: "v3.0.0"}, nil)
+	require.Error(t, err)
+
+	_, _, err = sc.PrepareUpgrade(ctx, "", &urproto.Upgrade{Height: 10, Tag: "v2.0.0", Services: map[string]string{"sidecar": "v2.0.0"}}, nil)
+	require.ErrorIs(t, err, ErrMultipleServices)
+
+	currBinary, newBinary, err := sc.PrepareUpgrade(ctx, "", &urproto.Upgrade{Height: 10, Tag: "v2.0.0"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(binariesDir, "v1.0.0", "bin", "simd"), currBinary)
+	assert.Equal(t, filepath.Join(binariesDir, "v2.0.0", "bin", "simd"), newBinary)
+
+	// sha256 of "#!/bin/sh\n"
+	digest, err := sc.ArtifactDigest(ctx, newBinary)
+	require.NoError(t, err)
+	assert.Equal(t, "sha256:a8076d3d28d21e02012b20eaf7dbf75409a6277134439025f282e368e3305abf", digest)
+
+	version, err := sc.GetCurrentVersion("")
+	require.NoError(t, err)
+	assert.Equal(t, "v1.0.0", version)
+
+	require.Error(t, sc.ValidateUpgradeImages(ctx, map[string]string{"simd": "v4.0.0"}))
+	require.NoError(t, sc.ValidateUpgradeImages(ctx, map[string]string{"simd": "v2.0.0"}))
+	require.NoError(t, sc.UpgradeImages(ctx, map[string]string{"simd": "v2.0.0"}))
+
+	version, err = sc.GetCurrentVersion("")
+	require.NoError(t, err)
+	assert.Equal(t, "v2.0.0", version)
+
+	target, err := os.Readlink(currentLink)
+	require.NoError(t, err)
+	assert.Equal(t, newBinary, target)
+
+	// no temporary links should be left behind
+	entries, err := os.ReadDir(tempDir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+}
@@ -0,0 +1,115 @@
+package upgrades_registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"blazar/internal/pkg/atomicfile"
+	"blazar/internal/pkg/errors"
+	"blazar/internal/pkg/log"
+	urproto "blazar/internal/pkg/proto/upgrades_registry"
+	vrproto "blazar/internal/pkg/proto/version_resolver"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// registryCache is the on-disk copy of the provider snapshots, it lets blazar start while a provider is unreachable
+type registryCache struct {
+	Network  string                                      `json:"network"`
+	SavedAt  time.Time                                   `json:"saved_at"`
+	Upgrades map[string]cachedSnapshot[*urproto.Upgrade] `json:"upgrades"`
+	Versions map[string]cachedSnapshot[*vrproto.Version] `json:"versions"`
+}
+
+type cachedSnapshot[T proto.Message] struct {
+	Items           []T       `json:"items"`
+	LastSuccessTime time.Time `json:"last_success_time"`
+}
+
+// loadCache seeds the provider snapshots with the cached ones, so the registry can fall back to them on the first sync.
+// A missing cache file is not an error
+func (ur *UpgradeRegistry) loadCache(path string) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "could not read %s registry cache file", path)
+	}
+
+	var cache registryCache
+	if err := json.Unmarshal(data, &cache); err != nil {
+		return errors.Wrapf(err, "could not unmarshal %s registry cache file", path)
+	}
+
+	if cache.Network != ur.network {
+		return fmt.Errorf("registry cache network %s does not match %s", cache.Network, ur.network)
+	}
+
+	ur.lock.Lock()
+	defer ur.lock.Unlock()
+
+	// the cache may contain providers that were removed from the config since
+	for providerName, snapshot := range cache.Upgrades {
+		providerType := urproto.ProviderType(urproto.ProviderType_value[providerName])
+		if _, ok := ur.providers[providerType]; ok {
+			ur.upgradeSnapshots[providerType] = &providerSnapshot[*urproto.Upgrade]{
+				items: snapshot.Items, lastSuccessTime: snapshot.LastSuccessTime, cached: true,
+			}
+		}
+	}
+	for providerName, snapshot := range cache.Versions {
+		providerType := urproto.ProviderType(urproto.ProviderType_value[providerName])
+		if _, ok := ur.providers[providerType]; ok {
+			ur.versionSnapshots[providerType] = &providerSnapshot[*vrproto.Version]{
+				items: snapshot.Items, lastSuccessTime: snapshot.LastSuccessTime, cached: true,
+			}
+		}
+	}
+
+	return nil
+}
+
+// saveCache writes the provider snapshots to the cache file
+func (ur *UpgradeRegistry) saveCache() error {
+	ur.lock.RLock()
+	cache := registryCache{
+		Network:  ur.network,
+		SavedAt:  time.Now(),
+		Upgrades: make(map[string]cachedSnapshot[*urproto.Upgrade], len(ur.upgradeSnapshots)),
+		Versions: make(map[string]cachedSnapshot[*vrproto.Version], len(ur.versionSnapshots)),
+	}
+	for providerType, snapshot := range ur.upgradeSnapshots {
+		cache.Upgrades[providerType.String()] = cachedSnapshot[*urproto.Upgrade]{Items: snapshot.items, LastSuccessTime: snapshot.lastSuccessTime}
+	}
+	for providerType, snapshot := range ur.versionSnapshots {
+		cache.Versions[providerType.String()] = cachedSnapshot[*vrproto.Version]{Items: snapshot.items, LastSuccessTime: snapshot.lastSuccessTime}
+	}
+	// the snapshot items are never modified in place, it is safe to marshal them outside of the lock
+	ur.lock.RUnlock()
+
+	data, err := json.Marshal(&cache)
+	if err != nil {
+		return errors.Wrapf(err, "could not marshal registry cache")
+	}
+
+	if err := atomicfile.WriteFile(ur.cachePath, data, 0600); err != nil {
+		return errors.Wrapf(err, "could not write %s registry cache file", ur.cachePath)
+	}
+
+	return nil
+}
+
+// persist saves the cache if it is enabled, a failure is logged as the in-memory registry is still up to date
+func (ur *UpgradeRegistry) persist(ctx context.Context) {
+	if ur.cachePath == "" {
+		return
+	}
+
+	if err := ur.saveCache(); err != nil {
+		log.FromContext(ctx).Err(err).Warnf("Failed to persist the upgrade registry to %s", ur.cachePath)
+	}
+}
@@ -0,0 +1,120 @@
+package upgrades_registry
+
+import (
+	"context"
+	"time"
+
+	"blazar/internal/pkg/errors"
+	"blazar/internal/pkg/log"
+	urproto "blazar/internal/pkg/proto/upgrades_registry"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// ProviderSyncInfo describes the last syncs of a single provider
+type ProviderSyncInfo struct {
+	// last time the provider returned its upgrades (and versions, for version resolvers) successfully
+	LastSuccessTime time.Time
+	// error of the last sync, nil if it succeeded
+	LastError error
+	// number of failed syncs in a row
+	ConsecutiveErrors int
+}
+
+// Staleness returns the age of the data served for the provider
+func (psi ProviderSyncInfo) Staleness() time.Duration {
+	return time.Since(psi.LastSuccessTime)
+}
+
+// providerSnapshot is the last-known-good result of a provider, served while the provider fails to sync
+type providerSnapshot[T proto.Message] struct {
+	items             []T
+	lastSuccessTime   time.Time
+	lastError         error
+	consecutiveErrors int
+
+	// restored from the registry cache, the provider didn't sync successfully since blazar started
+	cached bool
+}
+
+// mergeWithSnapshots returns the results of all providers, where the results of the failed providers are replaced with
+// their last-known-good snapshots. It fails only if a provider failed and there is no snapshot to fall back to.
+//
+// The snapshots are updated only if commit is true, the caller must hold the registry lock in that case.
+func mergeWithSnapshots[T proto.Message](
+	ctx context.Context,
+	kind string,
+	providers []urproto.ProviderType,
+	results [][]T,
+	errs []error,
+	snapshots map[urproto.ProviderType]*providerSnapshot[T],
+	commit bool,
+) ([]T, error) {
+	now := time.Now()
+	merged := make([]T, 0)
+
+	for i, providerType := range providers {
+		snapshot, hasSnapshot := snapshots[providerType]
+
+		if errs[i] == nil {
+			merged = append(merged, results[i]...)
+			if commit {
+				snapshots[providerType] = &providerSnapshot[T]{items: cloneAll(results[i]), lastSuccessTime: now}
+			}
+			continue
+		}
+
+		if !hasSnapshot {
+			return nil, errs[i]
+		}
+
+		if snapshot.cached && snapshot.consecutiveErrors == 0 {
+			log.FromContext(ctx).Errorf(errs[i],
+				"%s provider is unreachable, using its %s restored from the registry cache, last synced at %s. "+
+					"Verify the cached %s are still valid!", providerType, kind,
+				snapshot.lastSuccessTime.UTC().Format(time.RFC3339), kind,
+			)
+		} else {
+			log.FromContext(ctx).Err(errs[i]).Warnf(
+				"%s provider failed to fetch %s, using the last known %s from %s", providerType, kind, kind,
+				snapshot.lastSuccessTime.UTC().Format(time.RFC3339),
+			)
+		}
+		merged = append(merged, cloneAll(snapshot.items)...)
+
+		if commit {
+			snapshot.lastError = errs[i]
+			snapshot.consecutiveErrors++
+		}
+	}
+
+	return merged, nil
+}
+
+// providerSyncInfo combines the upgrades and versions snapshots of the provider, the older success time wins
+func providerSyncInfo[U, V proto.Message](upgrades *providerSnapshot[U], versions *providerSnapshot[V]) ProviderSyncInfo {
+	info := ProviderSyncInfo{}
+	if upgrades != nil {
+		info.LastSuccessTime = upgrades.lastSuccessTime
+		info.LastError = upgrades.lastError
+		info.ConsecutiveErrors = upgrades.consecutiveErrors
+	}
+
+	if versions != nil {
+		if upgrades == nil || versions.lastSuccessTime.Before(info.LastSuccessTime) {
+			info.LastSuccessTime = versions.lastSuccessTime
+		}
+		info.LastError = errors.Join(info.LastError, versions.lastError)
+		info.ConsecutiveErrors = max(info.ConsecutiveErrors, versions.consecutiveErrors)
+	}
+
+	return info
+}
+
+func cloneAll[T proto.Message](items []T) []T {
+	cloned := make([]T, len(items))
+	for n, item := range items {
+		cloned[n] = proto.Clone(item).(T)
+	}
+	return cloned
+}
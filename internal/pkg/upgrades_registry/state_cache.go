@@ -0,0 +1,72 @@
+package upgrades_registry
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+
+	"blazar/internal/pkg/atomicfile"
+	"blazar/internal/pkg/errors"
+	"blazar/internal/pkg/log"
+	sm "blazar/internal/pkg/state_machine"
+)
+
+// cachedStateStorage mirrors the state stored in a remote storage (the database) to a local file, the state is restored
+// from the file if the remote storage is unreachable when blazar starts
+type cachedStateStorage struct {
+	sm.StateMachineStorage
+	path string
+
+	// error of the storage the state was restored from the local copy after, nil if the storage was reachable
+	restoreErr error
+}
+
+// StateCachePath returns the path of the local copy of the state machine state, next to the registry cache
+func StateCachePath(cachePath string) string {
+	return cachePath + ".state"
+}
+
+func newCachedStateStorage(storage sm.StateMachineStorage, path string) *cachedStateStorage {
+	return &cachedStateStorage{StateMachineStorage: storage, path: path}
+}
+
+// StoreState stores the state in the remote storage, the local copy is written even if the remote storage failed
+func (s *cachedStateStorage) StoreState(ctx context.Context, state *sm.State) error {
+	err := s.StateMachineStorage.StoreState(ctx, state)
+
+	data, marshalErr := json.Marshal(state)
+	if marshalErr != nil {
+		return errors.Join(err, errors.Wrapf(marshalErr, "could not marshal state machine state"))
+	}
+	if writeErr := atomicfile.WriteFile(s.path, data, 0600); writeErr != nil {
+		return errors.Join(err, errors.Wrapf(writeErr, "could not write %s state cache file", s.path))
+	}
+
+	return err
+}
+
+// RestoreState restores the state from the remote storage, or from the local copy if the remote storage is unreachable
+func (s *cachedStateStorage) RestoreState(ctx context.Context) (*sm.State, error) {
+	state, err := s.StateMachineStorage.RestoreState(ctx)
+	if err == nil {
+		return state, nil
+	}
+
+	data, readErr := os.ReadFile(s.path)
+	if readErr != nil {
+		if os.IsNotExist(readErr) {
+			return nil, errors.Wrapf(err, "no local copy of the state in %s to fall back to", s.path)
+		}
+		return nil, errors.Join(err, errors.Wrapf(readErr, "could not read %s state cache file", s.path))
+	}
+
+	var cached sm.State
+	if unmarshalErr := json.Unmarshal(data, &cached); unmarshalErr != nil {
+		return nil, errors.Join(err, errors.Wrapf(unmarshalErr, "could not unmarshal %s state cache file", s.path))
+	}
+
+	log.FromContext(ctx).Err(err).Warnf("Failed to restore the state machine state from the storage, falling back to the local copy in %s", s.path)
+	s.restoreErr = err
+
+	return &cached, nil
+}
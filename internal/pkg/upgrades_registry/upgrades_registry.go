@@ -3,6 +3,7 @@ package upgrades_registry
 import (
 	"context"
 	"fmt"
+	"maps"
 	"slices"
 	"sort"
 	"sync"
@@ -10,13 +11,18 @@ import (
 
 	"blazar/internal/pkg/config"
 	"blazar/internal/pkg/cosmos"
+	"blazar/internal/pkg/daemon/util"
+	"blazar/internal/pkg/docker"
 	"blazar/internal/pkg/errors"
+	"blazar/internal/pkg/log"
 	urproto "blazar/internal/pkg/proto/upgrades_registry"
 	vrproto "blazar/internal/pkg/proto/version_resolver"
 	"blazar/internal/pkg/provider"
 	"blazar/internal/pkg/provider/chain"
+	"blazar/internal/pkg/provider/chain_registry"
 	"blazar/internal/pkg/provider/database"
 	"blazar/internal/pkg/provider/local"
+	"blazar/internal/pkg/provider/registry"
 	"blazar/internal/pkg/state_machine"
 
 	"golang.org/x/sync/errgroup"
@@ -26,6 +32,9 @@ import (
 type SyncInfo struct {
 	LastBlockHeight int64
 	LastUpdateTime  time.Time
+
+	// sync state of every provider, the registry keeps serving the last known data of the failing ones
+	Providers map[urproto.ProviderType]ProviderSyncInfo
 }
 
 type UpgradeRegistry struct {
@@ -53,9 +62,19 @@ type UpgradeRegistry struct {
 	// a list of versions that were overridden by another version with the same height and higher priority
 	overriddenVersions map[int64][]*vrproto.Version
 
+	// last-known-good upgrades and versions of every provider
+	upgradeSnapshots map[urproto.ProviderType]*providerSnapshot[*urproto.Upgrade]
+	versionSnapshots map[urproto.ProviderType]*providerSnapshot[*vrproto.Version]
+
 	// information about the last sync
 	syncInfo SyncInfo
 
+	// error of the state machine storage, if the state was restored from its local copy
+	stateRestoreErr error
+
+	// file the snapshots are persisted to after every sync, empty if disabled
+	cachePath string
+
 	// network for which the registry is created
 	network string
 }
@@ -69,6 +88,8 @@ func NewUpgradeRegistry(providers map[urproto.ProviderType]provider.UpgradeProvi
 		versions:           make(map[int64]*vrproto.Version, 0),
 		overriddenUpgrades: make(map[int64][]*urproto.Upgrade),
 		overriddenVersions: make(map[int64][]*vrproto.Version),
+		upgradeSnapshots:   make(map[urproto.ProviderType]*providerSnapshot[*urproto.Upgrade]),
+		versionSnapshots:   make(map[urproto.ProviderType]*providerSnapshot[*vrproto.Version]),
 		stateMachine:       stateMachine,
 		syncInfo:           SyncInfo{},
 		network:            network,
@@ -86,11 +107,18 @@ func NewUpgradesRegistryFromConfig(cfg *config.Config) (*UpgradeRegistry, error)
 			return nil, errors.Wrapf(err, "failed to create cosmos client")
 		}
 
-		if err := cosmosClient.StartCometbftClient(); err != nil {
-			return nil, errors.Wrapf(err, "failed to start cometbft client")
+		planInfoResolver, err := chain.NewPlanInfoResolver(cfg.UpgradeRegistry.Provider.Chain.PlanInfo)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to create plan info resolver")
 		}
 
-		provider := chain.NewProvider(cosmosClient, cfg.UpgradeRegistry.Network, cfg.UpgradeRegistry.Provider.Chain.DefaultPriority)
+		provider := chain.NewProvider(
+			cosmosClient,
+			cfg.UpgradeRegistry.Network,
+			cfg.UpgradeRegistry.Provider.Chain.DefaultPriority,
+			cfg.UpgradeRegistry.Provider.Chain.CurrentPlan,
+			planInfoResolver,
+		)
 		providers[provider.Type()] = provider
 	}
 
@@ -100,6 +128,7 @@ func NewUpgradesRegistryFromConfig(cfg *config.Config) (*UpgradeRegistry, error)
 		provider, err := database.NewDatabaseProvider(
 			cfg.UpgradeRegistry.Provider.Database,
 			cfg.UpgradeRegistry.Network,
+			instanceID(cfg),
 		)
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to create database provider")
@@ -121,6 +150,40 @@ func NewUpgradesRegistryFromConfig(cfg *config.Config) (*UpgradeRegistry, error)
 		providers[provider.Type()] = provider
 	}
 
+	if cfg.UpgradeRegistry.Provider.ChainRegistry != nil && cfg.UpgradeRegistry.VersionResolvers != nil && slices.Contains(
+		cfg.UpgradeRegistry.VersionResolvers.Providers, urproto.ProviderType_name[int32(urproto.ProviderType_CHAIN_REGISTRY)],
+	) {
+		provider, err := chain_registry.NewProvider(
+			context.Background(),
+			cfg.UpgradeRegistry.Provider.ChainRegistry,
+			cfg.UpgradeRegistry.Network,
+		)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to create chain-registry provider")
+		}
+		providers[provider.Type()] = provider
+	}
+
+	// the registry provider only resolves versions, for the upgrades of the other providers
+	if cfg.UpgradeRegistry.Provider.Registry != nil && cfg.UpgradeRegistry.VersionResolvers != nil && slices.Contains(
+		cfg.UpgradeRegistry.VersionResolvers.Providers, urproto.ProviderType_name[int32(urproto.ProviderType_REGISTRY)],
+	) {
+		var credentialHelper docker.CredentialHelper
+		if cfg.CredentialHelper != nil {
+			credentialHelper = docker.NewCredentialHelper(cfg.CredentialHelper.Command, cfg.CredentialHelper.Timeout)
+		}
+
+		provider, err := registry.NewProvider(
+			cfg.UpgradeRegistry.Provider.Registry,
+			credentialHelper,
+			cfg.UpgradeRegistry.Network,
+		)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to create registry provider")
+		}
+		providers[provider.Type()] = provider
+	}
+
 	versionProviders := make([]urproto.ProviderType, 0)
 	if cfg.UpgradeRegistry.VersionResolvers != nil {
 		for _, providerName := range cfg.UpgradeRegistry.VersionResolvers.Providers {
@@ -134,14 +197,53 @@ func NewUpgradesRegistryFromConfig(cfg *config.Config) (*UpgradeRegistry, error)
 
 	// handle state machine storage provider
 	var stateMachine *state_machine.StateMachine
+	var storage state_machine.StateMachineStorage
 	if cfg.UpgradeRegistry.StateMachine.Provider != "" {
-		if cfg.UpgradeRegistry.StateMachine.Provider != urproto.ProviderType_name[int32(urproto.ProviderType_LOCAL)] {
-			return nil, fmt.Errorf("state machine storage provider %s is not supported (only 'local' is supported now)", cfg.UpgradeRegistry.StateMachine.Provider)
+		providerType := urproto.ProviderType(urproto.ProviderType_value[cfg.UpgradeRegistry.StateMachine.Provider])
+		if _, ok := providers[providerType]; !ok {
+			return nil, fmt.Errorf("state machine storage provider %s is not enabled in upgrade-registry.providers", cfg.UpgradeRegistry.StateMachine.Provider)
 		}
 
-		providerType := urproto.ProviderType(urproto.ProviderType_value[cfg.UpgradeRegistry.StateMachine.Provider])
-		localProvider := providers[providerType].(*local.Provider)
-		stateMachine = state_machine.NewStateMachine(localProvider)
+		switch providerType {
+		case urproto.ProviderType_LOCAL:
+			localProvider := providers[providerType].(*local.Provider)
+
+			// the dry-run state is kept in a separate file, so the real state is never modified
+			if cfg.DryRun {
+				var err error
+				localProvider, err = local.NewProvider(
+					DryRunStatePath(cfg.UpgradeRegistry.Provider.Local.ConfigPath),
+					cfg.UpgradeRegistry.Network,
+					cfg.UpgradeRegistry.Provider.Local.DefaultPriority,
+				)
+				if err != nil {
+					return nil, errors.Wrapf(err, "failed to create dry-run state storage")
+				}
+			}
+			storage = localProvider.WithStateBackups(cfg.UpgradeRegistry.Provider.Local.StateBackups)
+
+		case urproto.ProviderType_DATABASE:
+			databaseProvider := providers[providerType].(*database.Provider)
+
+			// the dry-run state is kept under a separate instance id, so the real state is never modified
+			if cfg.DryRun {
+				databaseProvider = databaseProvider.WithInstanceID(DryRunStatePath(instanceID(cfg)))
+			}
+			storage = databaseProvider
+
+			// blazar can start while the database is down with the local copy of the state, kept next to the cache
+			if cfg.UpgradeRegistry.CachePath != "" {
+				statePath := StateCachePath(cfg.UpgradeRegistry.CachePath)
+				if cfg.DryRun {
+					statePath = DryRunStatePath(statePath)
+				}
+				storage = newCachedStateStorage(databaseProvider, statePath)
+			}
+
+		default:
+			return nil, fmt.Errorf("state machine storage provider %s is not supported (only 'local' and 'database' are supported now)", cfg.UpgradeRegistry.StateMachine.Provider)
+		}
+		stateMachine = state_machine.NewStateMachine(storage)
 	}
 
 	// state machine without storage provider is okay, everything will be stored in memory
@@ -154,14 +256,50 @@ func NewUpgradesRegistryFromConfig(cfg *config.Config) (*UpgradeRegistry, error)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to restore state machine")
 	}
+	stateMachine.SetSyncFromGenesis(cfg.SyncFromGenesis)
+
+	ur := NewUpgradeRegistry(providers, versionProviders, stateMachine, cfg.UpgradeRegistry.Network)
+	if cachedStorage, ok := storage.(*cachedStateStorage); ok {
+		ur.stateRestoreErr = cachedStorage.restoreErr
+	}
+
+	// the cache is only a fallback for the unreachable providers, blazar can start without it
+	if cfg.UpgradeRegistry.CachePath != "" {
+		ur.cachePath = cfg.UpgradeRegistry.CachePath
+		// the dry-run reads the real cache, but writes its own, so the real cache is never modified
+		if cfg.DryRun {
+			ur.cachePath = DryRunStatePath(cfg.UpgradeRegistry.CachePath)
+		}
+		if err := ur.loadCache(cfg.UpgradeRegistry.CachePath); err != nil {
+			log.FromContext(context.Background()).Err(err).Warn("Failed to load the upgrade registry cache, starting without it")
+		}
+	}
+
+	return ur, nil
+}
+
+// DryRunStatePath returns the path of the file (or the instance id) storing the state machine in the dry-run mode
+func DryRunStatePath(configPath string) string {
+	return configPath + ".dry-run"
+}
 
-	return NewUpgradeRegistry(providers, versionProviders, stateMachine, cfg.UpgradeRegistry.Network), nil
+// instanceID returns the identity the state machine state is stored under in the database
+func instanceID(cfg *config.Config) string {
+	if cfg.UpgradeRegistry.StateMachine.InstanceID != "" {
+		return cfg.UpgradeRegistry.StateMachine.InstanceID
+	}
+	return util.GetHostname()
 }
 
 func (ur *UpgradeRegistry) GetStateMachine() *state_machine.StateMachine {
 	return ur.stateMachine
 }
 
+// StateRestoreErr returns the error of the state machine storage, if the state was restored from its local copy
+func (ur *UpgradeRegistry) StateRestoreErr() error {
+	return ur.stateRestoreErr
+}
+
 func (ur *UpgradeRegistry) GetAllUpgradesWithCache() map[int64]*urproto.Upgrade {
 	ur.lock.RLock()
 	defer ur.lock.RUnlock()
@@ -258,14 +396,22 @@ func (ur *UpgradeRegistry) Update(ctx context.Context, currentHeight int64, comm
 	map[int64][]*urproto.Upgrade,
 	error,
 ) {
-	resolvedVersions, overriddenVersions, err := ur.UpdateVersions(ctx, commit)
+	// the upgrades are fetched before the versions, so the version resolvers reading the same source (e.g. the chain
+	// provider) reuse the upgrades of this pass instead of fetching them again
+	resolvedUpgrades, overriddenUpgrades, err := ur.fetchUpgrades(ctx, commit)
 	if err != nil {
-		return nil, nil, nil, nil, errors.Wrapf(err, "failed to update versions")
+		return nil, nil, nil, nil, errors.Wrapf(err, "failed to update upgrades")
 	}
 
-	resolvedUpgrades, overriddenUpgrades, err := ur.UpdateUpgrades(ctx, currentHeight, resolvedVersions, commit)
+	resolvedVersions, overriddenVersions, err := ur.UpdateVersions(ctx, resolvedUpgrades, commit)
 	if err != nil {
-		return nil, nil, nil, nil, errors.Wrapf(err, "failed to update upgrades")
+		return nil, nil, nil, nil, errors.Wrapf(err, "failed to update versions")
+	}
+
+	ur.UpdateUpgrades(currentHeight, resolvedUpgrades, overriddenUpgrades, resolvedVersions, commit)
+
+	if commit {
+		ur.persist(ctx)
 	}
 
 	ur.lock.Lock()
@@ -343,23 +489,42 @@ func (ur *UpgradeRegistry) GetVersion(ctx context.Context, useCache bool, height
 	return filterVersionsByHeight(resolvedVersions, height), nil
 }
 
-func (ur *UpgradeRegistry) UpdateVersions(ctx context.Context, commit bool) (map[int64]*vrproto.Version, map[int64][]*vrproto.Version, error) {
-	g, ctx := errgroup.WithContext(ctx)
+// UpdateVersions fetches the versions from the version resolvers. The resolvers mapping the upgrades to versions get the
+// resolved upgrades of the same update
+func (ur *UpgradeRegistry) UpdateVersions(ctx context.Context, upgrades map[int64]*urproto.Upgrade, commit bool) (map[int64]*vrproto.Version, map[int64][]*vrproto.Version, error) {
+	// a failing provider doesn't cancel the others, its last known versions are used instead
+	var g errgroup.Group
 	results := make([][]*vrproto.Version, len(ur.versionProviders))
+	errs := make([]error, len(ur.versionProviders))
+
+	upgradesList := make([]*urproto.Upgrade, 0, len(upgrades))
+	for _, height := range slices.Sorted(maps.Keys(upgrades)) {
+		upgradesList = append(upgradesList, upgrades[height])
+	}
 
 	for i, providerName := range ur.versionProviders {
 		// from go 1.22 the copy of the loop variable is not needed anymore
 		// https://tip.golang.org/doc/go1.22#language
 
 		g.Go(func() error {
-			if provider, ok := ur.providers[providerName].(provider.VersionResolver); ok {
-				versions, err := provider.GetVersions(ctx)
+			if resolver, ok := ur.providers[providerName].(provider.VersionResolver); ok {
+				var (
+					versions []*vrproto.Version
+					err      error
+				)
+				if upgradesResolver, ok := resolver.(provider.UpgradesVersionResolver); ok {
+					versions, err = upgradesResolver.GetVersionsForUpgrades(ctx, upgradesList)
+				} else {
+					versions, err = resolver.GetVersions(ctx)
+				}
 				if err != nil {
-					return errors.Wrapf(err, "%s provider failed to fetch versions", providerName)
+					errs[i] = errors.Wrapf(err, "%s provider failed to fetch versions", providerName)
+					return nil
 				}
 
 				if err := checkDuplicates(versions, providerName); err != nil {
-					return errors.Wrapf(err, "%s version provider returned duplicate versions", providerName)
+					errs[i] = errors.Wrapf(err, "%s version provider returned duplicate versions", providerName)
+					return nil
 				}
 
 				results[i] = versions
@@ -367,22 +532,24 @@ func (ur *UpgradeRegistry) UpdateVersions(ctx context.Context, commit bool) (map
 			return nil
 		})
 	}
+	_ = g.Wait()
 
-	if err := g.Wait(); err != nil {
-		return nil, nil, err
+	if commit {
+		ur.lock.Lock()
+		defer ur.lock.Unlock()
+	} else {
+		ur.lock.RLock()
+		defer ur.lock.RUnlock()
 	}
 
-	allVersions := make([]*vrproto.Version, 0)
-	for _, versions := range results {
-		allVersions = append(allVersions, versions...)
+	allVersions, err := mergeWithSnapshots(ctx, "versions", ur.versionProviders, results, errs, ur.versionSnapshots, commit)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	resolvedVersions, overriddenVersions := resolvePriorities(allVersions)
 
 	if commit {
-		ur.lock.Lock()
-		defer ur.lock.Unlock()
-
 		ur.versions = resolvedVersions
 		ur.overriddenVersions = overriddenVersions
 	}
@@ -390,58 +557,59 @@ func (ur *UpgradeRegistry) UpdateVersions(ctx context.Context, commit bool) (map
 	return resolvedVersions, overriddenVersions, nil
 }
 
-func (ur *UpgradeRegistry) UpdateUpgrades(ctx context.Context, currentHeight int64, versions map[int64]*vrproto.Version, commit bool) (map[int64]*urproto.Upgrade, map[int64][]*urproto.Upgrade, error) {
-	g, ctx := errgroup.WithContext(ctx)
-	results := make([][]*urproto.Upgrade, len(ur.providers))
+// fetchUpgrades fetches the upgrades from all providers and resolves their priorities
+func (ur *UpgradeRegistry) fetchUpgrades(ctx context.Context, commit bool) (map[int64]*urproto.Upgrade, map[int64][]*urproto.Upgrade, error) {
+	// a failing provider doesn't cancel the others, its last known upgrades are used instead
+	var g errgroup.Group
+	providerTypes := slices.Sorted(maps.Keys(ur.providers))
+	results := make([][]*urproto.Upgrade, len(providerTypes))
+	errs := make([]error, len(providerTypes))
 
-	i := 0
-	for _, provider := range ur.providers {
-		// from go 1.22 the copy of the loop variable (provider) is not needed anymore
+	for i, providerType := range providerTypes {
+		// from go 1.22 the copy of the loop variables is not needed anymore
 		// https://tip.golang.org/doc/go1.22#language
-		//
-		// but the copy of the global variable (i) is still needed
-		// https://golang.org/doc/faq#closures_and_goroutines
-		ii := i
+		provider := ur.providers[providerType]
 
 		g.Go(func() error {
 			upgrades, err := provider.GetUpgrades(ctx)
 			if err != nil {
-				return errors.Wrapf(err, "%s provider failed to fetch upgrades", provider.Type())
+				errs[i] = errors.Wrapf(err, "%s provider failed to fetch upgrades", provider.Type())
+				return nil
 			}
 
 			if err := checkDuplicates(upgrades, provider.Type()); err != nil {
-				return errors.Wrapf(err, "%s provider returned duplicate upgrades", provider.Type())
+				errs[i] = errors.Wrapf(err, "%s provider returned duplicate upgrades", provider.Type())
+				return nil
 			}
 
-			results[ii] = upgrades
+			results[i] = upgrades
 			return nil
 		})
-
-		i++
 	}
+	_ = g.Wait()
 
-	if err := g.Wait(); err != nil {
+	ur.lock.Lock()
+	allUpgrades, err := mergeWithSnapshots(ctx, "upgrades", providerTypes, results, errs, ur.upgradeSnapshots, commit)
+	ur.lock.Unlock()
+	if err != nil {
 		return nil, nil, err
 	}
 
-	allUpgrades := make([]*urproto.Upgrade, 0)
-	for _, upgrades := range results {
-		allUpgrades = append(allUpgrades, upgrades...)
-	}
-
 	resolvedUpgrades, overriddenUpgrades := resolvePriorities(allUpgrades)
+	return resolvedUpgrades, overriddenUpgrades, nil
+}
 
+// UpdateUpgrades fills the missing tags of the resolved upgrades from the versions and stores the upgrades if commit is set
+func (ur *UpgradeRegistry) UpdateUpgrades(currentHeight int64, resolvedUpgrades map[int64]*urproto.Upgrade, overriddenUpgrades map[int64][]*urproto.Upgrade, versions map[int64]*vrproto.Version, commit bool) {
 	// lock just in case the versions map is reference to ur.versions
 	ur.lock.RLock()
 	for _, upgrade := range resolvedUpgrades {
 		// try to resolve version for the upgrade
+		// the CHAIN version resolver covers the tags published in the upgrade plans
 		if upgrade.Tag == "" {
 			if version, ok := versions[upgrade.Height]; ok {
 				upgrade.Tag = version.Tag
 			}
-			// else {
-			// TODO: try to resolve version using different methods, RPC, regexes etc
-			// }
 		}
 	}
 	ur.lock.RUnlock()
@@ -455,8 +623,6 @@ func (ur *UpgradeRegistry) UpdateUpgrades(ctx context.Context, currentHeight int
 		// update statuses of all resolved upgrades
 		ur.stateMachine.UpdateStatus(currentHeight, ur.upgrades)
 	}
-
-	return resolvedUpgrades, overriddenUpgrades, nil
 }
 
 func (ur *UpgradeRegistry) RegisterVersion(ctx context.Context, version *vrproto.Version, overwrite bool) error {
@@ -480,6 +646,12 @@ func (ur *UpgradeRegistry) RegisterVersion(ctx context.Context, version *vrproto
 		} else {
 			return errors.New("local provider is not configured")
 		}
+
+	case urproto.ProviderType_REGISTRY:
+		return errors.New("register version is not supported for registry provider")
+
+	case urproto.ProviderType_CHAIN_REGISTRY:
+		return errors.New("register version is not supported for chain-registry provider")
 	}
 
 	return fmt.Errorf("unknown upgrade source %s", version.GetSource().String())
@@ -558,7 +730,45 @@ func (ur *UpgradeRegistry) SyncInfo() SyncInfo {
 	ur.lock.RLock()
 	defer ur.lock.RUnlock()
 
-	return ur.syncInfo
+	syncInfo := ur.syncInfo
+	syncInfo.Providers = make(map[urproto.ProviderType]ProviderSyncInfo, len(ur.providers))
+	for providerType := range ur.providers {
+		upgrades, versions := ur.upgradeSnapshots[providerType], ur.versionSnapshots[providerType]
+		if upgrades == nil && versions == nil {
+			continue
+		}
+		syncInfo.Providers[providerType] = providerSyncInfo(upgrades, versions)
+	}
+
+	return syncInfo
+}
+
+// Staleness returns the age of the data the upgrade at the given height was resolved from, that is the oldest successful
+// sync of the provider of the upgrade and the provider of its version, if any
+func (ur *UpgradeRegistry) Staleness(height int64) (time.Duration, urproto.ProviderType, bool) {
+	ur.lock.RLock()
+	defer ur.lock.RUnlock()
+
+	upgrade, ok := ur.upgrades[height]
+	if !ok {
+		return 0, 0, false
+	}
+
+	var (
+		staleness   time.Duration
+		stalest     urproto.ProviderType
+		hasSnapshot bool
+	)
+	if snapshot, ok := ur.upgradeSnapshots[upgrade.Source]; ok {
+		staleness, stalest, hasSnapshot = time.Since(snapshot.lastSuccessTime), upgrade.Source, true
+	}
+	if version, ok := ur.versions[height]; ok {
+		if snapshot, ok := ur.versionSnapshots[version.Source]; ok && time.Since(snapshot.lastSuccessTime) > staleness {
+			staleness, stalest, hasSnapshot = time.Since(snapshot.lastSuccessTime), version.Source, true
+		}
+	}
+
+	return staleness, stalest, hasSnapshot
 }
 
 func (ur *UpgradeRegistry) Network() string {
@@ -3,10 +3,14 @@ package upgrades_registry
 import (
 	"cmp"
 	"context"
+	"net"
+	"os"
 	"slices"
 	"sync"
 	"testing"
+	"time"
 
+	"blazar/internal/pkg/config"
 	"blazar/internal/pkg/errors"
 	urproto "blazar/internal/pkg/proto/upgrades_registry"
 	vrproto "blazar/internal/pkg/proto/version_resolver"
@@ -50,7 +54,7 @@ func prepareMockDatabaseProvider() (*database.Provider, error) {
 	if err != nil {
 		return nil, errors.Wrapf(err, "database migration failed for versions table")
 	}
-	return database.NewDatabaseProviderWithDB(db, "test", 1), nil
+	return database.NewDatabaseProviderWithDB(db, "test", 1, "test"), nil
 }
 
 func addDummyDatabaseProvider(t *testing.T, ur *UpgradeRegistry) {
@@ -257,11 +261,13 @@ func testProviders(t *testing.T, ur *UpgradeRegistry, source urproto.ProviderTyp
 
 func TestProviders(t *testing.T) {
 	ur1 := &UpgradeRegistry{
-		providers:    make(map[urproto.ProviderType]provider.UpgradeProvider),
-		upgrades:     make(map[int64]*urproto.Upgrade),
-		network:      "test",
-		lock:         &sync.RWMutex{},
-		stateMachine: sm.NewStateMachine(nil),
+		providers:        make(map[urproto.ProviderType]provider.UpgradeProvider),
+		upgrades:         make(map[int64]*urproto.Upgrade),
+		upgradeSnapshots: make(map[urproto.ProviderType]*providerSnapshot[*urproto.Upgrade]),
+		versionSnapshots: make(map[urproto.ProviderType]*providerSnapshot[*vrproto.Version]),
+		network:          "test",
+		lock:             &sync.RWMutex{},
+		stateMachine:     sm.NewStateMachine(nil),
 	}
 	addDummyLocalProvider(t, ur1)
 	t.Run("TestLocal", func(t *testing.T) {
@@ -269,11 +275,13 @@ func TestProviders(t *testing.T) {
 	})
 
 	ur2 := &UpgradeRegistry{
-		providers:    make(map[urproto.ProviderType]provider.UpgradeProvider),
-		upgrades:     make(map[int64]*urproto.Upgrade),
-		network:      "test",
-		lock:         &sync.RWMutex{},
-		stateMachine: sm.NewStateMachine(nil),
+		providers:        make(map[urproto.ProviderType]provider.UpgradeProvider),
+		upgrades:         make(map[int64]*urproto.Upgrade),
+		upgradeSnapshots: make(map[urproto.ProviderType]*providerSnapshot[*urproto.Upgrade]),
+		versionSnapshots: make(map[urproto.ProviderType]*providerSnapshot[*vrproto.Version]),
+		network:          "test",
+		lock:             &sync.RWMutex{},
+		stateMachine:     sm.NewStateMachine(nil),
 	}
 	addDummyDatabaseProvider(t, ur2)
 	t.Run("TestDatabase", func(t *testing.T) {
@@ -283,11 +291,13 @@ func TestProviders(t *testing.T) {
 
 func TestSimultaneousProviders(t *testing.T) {
 	ur := &UpgradeRegistry{
-		providers:    make(map[urproto.ProviderType]provider.UpgradeProvider),
-		upgrades:     make(map[int64]*urproto.Upgrade),
-		network:      "test",
-		lock:         &sync.RWMutex{},
-		stateMachine: sm.NewStateMachine(nil),
+		providers:        make(map[urproto.ProviderType]provider.UpgradeProvider),
+		upgrades:         make(map[int64]*urproto.Upgrade),
+		upgradeSnapshots: make(map[urproto.ProviderType]*providerSnapshot[*urproto.Upgrade]),
+		versionSnapshots: make(map[urproto.ProviderType]*providerSnapshot[*vrproto.Version]),
+		network:          "test",
+		lock:             &sync.RWMutex{},
+		stateMachine:     sm.NewStateMachine(nil),
 	}
 	addDummyLocalProvider(t, ur)
 	addDummyDatabaseProvider(t, ur)
@@ -346,3 +356,285 @@ func TestSimultaneousProviders(t *testing.T) {
 		})
 	}
 }
+
+type flakyProvider struct {
+	provider.UpgradeProvider
+	err error
+}
+
+func (p *flakyProvider) GetUpgrades(ctx context.Context) ([]*urproto.Upgrade, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	return p.UpgradeProvider.GetUpgrades(ctx)
+}
+
+func TestDegradedSync(t *testing.T) {
+	ur := NewUpgradeRegistry(make(map[urproto.ProviderType]provider.UpgradeProvider), nil, sm.NewStateMachine(nil), "test")
+	addDummyLocalProvider(t, ur)
+	addDummyDatabaseProvider(t, ur)
+
+	local := &flakyProvider{UpgradeProvider: ur.providers[urproto.ProviderType_LOCAL], err: errors.New("local is down")}
+	ur.providers[urproto.ProviderType_LOCAL] = local
+
+	require.NoError(t, local.AddUpgrade(context.Background(), &urproto.Upgrade{
+		Height: 100, Tag: "v1.0.0", Network: "test", Name: "local", Type: urproto.UpgradeType_NON_GOVERNANCE_COORDINATED,
+		Source: urproto.ProviderType_LOCAL, Priority: 1,
+	}, false))
+	require.NoError(t, ur.providers[urproto.ProviderType_DATABASE].AddUpgrade(context.Background(), &urproto.Upgrade{
+		Height: 200, Tag: "v2.0.0", Network: "test", Name: "database", Type: urproto.UpgradeType_NON_GOVERNANCE_COORDINATED,
+		Source: urproto.ProviderType_DATABASE, Priority: 1,
+	}, false))
+
+	// there is nothing to fall back to before the first successful sync
+	_, _, _, _, err := ur.Update(context.Background(), 50, true)
+	require.ErrorContains(t, err, "local is down")
+
+	local.err = nil
+	_, _, upgrades, _, err := ur.Update(context.Background(), 50, true)
+	require.NoError(t, err)
+	require.Len(t, upgrades, 2)
+
+	// the last known upgrades of the failing provider are merged with the fresh ones
+	local.err = errors.New("local is down")
+	for range 2 {
+		_, _, upgrades, _, err = ur.Update(context.Background(), 50, true)
+		require.NoError(t, err)
+		require.Len(t, upgrades, 2)
+		assert.Equal(t, "v1.0.0", upgrades[100].Tag)
+	}
+
+	providers := ur.SyncInfo().Providers
+	require.Len(t, providers, 2)
+	require.ErrorContains(t, providers[urproto.ProviderType_LOCAL].LastError, "local is down")
+	assert.Equal(t, 2, providers[urproto.ProviderType_LOCAL].ConsecutiveErrors)
+	require.NoError(t, providers[urproto.ProviderType_DATABASE].LastError)
+	assert.Equal(t, 0, providers[urproto.ProviderType_DATABASE].ConsecutiveErrors)
+	assert.True(t, providers[urproto.ProviderType_LOCAL].LastSuccessTime.Before(providers[urproto.ProviderType_DATABASE].LastSuccessTime))
+
+	staleness, source, ok := ur.Staleness(100)
+	require.True(t, ok)
+	assert.Equal(t, urproto.ProviderType_LOCAL, source)
+	assert.Greater(t, staleness, time.Duration(0))
+
+	_, _, ok = ur.Staleness(300)
+	assert.False(t, ok)
+
+	// a successful sync resets the errors
+	local.err = nil
+	_, _, _, _, err = ur.Update(context.Background(), 50, true)
+	require.NoError(t, err)
+	assert.NoError(t, ur.SyncInfo().Providers[urproto.ProviderType_LOCAL].LastError)
+	assert.Equal(t, 0, ur.SyncInfo().Providers[urproto.ProviderType_LOCAL].ConsecutiveErrors)
+}
+
+type upgradesVersionResolverStub struct {
+	provider.UpgradeProvider
+	provider.VersionResolver
+	upgrades []*urproto.Upgrade
+}
+
+func (p *upgradesVersionResolverStub) GetUpgrades(context.Context) ([]*urproto.Upgrade, error) {
+	return []*urproto.Upgrade{}, nil
+}
+
+func (p *upgradesVersionResolverStub) Type() urproto.ProviderType {
+	return urproto.ProviderType_REGISTRY
+}
+
+func (p *upgradesVersionResolverStub) GetVersionsForUpgrades(_ context.Context, upgrades []*urproto.Upgrade) ([]*vrproto.Version, error) {
+	p.upgrades = upgrades
+
+	versions := make([]*vrproto.Version, 0, len(upgrades))
+	for _, upgrade := range upgrades {
+		versions = append(versions, &vrproto.Version{
+			Height: upgrade.Height, Tag: upgrade.Name + "-tag", Network: "test", Source: urproto.ProviderType_REGISTRY, Priority: 1,
+		})
+	}
+	return versions, nil
+}
+
+func TestUpgradesVersionResolver(t *testing.T) {
+	resolver := &upgradesVersionResolverStub{}
+	ur := NewUpgradeRegistry(map[urproto.ProviderType]provider.UpgradeProvider{
+		urproto.ProviderType_REGISTRY: resolver,
+	}, []urproto.ProviderType{urproto.ProviderType_REGISTRY}, sm.NewStateMachine(nil), "test")
+	addDummyLocalProvider(t, ur)
+	addDummyDatabaseProvider(t, ur)
+
+	require.NoError(t, ur.providers[urproto.ProviderType_LOCAL].AddUpgrade(context.Background(), &urproto.Upgrade{
+		Height: 100, Network: "test", Name: "local", Type: urproto.UpgradeType_NON_GOVERNANCE_COORDINATED,
+		Source: urproto.ProviderType_LOCAL, Priority: 1,
+	}, false))
+	require.NoError(t, ur.providers[urproto.ProviderType_DATABASE].AddUpgrade(context.Background(), &urproto.Upgrade{
+		Height: 100, Network: "test", Name: "database", Type: urproto.UpgradeType_NON_GOVERNANCE_COORDINATED,
+		Source: urproto.ProviderType_DATABASE, Priority: 2,
+	}, false))
+
+	_, _, upgrades, _, err := ur.Update(context.Background(), 50, true)
+	require.NoError(t, err)
+
+	// the resolver gets the upgrade that won the priority resolution
+	require.Len(t, resolver.upgrades, 1)
+	assert.Equal(t, "database", resolver.upgrades[0].Name)
+	assert.Equal(t, "database-tag", upgrades[100].Tag)
+}
+
+func TestRegistryCache(t *testing.T) {
+	cachePath := t.TempDir() + "/registry.cache.json"
+
+	newRegistry := func() (*UpgradeRegistry, *flakyProvider) {
+		ur := NewUpgradeRegistry(make(map[urproto.ProviderType]provider.UpgradeProvider), nil, sm.NewStateMachine(nil), "test")
+		ur.cachePath = cachePath
+		addDummyLocalProvider(t, ur)
+		addDummyDatabaseProvider(t, ur)
+
+		database := &flakyProvider{UpgradeProvider: ur.providers[urproto.ProviderType_DATABASE]}
+		ur.providers[urproto.ProviderType_DATABASE] = database
+
+		require.NoError(t, database.AddUpgrade(context.Background(), &urproto.Upgrade{
+			Height: 200, Tag: "v2.0.0", Network: "test", Name: "database", Type: urproto.UpgradeType_NON_GOVERNANCE_COORDINATED,
+			Source: urproto.ProviderType_DATABASE, Priority: 1,
+		}, false))
+		return ur, database
+	}
+
+	ur, _ := newRegistry()
+	_, _, _, _, err := ur.Update(context.Background(), 50, true)
+	require.NoError(t, err)
+	syncTime := ur.SyncInfo().Providers[urproto.ProviderType_DATABASE].LastSuccessTime
+
+	// blazar restarts while the database is down
+	ur, database := newRegistry()
+	database.err = errors.New("connection refused")
+	require.NoError(t, ur.loadCache(cachePath))
+
+	_, _, upgrades, _, err := ur.Update(context.Background(), 50, true)
+	require.NoError(t, err)
+	require.Len(t, upgrades, 1)
+	assert.Equal(t, "v2.0.0", upgrades[200].Tag)
+	assert.True(t, syncTime.Equal(ur.SyncInfo().Providers[urproto.ProviderType_DATABASE].LastSuccessTime))
+
+	// the cache keeps the original sync time while the provider is down
+	ur, database = newRegistry()
+	database.err = errors.New("connection refused")
+	require.NoError(t, ur.loadCache(cachePath))
+	_, _, _, _, err = ur.Update(context.Background(), 50, true)
+	require.NoError(t, err)
+	assert.True(t, syncTime.Equal(ur.SyncInfo().Providers[urproto.ProviderType_DATABASE].LastSuccessTime))
+
+	// the live data wins once the provider recovers
+	database.err = nil
+	require.NoError(t, database.CancelUpgrade(context.Background(), 200, "test"))
+	_, _, upgrades, _, err = ur.Update(context.Background(), 50, true)
+	require.NoError(t, err)
+	assert.Equal(t, urproto.UpgradeStatus_CANCELLED, upgrades[200].Status)
+	assert.True(t, ur.SyncInfo().Providers[urproto.ProviderType_DATABASE].LastSuccessTime.After(syncTime))
+
+	// the cache of another network is ignored
+	otherNetwork := NewUpgradeRegistry(make(map[urproto.ProviderType]provider.UpgradeProvider), nil, sm.NewStateMachine(nil), "other")
+	require.ErrorContains(t, otherNetwork.loadCache(cachePath), "registry cache network test does not match other")
+
+	// no cache yet
+	require.NoError(t, ur.loadCache(cachePath+".missing"))
+}
+
+func TestDryRunStateStorage(t *testing.T) {
+	_, blazarDir := testutils.NewChainHomeDir(t)
+	configPath := blazarDir + "/local.db.json"
+
+	cfg := &config.Config{DryRun: true}
+	cfg.UpgradeRegistry.Network = "test"
+	cfg.UpgradeRegistry.SelectedProviders = []string{urproto.ProviderType_LOCAL.String()}
+	cfg.UpgradeRegistry.Provider.Local = &config.LocalProvider{ConfigPath: configPath, DefaultPriority: 1}
+	cfg.UpgradeRegistry.StateMachine.Provider = urproto.ProviderType_LOCAL.String()
+	cfg.UpgradeRegistry.CachePath = blazarDir + "/registry.cache.json"
+
+	ur, err := NewUpgradesRegistryFromConfig(cfg)
+	require.NoError(t, err)
+
+	ur.GetStateMachine().MustSetStatus(100, urproto.UpgradeStatus_SCHEDULED)
+
+	// the real state is untouched
+	lp, err := local.NewProvider(configPath, "test", 1)
+	require.NoError(t, err)
+	state, err := lp.RestoreState(context.Background())
+	require.NoError(t, err)
+	assert.Nil(t, state)
+
+	// the dry-run state is stored separately
+	lp, err = local.NewProvider(DryRunStatePath(configPath), "test", 1)
+	require.NoError(t, err)
+	state, err = lp.RestoreState(context.Background())
+	require.NoError(t, err)
+	require.NotNil(t, state)
+	assert.Equal(t, urproto.UpgradeStatus_SCHEDULED, state.UpgradeStatus[100])
+
+	// the registry cache is written to a separate file as well
+	_, _, _, _, err = ur.Update(context.Background(), 50, true)
+	require.NoError(t, err)
+	assert.NoFileExists(t, cfg.UpgradeRegistry.CachePath)
+	assert.FileExists(t, DryRunStatePath(cfg.UpgradeRegistry.CachePath))
+}
+
+func TestUnreachableDatabase(t *testing.T) {
+	_, blazarDir := testutils.NewChainHomeDir(t)
+	cachePath := blazarDir + "/registry.cache.json"
+
+	// the last sync before the database went down
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, database.AutoMigrate(db))
+	dp := database.NewDatabaseProviderWithDB(db, "test", 1, "test")
+
+	stateMachine := sm.NewStateMachine(newCachedStateStorage(dp, StateCachePath(cachePath)))
+	ur := NewUpgradeRegistry(map[urproto.ProviderType]provider.UpgradeProvider{urproto.ProviderType_DATABASE: dp}, nil, stateMachine, "test")
+	ur.cachePath = cachePath
+	require.NoError(t, dp.AddUpgrade(context.Background(), &urproto.Upgrade{
+		Height: 200, Tag: "v2.0.0", Network: "test", Name: "database", Type: urproto.UpgradeType_NON_GOVERNANCE_COORDINATED,
+		Source: urproto.ProviderType_DATABASE, Priority: 1,
+	}, false))
+	_, _, _, _, err = ur.Update(context.Background(), 50, true)
+	require.NoError(t, err)
+	stateMachine.MustSetStatusAndStep(200, urproto.UpgradeStatus_EXECUTING, urproto.UpgradeStep_PRE_UPGRADE_CHECK)
+
+	// nothing listens on the database port
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := listener.Addr().(*net.TCPAddr).Port
+	require.NoError(t, listener.Close())
+
+	cfg := &config.Config{}
+	cfg.UpgradeRegistry.Network = "test"
+	cfg.UpgradeRegistry.SelectedProviders = []string{urproto.ProviderType_DATABASE.String()}
+	cfg.UpgradeRegistry.Provider.Database = &config.DatabaseProvider{
+		DefaultPriority: 1,
+		Host:            "127.0.0.1",
+		Port:            uint16(port),
+		DB:              "blazar",
+		User:            "blazar",
+		SslMode:         config.Disable,
+		AutoMigrate:     true,
+	}
+	cfg.UpgradeRegistry.CachePath = cachePath
+	cfg.UpgradeRegistry.StateMachine.Provider = urproto.ProviderType_DATABASE.String()
+	cfg.UpgradeRegistry.StateMachine.InstanceID = "test"
+
+	ur, err = NewUpgradesRegistryFromConfig(cfg)
+	require.NoError(t, err)
+
+	_, _, upgrades, _, err := ur.Update(context.Background(), 50, true)
+	require.NoError(t, err)
+	require.Len(t, upgrades, 1)
+	assert.Equal(t, "v2.0.0", upgrades[200].Tag)
+
+	// the state is restored from the local copy
+	require.ErrorContains(t, ur.StateRestoreErr(), "failed to connect")
+	assert.Equal(t, urproto.UpgradeStatus_EXECUTING, ur.GetStateMachine().GetStatus(200))
+	assert.Equal(t, urproto.UpgradeStep_PRE_UPGRADE_CHECK, ur.GetStateMachine().GetStep(200))
+
+	// without the local copy the state is unknown, blazar can't start
+	require.NoError(t, os.Remove(StateCachePath(cachePath)))
+	_, err = NewUpgradesRegistryFromConfig(cfg)
+	require.ErrorContains(t, err, "no local copy of the state")
+}